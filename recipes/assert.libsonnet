@@ -1,18 +1,58 @@
 local want = import "@want";
 
-local subsetOf(x, superset) =
-    want.compute("assert.all", [
-        want.input("x", x),
-        want.input("subsetOf", superset),
-    ]);
-
-local pathExists(x, path) =
-    want.compute("assert.all", [
-        want.input("x", x),
-        want.input("pathExists", want.blob(path)),
-    ]);
+local refAssertions = ["subsetOf", "equal", "exactly"];
+local checkAssertions = ["matches", "fileCount", "size", "modes", "json"];
+
+// check evaluates to x if all of the assertions in spec hold.
+// Otherwise it fails with every violation.
+// spec can have any of the fields:
+//   subsetOf, equal, exactly: an expression to compare x against
+//   pathExists: a path which must exist in x
+//   matches: a list of {path, pattern}
+//   fileCount, size: a list of {path, min, max}
+//   modes: a list of {path, mode}
+//   json: a list of {path, query, value}
+//   message: included in the error
+local check(x, spec) =
+    local checks = {[k]: spec[k] for k in checkAssertions if std.objectHas(spec, k)};
+    want.compute("assert.all", [want.input("x", x)]
+        + [want.input(k, spec[k]) for k in refAssertions if std.objectHas(spec, k)]
+        + (if std.objectHas(spec, "pathExists") then [want.input("pathExists", want.blob(spec.pathExists))] else [])
+        + (if std.length(checks) > 0 then [want.input("checks", want.blob(std.manifestJsonMinified(checks)))] else [])
+        + (if std.objectHas(spec, "message") then [want.input("message", want.blob(spec.message))] else [])
+    );
+
+local subsetOf(x, superset) = check(x, {subsetOf: superset});
+
+local pathExists(x, path) = check(x, {pathExists: path});
+
+// equal requires x to have the same paths and contents as expected, ignoring file modes.
+local equal(x, expected) = check(x, {equal: expected});
+
+// exactly requires x to be identical to expected, including file modes.
+local exactly(x, expected) = check(x, {exactly: expected});
+
+local matches(x, path, pattern) = check(x, {matches: [{path: path, pattern: pattern}]});
+
+local fileCount(x, path="", min=null, max=null) = check(x, {fileCount: [{path: path, min: min, max: max}]});
+
+local size(x, path="", min=null, max=null) = check(x, {size: [{path: path, min: min, max: max}]});
+
+// mode requires the permission bits at path, as an octal string e.g. "0755"
+local mode(x, path, mode) = check(x, {modes: [{path: path, mode: mode}]});
+
+// jsonEqual requires the value selected by query e.g. "$.a.b[0]" from the JSON blob at path to equal value.
+local jsonEqual(x, path, query, value) = check(x, {json: [{path: path, query: query, value: value}]});
 
 {
+    check :: check,
     pathExists :: pathExists,
     subsetOf :: subsetOf,
-}
\ No newline at end of file
+    equal :: equal,
+    exactly :: exactly,
+    matches :: matches,
+    fileCount :: fileCount,
+    size :: size,
+    mode :: mode,
+    jsonEqual :: jsonEqual,
+}
@@ -1,8 +1,9 @@
 local want = import "@want";
 
 {
-    makeExec :: function(modSrc, modcache, main, goarch, goos)
-        local config = want.blob(std.manifestJsonEx({
+    // opts can set goVersion, goDistHash, tags, ldflags, x, trimpath and buildvcs
+    makeExec :: function(modSrc, modcache, main, goarch, goos, opts={})
+        local config = want.blob(std.manifestJsonEx(opts + {
             "GOARCH": goarch,
             "GOOS": goos,
             "main": main,
@@ -17,6 +18,13 @@ local want = import "@want";
         want.compute("golang.modDownload", [
         want.input("", x),
     ]),
+    // opts can set runtime, packages, run, tags, cover, timeout, goVersion, goDistHash and allowFailure
+    test :: function(modSrc, modcache, opts={})
+        want.compute("golang.test", [
+            want.input("module", modSrc),
+            want.input("modcache", modcache),
+            want.input("config.json", want.blob(std.manifestJsonEx(opts, ""))),
+        ]),
     makeTestExec :: function(modSrc, modcache, path, goarch, goos)
         local config = want.blob(std.manifestJsonEx({
             "GOARCH": goarch,
@@ -26,7 +26,26 @@ local modDownload(modSrc) = builtin.modDownload(
     want.filter(modSrc, want.union([want.unit("go.mod"), want.unit("go.sum")]))
 );
 
-local makeExec(modSrc, main, goarch, goos) = builtin.makeExec(modSrc, modDownload(modSrc), main, goarch, goos);
+local makeExec(modSrc, main, goarch, goos, opts={}) = builtin.makeExec(modSrc, modDownload(modSrc), main, goarch, goos, opts);
+
+// makeExecMatrix builds main for each platform in platforms, which are strings like "linux/amd64".
+// Each binary is placed at GOOS_GOARCH/name, with .exe added for windows.
+local makeExecMatrix(modSrc, main, platforms, name=null, opts={}) =
+    local binName = if name != null then name else std.split(main, "/")[std.length(std.split(main, "/")) - 1];
+    assert binName != "" : "name must be set when building the module root";
+    want.pass([
+        local parts = std.split(platform, "/");
+        local goos = parts[0];
+        local goarch = parts[1];
+        local ext = if goos == "windows" then ".exe" else "";
+        want.input("%s_%s/%s%s" % [goos, goarch, binName, ext], makeExec(modSrc, main, goarch, goos, opts))
+        for platform in platforms
+    ]);
+
+// test runs go test -json on the module, and evaluates to a tree containing
+// summary.json, junit.xml, results/<package>/<test>.json and, if opts.cover is set, coverage.out.
+// By default the tests are compiled for wasip1 and run in a WebAssembly sandbox, set opts.runtime = "native" to run them on the host.
+local test(modSrc, opts={}) = builtin.test(modSrc, modDownload(modSrc), opts);
 
 local makeTestExec(modSrc, main, goarch, goos) = builtin.makeTestExec(modSrc, modDownload(modSrc), main, goarch, goos);
 
@@ -91,7 +110,9 @@ local runTests(modSrc, basefs=defaultBaseFS, kernel=defaultKernel, ignore=[]) =
     pathSet :: pathSet,
     modDownload :: modDownload,
     makeExec :: makeExec,
+    makeExecMatrix :: makeExecMatrix,
     makeTestExec :: makeTestExec,
+    test :: test,
     runTests :: runTests,
     goTest :: goTest,
 }
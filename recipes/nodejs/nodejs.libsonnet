@@ -19,6 +19,10 @@ local dist() =
         ])
     );
 
+// nodeModules returns the node_modules directory for the package-lock.json at the root of src.
+local nodeModules(src) = want.importNPMLock(want.pick(src, "package-lock.json"));
+
 {
     dist :: dist,
+    nodeModules :: nodeModules,
 }
\ No newline at end of file
@@ -3,6 +3,55 @@ local want = import "@want";
 local importImage(name, hash, algo="sha256") =
     want.importOCIImage(name, algo, hash);
 
+// importManifest imports the raw manifest of an image.
+local importManifest(name, hash, algo="sha256") =
+    local spec = std.manifestJsonEx({
+        name: name,
+        algo: algo,
+        hash: hash,
+    }, "");
+    want.compute("import.fromOCIManifest", [
+        want.input("", want.blob(spec)),
+    ]);
+
+// importLayer imports a single layer of an image, so it can be cached and shared between images.
+// digest is the digest of the compressed layer, as it appears in the manifest.
+local importLayer(name, digest, mediaType="application/vnd.oci.image.layer.v1.tar+gzip") =
+    local spec = std.manifestJsonEx({
+        name: name,
+        descriptor: {
+            mediaType: mediaType,
+            digest: digest,
+        },
+    }, "");
+    want.compute("import.fromOCILayer", [
+        want.input("", want.blob(spec)),
+    ]);
+
+// mergeLayers applies layers in order to produce a root filesystem.
+local mergeLayers(layers) =
+    want.compute("import.mergeOCILayers", [
+        want.input(std.toString(i), layers[i])
+        for i in std.range(0, std.length(layers) - 1)
+    ]);
+
+// build produces an OCI image layout containing rootfs as a single layer.
+// config is the "config" section of the image config, e.g. {Entrypoint: ["/bin/sh"]}
+local build(rootfs, config={}, architecture="amd64", os="linux") =
+    local configJSON = std.manifestJsonEx({
+        architecture: architecture,
+        os: os,
+        config: config,
+    }, "");
+    want.compute("oci.build", [
+        want.input("rootfs", rootfs),
+        want.input("config.json", want.blob(configJSON)),
+    ]);
+
 {
     importImage :: importImage,
-}
\ No newline at end of file
+    importManifest :: importManifest,
+    importLayer :: importLayer,
+    mergeLayers :: mergeLayers,
+    build :: build,
+}
@@ -12,6 +12,20 @@ local wasip1(memory, wasm, inp, args=[], env={}) =
         want.input("config.json", config)
     ]);
 
+// wasip1Component runs a component built from a wasip1 module with the wasi_snapshot_preview1 adapter.
+// The wasip1 module is run in place of the component, components which import WASI preview 2 directly are not supported.
+local wasip1Component(memory, wasm, inp, args=[], env={}) =
+    local config = want.blob(std.manifestJsonEx({
+        args: args,
+        env: env,
+        memory: memory,
+    }, ""));
+    want.compute("wasm.wasip1Component", [
+        want.input("program", wasm),
+        want.input("input", inp),
+        want.input("config.json", config)
+    ]);
+
 local nativeGLFS(memory, wasm, inp, args=[], env={}) =
     local config = want.blob(std.manifestJsonEx({
         args: args,
@@ -26,5 +40,6 @@ local nativeGLFS(memory, wasm, inp, args=[], env={}) =
 
 {
     wasip1 :: wasip1,
+    wasip1Component :: wasip1Component,
     nativeGLFS :: nativeGLFS,
 }
\ No newline at end of file
@@ -0,0 +1,140 @@
+// Package glfsarchive writes glfs trees as archive files.
+// All of the formats are deterministic: entries are sorted, timestamps are fixed, and owners are zeroed.
+package glfsarchive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+
+	"blobcache.io/glfs"
+	"github.com/klauspost/compress/zstd"
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/internal/glfscpio"
+	"wantbuild.io/want/src/internal/glfsport"
+	"wantbuild.io/want/src/internal/op/ociops"
+	"wantbuild.io/want/src/internal/stores"
+	"wantbuild.io/want/src/wantjob"
+)
+
+type Format string
+
+const (
+	FormatTar    = Format("tar")
+	FormatTarGz  = Format("tar.gz")
+	FormatTarZst = Format("tar.zst")
+	FormatZip    = Format("zip")
+	FormatCPIO   = Format("cpio")
+	// FormatOCILayout is a tar of an OCI image layout, containing an image with the tree as its only layer.
+	FormatOCILayout = Format("oci-layout")
+)
+
+var formats = []Format{FormatTar, FormatTarGz, FormatTarZst, FormatZip, FormatCPIO, FormatOCILayout}
+
+// ParseFormat returns the Format named x
+func ParseFormat(x string) (Format, error) {
+	for _, f := range formats {
+		if string(f) == x {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown archive format %q. must be one of %v", x, formats)
+}
+
+// Write writes the tree at root to w as an archive in format f.
+func Write(ctx context.Context, src cadata.Getter, root glfs.Ref, f Format, w io.Writer) error {
+	if root.Type != glfs.TypeTree {
+		return fmt.Errorf("cannot archive non-tree %v", root.Type)
+	}
+	switch f {
+	case FormatTar:
+		return writeTAR(ctx, src, root, w)
+	case FormatTarGz:
+		gw := gzip.NewWriter(w)
+		if err := writeTAR(ctx, src, root, gw); err != nil {
+			return err
+		}
+		return gw.Close()
+	case FormatTarZst:
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return err
+		}
+		if err := writeTAR(ctx, src, root, zw); err != nil {
+			return err
+		}
+		return zw.Close()
+	case FormatZip:
+		return writeZip(ctx, src, root, w)
+	case FormatCPIO:
+		return glfscpio.Write(ctx, src, root, w)
+	case FormatOCILayout:
+		scratch := stores.NewMem()
+		jc := wantjob.Ctx{Context: ctx, Dst: scratch}
+		layout, err := ociops.Build(jc, src, ociops.BuildTask{Rootfs: root})
+		if err != nil {
+			return err
+		}
+		return writeTAR(ctx, stores.Union{src, scratch}, *layout, w)
+	default:
+		return fmt.Errorf("unknown archive format %q", f)
+	}
+}
+
+func writeTAR(ctx context.Context, src cadata.Getter, root glfs.Ref, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	if err := glfsport.WriteTAR(ctx, src, root, tw); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// zipEpoch is the earliest time which can be represented in a zip file.
+var zipEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func writeZip(ctx context.Context, src cadata.Getter, root glfs.Ref, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	if err := glfs.WalkTree(ctx, src, root, func(prefix string, ent glfs.TreeEntry) error {
+		hdr := &zip.FileHeader{
+			Name:     path.Join(prefix, ent.Name),
+			Method:   zip.Deflate,
+			Modified: zipEpoch,
+		}
+		switch {
+		case ent.Ref.Type == glfs.TypeTree:
+			hdr.Name += "/"
+			hdr.Method = zip.Store
+			hdr.SetMode(fs.ModeDir | 0o755)
+			_, err := zw.CreateHeader(hdr)
+			return err
+		case ent.FileMode&fs.ModeSymlink != 0:
+			hdr.SetMode(fs.ModeSymlink | 0o777)
+		default:
+			perm := ent.FileMode.Perm()
+			if perm == 0 {
+				perm = 0o644
+			}
+			hdr.SetMode(perm)
+		}
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		r, err := glfs.GetBlob(ctx, src, ent.Ref)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(fw, r)
+		return err
+	}); err != nil {
+		return err
+	}
+	return zw.Close()
+}
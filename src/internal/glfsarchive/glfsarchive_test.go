@@ -0,0 +1,68 @@
+package glfsarchive
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"wantbuild.io/want/src/internal/stores"
+	"wantbuild.io/want/src/internal/testutil"
+)
+
+func TestDeterministic(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+	root := testutil.PostFSStr(t, s, map[string]string{
+		"a/b/c.txt": "c123",
+		"a/b.txt":   "b123",
+		"d.txt":     "d123",
+	})
+	for _, f := range formats {
+		t.Run(string(f), func(t *testing.T) {
+			var bufs [2]bytes.Buffer
+			for i := range bufs {
+				require.NoError(t, Write(ctx, s, root, f, &bufs[i]))
+			}
+			require.NotZero(t, bufs[0].Len())
+			require.Equal(t, bufs[0].Bytes(), bufs[1].Bytes())
+		})
+	}
+}
+
+func TestTar(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+	root := testutil.PostFSStr(t, s, map[string]string{
+		"b/c.txt": "c123",
+		"a.txt":   "a123",
+	})
+	var buf bytes.Buffer
+	require.NoError(t, Write(ctx, s, root, FormatTar, &buf))
+
+	tr := tar.NewReader(&buf)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, time.Unix(0, 0), hdr.ModTime)
+		require.Zero(t, hdr.Uid)
+		require.Zero(t, hdr.Gid)
+		names = append(names, hdr.Name)
+	}
+	require.Equal(t, []string{"a.txt", "b/", "b/c.txt"}, names)
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("tar.zst")
+	require.NoError(t, err)
+	require.Equal(t, FormatTarZst, f)
+	_, err = ParseFormat("rar")
+	require.Error(t, err)
+}
@@ -2,24 +2,56 @@ package glfsgit
 
 import (
 	"context"
+	"path"
 
 	"blobcache.io/glfs"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/storage"
 	"go.brendoncarroll.net/state/cadata"
 )
 
 func ImportTree(ctx context.Context, dst cadata.PostExister, gitstor storage.Storer, gt *object.Tree) (*glfs.Ref, error) {
+	im := Importer{Store: dst, Git: gitstor}
+	return im.ImportTree(ctx, "", gt)
+}
+
+func ImportBlob(ctx context.Context, dst cadata.PostExister, gitstore storage.Storer, gb *object.Blob) (*glfs.Ref, error) {
+	rc, err := gb.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return glfs.PostBlob(ctx, dst, rc)
+}
+
+// Importer imports Git trees into GLFS
+type Importer struct {
+	Store cadata.PostExister
+	Git   storage.Storer
+
+	// Submodule is called with the path and commit hash of each gitlink entry.
+	// If Submodule is nil, gitlinks are imported as empty trees, the same as a checkout without submodules.
+	Submodule func(ctx context.Context, p string, h plumbing.Hash) (*glfs.Ref, error)
+	// Blob is called to import each blob.
+	// If Blob is nil, ImportBlob is used.
+	Blob func(ctx context.Context, p string, gb *object.Blob) (*glfs.Ref, error)
+}
+
+// ImportTree imports the Git tree gt, which is at path p in the root tree.
+func (im *Importer) ImportTree(ctx context.Context, p string, gt *object.Tree) (*glfs.Ref, error) {
 	var ents []glfs.TreeEntry
 	for _, ge := range gt.Entries {
+		p2 := path.Join(p, ge.Name)
 		mode, err := ge.Mode.ToOSFileMode()
 		if err != nil {
 			return nil, err
 		}
 		var ref *glfs.Ref
-		if ge.Mode.IsFile() {
-			eo, err := gitstor.EncodedObject(plumbing.BlobObject, ge.Hash)
+		switch {
+		case ge.Mode.IsFile():
+			eo, err := im.Git.EncodedObject(plumbing.BlobObject, ge.Hash)
 			if err != nil {
 				return nil, err
 			}
@@ -27,19 +59,23 @@ func ImportTree(ctx context.Context, dst cadata.PostExister, gitstor storage.Sto
 			if err != nil {
 				return nil, err
 			}
-			if ref, err = ImportBlob(ctx, dst, gitstor, gb); err != nil {
+			if ref, err = im.importBlob(ctx, p2, gb); err != nil {
+				return nil, err
+			}
+		case ge.Mode == filemode.Submodule:
+			if ref, err = im.importSubmodule(ctx, p2, ge.Hash); err != nil {
 				return nil, err
 			}
-		} else {
-			eo, err := gitstor.EncodedObject(plumbing.TreeObject, ge.Hash)
+		default:
+			eo, err := im.Git.EncodedObject(plumbing.TreeObject, ge.Hash)
 			if err != nil {
 				return nil, err
 			}
-			gt, err := object.DecodeTree(gitstor, eo)
+			gt, err := object.DecodeTree(im.Git, eo)
 			if err != nil {
 				return nil, err
 			}
-			if ref, err = ImportTree(ctx, dst, gitstor, gt); err != nil {
+			if ref, err = im.ImportTree(ctx, p2, gt); err != nil {
 				return nil, err
 			}
 		}
@@ -49,14 +85,19 @@ func ImportTree(ctx context.Context, dst cadata.PostExister, gitstor storage.Sto
 			Ref:      *ref,
 		})
 	}
-	return glfs.PostTreeSlice(ctx, dst, ents)
+	return glfs.PostTreeSlice(ctx, im.Store, ents)
 }
 
-func ImportBlob(ctx context.Context, dst cadata.PostExister, gitstore storage.Storer, gb *object.Blob) (*glfs.Ref, error) {
-	rc, err := gb.Reader()
-	if err != nil {
-		return nil, err
+func (im *Importer) importBlob(ctx context.Context, p string, gb *object.Blob) (*glfs.Ref, error) {
+	if im.Blob != nil {
+		return im.Blob(ctx, p, gb)
 	}
-	defer rc.Close()
-	return glfs.PostBlob(ctx, dst, rc)
+	return ImportBlob(ctx, im.Store, im.Git, gb)
+}
+
+func (im *Importer) importSubmodule(ctx context.Context, p string, h plumbing.Hash) (*glfs.Ref, error) {
+	if im.Submodule != nil {
+		return im.Submodule(ctx, p, h)
+	}
+	return glfs.PostTreeSlice(ctx, im.Store, nil)
 }
@@ -16,6 +16,16 @@ func (e ErrUnexpectedFile) Error() string {
 	return fmt.Sprintf("unexpected file %s %v", e.Path, e.Info.Mode())
 }
 
+// ErrSymlinkEscape is returned by Export when a symlink would point outside of the exported tree.
+type ErrSymlinkEscape struct {
+	Path   string
+	Target string
+}
+
+func (e ErrSymlinkEscape) Error() string {
+	return fmt.Sprintf("symlink %s -> %s points outside of the exported tree", e.Path, e.Target)
+}
+
 // ErrStaleStale is returned by Export when the cache entry for a file does not match what is in the cache.
 type ErrStaleCache struct {
 	Path       string
@@ -102,7 +102,50 @@ func (ex Exporter) exportLink(ctx context.Context, ref glfs.Ref, mode os.FileMod
 	if err != nil {
 		return err
 	}
-	return ex.symlink(string(pathData), p)
+	target := string(pathData)
+	if !symlinkIsContained(p, target) {
+		return ErrSymlinkEscape{Path: p, Target: target}
+	}
+	finfo, err := ex.stat(p)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if finfo.Mode()&fs.ModeSymlink == 0 {
+			if !ex.Clobber {
+				return ErrUnexpectedFile{Path: p, Info: finfo}
+			}
+		} else if existing, err := ex.readLink(p); err == nil && existing == target {
+			return nil
+		}
+		if err := ex.remove(p); err != nil {
+			return err
+		}
+	}
+	return ex.symlink(target, p)
+}
+
+// symlinkIsContained returns true if a symlink at p, pointing to target, resolves to a path within the root.
+// ".." is only allowed before the first name in target, because the name could be another symlink,
+// and going up from where it resolves to could escape the root, even though the path is contained lexically.
+func symlinkIsContained(p, target string) bool {
+	if path.IsAbs(target) {
+		return false
+	}
+	up := true
+	for _, elem := range strings.Split(target, "/") {
+		switch elem {
+		case "", ".":
+		case "..":
+			if !up {
+				return false
+			}
+		default:
+			up = false
+		}
+	}
+	resolved := path.Join(path.Dir(glfs.CleanPath(p)), target)
+	return resolved != ".." && !strings.HasPrefix(resolved, "../")
 }
 
 func (ex Exporter) exportFile(ctx context.Context, ref glfs.Ref, mode os.FileMode, p string) error {
@@ -139,6 +182,12 @@ func (ex Exporter) exportFile(ctx context.Context, ref glfs.Ref, mode os.FileMod
 	// - there isn't a non-file at p
 	// - if there was a file, we exported it and it hasn't been modified
 	// - otherwise there is no file
+	if err == nil && finfo.Mode()&fs.ModeSymlink != 0 {
+		// don't write through the link
+		if err := ex.remove(p); err != nil {
+			return err
+		}
+	}
 	r, err := glfs.GetBlob(ctx, ex.Store, ref)
 	if err != nil {
 		return err
@@ -159,12 +208,32 @@ func (ex Exporter) exportFile(ctx context.Context, ref glfs.Ref, mode os.FileMod
 	return ex.Cache.Put(ctx, p, ent)
 }
 
+// stat does not follow symlinks, except for the root, so that nothing is ever exported outside of Dir.
 func (ex *Exporter) stat(p string) (fs.FileInfo, error) {
 	p2, err := ex.path(p)
 	if err != nil {
 		return nil, err
 	}
-	return os.Stat(p2)
+	if glfs.CleanPath(p) == "" {
+		return os.Stat(p2)
+	}
+	return os.Lstat(p2)
+}
+
+func (ex *Exporter) readLink(p string) (string, error) {
+	p2, err := ex.path(p)
+	if err != nil {
+		return "", err
+	}
+	return os.Readlink(p2)
+}
+
+func (ex *Exporter) remove(p string) error {
+	p2, err := ex.path(p)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p2)
 }
 
 func (ex *Exporter) putFile(ctx context.Context, p string, mode os.FileMode, r io.Reader) error {
@@ -2,10 +2,12 @@ package glfsport
 
 import (
 	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"blobcache.io/glfs"
 	"github.com/stretchr/testify/require"
 
 	"wantbuild.io/want/src/internal/stores"
@@ -82,3 +84,91 @@ func TestExportDirty(t *testing.T) {
 	require.Error(t, err)
 	require.True(t, errors.As(err, &ErrStaleCache{}))
 }
+
+func TestSymlinkRoundTrip(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+	dir := t.TempDir()
+
+	exp := Exporter{
+		Cache: NullCache{},
+		Store: s,
+		Dir:   dir,
+	}
+	ref := testutil.SymlinkTree(t, s, "target", "link")
+	require.NoError(t, exp.Export(ctx, ref, ""))
+	target, err := os.Readlink(filepath.Join(dir, "link"))
+	require.NoError(t, err)
+	require.Equal(t, "target", target)
+
+	imp := Importer{
+		Cache: NullCache{},
+		Store: s,
+		Dir:   dir,
+	}
+	ref2, err := imp.Import(ctx, "")
+	require.NoError(t, err)
+	ents, err := glfs.GetTreeSlice(ctx, s, *ref2, 1)
+	require.NoError(t, err)
+	require.Len(t, ents, 1)
+	require.Equal(t, "link", ents[0].Name)
+	require.NotZero(t, ents[0].FileMode&fs.ModeSymlink)
+	data, err := glfs.GetBlobBytes(ctx, s, ents[0].Ref, 1024)
+	require.NoError(t, err)
+	require.Equal(t, "target", string(data))
+}
+
+func TestSymlinkEscape(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+
+	for _, target := range []string{"/etc/passwd", "..", "../x", "a/../../x", "a/../x"} {
+		dir := t.TempDir()
+		exp := Exporter{
+			Cache: NullCache{},
+			Store: s,
+			Dir:   dir,
+		}
+		ref := testutil.SymlinkTree(t, s, target, "link")
+		err := exp.Export(ctx, ref, "")
+		require.Error(t, err, target)
+		require.True(t, errors.As(err, &ErrSymlinkEscape{}), target)
+	}
+
+	// each link stays in the root lexically, but the chain resolves l2 to the parent of the root.
+	dir := t.TempDir()
+	exp := Exporter{
+		Cache: NullCache{},
+		Store: s,
+		Dir:   dir,
+	}
+	ref := testutil.MergeFS(t, s,
+		testutil.PostTree(t, s, []glfs.TreeEntry{
+			{Name: "d", FileMode: fs.ModeDir | 0o755, Ref: testutil.SymlinkTree(t, s, "..", "d2")},
+		}),
+		testutil.SymlinkTree(t, s, "d/d2", "l1"),
+		testutil.SymlinkTree(t, s, "l1/..", "l2"),
+	)
+	err := exp.Export(ctx, ref, "")
+	require.True(t, errors.As(err, &ErrSymlinkEscape{}), err)
+}
+
+func TestSymlinkIsContained(t *testing.T) {
+	for _, tc := range []struct {
+		Path, Target string
+		Contained    bool
+	}{
+		{"link", "target", true},
+		{"link", "./a/b", true},
+		{"a/b/link", "../../x", true},
+		{"a/b/link", "../../../x", false},
+		{"a/link", "../b/c", true},
+		{"d/d2", "..", true},
+		{"l1", "d/d2", true},
+		{"l2", "l1/..", false},
+		{"a/link", "b/../c", false},
+		{"link", "/abs", false},
+	} {
+		require.Equal(t, tc.Contained, symlinkIsContained(tc.Path, tc.Target), "%s -> %s", tc.Path, tc.Target)
+	}
+}
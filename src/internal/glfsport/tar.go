@@ -0,0 +1,67 @@
+package glfsport
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+
+	"blobcache.io/glfs"
+	"go.brendoncarroll.net/state/cadata"
+)
+
+// WriteTAR writes the tree at root to tw.
+// The output only depends on the tree: entries are written in tree order, and timestamps and owners are zeroed.
+func WriteTAR(ctx context.Context, src cadata.Getter, root glfs.Ref, tw *tar.Writer) error {
+	if root.Type != glfs.TypeTree {
+		return fmt.Errorf("WriteTAR: root must be a tree, have %v", root.Type)
+	}
+	ag := glfs.NewAgent()
+	return ag.WalkTree(ctx, src, root, func(prefix string, ent glfs.TreeEntry) error {
+		hdr := &tar.Header{
+			Name:    path.Join(prefix, ent.Name),
+			Mode:    int64(ent.FileMode.Perm()),
+			ModTime: time.Unix(0, 0),
+			Format:  tar.FormatPAX,
+		}
+		switch ent.Ref.Type {
+		case glfs.TypeTree:
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+			if hdr.Mode == 0 {
+				hdr.Mode = 0o755
+			}
+			return tw.WriteHeader(hdr)
+		case glfs.TypeBlob:
+			if ent.FileMode&fs.ModeSymlink != 0 {
+				target, err := ag.GetBlobBytes(ctx, src, ent.Ref, MaxPathLen)
+				if err != nil {
+					return err
+				}
+				hdr.Typeflag = tar.TypeSymlink
+				hdr.Linkname = string(target)
+				hdr.Mode = 0o777
+				return tw.WriteHeader(hdr)
+			}
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = int64(ent.Ref.Size)
+			if hdr.Mode == 0 {
+				hdr.Mode = 0o644
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			r, err := ag.GetBlob(ctx, src, ent.Ref)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, r)
+			return err
+		default:
+			return fmt.Errorf("WriteTAR: cannot write type %v at %s", ent.Ref.Type, hdr.Name)
+		}
+	})
+}
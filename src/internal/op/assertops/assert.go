@@ -2,7 +2,9 @@ package assertops
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -27,6 +29,13 @@ type AssertTask struct {
 type Assertions struct {
 	SubsetOf   *glfs.Ref
 	PathExists *string
+	// Equal is a reference which must have the same paths and contents as the object.
+	// File modes are not compared.
+	Equal *glfs.Ref
+	// Exactly is a reference which must be identical to the object, including file modes.
+	Exactly *glfs.Ref
+
+	Checks
 }
 
 func PostAssertTask(ctx context.Context, s cadata.PostExister, x AssertTask) (*glfs.Ref, error) {
@@ -54,6 +63,46 @@ func PostAssertTask(ctx context.Context, s cadata.PostExister, x AssertTask) (*g
 			Ref:      *ref,
 		})
 	}
+	if x.Equal != nil {
+		ents = append(ents, glfs.TreeEntry{
+			Name:     "equal",
+			FileMode: 0o777,
+			Ref:      *x.Equal,
+		})
+	}
+	if x.Exactly != nil {
+		ents = append(ents, glfs.TreeEntry{
+			Name:     "exactly",
+			FileMode: 0o777,
+			Ref:      *x.Exactly,
+		})
+	}
+	if !x.Checks.IsEmpty() {
+		data, err := json.Marshal(x.Checks)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := glfs.PostBlob(ctx, s, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		ents = append(ents, glfs.TreeEntry{
+			Name:     "checks",
+			FileMode: 0o777,
+			Ref:      *ref,
+		})
+	}
+	if x.Msg != "" {
+		ref, err := glfs.PostBlob(ctx, s, strings.NewReader(x.Msg))
+		if err != nil {
+			return nil, err
+		}
+		ents = append(ents, glfs.TreeEntry{
+			Name:     "message",
+			FileMode: 0o777,
+			Ref:      *ref,
+		})
+	}
 	return glfs.PostTreeSlice(ctx, s, ents)
 }
 
@@ -87,6 +136,24 @@ func GetAssertTask(ctx context.Context, s cadata.Getter, x glfs.Ref) (*AssertTas
 		p := string(data)
 		ret.PathExists = &p
 	}
+	if ent := glfs.Lookup(tree, "equal"); ent != nil {
+		ret.Equal = &ent.Ref
+	}
+	if ent := glfs.Lookup(tree, "exactly"); ent != nil {
+		ret.Exactly = &ent.Ref
+	}
+	if ent := glfs.Lookup(tree, "checks"); ent != nil {
+		data, err := glfs.GetBlobBytes(ctx, s, ent.Ref, 1e6)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &ret.Checks); err != nil {
+			return nil, fmt.Errorf("parsing assert checks: %w", err)
+		}
+		if err := ret.Checks.Validate(); err != nil {
+			return nil, err
+		}
+	}
 	return &ret, nil
 }
 
@@ -108,7 +175,13 @@ func checkAssertions(ctx context.Context, s cadata.Getter, x glfs.Ref, as Assert
 	if as.PathExists != nil {
 		err = errors.Join(err, assertPathExists(ctx, ag, s, x, *as.PathExists))
 	}
-	return err
+	if as.Equal != nil {
+		err = errors.Join(err, assertEqual(ctx, ag, s, x, *as.Equal, false))
+	}
+	if as.Exactly != nil {
+		err = errors.Join(err, assertEqual(ctx, ag, s, x, *as.Exactly, true))
+	}
+	return errors.Join(err, checkAll(ctx, ag, s, x, as.Checks))
 }
 
 func assertSubsetOf(ctx context.Context, ag *glfs.Agent, s cadata.Getter, x, subsetOf glfs.Ref) error {
@@ -0,0 +1,155 @@
+package assertops
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"blobcache.io/glfs"
+	"github.com/stretchr/testify/require"
+
+	"wantbuild.io/want/src/internal/stores"
+	"wantbuild.io/want/src/internal/testutil"
+)
+
+func TestAssertEqual(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+	want := testutil.PostFSStr(t, s, map[string]string{
+		"a.txt":     "one\ntwo\nthree\n",
+		"dir/b.txt": "b",
+		"gone.txt":  "gone",
+	})
+	have := testutil.PostFSStr(t, s, map[string]string{
+		"a.txt":     "one\nTWO\nthree\n",
+		"dir/b.txt": "b",
+		"extra.txt": "extra",
+	})
+	require.NoError(t, checkAssertions(ctx, s, want, Assertions{Equal: &want}))
+
+	err := checkAssertions(ctx, s, have, Assertions{Equal: &want})
+	require.Error(t, err)
+	msg := err.Error()
+	// every difference is reported
+	require.Contains(t, msg, "a.txt: contents differ")
+	require.Contains(t, msg, "-two\n+TWO")
+	require.Contains(t, msg, "extra.txt: unexpected")
+	require.Contains(t, msg, "gone.txt: missing")
+}
+
+func TestAssertExactly(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+	blob := testutil.PostString(t, s, "x")
+	x := testutil.PostTree(t, s, []glfs.TreeEntry{{Name: "x", FileMode: 0o644, Ref: blob}})
+	y := testutil.PostTree(t, s, []glfs.TreeEntry{{Name: "x", FileMode: 0o755, Ref: blob}})
+
+	require.NoError(t, checkAssertions(ctx, s, x, Assertions{Equal: &y}))
+	err := checkAssertions(ctx, s, x, Assertions{Exactly: &y})
+	require.ErrorContains(t, err, "x: mode is -rw-r--r--, want -rwxr-xr-x")
+}
+
+func TestChecks(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+	x := testutil.PostFSStr(t, s, map[string]string{
+		"version.txt":  "v1.2.3\n",
+		"cfg.json":     `{"a": {"b": [1, "two"]}}`,
+		"bin/tool":     "12345678",
+		"bin/tool2":    "1234",
+		"doc/README":   "hello",
+		"doc/more/abc": "abc",
+	})
+	tcs := []struct {
+		Checks Checks
+		Errs   []string
+	}{
+		{
+			Checks: Checks{
+				Matches:   []MatchCheck{{Path: "version.txt", Pattern: `^v\d+\.\d+\.\d+\n$`}},
+				FileCount: []BoundsCheck{{Path: "doc", Min: ptr(2), Max: ptr(2)}},
+				Size:      []BoundsCheck{{Path: "bin", Max: ptr(12)}},
+				Modes:     []ModeCheck{{Path: "bin/tool", Mode: "0700"}},
+				JSON: []JSONCheck{
+					{Path: "cfg.json", Query: "$.a.b[1]", Value: json.RawMessage(`"two"`)},
+					{Path: "cfg.json", Query: "a.b", Value: json.RawMessage(`[1, "two"]`)},
+				},
+			},
+		},
+		{
+			Checks: Checks{
+				Matches:   []MatchCheck{{Path: "version.txt", Pattern: `^v2`}},
+				FileCount: []BoundsCheck{{Path: "", Min: ptr(10)}},
+				Size:      []BoundsCheck{{Path: "bin/tool", Max: ptr(4)}},
+				Modes:     []ModeCheck{{Path: "bin/tool", Mode: "755"}},
+				JSON:      []JSONCheck{{Path: "cfg.json", Query: "$.a.b[0]", Value: json.RawMessage(`2`)}},
+			},
+			Errs: []string{
+				`version.txt: does not match "^v2"`,
+				`.: file count is 6, want at least 10`,
+				`bin/tool: size is 8, want at most 4`,
+				`bin/tool: mode is 0700, want 0755`,
+				`cfg.json: $.a.b[0] is 1, want 2`,
+			},
+		},
+	}
+	for i, tc := range tcs {
+		require.NoError(t, tc.Checks.Validate(), i)
+		err := checkAssertions(ctx, s, x, Assertions{Checks: tc.Checks})
+		if len(tc.Errs) == 0 {
+			require.NoError(t, err, i)
+			continue
+		}
+		require.Error(t, err, i)
+		require.Equal(t, tc.Errs, strings.Split(err.Error(), "\n"), i)
+	}
+}
+
+func TestAssertTaskRoundTrip(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+	x := testutil.PostString(t, s, "x")
+	task := AssertTask{
+		X:   x,
+		Msg: "hello",
+		Assertions: Assertions{
+			Equal:  &x,
+			Checks: Checks{Matches: []MatchCheck{{Pattern: "x"}}},
+		},
+	}
+	ref, err := PostAssertTask(ctx, s, task)
+	require.NoError(t, err)
+	task2, err := GetAssertTask(ctx, s, *ref)
+	require.NoError(t, err)
+	require.Equal(t, task, *task2)
+}
+
+func TestParseJSONPath(t *testing.T) {
+	tcs := []struct {
+		In  string
+		Out []any
+		Err bool
+	}{
+		{In: "", Out: nil},
+		{In: "$", Out: nil},
+		{In: "$.a.b[2]", Out: []any{"a", "b", 2}},
+		{In: "a[0][1].c", Out: []any{"a", 0, 1, "c"}},
+		{In: "$a", Err: true},
+		{In: "a..b", Err: true},
+		{In: "a[x]", Err: true},
+		{In: "a[0", Err: true},
+	}
+	for _, tc := range tcs {
+		out, err := parseJSONPath(tc.In)
+		if tc.Err {
+			require.Error(t, err, tc.In)
+			continue
+		}
+		require.NoError(t, err, tc.In)
+		require.Equal(t, tc.Out, out, tc.In)
+	}
+}
+
+func ptr(x int64) *int64 {
+	return &x
+}
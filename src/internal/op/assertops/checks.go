@@ -0,0 +1,407 @@
+package assertops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"blobcache.io/glfs"
+	"github.com/pmezard/go-difflib/difflib"
+	"go.brendoncarroll.net/state/cadata"
+)
+
+const (
+	// MaxBlobSize is the largest blob which will be read to check its contents.
+	MaxBlobSize = 64 << 20
+	// MaxDiffSize is the largest blob which will be included in a diff.
+	MaxDiffSize = 1 << 20
+)
+
+// Checks are assertions about the paths within an object.
+// In every check, the empty path refers to the object itself.
+type Checks struct {
+	// Matches requires blobs to match a regular expression.
+	Matches []MatchCheck `json:"matches,omitempty"`
+	// FileCount bounds the number of files beneath a path.
+	FileCount []BoundsCheck `json:"fileCount,omitempty"`
+	// Size bounds the total size in bytes of the files beneath a path.
+	Size []BoundsCheck `json:"size,omitempty"`
+	// Modes requires the permission bits of a path.
+	Modes []ModeCheck `json:"modes,omitempty"`
+	// JSON requires a value within a JSON blob.
+	JSON []JSONCheck `json:"json,omitempty"`
+}
+
+func (c Checks) IsEmpty() bool {
+	return len(c.Matches) == 0 && len(c.FileCount) == 0 && len(c.Size) == 0 && len(c.Modes) == 0 && len(c.JSON) == 0
+}
+
+func (c Checks) Validate() error {
+	for _, mc := range c.Matches {
+		if _, err := regexp.Compile(mc.Pattern); err != nil {
+			return fmt.Errorf("assert matches %q: %w", mc.Path, err)
+		}
+	}
+	for _, bc := range slices.Concat(c.FileCount, c.Size) {
+		if bc.Min != nil && bc.Max != nil && *bc.Min > *bc.Max {
+			return fmt.Errorf("assert bounds %q: min %d is greater than max %d", bc.Path, *bc.Min, *bc.Max)
+		}
+	}
+	for _, mc := range c.Modes {
+		if _, err := mc.Perm(); err != nil {
+			return err
+		}
+	}
+	for _, jc := range c.JSON {
+		if _, err := parseJSONPath(jc.Query); err != nil {
+			return err
+		}
+		if !json.Valid(jc.Value) {
+			return fmt.Errorf("assert json %q: invalid expected value", jc.Path)
+		}
+	}
+	return nil
+}
+
+type MatchCheck struct {
+	Path    string `json:"path"`
+	Pattern string `json:"pattern"`
+}
+
+type BoundsCheck struct {
+	Path string `json:"path"`
+	Min  *int64 `json:"min,omitempty"`
+	Max  *int64 `json:"max,omitempty"`
+}
+
+func (bc BoundsCheck) check(what string, n int64) error {
+	switch {
+	case bc.Min != nil && n < *bc.Min:
+		return fmt.Errorf("%s: %s is %d, want at least %d", displayPath(bc.Path), what, n, *bc.Min)
+	case bc.Max != nil && n > *bc.Max:
+		return fmt.Errorf("%s: %s is %d, want at most %d", displayPath(bc.Path), what, n, *bc.Max)
+	}
+	return nil
+}
+
+type ModeCheck struct {
+	Path string `json:"path"`
+	// Mode is the permission bits in octal e.g. "0755"
+	Mode string `json:"mode"`
+}
+
+func (mc ModeCheck) Perm() (fs.FileMode, error) {
+	n, err := strconv.ParseUint(mc.Mode, 8, 32)
+	if err != nil || fs.FileMode(n)&^fs.ModePerm != 0 {
+		return 0, fmt.Errorf("assert mode %q: invalid mode %q", mc.Path, mc.Mode)
+	}
+	return fs.FileMode(n), nil
+}
+
+type JSONCheck struct {
+	Path string `json:"path"`
+	// Query selects a value within the blob e.g. "$.a.b[0]"
+	Query string          `json:"query"`
+	Value json.RawMessage `json:"value"`
+}
+
+// checkAll runs every check, and returns all of the violations.
+func checkAll(ctx context.Context, ag *glfs.Agent, s cadata.Getter, x glfs.Ref, c Checks) (err error) {
+	for _, mc := range c.Matches {
+		err = errors.Join(err, checkMatch(ctx, ag, s, x, mc))
+	}
+	for _, bc := range c.FileCount {
+		err = errors.Join(err, checkFiles(ctx, ag, s, x, bc, "file count", func(glfs.Ref) int64 { return 1 }))
+	}
+	for _, bc := range c.Size {
+		err = errors.Join(err, checkFiles(ctx, ag, s, x, bc, "size", func(ref glfs.Ref) int64 { return int64(ref.Size) }))
+	}
+	for _, mc := range c.Modes {
+		err = errors.Join(err, checkMode(ctx, ag, s, x, mc))
+	}
+	for _, jc := range c.JSON {
+		err = errors.Join(err, checkJSON(ctx, ag, s, x, jc))
+	}
+	return err
+}
+
+func checkMatch(ctx context.Context, ag *glfs.Agent, s cadata.Getter, x glfs.Ref, mc MatchCheck) error {
+	re, err := regexp.Compile(mc.Pattern)
+	if err != nil {
+		return err
+	}
+	data, err := getBlobAt(ctx, ag, s, x, mc.Path)
+	if err != nil {
+		return err
+	}
+	if !re.Match(data) {
+		return fmt.Errorf("%s: does not match %q", displayPath(mc.Path), mc.Pattern)
+	}
+	return nil
+}
+
+// checkFiles sums measure over all the files beneath bc.Path and checks the total against the bounds.
+func checkFiles(ctx context.Context, ag *glfs.Agent, s cadata.Getter, x glfs.Ref, bc BoundsCheck, what string, measure func(glfs.Ref) int64) error {
+	ents, err := getEntries(ctx, ag, s, x, bc.Path)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, ent := range ents {
+		if ent.Ref.Type == glfs.TypeBlob {
+			total += measure(ent.Ref)
+		}
+	}
+	return bc.check(what, total)
+}
+
+func checkMode(ctx context.Context, ag *glfs.Agent, s cadata.Getter, x glfs.Ref, mc ModeCheck) error {
+	perm, err := mc.Perm()
+	if err != nil {
+		return err
+	}
+	p := glfs.CleanPath(mc.Path)
+	if p == "" {
+		return fmt.Errorf("assert mode: the root has no mode")
+	}
+	parent, err := ag.GetAtPath(ctx, s, x, path.Dir(p))
+	if err != nil {
+		return err
+	}
+	tree, err := ag.GetTreeSlice(ctx, s, *parent, 1e6)
+	if err != nil {
+		return err
+	}
+	ent := glfs.Lookup(tree, path.Base(p))
+	if ent == nil {
+		return fmt.Errorf("%s: does not exist", p)
+	}
+	if have := ent.FileMode & fs.ModePerm; have != perm {
+		return fmt.Errorf("%s: mode is %04o, want %04o", p, have, perm)
+	}
+	return nil
+}
+
+func checkJSON(ctx context.Context, ag *glfs.Agent, s cadata.Getter, x glfs.Ref, jc JSONCheck) error {
+	segs, err := parseJSONPath(jc.Query)
+	if err != nil {
+		return err
+	}
+	data, err := getBlobAt(ctx, ag, s, x, jc.Path)
+	if err != nil {
+		return err
+	}
+	var doc, want any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%s: %w", displayPath(jc.Path), err)
+	}
+	if err := json.Unmarshal(jc.Value, &want); err != nil {
+		return err
+	}
+	have, err := selectJSON(doc, segs)
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", displayPath(jc.Path), jc.Query, err)
+	}
+	if !reflect.DeepEqual(have, want) {
+		haveJSON, _ := json.Marshal(have)
+		wantJSON, _ := json.Marshal(want)
+		return fmt.Errorf("%s: %s is %s, want %s", displayPath(jc.Path), jc.Query, haveJSON, wantJSON)
+	}
+	return nil
+}
+
+// assertEqual returns an error listing every difference between x and expected.
+// If modes is true, the file modes must also be equal.
+func assertEqual(ctx context.Context, ag *glfs.Agent, s cadata.Getter, x, expected glfs.Ref, modes bool) error {
+	if x.Equals(expected) {
+		return nil
+	}
+	haveEnts, err := getEntries(ctx, ag, s, x, "")
+	if err != nil {
+		return err
+	}
+	wantEnts, err := getEntries(ctx, ag, s, expected, "")
+	if err != nil {
+		return err
+	}
+	var paths []string
+	for p := range haveEnts {
+		paths = append(paths, p)
+	}
+	for p := range wantEnts {
+		if _, exists := haveEnts[p]; !exists {
+			paths = append(paths, p)
+		}
+	}
+	slices.Sort(paths)
+
+	var errs []error
+	for _, p := range paths {
+		have, inHave := haveEnts[p]
+		want, inWant := wantEnts[p]
+		switch {
+		case !inHave:
+			errs = append(errs, fmt.Errorf("%s: missing", displayPath(p)))
+		case !inWant:
+			errs = append(errs, fmt.Errorf("%s: unexpected", displayPath(p)))
+		case have.Ref.Type != want.Ref.Type:
+			errs = append(errs, fmt.Errorf("%s: is a %s, want %s", displayPath(p), have.Ref.Type, want.Ref.Type))
+		default:
+			if modes && have.FileMode != want.FileMode {
+				errs = append(errs, fmt.Errorf("%s: mode is %v, want %v", displayPath(p), have.FileMode, want.FileMode))
+			}
+			if have.Ref.Type == glfs.TypeBlob && !have.Ref.Equals(want.Ref) {
+				errs = append(errs, diffBlobs(ctx, ag, s, p, have.Ref, want.Ref))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// diffBlobs returns an error containing a unified diff from want to have.
+func diffBlobs(ctx context.Context, ag *glfs.Agent, s cadata.Getter, p string, have, want glfs.Ref) error {
+	if have.Size > MaxDiffSize || want.Size > MaxDiffSize {
+		return fmt.Errorf("%s: contents differ (%d bytes, want %d bytes)", displayPath(p), have.Size, want.Size)
+	}
+	haveData, err := ag.GetBlobBytes(ctx, s, have, MaxDiffSize)
+	if err != nil {
+		return err
+	}
+	wantData, err := ag.GetBlobBytes(ctx, s, want, MaxDiffSize)
+	if err != nil {
+		return err
+	}
+	if !isText(haveData) || !isText(wantData) {
+		return fmt.Errorf("%s: binary contents differ", displayPath(p))
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(wantData)),
+		B:        difflib.SplitLines(string(haveData)),
+		FromFile: path.Join("want", p),
+		ToFile:   path.Join("have", p),
+		Context:  3,
+	})
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%s: contents differ\n%s", displayPath(p), strings.TrimRight(diff, "\n"))
+}
+
+// getEntries returns every entry at or beneath p, keyed by their path relative to p.
+// If p refers to a blob, it is returned with the empty path.
+func getEntries(ctx context.Context, ag *glfs.Agent, s cadata.Getter, x glfs.Ref, p string) (map[string]glfs.TreeEntry, error) {
+	root, err := ag.GetAtPath(ctx, s, x, p)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]glfs.TreeEntry)
+	if root.Type != glfs.TypeTree {
+		ret[""] = glfs.TreeEntry{Ref: *root}
+		return ret, nil
+	}
+	if err := ag.WalkTree(ctx, s, *root, func(prefix string, ent glfs.TreeEntry) error {
+		ret[path.Join(prefix, ent.Name)] = ent
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func getBlobAt(ctx context.Context, ag *glfs.Agent, s cadata.Getter, x glfs.Ref, p string) ([]byte, error) {
+	ref, err := ag.GetAtPath(ctx, s, x, p)
+	if err != nil {
+		return nil, err
+	}
+	if ref.Type != glfs.TypeBlob {
+		return nil, fmt.Errorf("%s: is a %s, want %s", displayPath(p), ref.Type, glfs.TypeBlob)
+	}
+	return ag.GetBlobBytes(ctx, s, *ref, MaxBlobSize)
+}
+
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !bytes.ContainsRune(data, 0)
+}
+
+func displayPath(p string) string {
+	if p = glfs.CleanPath(p); p == "" {
+		return "."
+	}
+	return p
+}
+
+// parseJSONPath parses a query made of object keys and array indexes e.g. "$.a.b[0]".
+// The leading "$" is optional.
+// Object keys are returned as strings and array indexes as ints.
+func parseJSONPath(q string) ([]any, error) {
+	rest := strings.TrimPrefix(q, "$")
+	var ret []any
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("json path %q: empty key", q)
+			}
+			ret = append(ret, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("json path %q: unterminated index", q)
+			}
+			n, err := strconv.Atoi(rest[1:end])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("json path %q: invalid index %q", q, rest[1:end])
+			}
+			ret = append(ret, n)
+			rest = rest[end+1:]
+		default:
+			if len(ret) > 0 || strings.HasPrefix(q, "$") {
+				return nil, fmt.Errorf("json path %q: expected '.' or '['", q)
+			}
+			// allow the first key without a leading '.'
+			rest = "." + rest
+		}
+	}
+	return ret, nil
+}
+
+func selectJSON(x any, segs []any) (any, error) {
+	for _, seg := range segs {
+		switch seg := seg.(type) {
+		case string:
+			obj, ok := x.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("cannot select key %q from %T", seg, x)
+			}
+			if x, ok = obj[seg]; !ok {
+				return nil, fmt.Errorf("no key %q", seg)
+			}
+		case int:
+			arr, ok := x.([]any)
+			if !ok {
+				return nil, fmt.Errorf("cannot index %T", x)
+			}
+			if seg >= len(arr) {
+				return nil, fmt.Errorf("index %d out of range, length is %d", seg, len(arr))
+			}
+			x = arr[seg]
+		}
+	}
+	return x, nil
+}
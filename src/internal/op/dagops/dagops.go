@@ -9,13 +9,18 @@ import (
 )
 
 const (
-	// OpExec evaluates a subgraph.
+	// OpExecLast evaluates a DAG, and outputs the result of the last node.
 	OpExecLast = wantjob.OpName("execLast")
+	// OpExecAll evaluates a DAG, and outputs the results of every node, as written by wantdag.PostNodeResults.
+	OpExecAll = wantjob.OpName("execAll")
 )
 
 var _ wantjob.Executor = &Executor{}
 
-type Executor struct{}
+type Executor struct {
+	// Narrowers are used to compute only the parts of a DAG's nodes which are needed by ExecLast.
+	Narrowers map[wantjob.OpName]wantdag.Narrower
+}
 
 func (e Executor) Execute(jc wantjob.Ctx, src cadata.Getter, x wantjob.Task) wantjob.Result {
 	switch x.Op {
@@ -23,6 +28,10 @@ func (e Executor) Execute(jc wantjob.Ctx, src cadata.Getter, x wantjob.Task) wan
 		return glfstasks.Exec(x.Input, func(x glfs.Ref) (*glfs.Ref, error) {
 			return e.ExecLast(jc, src, x)
 		})
+	case OpExecAll:
+		return glfstasks.Exec(x.Input, func(x glfs.Ref) (*glfs.Ref, error) {
+			return e.ExecAll(jc, src, x)
+		})
 	default:
 		return *wantjob.Result_ErrExec(wantjob.NewErrUnknownOperator(x.Op))
 	}
@@ -34,6 +43,10 @@ func (e Executor) ExecLast(jc wantjob.Ctx, s cadata.Getter, ref glfs.Ref) (*glfs
 	if err != nil {
 		return nil, err
 	}
+	dag, _, err = wantdag.Narrow(ctx, s, dag, []wantdag.NodeID{wantdag.NodeID(len(dag) - 1)}, e.Narrowers)
+	if err != nil {
+		return nil, err
+	}
 	res, err := wantdag.ParallelExecLast(jc, s, dag)
 	if err != nil {
 		return nil, err
@@ -43,3 +56,18 @@ func (e Executor) ExecLast(jc wantjob.Ctx, s cadata.Getter, ref glfs.Ref) (*glfs
 	}
 	return glfstasks.ParseGLFSRef(res.Root)
 }
+
+// ExecAll executes the DAG at ref, and returns a tree of the results of every node.
+// Nodes which fail do not cause ExecAll to fail, their errors are in their results.
+func (e Executor) ExecAll(jc wantjob.Ctx, s cadata.Getter, ref glfs.Ref) (*glfs.Ref, error) {
+	ctx := jc.Context
+	dag, err := wantdag.GetDAG(ctx, s, ref)
+	if err != nil {
+		return nil, err
+	}
+	results, err := wantdag.ParallelExecAll(jc, s, dag)
+	if err != nil {
+		return nil, err
+	}
+	return wantdag.PostNodeResults(ctx, jc.Dst, results)
+}
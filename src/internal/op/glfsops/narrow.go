@@ -0,0 +1,291 @@
+package glfsops
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"blobcache.io/glfs"
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/internal/stringsets"
+	"wantbuild.io/want/src/internal/wantdag"
+	"wantbuild.io/want/src/wantcfg"
+)
+
+type Demand = wantdag.Demand
+
+// Narrowers returns the wantdag.Narrowers for the glfs operations, with their names prefixed by prefix.
+//
+// The input to an operation is a tree, which may be given by named inputs, or by a single input which merges placed layers.
+// Parameters like the path for pick are only used to narrow a node if they are facts in the DAG.
+func Narrowers(prefix OpName) map[OpName]wantdag.Narrower {
+	n := narrower{prefix: prefix}
+	return map[OpName]wantdag.Narrower{
+		prefix + OpPick:          n.inputTree(n.pick),
+		prefix + OpPlace:         n.inputTree(n.place),
+		prefix + OpPassthrough:   n.inputTree(n.passthrough),
+		prefix + OpFilterPathSet: n.inputTree(n.filterPathSet),
+		prefix + OpMerge:         n.merge,
+	}
+}
+
+type narrower struct {
+	prefix OpName
+}
+
+// treeDemandFunc returns the demand on the input tree of node, given the demand on its output.
+// param returns the contents of a blob in the input tree, if it is known before the node is executed.
+type treeDemandFunc = func(ctx context.Context, param func(name string) ([]byte, error), demand Demand) (Demand, error)
+
+// inputTree returns a Narrower which passes the demand from fn on to each of the node's inputs.
+func (n narrower) inputTree(fn treeDemandFunc) wantdag.Narrower {
+	return func(ctx context.Context, src cadata.Getter, x wantdag.DAG, node wantdag.Node, demand Demand) ([]NodeInput, []Demand, error) {
+		param := func(name string) ([]byte, error) {
+			ref, err := n.lookupFact(ctx, src, x, node.Inputs, name)
+			if err != nil || ref == nil || ref.Type != glfs.TypeBlob {
+				return nil, err
+			}
+			return glfs.GetBlobBytes(ctx, src, *ref, 1e6)
+		}
+		treeDemand, err := fn(ctx, param, demand)
+		if err != nil {
+			return nil, nil, err
+		}
+		demands := make([]Demand, len(node.Inputs))
+		for i, in := range node.Inputs {
+			demands[i] = treeDemand.Within(in.Name)
+		}
+		return node.Inputs, demands, nil
+	}
+}
+
+// pick needs only the picked path from x.
+func (n narrower) pick(ctx context.Context, param func(string) ([]byte, error), demand Demand) (Demand, error) {
+	p, err := param("path")
+	if err != nil || p == nil {
+		return wantdag.DemandAll(), err
+	}
+	return wantdag.DemandPaths("path").Union(orAll(demand).Under(string(p)).Under("x")), nil
+}
+
+// place needs only the part of x which ends up in demand, after x is placed at path.
+func (n narrower) place(ctx context.Context, param func(string) ([]byte, error), demand Demand) (Demand, error) {
+	p, err := param("path")
+	if err != nil || p == nil {
+		return wantdag.DemandAll(), err
+	}
+	return wantdag.DemandPaths("path").Union(orAll(demand.Within(string(p))).Under("x")), nil
+}
+
+// filterPathSet needs only the paths from x which are in both the filter and demand.
+// Filters which cannot be expressed as paths need all of x.
+func (n narrower) filterPathSet(ctx context.Context, param func(string) ([]byte, error), demand Demand) (Demand, error) {
+	data, err := param("filter")
+	if err != nil || data == nil {
+		return wantdag.DemandAll(), err
+	}
+	var q wantcfg.PathSet
+	if err := json.Unmarshal(data, &q); err != nil {
+		return wantdag.DemandAll(), nil
+	}
+	return wantdag.DemandPaths("filter").Union(orAll(demand.Intersect(pathSetDemand(q))).Under("x")), nil
+}
+
+// passthrough outputs its input tree, so it needs the same paths from it.
+func (n narrower) passthrough(ctx context.Context, param func(string) ([]byte, error), demand Demand) (Demand, error) {
+	return orAll(demand), nil
+}
+
+// merge drops layers which only place content outside of demand.
+// Each entry in the input tree of a merge is a layer at the root of its output.
+// The input tree is either given by named inputs, or by a single unnamed input, which is usually a merge of placed layers.
+func (n narrower) merge(ctx context.Context, src cadata.Getter, x wantdag.DAG, node wantdag.Node, demand Demand) ([]NodeInput, []Demand, error) {
+	if len(node.Inputs) == 0 {
+		return node.Inputs, nil, nil
+	}
+	if len(node.Inputs) == 1 && node.Inputs[0].Name == "" {
+		names, layers, err := n.entries(ctx, src, x, node.Inputs[0].Node)
+		if err != nil {
+			return nil, nil, err
+		}
+		var inDemand Demand
+		for i, name := range names {
+			needed, err := n.layerNeeded(ctx, src, x, layers[i], demand)
+			if err != nil {
+				return nil, nil, err
+			}
+			if needed {
+				inDemand = inDemand.Union(demand.Under(name))
+			}
+		}
+		if len(inDemand) == 0 {
+			// the entries are not known, or none of them are needed.
+			inDemand = wantdag.DemandAll()
+		}
+		return node.Inputs, []Demand{inDemand}, nil
+	}
+	var inputs []NodeInput
+	var demands []Demand
+	for _, in := range node.Inputs {
+		needed, err := n.layerNeeded(ctx, src, x, in.Node, demand)
+		if err != nil {
+			return nil, nil, err
+		}
+		if needed {
+			inputs = append(inputs, in)
+			demands = append(demands, demand)
+		}
+	}
+	if len(inputs) == 0 {
+		// a merge needs at least one layer, and any of them could be chosen, so keep them all.
+		inputs = node.Inputs
+		demands = make([]Demand, len(inputs))
+		for i := range demands {
+			demands[i] = demand
+		}
+	}
+	return inputs, demands, nil
+}
+
+// layerNeeded returns false if the layer computed by id is known to only place content outside of demand.
+func (n narrower) layerNeeded(ctx context.Context, src cadata.Getter, x wantdag.DAG, id wantdag.NodeID, demand Demand) (bool, error) {
+	p, ok, err := n.placePath(ctx, src, x, x[id])
+	if err != nil || !ok {
+		return true, err
+	}
+	return len(demand.Within(p)) > 0, nil
+}
+
+// entries returns the names of the entries in the tree output by the node id, and the node which computes each of them.
+// It looks through merges of layers placed at a single name, which is how compute inputs are assembled.
+// entries returns nothing if the entries are not known before execution.
+func (n narrower) entries(ctx context.Context, src cadata.Getter, x wantdag.DAG, id wantdag.NodeID) (names []string, nodes []wantdag.NodeID, _ error) {
+	node := x[id]
+	switch node.Op {
+	case n.prefix + OpMerge:
+		for _, in := range node.Inputs {
+			if in.Name == "" {
+				return nil, nil, nil
+			}
+			names2, nodes2, err := n.entries(ctx, src, x, in.Node)
+			if err != nil || names2 == nil {
+				return nil, nil, err
+			}
+			names = append(names, names2...)
+			nodes = append(nodes, nodes2...)
+		}
+		return names, nodes, nil
+	case n.prefix + OpPlace:
+		p, ok, err := n.placePath(ctx, src, x, node)
+		if err != nil || !ok || p == "" || strings.Contains(p, "/") {
+			return nil, nil, err
+		}
+		for _, in := range node.Inputs {
+			if in.Name == "x" {
+				return []string{p}, []wantdag.NodeID{in.Node}, nil
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// lookupFact returns the ref at name in the tree formed by ins, if it is a fact in x.
+// Unnamed inputs are searched through merges of placed layers, the way compute inputs are built.
+// It returns nil if the ref is not known before execution.
+func (n narrower) lookupFact(ctx context.Context, src cadata.Getter, x wantdag.DAG, ins []NodeInput, name string) (*glfs.Ref, error) {
+	for _, in := range ins {
+		node := x[in.Node]
+		switch {
+		case in.Name == name && node.IsFact():
+			return node.Value, nil
+		case in.Name != "":
+			continue
+		case node.Op == n.prefix+OpMerge:
+			if ref, err := n.lookupFact(ctx, src, x, unnamed(node.Inputs), name); err != nil || ref != nil {
+				return ref, err
+			}
+		case node.Op == n.prefix+OpPlace:
+			p, ok, err := n.placePath(ctx, src, x, node)
+			if err != nil {
+				return nil, err
+			}
+			if !ok || p != glfs.CleanPath(name) {
+				continue
+			}
+			for _, in2 := range node.Inputs {
+				if in2.Name == "x" && x[in2.Node].IsFact() {
+					return x[in2.Node].Value, nil
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// placePath returns the path that node places its input at, if node is a place with a fact for its path.
+func (n narrower) placePath(ctx context.Context, src cadata.Getter, x wantdag.DAG, node wantdag.Node) (string, bool, error) {
+	if node.Op != n.prefix+OpPlace {
+		return "", false, nil
+	}
+	ref, err := n.lookupFact(ctx, src, x, node.Inputs, "path")
+	if err != nil || ref == nil || ref.Type != glfs.TypeBlob {
+		return "", false, err
+	}
+	data, err := glfs.GetBlobBytes(ctx, src, *ref, MaxPathLen)
+	if err != nil {
+		return "", false, err
+	}
+	return glfs.CleanPath(string(data)), true, nil
+}
+
+// unnamed returns the layers of a merge, which are each at the root of its output.
+func unnamed(ins []NodeInput) []NodeInput {
+	ret := make([]NodeInput, len(ins))
+	for i, in := range ins {
+		ret[i] = NodeInput{Node: in.Node}
+	}
+	return ret
+}
+
+// pathSetDemand returns a Demand which contains every path in q.
+// It may contain more paths than q, if q cannot be expressed exactly.
+func pathSetDemand(q wantcfg.PathSet) Demand {
+	switch {
+	case q.Unit != nil:
+		return wantdag.DemandPaths(*q.Unit)
+	case q.Prefix != nil:
+		// a prefix may end part way through a name, so only the directories before it are certain.
+		p := *q.Prefix
+		if i := strings.LastIndex(p, "/"); i >= 0 {
+			return wantdag.DemandPaths(p[:i])
+		}
+		return wantdag.DemandAll()
+	case q.Glob != nil, q.Regex != nil:
+		// every path in a pattern starts with its literal prefix.
+		return pathSetDemand(wantcfg.Prefix(stringsets.BoundingPrefix(stringsets.FromPathSet(q))))
+	case q.Union != nil:
+		var ret Demand
+		for _, q2 := range q.Union {
+			ret = ret.Union(pathSetDemand(q2))
+		}
+		return ret
+	case q.Intersect != nil:
+		ret := wantdag.DemandAll()
+		for _, q2 := range q.Intersect {
+			ret = ret.Intersect(pathSetDemand(q2))
+		}
+		return ret
+	default:
+		return wantdag.DemandAll()
+	}
+}
+
+// orAll returns d, or all of the output if d is empty.
+// It is used for inputs which an operation requires, even if none of their content is needed.
+func orAll(d Demand) Demand {
+	if len(d) == 0 {
+		return wantdag.DemandAll()
+	}
+	return d
+}
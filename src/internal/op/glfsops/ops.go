@@ -6,12 +6,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 
 	"blobcache.io/glfs"
 	"go.brendoncarroll.net/state/cadata"
 
+	"wantbuild.io/want/src/internal/glfsarchive"
 	"wantbuild.io/want/src/internal/glfstasks"
 	"wantbuild.io/want/src/internal/stringsets"
 	"wantbuild.io/want/src/internal/wantdag"
@@ -33,6 +35,7 @@ const (
 	OpFilterPathSet = OpName("filterPathSet")
 	OpChmod         = OpName("chmod")
 	OpDiff          = OpName("diff")
+	OpArchive       = OpName("archive")
 )
 
 const MaxPathLen = 4096
@@ -45,6 +48,7 @@ var ops = map[OpName]Operator{
 	OpFilterPathSet: FilterPathSet,
 	OpChmod:         Chmod,
 	OpDiff:          Diff,
+	OpArchive:       Archive,
 }
 
 type Operator func(ctx context.Context, dst cadata.PostExister, src cadata.Getter, x glfs.Ref) (*glfs.Ref, error)
@@ -230,3 +234,33 @@ func NewErrInvalidInput(input glfs.Ref, msg string) ErrInvalidInput {
 func (e ErrInvalidInput) Error() string {
 	return fmt.Sprintf("invalid input. input=%v msg=%v", e.Input, e.Msg)
 }
+
+// Archive writes the tree x to a blob, as an archive in the format specified by the format blob.
+func Archive(ctx context.Context, dst cadata.PostExister, src cadata.Getter, inputRef glfs.Ref) (*glfs.Ref, error) {
+	inputTree, err := glfs.GetTreeSlice(ctx, src, inputRef, 1e6)
+	if err != nil {
+		return nil, err
+	}
+	xent := glfs.Lookup(inputTree, "x")
+	if xent == nil {
+		return nil, errors.New("no target")
+	}
+	formatEnt := glfs.Lookup(inputTree, "format")
+	if formatEnt == nil {
+		return nil, errors.New("no format")
+	}
+	formatBytes, err := glfs.GetBlobBytes(ctx, src, formatEnt.Ref, 64)
+	if err != nil {
+		return nil, fmt.Errorf("archive: while reading format %w", err)
+	}
+	format, err := glfsarchive.ParseFormat(string(formatBytes))
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(glfsarchive.Write(ctx, src, xent.Ref, format, pw))
+	}()
+	defer pr.Close()
+	return glfs.PostBlob(ctx, dst, pr)
+}
@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sync"
 
 	"blobcache.io/glfs"
 	"go.brendoncarroll.net/state/cadata"
@@ -22,6 +23,7 @@ const (
 
 	OpMakeExec     = wantjob.OpName("makeExec")
 	OpMakeTestExec = wantjob.OpName("makeTestExec")
+	OpTest         = wantjob.OpName("test")
 )
 
 const (
@@ -34,6 +36,8 @@ type Executor struct {
 	goRoot     string
 	scratchDir string
 	buildSem   *semaphore.Weighted
+
+	toolchainMu sync.Mutex
 }
 
 func NewExecutor(goRoot string, scratchDir string) *Executor {
@@ -67,6 +71,14 @@ func (e *Executor) Execute(jc wantjob.Ctx, src cadata.Getter, task wantjob.Task)
 			}
 			return e.MakeTestExec(jc, src, *mtet)
 		})
+	case OpTest:
+		return glfstasks.Exec(task.Input, func(x glfs.Ref) (*glfs.Ref, error) {
+			tt, err := GetTestTask(ctx, src, x)
+			if err != nil {
+				return nil, err
+			}
+			return e.Test(jc, src, *tt)
+		})
 	case OpModDownload:
 		return glfstasks.Exec(task.Input, func(x glfs.Ref) (*glfs.Ref, error) {
 			return e.ModDownload(jc, src, x)
@@ -77,6 +89,8 @@ func (e *Executor) Execute(jc wantjob.Ctx, src cadata.Getter, task wantjob.Task)
 }
 
 type goConfig struct {
+	// GOROOT is the toolchain to use, if empty the Executor's default is used.
+	GOROOT     string
 	GOARCH     string
 	GOOS       string
 	GOMODCACHE string
@@ -85,6 +99,9 @@ type goConfig struct {
 
 func (e *Executor) newCommand(ctx context.Context, cfg goConfig, args ...string) *exec.Cmd {
 	goRoot := e.goRoot
+	if cfg.GOROOT != "" {
+		goRoot = cfg.GOROOT
+	}
 	cmd := exec.CommandContext(ctx, filepath.Join(goRoot, "bin", "go"), args...)
 	cmd.Env = []string{
 		"PATH=/usr/bin/",
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"blobcache.io/glfs"
 	"github.com/stretchr/testify/require"
 	"go.brendoncarroll.net/state/cadata"
 
@@ -36,6 +37,39 @@ func TestMakeExec(t *testing.T) {
 	t.Log(ref)
 }
 
+func TestBuildArgs(t *testing.T) {
+	require.Equal(t, []string{"-trimpath", "-ldflags", "-s -w -buildid=", "-buildvcs=false"}, MakeExecConfig{}.buildArgs())
+
+	trimPath := false
+	cfg := MakeExecConfig{
+		Tags:     []string{"netgo", "osusergo"},
+		LDFlags:  "-extldflags=-static",
+		X:        map[string]string{"main.version": "v1.0.0", "main.commit": "abc"},
+		TrimPath: &trimPath,
+		BuildVCS: "auto",
+	}
+	require.Equal(t, []string{
+		"-ldflags", "-s -w -buildid= -X 'main.commit=abc' -X 'main.version=v1.0.0' -extldflags=-static",
+		"-buildvcs=auto",
+		"-tags", "netgo,osusergo",
+	}, cfg.buildArgs())
+
+	task := MakeExecTask{MakeExecConfig: MakeExecConfig{GOOS: "linux", GOARCH: "amd64"}}
+	require.NoError(t, task.Validate())
+	task.X = map[string]string{"main.version": "it's"}
+	require.Error(t, task.Validate())
+}
+
+func TestInstallSnippetVersion(t *testing.T) {
+	_, err := installSnippetVersion("1.22.0\"", "")
+	require.Error(t, err)
+	_, err = installSnippetVersion("1.22.0", "not-a-hash")
+	require.Error(t, err)
+	snippet, err := installSnippetVersion("1.22.0", "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393")
+	require.NoError(t, err)
+	require.Contains(t, snippet, `local goVersion = "1.22.0";`)
+}
+
 func TestMakeTestExec(t *testing.T) {
 	jc, s, e := setupTest(t, true)
 	ref, err := e.MakeTestExec(jc, s, MakeTestExecTask{
@@ -64,6 +98,45 @@ func TestMakeTestExec(t *testing.T) {
 	t.Log(ref)
 }
 
+func TestTest(t *testing.T) {
+	jc, s, e := setupTest(t, true)
+	module := testutil.PostFS(t, s, map[string][]byte{
+		"go.mod": []byte("module test-module"),
+		"go.sum": []byte(""),
+		"a/a.go": []byte(`package a
+			func F() int { return 1 }
+		`),
+		"a/a_test.go": []byte(`package a
+			import "testing"
+
+			func TestF(t *testing.T) {
+				if F() != 1 {
+					t.Fatal("wrong")
+				}
+			}
+		`),
+		"b/b_test.go": []byte(`package b
+			import "testing"
+
+			func TestFail(t *testing.T) {
+				t.Error("boom")
+			}
+		`),
+	})
+	_, err := e.Test(jc, s, TestTask{Module: module})
+	require.ErrorContains(t, err, "--- FAIL: test-module/b TestFail")
+
+	ref, err := e.Test(jc, s, TestTask{
+		Module:     module,
+		TestConfig: TestConfig{Cover: true, AllowFailure: true},
+	})
+	require.NoError(t, err)
+	for _, p := range []string{"summary.json", "junit.xml", "coverage.out", "results/test-module/a/TestF.json"} {
+		_, err := glfs.GetAtPath(jc.Context, s, *ref, p)
+		require.NoError(t, err, p)
+	}
+}
+
 func TestTest2JSON(t *testing.T) {
 	jc, s, e := setupTest(t, true)
 	input := testutil.PostBlob(t, s, []byte(`=== RUN   TestMakeExec
@@ -7,6 +7,8 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"blobcache.io/glfs"
 	"go.brendoncarroll.net/state/cadata"
@@ -28,6 +30,21 @@ func (t MakeExecTask) Validate() error {
 	if t.GOOS == "" {
 		return errors.New("GOOS must be set")
 	}
+	for _, tag := range t.Tags {
+		if tag == "" || strings.ContainsAny(tag, ", \t\n") {
+			return fmt.Errorf("invalid build tag %q", tag)
+		}
+	}
+	for k, v := range t.X {
+		if strings.ContainsAny(k+v, "'\"\n") || strings.ContainsAny(k, "= ") {
+			return fmt.Errorf("invalid -X flag %s=%s", k, v)
+		}
+	}
+	switch t.BuildVCS {
+	case "", "true", "false", "auto":
+	default:
+		return fmt.Errorf("buildvcs must be true, false or auto. HAVE: %q", t.BuildVCS)
+	}
 	return nil
 }
 
@@ -35,6 +52,53 @@ type MakeExecConfig struct {
 	GOARCH string `json:"GOARCH"`
 	GOOS   string `json:"GOOS"`
 	Main   string `json:"main"`
+
+	// GoVersion selects the Go toolchain, which is installed on first use.
+	// If empty, the default toolchain is used.
+	GoVersion string `json:"goVersion,omitempty"`
+	// GoDistHash is the SHA256 of the Go distribution for the host platform.
+	// It is only required for versions which Want does not know the hash of.
+	GoDistHash string `json:"goDistHash,omitempty"`
+
+	// Tags are passed to -tags
+	Tags []string `json:"tags,omitempty"`
+	// LDFlags are appended to the default linker flags "-s -w -buildid="
+	LDFlags string `json:"ldflags,omitempty"`
+	// X sets string variables with the linker's -X flag, e.g. {"main.version": "v1.0.0"}
+	X map[string]string `json:"x,omitempty"`
+	// TrimPath defaults to true
+	TrimPath *bool `json:"trimpath,omitempty"`
+	// BuildVCS is passed to -buildvcs, it defaults to false.
+	BuildVCS string `json:"buildvcs,omitempty"`
+}
+
+// buildArgs returns the flags for go build
+func (c MakeExecConfig) buildArgs() []string {
+	ldflags := "-s -w -buildid="
+	xkeys := make([]string, 0, len(c.X))
+	for k := range c.X {
+		xkeys = append(xkeys, k)
+	}
+	sort.Strings(xkeys)
+	for _, k := range xkeys {
+		ldflags += fmt.Sprintf(" -X '%s=%s'", k, c.X[k])
+	}
+	if c.LDFlags != "" {
+		ldflags += " " + c.LDFlags
+	}
+	buildVCS := c.BuildVCS
+	if buildVCS == "" {
+		buildVCS = "false"
+	}
+	var args []string
+	if c.TrimPath == nil || *c.TrimPath {
+		args = append(args, "-trimpath")
+	}
+	args = append(args, "-ldflags", ldflags, "-buildvcs="+buildVCS)
+	if len(c.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(c.Tags, ","))
+	}
+	return args
 }
 
 func GetMakeExecTask(ctx context.Context, s cadata.Getter, x glfs.Ref) (*MakeExecTask, error) {
@@ -120,18 +184,18 @@ func (e *Executor) MakeExec(jc wantjob.Ctx, src cadata.Getter, task MakeExecTask
 		}
 	}
 
-	args := []string{"build",
-		"-v",
-		"-o", outPath,
-		"-trimpath",
-		"-ldflags", `-s -w -buildid=`,
-		"-buildvcs=false",
+	goRoot, err := e.goRootFor(jc, task.GoVersion, task.GoDistHash)
+	if err != nil {
+		return nil, err
 	}
+	args := []string{"build", "-v", "-o", outPath}
+	args = append(args, task.buildArgs()...)
 	if entryPath != "" {
 		args = append(args, entryPath)
 	}
 	jc.Infof("go %v", args)
 	cmd := e.newCommand(ctx, goConfig{
+		GOROOT:     goRoot,
 		GOARCH:     task.GOARCH,
 		GOOS:       task.GOOS,
 		GOMODCACHE: filepath.Join(dir, "modcache"),
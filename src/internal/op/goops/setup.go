@@ -5,15 +5,95 @@ package goops
 import (
 	_ "embed"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
 	"runtime"
+
+	"wantbuild.io/want/src/internal/wantsetup"
+	"wantbuild.io/want/src/wantjob"
 )
 
 //go:embed setup.jsonnet
 var setupJsonnet string
 
+// goDistHashes are the SHA256 hashes of the Go distributions which can be installed without providing a hash.
+// The keys are <version>-<goos>-<goarch>.
+var goDistHashes = map[string]string{
+	"1.23.4-linux-amd64":  "6924efde5de86fe277676e929dc9917d466efa02fb934197bc2eba35d5680971",
+	"1.23.4-darwin-arm64": "87d2bb0ad4fe24d2a0685a55df321e0efe4296419a9b3de03369dbe60b8acd3a",
+}
+
+var (
+	goVersionRegexp = regexp.MustCompile(`^[0-9]+\.[0-9]+(\.[0-9]+)?((rc|beta)[0-9]+)?$`)
+	sha256Regexp    = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// InstallSnippet returns a snippet which evaluates to the default Go toolchain for this platform.
 func InstallSnippet() string {
+	snippet, err := installSnippetVersion(goVersion, "")
+	if err != nil {
+		return fmt.Sprintf("error %q", err.Error())
+	}
+	return snippet
+}
+
+// installSnippetVersion returns a snippet which evaluates to a Go toolchain for this platform.
+// If hash is empty, the known hash for the version is used.
+func installSnippetVersion(version, hash string) (string, error) {
+	if !goVersionRegexp.MatchString(version) {
+		return "", fmt.Errorf("invalid Go version %q", version)
+	}
+	if hash == "" {
+		var ok bool
+		if hash, ok = goDistHashes[version+"-"+runtime.GOOS+"-"+runtime.GOARCH]; !ok {
+			return "", fmt.Errorf("no known hash for go%s %s/%s, goDistHash must be set", version, runtime.GOOS, runtime.GOARCH)
+		}
+	}
+	if !sha256Regexp.MatchString(hash) {
+		return "", fmt.Errorf("goDistHash must be a hex encoded SHA256, HAVE: %q", hash)
+	}
 	return fmt.Sprintf(`local goarch = "%s";`, runtime.GOARCH) + "\n" +
 		fmt.Sprintf(`local goos = "%s";`, runtime.GOOS) + "\n" +
-		fmt.Sprintf(`local goVersion = "%s";`, goVersion) + "\n" +
-		setupJsonnet
+		fmt.Sprintf(`local goVersion = "%s";`, version) + "\n" +
+		fmt.Sprintf(`local goDistHash = "%s";`, hash) + "\n" +
+		setupJsonnet, nil
+}
+
+// goRootFor returns the GOROOT for a Go version, installing it if necessary.
+// The default version is installed when the Executor is created, and other versions are installed under the scratch dir.
+func (e *Executor) goRootFor(jc wantjob.Ctx, version, hash string) (string, error) {
+	if version == "" || (version == goVersion && hash == "") {
+		return e.goRoot, nil
+	}
+	snippet, err := installSnippetVersion(version, hash)
+	if err != nil {
+		return "", err
+	}
+	e.toolchainMu.Lock()
+	defer e.toolchainMu.Unlock()
+	dir := filepath.Join(e.scratchDir, "toolchains", "go"+version)
+	if hash != "" {
+		dir += "-" + hash[:16]
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	jc.Infof("installing go%s into %s", version, dir)
+	tmpDir := dir + ".tmp"
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
+		return "", err
+	}
+	if err := wantsetup.Install(jc.Context, jc.System, tmpDir, snippet); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return "", err
+	}
+	return dir, nil
 }
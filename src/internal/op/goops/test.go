@@ -0,0 +1,469 @@
+package goops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"blobcache.io/glfs"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/internal/glfsport"
+	"wantbuild.io/want/src/wantjob"
+)
+
+// TestRuntimeWASIp1 compiles the tests for wasip1 and runs them in a WebAssembly sandbox.
+const TestRuntimeWASIp1 = "wasip1"
+
+type TestTask struct {
+	Module   glfs.Ref
+	ModCache *glfs.Ref
+	TestConfig
+}
+
+type TestConfig struct {
+	// Runtime is where the tests are run.  The only runtime is TestRuntimeWASIp1, which is the default.
+	// Tests cannot access the host's filesystem or network.
+	Runtime string `json:"runtime,omitempty"`
+	// Packages are the package patterns to test, relative to the module. Defaults to ./...
+	Packages []string `json:"packages,omitempty"`
+	// Run is passed to -run
+	Run string `json:"run,omitempty"`
+	// Tags are passed to -tags
+	Tags []string `json:"tags,omitempty"`
+	// Cover enables coverage, and produces coverage.out
+	Cover bool `json:"cover,omitempty"`
+	// Timeout is passed to -timeout, e.g. "10m"
+	Timeout string `json:"timeout,omitempty"`
+
+	GoVersion  string `json:"goVersion,omitempty"`
+	GoDistHash string `json:"goDistHash,omitempty"`
+
+	// AllowFailure produces the results even if tests fail, instead of failing the job.
+	AllowFailure bool `json:"allowFailure,omitempty"`
+}
+
+func (c TestConfig) Validate() error {
+	switch c.Runtime {
+	case "", TestRuntimeWASIp1:
+	default:
+		return fmt.Errorf("runtime must be %s. HAVE: %q", TestRuntimeWASIp1, c.Runtime)
+	}
+	for _, p := range c.Packages {
+		if p != "." && p != "./..." && !strings.HasPrefix(p, "./") {
+			return fmt.Errorf("package pattern %q must start with ./", p)
+		}
+		if !filepath.IsLocal(strings.TrimSuffix(p, "/...")) {
+			return fmt.Errorf("package pattern %q is not local", p)
+		}
+	}
+	for _, tag := range c.Tags {
+		if tag == "" || strings.ContainsAny(tag, ", \t\n") {
+			return fmt.Errorf("invalid build tag %q", tag)
+		}
+	}
+	return nil
+}
+
+func (c TestConfig) packages() []string {
+	if len(c.Packages) == 0 {
+		return []string{"./..."}
+	}
+	return c.Packages
+}
+
+func GetTestTask(ctx context.Context, s cadata.Getter, x glfs.Ref) (*TestTask, error) {
+	moduleRef, err := glfs.GetAtPath(ctx, s, x, "module")
+	if err != nil {
+		return nil, err
+	}
+	modcacheRef, err := glfs.GetAtPath(ctx, s, x, "modcache")
+	if err != nil && !glfs.IsErrNoEnt(err) {
+		return nil, err
+	}
+	configRef, err := glfs.GetAtPath(ctx, s, x, "config.json")
+	if err != nil {
+		return nil, err
+	}
+	configData, err := glfs.GetBlobBytes(ctx, s, *configRef, 1e6)
+	if err != nil {
+		return nil, err
+	}
+	var config TestConfig
+	if err := json.Unmarshal(configData, &config); err != nil {
+		return nil, err
+	}
+	return &TestTask{
+		Module:     *moduleRef,
+		ModCache:   modcacheRef,
+		TestConfig: config,
+	}, nil
+}
+
+func PostTestTask(ctx context.Context, s cadata.PostExister, x TestTask) (*glfs.Ref, error) {
+	data, err := json.Marshal(x.TestConfig)
+	if err != nil {
+		return nil, err
+	}
+	configRef, err := glfs.PostBlob(ctx, s, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	ents := []glfs.TreeEntry{
+		{Name: "module", FileMode: 0o777, Ref: x.Module},
+		{Name: "config.json", FileMode: 0o777, Ref: *configRef},
+	}
+	if x.ModCache != nil {
+		ents = append(ents, glfs.TreeEntry{Name: "modcache", FileMode: 0o777, Ref: *x.ModCache})
+	}
+	return glfs.PostTreeSlice(ctx, s, ents)
+}
+
+// Test runs the tests in a module, and returns a tree with the results:
+//   - summary.json contains the counts, and the result of each package.
+//   - results/<package>/<test>.json contains the result of each test, including its output.
+//   - junit.xml contains all the results in the JUnit XML format.
+//   - coverage.out is the coverage profile for all the packages, if Cover is set.
+//
+// The durations of tests are only written to the job log, so the results are the same for every run.
+// If any tests fail, Test returns an error describing the failures, unless AllowFailure is set.
+func (e *Executor) Test(jc wantjob.Ctx, src cadata.Getter, task TestTask) (*glfs.Ref, error) {
+	ctx := jc.Context
+	if err := task.Validate(); err != nil {
+		return nil, err
+	}
+	dir, cleanup, err := e.mkdirTemp(ctx, "test-")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	exp := glfsport.Exporter{
+		Dir:   dir,
+		Cache: glfsport.NullCache{},
+		Store: src,
+	}
+	if err := exp.Export(ctx, task.Module, "in"); err != nil {
+		return nil, err
+	}
+	if task.ModCache != nil {
+		if err := exp.Export(ctx, *task.ModCache, "modcache"); err != nil {
+			return nil, err
+		}
+	}
+	goRoot, err := e.goRootFor(jc, task.GoVersion, task.GoDistHash)
+	if err != nil {
+		return nil, err
+	}
+	tr := testRun{
+		e:      e,
+		jc:     jc,
+		task:   task,
+		dir:    dir,
+		goRoot: goRoot,
+		c:      newTestCollector(),
+	}
+	df := jc.InfoSpan("go test")
+	coverage, err := tr.runWASIp1()
+	df()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := tr.c.summary()
+	tests := tr.c.tests()
+	jc.Infof("%s", formatSummary(sum, tests, true))
+	if !sum.OK() && !task.AllowFailure {
+		return nil, errors.New(formatSummary(sum, tests, false))
+	}
+	return postTestResults(ctx, jc.Dst, sum, tests, coverage)
+}
+
+// testRun holds the state for running the tests in a single TestTask.
+type testRun struct {
+	e      *Executor
+	jc     wantjob.Ctx
+	task   TestTask
+	dir    string
+	goRoot string
+	c      *testCollector
+}
+
+func (tr *testRun) goConfig(goos, goarch string) goConfig {
+	return goConfig{
+		GOROOT:     tr.goRoot,
+		GOOS:       goos,
+		GOARCH:     goarch,
+		GOMODCACHE: filepath.Join(tr.dir, "modcache"),
+	}
+}
+
+// buildArgs are the flags shared by go test and go list.
+func (tr *testRun) buildArgs() []string {
+	args := []string{"-trimpath", "-buildvcs=false"}
+	if len(tr.task.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(tr.task.Tags, ","))
+	}
+	return args
+}
+
+// listedPackage is the subset of go list -json used to find test packages.
+type listedPackage struct {
+	ImportPath   string
+	Dir          string
+	TestGoFiles  []string
+	XTestGoFiles []string
+	Error        *struct{ Err string }
+}
+
+// runWASIp1 compiles a test binary for each package, and runs each one with wazero.
+// The output of each binary is converted to events with go tool test2json.
+func (tr *testRun) runWASIp1() ([]byte, error) {
+	ctx := tr.jc.Context
+	inDir := filepath.Join(tr.dir, "in")
+	cfg := tr.goConfig("wasip1", "wasm")
+
+	args := append([]string{"list", "-e", "-json"}, tr.buildArgs()...)
+	args = append(args, tr.task.packages()...)
+	cmd := tr.e.newCommand(ctx, cfg, args...)
+	cmd.Dir = inDir
+	cmd.Stderr = tr.jc.Writer("stderr")
+	listOut, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %w", err)
+	}
+	var pkgs []listedPackage
+	dec := json.NewDecoder(bytes.NewReader(listOut))
+	for dec.More() {
+		var pkg listedPackage
+		if err := dec.Decode(&pkg); err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+	}
+
+	var profiles [][]byte
+	for _, pkg := range pkgs {
+		if pkg.Error != nil {
+			tr.c.failPackage(pkg.ImportPath, pkg.Error.Err+"\n")
+			continue
+		}
+		if len(pkg.TestGoFiles)+len(pkg.XTestGoFiles) == 0 {
+			tr.c.add(testEvent{Action: "skip", Package: pkg.ImportPath})
+			tr.c.add(testEvent{Action: "output", Package: pkg.ImportPath, Output: "?   \t" + pkg.ImportPath + "\t[no test files]\n"})
+			continue
+		}
+		profile, err := tr.runWASIp1Package(pkg)
+		if err != nil {
+			return nil, err
+		}
+		if profile != nil {
+			profiles = append(profiles, profile)
+		}
+	}
+	if !tr.task.Cover {
+		return nil, nil
+	}
+	return mergeCoverProfiles(profiles...)
+}
+
+// runWASIp1Package builds and runs the tests for a single package, and returns its coverage profile.
+func (tr *testRun) runWASIp1Package(pkg listedPackage) ([]byte, error) {
+	ctx := tr.jc.Context
+	inDir := filepath.Join(tr.dir, "in")
+	relDir, err := filepath.Rel(inDir, pkg.Dir)
+	if err != nil || !filepath.IsLocal(relDir) {
+		return nil, fmt.Errorf("package %s is outside of the module", pkg.ImportPath)
+	}
+	pkgDir, cleanup, err := tr.e.mkdirTemp(ctx, "testpkg-")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	binPath := filepath.Join(pkgDir, "test.wasm")
+	args := []string{"test", "-c", "-o", binPath}
+	args = append(args, tr.buildArgs()...)
+	if tr.task.Cover {
+		args = append(args, "-cover")
+	}
+	args = append(args, "./"+filepath.ToSlash(relDir))
+	cmd := tr.e.newCommand(ctx, tr.goConfig("wasip1", "wasm"), args...)
+	cmd.Dir = inDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, err
+		}
+		tr.c.failPackage(pkg.ImportPath, string(out)+"FAIL\t"+pkg.ImportPath+" [build failed]\n")
+		return nil, nil
+	}
+	program, err := os.ReadFile(binPath)
+	if err != nil {
+		return nil, err
+	}
+
+	outDir := filepath.Join(pkgDir, "output")
+	tmpDir := filepath.Join(pkgDir, "tmp")
+	for _, d := range []string{outDir, tmpDir} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	testArgs := []string{"-test.v=test2json", "-test.paniconexit0"}
+	if tr.task.Run != "" {
+		testArgs = append(testArgs, "-test.run="+tr.task.Run)
+	}
+	if tr.task.Timeout != "" {
+		testArgs = append(testArgs, "-test.timeout="+tr.task.Timeout)
+	}
+	if tr.task.Cover {
+		testArgs = append(testArgs, "-test.coverprofile=/output/coverage.out")
+	}
+	var stdout bytes.Buffer
+	exitCode, err := runWASIp1Test(ctx, program, testArgs, wasip1TestEnv{
+		ModuleDir: inDir,
+		WorkDir:   path.Join("/module", filepath.ToSlash(relDir)),
+		OutputDir: outDir,
+		TmpDir:    tmpDir,
+		Stdout:    &stdout,
+		Stderr:    tr.jc.Writer("stderr"),
+	})
+	if err != nil {
+		tr.c.failPackage(pkg.ImportPath, stdout.String()+err.Error()+"\n")
+		return nil, nil
+	}
+
+	// convert the test output to events.
+	cmd = tr.e.newCommand(ctx, goConfig{GOROOT: tr.goRoot}, "tool", "test2json", "-t", "-p", pkg.ImportPath)
+	cmd.Stdin = &stdout
+	cmd.Stderr = tr.jc.Writer("stderr")
+	events, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go tool test2json: %w", err)
+	}
+	if _, err := tr.c.readJSON(bytes.NewReader(events)); err != nil {
+		return nil, err
+	}
+	if exitCode != 0 {
+		tr.c.failPackage(pkg.ImportPath, fmt.Sprintf("exit status %d\n", exitCode))
+	}
+	if !tr.task.Cover {
+		return nil, nil
+	}
+	profile, err := os.ReadFile(filepath.Join(outDir, "coverage.out"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return profile, err
+}
+
+type wasip1TestEnv struct {
+	// ModuleDir is mounted read only at /module
+	ModuleDir string
+	// WorkDir is the working directory of the test, within /module.
+	WorkDir string
+	// OutputDir is mounted at /output
+	OutputDir string
+	// TmpDir is mounted at /tmp
+	TmpDir string
+
+	Stdout, Stderr io.Writer
+}
+
+// runWASIp1Test runs a test binary with wazero, and returns its exit code.
+func runWASIp1Test(ctx context.Context, program []byte, args []string, env wasip1TestEnv) (int, error) {
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	defer r.Close(ctx)
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		return 0, err
+	}
+	fsCfg := wazero.NewFSConfig().
+		WithReadOnlyDirMount(env.ModuleDir, "/module").
+		WithDirMount(env.OutputDir, "/output").
+		WithDirMount(env.TmpDir, "/tmp")
+	cfg := wazero.NewModuleConfig().
+		WithName("").
+		WithStdout(env.Stdout).
+		WithStderr(env.Stderr).
+		WithFSConfig(fsCfg).
+		WithArgs(append([]string{"test.wasm"}, args...)...).
+		WithEnv("PWD", env.WorkDir).
+		WithEnv("TMPDIR", "/tmp").
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep()
+	_, err := r.InstantiateWithConfig(ctx, program, cfg)
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) {
+		return int(exitErr.ExitCode()), nil
+	}
+	return 0, err
+}
+
+// postTestResults posts the output of a Test
+func postTestResults(ctx context.Context, s cadata.PostExister, sum TestSummary, tests []TestResult, coverage []byte) (*glfs.Ref, error) {
+	var ents []glfs.TreeEntry
+	putFile := func(p string, data []byte) error {
+		ref, err := glfs.PostBlob(ctx, s, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		ents = append(ents, glfs.TreeEntry{Name: p, FileMode: 0o644, Ref: *ref})
+		return nil
+	}
+	for _, r := range tests {
+		data, err := json.MarshalIndent(r, "", " ")
+		if err != nil {
+			return nil, err
+		}
+		if err := putFile(path.Join("results", r.Package, testResultPath(r.Test)), data); err != nil {
+			return nil, err
+		}
+	}
+	sumData, err := json.MarshalIndent(sum, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	if err := putFile("summary.json", sumData); err != nil {
+		return nil, err
+	}
+	var junit bytes.Buffer
+	if err := writeJUnit(&junit, sum.Packages, tests); err != nil {
+		return nil, err
+	}
+	if err := putFile("junit.xml", junit.Bytes()); err != nil {
+		return nil, err
+	}
+	if coverage != nil {
+		if err := putFile("coverage.out", coverage); err != nil {
+			return nil, err
+		}
+	}
+	return glfs.PostTreeSlice(ctx, s, ents)
+}
+
+// testResultPath returns the path of the result file for a test, relative to its package.
+// Subtests are placed in a directory named after their parent.
+// Path elements which are not valid names are escaped.
+func testResultPath(test string) string {
+	parts := strings.Split(test, "/")
+	for i, part := range parts {
+		switch part {
+		case "", ".", "..":
+			parts[i] = strings.NewReplacer(".", "%2E").Replace(part) + "%"
+		}
+	}
+	return strings.Join(parts, "/") + ".json"
+}
@@ -0,0 +1,439 @@
+package goops
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// testEvent is an event emitted by go test -json, see go doc test2json.
+type testEvent struct {
+	Time        time.Time `json:"Time"`
+	Action      string    `json:"Action"`
+	Package     string    `json:"Package"`
+	ImportPath  string    `json:"ImportPath"`
+	Test        string    `json:"Test"`
+	Elapsed     float64   `json:"Elapsed"`
+	Output      string    `json:"Output"`
+	FailedBuild string    `json:"FailedBuild"`
+}
+
+// TestResult is the outcome of a single test, or of a package when Test is empty.
+type TestResult struct {
+	Package string `json:"package"`
+	Test    string `json:"test,omitempty"`
+	// Action is one of pass, fail or skip.
+	Action string `json:"action"`
+	// Elapsed is the duration in seconds.
+	// It is only written to the job log, since it is different for every run.
+	Elapsed float64 `json:"-"`
+	Output  string  `json:"output"`
+}
+
+// TestSummary counts the results of a test run.
+type TestSummary struct {
+	Passed   int          `json:"passed"`
+	Failed   int          `json:"failed"`
+	Skipped  int          `json:"skipped"`
+	Packages []TestResult `json:"packages"`
+}
+
+// OK returns true if no tests or packages failed.
+func (s TestSummary) OK() bool {
+	if s.Failed > 0 {
+		return false
+	}
+	for _, pkg := range s.Packages {
+		if pkg.Action == "fail" {
+			return false
+		}
+	}
+	return true
+}
+
+// testCollector accumulates test events into results.
+type testCollector struct {
+	pkgs map[string]*testPackage
+	// buildOutput is keyed by the ImportPath of build-output events.
+	buildOutput map[string]*strings.Builder
+}
+
+type testPackage struct {
+	result TestResult
+	output strings.Builder
+	tests  map[string]*testCase
+}
+
+type testCase struct {
+	result TestResult
+	output strings.Builder
+}
+
+func newTestCollector() *testCollector {
+	return &testCollector{
+		pkgs:        map[string]*testPackage{},
+		buildOutput: map[string]*strings.Builder{},
+	}
+}
+
+// readJSON adds the events from r, which is the output of go test -json.
+// Lines which are not events are returned, they are usually build errors.
+func (c *testCollector) readJSON(r io.Reader) (string, error) {
+	var other strings.Builder
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<24)
+	for sc.Scan() {
+		line := sc.Bytes()
+		var ev testEvent
+		if !bytes.HasPrefix(line, []byte("{")) || json.Unmarshal(line, &ev) != nil {
+			other.Write(line)
+			other.WriteString("\n")
+			continue
+		}
+		c.add(ev)
+	}
+	return other.String(), sc.Err()
+}
+
+func (c *testCollector) pkg(name string) *testPackage {
+	p := c.pkgs[name]
+	if p == nil {
+		p = &testPackage{
+			result: TestResult{Package: name},
+			tests:  map[string]*testCase{},
+		}
+		c.pkgs[name] = p
+	}
+	return p
+}
+
+func (c *testCollector) add(ev testEvent) {
+	switch ev.Action {
+	case "build-output":
+		b := c.buildOutput[ev.ImportPath]
+		if b == nil {
+			b = &strings.Builder{}
+			c.buildOutput[ev.ImportPath] = b
+		}
+		b.WriteString(ev.Output)
+		return
+	case "build-fail":
+		return
+	}
+	if ev.Package == "" {
+		return
+	}
+	p := c.pkg(ev.Package)
+	if ev.Test == "" {
+		switch ev.Action {
+		case "output":
+			p.output.WriteString(stripElapsed(ev.Output))
+		case "pass", "fail", "skip":
+			p.result.Action = ev.Action
+			p.result.Elapsed = ev.Elapsed
+			if b := c.buildOutput[ev.FailedBuild]; b != nil {
+				p.output.WriteString(b.String())
+			}
+		}
+		return
+	}
+	tc := p.tests[ev.Test]
+	if tc == nil {
+		tc = &testCase{result: TestResult{Package: ev.Package, Test: ev.Test}}
+		p.tests[ev.Test] = tc
+	}
+	switch ev.Action {
+	case "output":
+		tc.output.WriteString(stripElapsed(ev.Output))
+	case "pass", "fail", "skip":
+		tc.result.Action = ev.Action
+		tc.result.Elapsed = ev.Elapsed
+	}
+}
+
+// elapsedRE matches the duration at the end of a test's result line, e.g. --- PASS: TestA (0.01s)
+var elapsedRE = regexp.MustCompile(`^(\s*--- [A-Z]+: .*) \(\d+\.\d+s\)(\n?)$`)
+
+// stripElapsed removes the duration from a line of test output, so the output is the same for every run.
+func stripElapsed(line string) string {
+	return elapsedRE.ReplaceAllString(line, "$1$2")
+}
+
+// failPackage marks a package as failed, if it did not report a result.
+// It is used when a test binary exits without printing a final result, and for build failures.
+func (c *testCollector) failPackage(name string, output string) {
+	p := c.pkg(name)
+	if p.result.Action == "" || p.result.Action == "pass" {
+		p.result.Action = "fail"
+	}
+	p.output.WriteString(output)
+}
+
+// packages returns the package results, sorted by package.
+func (c *testCollector) packages() []TestResult {
+	ret := make([]TestResult, 0, len(c.pkgs))
+	for _, p := range c.pkgs {
+		r := p.result
+		r.Output = p.output.String()
+		if r.Action == "" {
+			// the package never finished, e.g. the test binary crashed.
+			r.Action = "fail"
+		}
+		ret = append(ret, r)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Package < ret[j].Package
+	})
+	return ret
+}
+
+// tests returns the test results, sorted by package then test.
+// Tests which started but never finished are reported as failed.
+func (c *testCollector) tests() []TestResult {
+	var ret []TestResult
+	for _, p := range c.pkgs {
+		for _, tc := range p.tests {
+			r := tc.result
+			r.Output = tc.output.String()
+			if r.Action == "" {
+				r.Action = "fail"
+			}
+			ret = append(ret, r)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Package != ret[j].Package {
+			return ret[i].Package < ret[j].Package
+		}
+		return ret[i].Test < ret[j].Test
+	})
+	return ret
+}
+
+func (c *testCollector) summary() TestSummary {
+	s := TestSummary{Packages: c.packages()}
+	for _, r := range c.tests() {
+		switch r.Action {
+		case "pass":
+			s.Passed++
+		case "fail":
+			s.Failed++
+		case "skip":
+			s.Skipped++
+		}
+	}
+	return s
+}
+
+// maxSummaryLines is the number of output lines shown for each failure in a summary.
+const maxSummaryLines = 20
+
+// formatSummary returns a human readable description of the failures, followed by the counts.
+// The duration of each failed test is included if elapsed is true.
+func formatSummary(sum TestSummary, tests []TestResult, elapsed bool) string {
+	var sb strings.Builder
+	for _, r := range tests {
+		if r.Action != "fail" {
+			continue
+		}
+		fmt.Fprintf(&sb, "--- FAIL: %s %s", r.Package, r.Test)
+		if elapsed {
+			fmt.Fprintf(&sb, " (%.2fs)", r.Elapsed)
+		}
+		sb.WriteString("\n")
+		writeTail(&sb, r.Output)
+	}
+	for _, pkg := range sum.Packages {
+		if pkg.Action != "fail" || hasFailedTest(tests, pkg.Package) {
+			continue
+		}
+		fmt.Fprintf(&sb, "FAIL %s\n", pkg.Package)
+		writeTail(&sb, pkg.Output)
+	}
+	status := "ok"
+	if !sum.OK() {
+		status = "FAIL"
+	}
+	fmt.Fprintf(&sb, "%s: %d passed, %d failed, %d skipped in %d packages", status, sum.Passed, sum.Failed, sum.Skipped, len(sum.Packages))
+	return sb.String()
+}
+
+func hasFailedTest(tests []TestResult, pkg string) bool {
+	for _, r := range tests {
+		if r.Package == pkg && r.Action == "fail" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeTail writes the last lines of output, indented.
+// The === RUN, PAUSE and CONT lines are skipped.
+func writeTail(w io.Writer, output string) {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > maxSummaryLines {
+		fmt.Fprintf(w, "    ... %d lines omitted\n", len(lines)-maxSummaryLines)
+		lines = lines[len(lines)-maxSummaryLines:]
+	}
+	for _, line := range lines {
+		if line == "" || strings.HasPrefix(line, "=== ") {
+			continue
+		}
+		fmt.Fprintf(w, "    %s\n", strings.TrimLeft(line, " "))
+	}
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+	SystemOut string          `xml:"system-out,omitempty"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// writeJUnit writes the results in the JUnit XML format, with a testsuite for each package.
+// A package which failed without a failing test, e.g. because it did not build, is reported as a failed testcase.
+// The optional time attributes are omitted, so the output is the same for every run.
+func writeJUnit(w io.Writer, pkgs []TestResult, tests []TestResult) error {
+	doc := junitTestSuites{}
+	for _, pkg := range pkgs {
+		suite := junitTestSuite{
+			Name: pkg.Package,
+		}
+		for _, r := range tests {
+			if r.Package != pkg.Package {
+				continue
+			}
+			tc := junitTestCase{
+				ClassName: r.Package,
+				Name:      r.Test,
+			}
+			switch r.Action {
+			case "fail":
+				tc.Failure = &junitMessage{Message: "Failed", Body: r.Output}
+				suite.Failures++
+			case "skip":
+				tc.Skipped = &junitMessage{Message: "Skipped", Body: r.Output}
+				suite.Skipped++
+			default:
+				tc.SystemOut = r.Output
+			}
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+		if pkg.Action == "fail" && suite.Failures == 0 {
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				ClassName: pkg.Package,
+				Name:      "[package]",
+				Failure:   &junitMessage{Message: "Failed", Body: pkg.Output},
+			})
+			suite.Failures++
+		} else {
+			suite.SystemOut = pkg.Output
+		}
+		suite.Tests = len(suite.TestCases)
+		doc.Tests += suite.Tests
+		doc.Failures += suite.Failures
+		doc.Skipped += suite.Skipped
+		doc.Suites = append(doc.Suites, suite)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// mergeCoverProfiles merges coverage profiles written by go test -coverprofile.
+// Counts for the same block are added, or combined with OR in set mode.
+// The blocks in the output are sorted, so it does not depend on the order of the inputs.
+func mergeCoverProfiles(profiles ...[]byte) ([]byte, error) {
+	mode := ""
+	counts := map[string]int64{}
+	for _, profile := range profiles {
+		sc := bufio.NewScanner(bytes.NewReader(profile))
+		sc.Buffer(nil, 1<<20)
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" {
+				continue
+			}
+			if m, ok := strings.CutPrefix(line, "mode: "); ok {
+				if mode != "" && mode != m {
+					return nil, fmt.Errorf("cannot merge coverage profiles with modes %s and %s", mode, m)
+				}
+				mode = m
+				continue
+			}
+			i := strings.LastIndexByte(line, ' ')
+			if i < 0 {
+				return nil, fmt.Errorf("invalid coverage profile line %q", line)
+			}
+			block, countStr := line[:i], line[i+1:]
+			count, err := strconv.ParseInt(countStr, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid coverage profile line %q", line)
+			}
+			if mode == "set" {
+				if count > 0 {
+					counts[block] = 1
+				} else if _, exists := counts[block]; !exists {
+					counts[block] = 0
+				}
+			} else {
+				counts[block] += count
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if mode == "" {
+		return nil, nil
+	}
+	blocks := make([]string, 0, len(counts))
+	for block := range counts {
+		blocks = append(blocks, block)
+	}
+	sort.Strings(blocks)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "mode: %s\n", mode)
+	for _, block := range blocks {
+		fmt.Fprintf(&buf, "%s %d\n", block, counts[block])
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,92 @@
+package goops
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestCollector(t *testing.T) {
+	c := newTestCollector()
+	other, err := c.readJSON(strings.NewReader(`# example.com/m/broken
+{"Action":"start","Package":"example.com/m/a"}
+{"Action":"run","Package":"example.com/m/a","Test":"TestA"}
+{"Action":"output","Package":"example.com/m/a","Test":"TestA","Output":"=== RUN   TestA\n"}
+{"Action":"output","Package":"example.com/m/a","Test":"TestA","Output":"    a_test.go:10: boom\n"}
+{"Action":"output","Package":"example.com/m/a","Test":"TestA","Output":"--- FAIL: TestA (0.50s)\n"}
+{"Action":"fail","Package":"example.com/m/a","Test":"TestA","Elapsed":0.5}
+{"Action":"run","Package":"example.com/m/a","Test":"TestB"}
+{"Action":"skip","Package":"example.com/m/a","Test":"TestB"}
+{"Action":"run","Package":"example.com/m/a","Test":"TestC"}
+{"Action":"pass","Package":"example.com/m/a","Test":"TestC","Elapsed":0.1}
+{"Action":"fail","Package":"example.com/m/a","Elapsed":0.7}
+{"ImportPath":"example.com/m/broken [example.com/m/broken.test]","Action":"build-output","Output":"broken.go:1:1: syntax error\n"}
+{"ImportPath":"example.com/m/broken [example.com/m/broken.test]","Action":"build-fail"}
+{"Action":"fail","Package":"example.com/m/broken","FailedBuild":"example.com/m/broken [example.com/m/broken.test]"}
+{"Action":"run","Package":"example.com/m/crash","Test":"TestCrash"}
+`))
+	require.NoError(t, err)
+	require.Equal(t, "# example.com/m/broken\n", other)
+
+	sum := c.summary()
+	require.Equal(t, 1, sum.Passed)
+	// TestCrash never finished
+	require.Equal(t, 2, sum.Failed)
+	require.Equal(t, 1, sum.Skipped)
+	require.False(t, sum.OK())
+	require.Len(t, sum.Packages, 3)
+	require.Equal(t, "broken.go:1:1: syntax error\n", sum.Packages[1].Output)
+	require.Equal(t, "fail", sum.Packages[2].Action)
+
+	report := formatSummary(sum, c.tests(), true)
+	require.Equal(t, `--- FAIL: example.com/m/a TestA (0.50s)
+    a_test.go:10: boom
+    --- FAIL: TestA
+--- FAIL: example.com/m/crash TestCrash (0.00s)
+FAIL example.com/m/broken
+    broken.go:1:1: syntax error
+FAIL: 1 passed, 2 failed, 1 skipped in 3 packages`, report)
+	report = formatSummary(sum, c.tests(), false)
+	require.NotContains(t, report, "(0.50s)")
+
+	var buf bytes.Buffer
+	require.NoError(t, writeJUnit(&buf, sum.Packages, c.tests()))
+	junit := buf.String()
+	require.Contains(t, junit, `<testsuites tests="5" failures="3" skipped="1">`)
+	require.Contains(t, junit, `<testcase classname="example.com/m/broken" name="[package]">`)
+	require.Contains(t, junit, `<failure message="Failed">=== RUN   TestA&#xA;    a_test.go:10: boom&#xA;--- FAIL: TestA&#xA;</failure>`)
+	require.NotContains(t, junit, "time=")
+
+	// durations are not written to the results.
+	data, err := json.Marshal(c.tests()[0])
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "0.5")
+}
+
+func TestMergeCoverProfiles(t *testing.T) {
+	out, err := mergeCoverProfiles(
+		[]byte("mode: set\nm/a.go:1.1,2.2 1 0\nm/a.go:3.1,4.2 1 1\n"),
+		[]byte("mode: set\nm/a.go:1.1,2.2 1 1\nm/b.go:1.1,2.2 2 0\n"),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "mode: set\nm/a.go:1.1,2.2 1 1\nm/a.go:3.1,4.2 1 1\nm/b.go:1.1,2.2 2 0\n", string(out))
+
+	out, err = mergeCoverProfiles(
+		[]byte("mode: count\nm/a.go:1.1,2.2 1 2\n"),
+		[]byte("mode: count\nm/a.go:1.1,2.2 1 3\n"),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "mode: count\nm/a.go:1.1,2.2 1 5\n", string(out))
+
+	_, err = mergeCoverProfiles([]byte("mode: set\n"), []byte("mode: count\n"))
+	require.Error(t, err)
+}
+
+func TestTestResultPath(t *testing.T) {
+	require.Equal(t, "TestA.json", testResultPath("TestA"))
+	require.Equal(t, "TestA/case_1.json", testResultPath("TestA/case_1"))
+	require.Equal(t, "TestA/%2E%2E%/%.json", testResultPath("TestA/../"))
+}
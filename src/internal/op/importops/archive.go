@@ -0,0 +1,279 @@
+package importops
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"blobcache.io/glfs"
+	"go.brendoncarroll.net/state/cadata"
+)
+
+// readTAR imports a tar stream as a GLFS tree.
+// Symlinks are stored as blobs containing their target, and hard links share the ref of their target.
+// Later entries replace earlier entries at the same path.
+func readTAR(ctx context.Context, ag *glfs.Agent, s cadata.PostExister, tr *tar.Reader) (*glfs.Ref, error) {
+	b := newTreeBuilder()
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		p, err := archivePath(h.Name)
+		if err != nil {
+			return nil, err
+		}
+		mode := fs.FileMode(h.Mode) & fs.ModePerm
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err := b.putDir(p, mode); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			ref, err := ag.PostBlob(ctx, s, tr)
+			if err != nil {
+				return nil, err
+			}
+			if err := b.put(p, mode, *ref); err != nil {
+				return nil, err
+			}
+		case tar.TypeSymlink:
+			if err := b.putSymlink(ctx, s, p, h.Linkname); err != nil {
+				return nil, err
+			}
+		case tar.TypeLink:
+			target, err := archivePath(h.Linkname)
+			if err != nil {
+				return nil, err
+			}
+			ent := b.get(target)
+			if ent == nil || ent.children != nil {
+				return nil, fmt.Errorf("tar: hard link %q has no regular file target %q", h.Name, h.Linkname)
+			}
+			if err := b.put(p, ent.mode, ent.ref); err != nil {
+				return nil, err
+			}
+		case tar.TypeXGlobalHeader:
+		default:
+			return nil, fmt.Errorf("tar: cannot import %q with type %q", h.Name, h.Typeflag)
+		}
+	}
+	return b.finish(ctx, ag, s)
+}
+
+// readZip imports a zip archive as a GLFS tree.
+// Symlinks are stored as blobs containing their target.
+func readZip(ctx context.Context, ag *glfs.Agent, s cadata.PostExister, zr *zip.Reader) (*glfs.Ref, error) {
+	b := newTreeBuilder()
+	for _, zf := range zr.File {
+		p, err := archivePath(zf.Name)
+		if err != nil {
+			return nil, err
+		}
+		mode := zf.Mode()
+		if mode.IsDir() {
+			if err := b.putDir(p, mode&fs.ModePerm); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if mode.Type() != 0 && mode.Type() != fs.ModeSymlink {
+			return nil, fmt.Errorf("zip: cannot import %q with mode %v", zf.Name, mode)
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		ref, err := ag.PostBlob(ctx, s, rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if mode.Type() == fs.ModeSymlink {
+			mode = fs.ModeSymlink | 0o777
+		} else {
+			mode &= fs.ModePerm
+		}
+		if err := b.put(p, mode, *ref); err != nil {
+			return nil, err
+		}
+	}
+	return b.finish(ctx, ag, s)
+}
+
+// archivePath cleans a path from an archive, and rejects paths which would be outside of the tree.
+func archivePath(x string) (string, error) {
+	if path.IsAbs(x) || strings.Contains(x, "\\") {
+		return "", fmt.Errorf("archive contains unsafe path %q", x)
+	}
+	p := path.Clean(x)
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return "", fmt.Errorf("archive contains unsafe path %q", x)
+	}
+	return glfs.CleanPath(p), nil
+}
+
+// treeBuilder accumulates entries in memory, and posts the tree bottom up.
+type treeBuilder struct {
+	// src is used to read the base of directories added with putTree.
+	src  cadata.Getter
+	root *treeNode
+}
+
+type treeNode struct {
+	mode fs.FileMode
+	ref  glfs.Ref
+	// children is nil for non-directories.
+	children map[string]*treeNode
+	// base, if set, is a tree which children are merged over.
+	base *glfs.Ref
+}
+
+func newTreeBuilder() *treeBuilder {
+	return &treeBuilder{root: &treeNode{mode: fs.ModeDir | 0o755, children: map[string]*treeNode{}}}
+}
+
+func (b *treeBuilder) get(p string) *treeNode {
+	n := b.root
+	for _, part := range splitPath(p) {
+		if n.children == nil {
+			return nil
+		}
+		if n = n.children[part]; n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// parent returns the directory containing p, creating it if necessary.
+func (b *treeBuilder) parent(p string) (*treeNode, string, error) {
+	parts := splitPath(p)
+	if len(parts) == 0 {
+		return nil, "", fmt.Errorf("cannot replace root of archive")
+	}
+	n := b.root
+	for i, part := range parts[:len(parts)-1] {
+		child := n.children[part]
+		if child == nil {
+			child = &treeNode{mode: fs.ModeDir | 0o755, children: map[string]*treeNode{}}
+			n.children[part] = child
+		}
+		if child.children == nil {
+			return nil, "", fmt.Errorf("archive path %q is not a directory", path.Join(parts[:i+1]...))
+		}
+		n = child
+	}
+	return n, parts[len(parts)-1], nil
+}
+
+func (b *treeBuilder) put(p string, mode fs.FileMode, ref glfs.Ref) error {
+	dir, name, err := b.parent(p)
+	if err != nil {
+		return err
+	}
+	dir.children[name] = &treeNode{mode: mode, ref: ref}
+	return nil
+}
+
+func (b *treeBuilder) putDir(p string, perm fs.FileMode) error {
+	if p == "" {
+		return nil
+	}
+	dir, name, err := b.parent(p)
+	if err != nil {
+		return err
+	}
+	if n := dir.children[name]; n != nil && n.children != nil {
+		n.mode = fs.ModeDir | perm
+		return nil
+	}
+	dir.children[name] = &treeNode{mode: fs.ModeDir | perm, children: map[string]*treeNode{}}
+	return nil
+}
+
+// putSymlink places a symlink to target at p.
+func (b *treeBuilder) putSymlink(ctx context.Context, s cadata.Poster, p, target string) error {
+	ref, err := glfs.PostBlob(ctx, s, strings.NewReader(target))
+	if err != nil {
+		return err
+	}
+	return b.put(p, fs.ModeSymlink|0o777, *ref)
+}
+
+// putTree places the existing tree ref at p.
+// Entries added below p, before or after, are merged over it.
+func (b *treeBuilder) putTree(p string, ref glfs.Ref) error {
+	if p == "" {
+		b.root.base = &ref
+		return nil
+	}
+	dir, name, err := b.parent(p)
+	if err != nil {
+		return err
+	}
+	n := dir.children[name]
+	if n == nil || n.children == nil {
+		n = &treeNode{mode: fs.ModeDir | 0o755, children: map[string]*treeNode{}}
+		dir.children[name] = n
+	}
+	n.base = &ref
+	return nil
+}
+
+func (b *treeBuilder) finish(ctx context.Context, ag *glfs.Agent, s cadata.PostExister) (*glfs.Ref, error) {
+	return b.postNode(ctx, ag, s, b.root)
+}
+
+func (b *treeBuilder) postNode(ctx context.Context, ag *glfs.Agent, s cadata.PostExister, n *treeNode) (*glfs.Ref, error) {
+	if n.base != nil {
+		baseEnts, err := ag.GetTreeSlice(ctx, b.src, *n.base, 1e6)
+		if err != nil {
+			return nil, err
+		}
+		for _, ent := range baseEnts {
+			child, exists := n.children[ent.Name]
+			switch {
+			case !exists:
+				n.children[ent.Name] = &treeNode{mode: ent.FileMode, ref: ent.Ref}
+			case child.children != nil && child.base == nil && ent.FileMode.IsDir():
+				child.base = &ent.Ref
+			}
+		}
+	}
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ents := make([]glfs.TreeEntry, 0, len(names))
+	for _, name := range names {
+		child := n.children[name]
+		ref := child.ref
+		if child.children != nil {
+			r, err := b.postNode(ctx, ag, s, child)
+			if err != nil {
+				return nil, err
+			}
+			ref = *r
+		}
+		ents = append(ents, glfs.TreeEntry{Name: name, FileMode: child.mode, Ref: ref})
+	}
+	return ag.PostTreeSlice(ctx, s, ents)
+}
+
+func splitPath(p string) []string {
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
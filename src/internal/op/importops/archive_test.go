@@ -0,0 +1,65 @@
+package importops
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/fs"
+	"testing"
+
+	"blobcache.io/glfs"
+	"github.com/stretchr/testify/require"
+
+	"wantbuild.io/want/src/internal/stores"
+	"wantbuild.io/want/src/internal/testutil"
+)
+
+func TestReadTARLinks(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+	data := writeTestTAR(t, []*tar.Header{
+		{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "bin/tool", Typeflag: tar.TypeReg, Mode: 0o755, Size: 4},
+		{Name: "bin/alias", Typeflag: tar.TypeSymlink, Linkname: "tool"},
+		{Name: "bin/hard", Typeflag: tar.TypeLink, Linkname: "bin/tool"},
+	})
+	ref, err := readTAR(ctx, glfs.NewAgent(), s, tar.NewReader(bytes.NewReader(data)))
+	require.NoError(t, err)
+
+	binRef, err := glfs.GetAtPath(ctx, s, *ref, "bin")
+	require.NoError(t, err)
+	ents, err := glfs.GetTreeSlice(ctx, s, *binRef, 10)
+	require.NoError(t, err)
+	require.Len(t, ents, 3)
+	alias := glfs.Lookup(ents, "alias")
+	require.Equal(t, fs.ModeSymlink|0o777, alias.FileMode)
+	target, err := glfs.GetBlobBytes(ctx, s, alias.Ref, 1024)
+	require.NoError(t, err)
+	require.Equal(t, "tool", string(target))
+	require.Equal(t, glfs.Lookup(ents, "tool").Ref, glfs.Lookup(ents, "hard").Ref)
+}
+
+func TestReadTARUnsafe(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+	for _, name := range []string{"../x", "/etc/passwd", "a/../../x"} {
+		data := writeTestTAR(t, []*tar.Header{
+			{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: 4},
+		})
+		_, err := readTAR(ctx, glfs.NewAgent(), s, tar.NewReader(bytes.NewReader(data)))
+		require.Error(t, err, name)
+	}
+}
+
+func writeTestTAR(t testing.TB, hdrs []*tar.Header) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, h := range hdrs {
+		require.NoError(t, tw.WriteHeader(h))
+		if h.Size > 0 {
+			_, err := tw.Write(bytes.Repeat([]byte{'x'}, int(h.Size)))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
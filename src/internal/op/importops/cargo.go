@@ -0,0 +1,195 @@
+package importops
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"blobcache.io/glfs"
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/wantjob"
+)
+
+const (
+	cargoLockfile = "Cargo.lock"
+	// CratesIOBaseURL is where crates from crates.io are downloaded from.
+	CratesIOBaseURL = "https://static.crates.io/crates"
+)
+
+// cratesIOSources are the lockfile sources which refer to crates.io.
+var cratesIOSources = []string{
+	"registry+https://github.com/rust-lang/crates.io-index",
+	"sparse+https://index.crates.io/",
+}
+
+type cargoPackage struct {
+	Name     string
+	Version  string
+	Source   string
+	Checksum string
+}
+
+// ImportCargoLock reads the Cargo.lock at the root of x, and imports every crate it pins from crates.io.
+// The output is a directory which can be used with Cargo's directory source replacement,
+// with a subdirectory for each crate named <name>-<version>.
+func (e *Executor) ImportCargoLock(jc wantjob.Ctx, s cadata.Getter, x glfs.Ref) (*glfs.Ref, error) {
+	ctx := jc.Context
+	lockRef, err := glfs.GetAtPath(ctx, s, x, cargoLockfile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := glfs.GetBlobBytes(ctx, s, *lockRef, maxLockfileSize)
+	if err != nil {
+		return nil, err
+	}
+	cpkgs, err := parseCargoLock(data)
+	if err != nil {
+		return nil, err
+	}
+	b := newTreeBuilder()
+	b.src = jc.Dst
+	var pkgs []lockedPackage
+	for _, cp := range cpkgs {
+		switch {
+		case cp.Source == "":
+			// path dependencies and workspace members are part of the module.
+			continue
+		case !isCratesIOSource(cp.Source):
+			return nil, fmt.Errorf("%s: %s %s has unsupported source %q", cargoLockfile, cp.Name, cp.Version, cp.Source)
+		case cp.Checksum == "":
+			return nil, fmt.Errorf("%s: %s %s has no checksum", cargoLockfile, cp.Name, cp.Version)
+		}
+		dir := cp.Name + "-" + cp.Version
+		if _, err := archivePath(dir); err != nil || strings.Contains(dir, "/") {
+			return nil, fmt.Errorf("%s: invalid crate %q", cargoLockfile, dir)
+		}
+		pkgs = append(pkgs, lockedPackage{
+			Path: dir,
+			Archive: ImportURLTask{
+				URL:        fmt.Sprintf("%s/%s/%s.crate", CratesIOBaseURL, cp.Name, dir),
+				Algo:       "SHA256",
+				Hash:       cp.Checksum,
+				Transforms: []string{"ungzip", "untar"},
+			},
+		})
+		// Cargo checks the files listed here, and the package checksum against the lockfile.
+		checksumJSON, err := json.Marshal(map[string]any{
+			"files":   map[string]string{},
+			"package": cp.Checksum,
+		})
+		if err != nil {
+			return nil, err
+		}
+		ref, err := glfs.PostBlob(ctx, jc.Dst, bytes.NewReader(checksumJSON))
+		if err != nil {
+			return nil, err
+		}
+		if err := b.put(path.Join(dir, ".cargo-checksum.json"), 0o644, *ref); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.importLocked(jc, b, pkgs); err != nil {
+		return nil, err
+	}
+	return b.finish(ctx, glfs.NewAgent(), jc.Dst)
+}
+
+func isCratesIOSource(x string) bool {
+	for _, src := range cratesIOSources {
+		if x == src {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCargoLock parses the [[package]] tables from a Cargo.lock file.
+// Checksums from the [metadata] table used by version 1 lockfiles are merged into the packages.
+func parseCargoLock(data []byte) ([]cargoPackage, error) {
+	var pkgs []cargoPackage
+	metadata := map[string]string{}
+	section := ""
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<20)
+	lineNum := 0
+	inArray := false
+	for sc.Scan() {
+		lineNum++
+		line := strings.TrimSpace(sc.Text())
+		if inArray {
+			inArray = !strings.HasSuffix(line, "]")
+			continue
+		}
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case line == "[[package]]":
+			section = "package"
+			pkgs = append(pkgs, cargoPackage{})
+			continue
+		case strings.HasPrefix(line, "["):
+			section = strings.Trim(line, "[]")
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", cargoLockfile, lineNum)
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if strings.HasPrefix(v, "[") {
+			inArray = !strings.HasSuffix(v, "]")
+			continue
+		}
+		if strings.HasPrefix(k, `"`) {
+			var err error
+			if k, err = strconv.Unquote(k); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", cargoLockfile, lineNum, err)
+			}
+		}
+		str, err := strconv.Unquote(v)
+		if err != nil {
+			// only strings are used
+			continue
+		}
+		switch section {
+		case "package":
+			pkg := &pkgs[len(pkgs)-1]
+			switch k {
+			case "name":
+				pkg.Name = str
+			case "version":
+				pkg.Version = str
+			case "source":
+				pkg.Source = str
+			case "checksum":
+				pkg.Checksum = str
+			}
+		case "metadata":
+			if rest, ok := strings.CutPrefix(k, "checksum "); ok {
+				metadata[rest] = str
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for i := range pkgs {
+		pkg := &pkgs[i]
+		if pkg.Checksum == "" && pkg.Source != "" {
+			pkg.Checksum = metadata[fmt.Sprintf("%s %s (%s)", pkg.Name, pkg.Version, pkg.Source)]
+		}
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		if pkgs[i].Name != pkgs[j].Name {
+			return pkgs[i].Name < pkgs[j].Name
+		}
+		return pkgs[i].Version < pkgs[j].Version
+	})
+	return pkgs, nil
+}
@@ -0,0 +1,134 @@
+package importops
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"wantbuild.io/want/src/wantjob"
+)
+
+const DefaultGoProxy = "https://proxy.golang.org"
+
+// ErrOffline is returned when content is not vendored and the Executor is not allowed to use the network.
+var ErrOffline = errors.New("importops: content is not vendored and network access is disabled")
+
+// Config configures where the Executor fetches content from.
+// None of the options change the integrity checks performed on the content.
+type Config struct {
+	// Mirrors maps a URL prefix to an ordered list of prefixes to try in its place.
+	// The longest matching prefix is used.
+	// The original URL is tried after all of the mirrors.
+	// OCI image names are matched against the same prefixes.
+	Mirrors map[string][]string `json:"mirrors,omitempty"`
+	// GoProxy is a list of Go module proxies, in the same format as GOPROXY.
+	// If empty, DefaultGoProxy is used.
+	GoProxy string `json:"go_proxy,omitempty"`
+	// VendorDir, if set, is checked for content before the network.
+	VendorDir string `json:"vendor_dir,omitempty"`
+	// GitCacheDir, if set, holds an object store for each Git remote, so objects are only fetched once.
+	GitCacheDir string `json:"git_cache_dir,omitempty"`
+	// Offline disables network access. All content must come from the VendorDir or GitCacheDir.
+	Offline bool `json:"offline,omitempty"`
+}
+
+// mirrorURLs returns the URLs to try, in order, when fetching rawURL.
+func (c Config) mirrorURLs(rawURL string) []string {
+	var ret []string
+	if prefix, ok := c.longestMirrorPrefix(rawURL); ok {
+		for _, m := range c.Mirrors[prefix] {
+			ret = append(ret, m+strings.TrimPrefix(rawURL, prefix))
+		}
+	}
+	return append(ret, rawURL)
+}
+
+func (c Config) longestMirrorPrefix(x string) (string, bool) {
+	prefixes := make([]string, 0, len(c.Mirrors))
+	for prefix := range c.Mirrors {
+		if strings.HasPrefix(x, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	if len(prefixes) == 0 {
+		return "", false
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) > len(prefixes[j])
+	})
+	return prefixes[0], true
+}
+
+// goProxies returns the Go module proxy URLs to try in order.
+// "direct" entries are skipped, since modules are only fetched as zips from a proxy.
+// An "off" entry ends the list.
+func (c Config) goProxies() []string {
+	goproxy := c.GoProxy
+	if goproxy == "" {
+		goproxy = DefaultGoProxy
+	}
+	var ret []string
+	for _, p := range strings.FieldsFunc(goproxy, func(r rune) bool { return r == ',' || r == '|' }) {
+		p = strings.TrimSpace(p)
+		switch p {
+		case "", "direct":
+			continue
+		case "off":
+			return ret
+		}
+		ret = append(ret, strings.TrimSuffix(p, "/"))
+	}
+	return ret
+}
+
+// fetch tries each of the URLs in order, and returns the body of the first successful response.
+func (e *Executor) fetch(jc wantjob.Ctx, rawURLs []string) (io.ReadCloser, error) {
+	if e.cfg.Offline {
+		return nil, ErrOffline
+	}
+	var errs []error
+	for _, rawURL := range rawURLs {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		rc, err := e.download(jc, u)
+		if err != nil {
+			jc.Infof("fetch %v: %v", rawURL, err)
+			errs = append(errs, err)
+			continue
+		}
+		return rc, nil
+	}
+	return nil, fmt.Errorf("fetch failed from all sources: %w", errors.Join(errs...))
+}
+
+func (e *Executor) download(jc wantjob.Ctx, u *url.URL) (io.ReadCloser, error) {
+	switch u.Scheme {
+	case "http", "https":
+		return e.downloadHTTP(jc, u)
+	default:
+		return nil, errors.New("url scheme not supported")
+	}
+}
+
+func (e *Executor) downloadHTTP(jc wantjob.Ctx, u *url.URL) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(jc.Context, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := e.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	jc.Infof("http %v %v", res.Status, u.String())
+	if res.StatusCode != 200 {
+		res.Body.Close()
+		return nil, fmt.Errorf("got non 200 status %s", res.Status)
+	}
+	return res.Body, nil
+}
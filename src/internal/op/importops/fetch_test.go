@@ -0,0 +1,82 @@
+package importops
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"wantbuild.io/want/src/internal/stores"
+	"wantbuild.io/want/src/internal/testutil"
+	"wantbuild.io/want/src/wantjob"
+)
+
+func TestMirrorURLs(t *testing.T) {
+	cfg := Config{
+		Mirrors: map[string][]string{
+			"https://example.com/":     {"https://m1.example.com/"},
+			"https://example.com/a/b/": {"https://m2.example.com/", "https://m3.example.com/"},
+		},
+	}
+	require.Equal(t, []string{
+		"https://m2.example.com/c.txt",
+		"https://m3.example.com/c.txt",
+		"https://example.com/a/b/c.txt",
+	}, cfg.mirrorURLs("https://example.com/a/b/c.txt"))
+	require.Equal(t, []string{
+		"https://m1.example.com/x",
+		"https://example.com/x",
+	}, cfg.mirrorURLs("https://example.com/x"))
+	require.Equal(t, []string{"https://other.com/x"}, cfg.mirrorURLs("https://other.com/x"))
+}
+
+func TestGoProxies(t *testing.T) {
+	require.Equal(t, []string{DefaultGoProxy}, Config{}.goProxies())
+	require.Equal(t, []string{"https://a.example.com", "https://b.example.com"},
+		Config{GoProxy: "https://a.example.com/,direct|https://b.example.com,off,https://c.example.com"}.goProxies())
+	require.Empty(t, Config{GoProxy: "off"}.goProxies())
+}
+
+func TestImportURLMirrorVendor(t *testing.T) {
+	ctx := testutil.Context(t)
+	data := []byte("hello world\n")
+	sum := sha256.Sum256(data)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/mirror/file.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer srv.Close()
+	spec := ImportURLTask{
+		URL:  srv.URL + "/origin/file.txt",
+		Algo: "SHA256",
+		Hash: hex.EncodeToString(sum[:]),
+	}
+	vendorDir := t.TempDir()
+
+	// the origin 404s, so this only succeeds by using the mirror.
+	e := NewExecutor(Config{
+		Mirrors:   map[string][]string{srv.URL + "/origin/": {srv.URL + "/mirror/"}},
+		VendorDir: vendorDir,
+	})
+	s := stores.NewMem()
+	jc := wantjob.Ctx{Context: ctx, Dst: s}
+	expected, err := e.ImportURL(jc, spec)
+	require.NoError(t, err)
+	require.NoError(t, e.VendorURL(jc, spec))
+
+	// offline, without the mirror, the content must come from the vendor directory.
+	e = NewExecutor(Config{VendorDir: vendorDir, Offline: true})
+	actual, err := e.ImportURL(jc, spec)
+	require.NoError(t, err)
+	require.Equal(t, *expected, *actual)
+
+	spec.Hash = hex.EncodeToString(make([]byte, 32))
+	_, err = e.ImportURL(jc, spec)
+	require.ErrorIs(t, err, ErrOffline)
+}
@@ -3,24 +3,46 @@ package importops
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 
 	"blobcache.io/glfs"
+	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/filesystem"
 	"github.com/go-git/go-git/v5/storage/memory"
 	"go.brendoncarroll.net/state/cadata"
 
 	"wantbuild.io/want/src/internal/glfsgit"
+	"wantbuild.io/want/src/wantjob"
 )
 
 type ImportGitTask struct {
 	URL        string `json:"url"`
 	Branch     string `json:"branch"`
 	CommitHash string `json:"commitHash"`
+	// Submodules causes submodules to be imported recursively, each at the commit recorded by its gitlink.
+	// Otherwise submodules are imported as empty directories.
+	Submodules bool `json:"submodules,omitempty"`
+	// LFS is the Git LFS endpoint used to resolve LFS pointer files.
+	// If empty, pointer files are imported as they are.
+	// The endpoint is not used for submodules.
+	LFS string `json:"lfs,omitempty"`
 }
 
 // PostImportGitTask marshals spec and posts it to the store, returning a Blob Ref.
@@ -37,36 +59,206 @@ func GetImportGitTask(ctx context.Context, s cadata.Getter, x glfs.Ref) (*Import
 	return loadJSON[ImportGitTask](ctx, s, x)
 }
 
-func (e *Executor) ImportGit(ctx context.Context, dst cadata.PostExister, spec ImportGitTask) (*glfs.Ref, error) {
+func (e *Executor) ImportGit(jc wantjob.Ctx, dst cadata.PostExister, spec ImportGitTask) (*glfs.Ref, error) {
+	return e.importGit(jc, dst, spec, nil)
+}
+
+// importGit imports spec.
+// parents are the URLs of the repositories which spec is a submodule of.
+func (e *Executor) importGit(jc wantjob.Ctx, dst cadata.PostExister, spec ImportGitTask, parents []string) (*glfs.Ref, error) {
 	if len(spec.CommitHash) < 40 {
 		return nil, fmt.Errorf("invalid commit_hash %q, len=%d", spec.CommitHash, len(spec.CommitHash))
 	}
-	gstor := memory.NewStorage()
-	r := git.NewRemote(gstor, &config.RemoteConfig{
-		Name: "origin",
-		URLs: []string{spec.URL},
+	if slices.Contains(parents, spec.URL) {
+		return nil, fmt.Errorf("git: submodule cycle through %s", spec.URL)
+	}
+	h := plumbing.NewHash(spec.CommitHash)
+	return e.withGitStorage(spec.URL, func(gstor storage.Storer) (*glfs.Ref, error) {
+		commit, err := e.fetchGitCommit(jc, gstor, spec.URL, h)
+		if err != nil {
+			return nil, err
+		}
+		tree, err := commit.Tree()
+		if err != nil {
+			return nil, err
+		}
+		im := glfsgit.Importer{Store: dst, Git: gstor}
+		if spec.LFS != "" {
+			im.Blob = func(ctx context.Context, p string, gb *object.Blob) (*glfs.Ref, error) {
+				return e.importLFSBlob(jc, dst, spec.LFS, gb)
+			}
+		}
+		if spec.Submodules {
+			mods, err := loadGitModules(tree)
+			if err != nil {
+				return nil, err
+			}
+			im.Submodule = func(ctx context.Context, p string, h plumbing.Hash) (*glfs.Ref, error) {
+				i := slices.IndexFunc(mods, func(sm *config.Submodule) bool { return path.Clean(sm.Path) == p })
+				if i < 0 {
+					return nil, fmt.Errorf("git: no .gitmodules entry for submodule at %q", p)
+				}
+				u, err := resolveSubmoduleURL(spec.URL, mods[i].URL)
+				if err != nil {
+					return nil, err
+				}
+				jc.Infof("submodule %s: %s@%v", p, u, h)
+				return e.importGit(jc, dst, ImportGitTask{
+					URL:        u,
+					CommitHash: h.String(),
+					Submodules: true,
+				}, append(parents, spec.URL))
+			}
+		}
+		return im.ImportTree(jc.Context, "", tree)
 	})
-	if err := r.Fetch(&git.FetchOptions{
-		RemoteName: "origin",
-		Depth:      1,
-		RefSpecs: []config.RefSpec{
-			config.RefSpec(fmt.Sprintf("%s:%s", spec.CommitHash, spec.CommitHash)),
-		},
-	}); err != nil {
+}
+
+// fetchGitCommit returns the commit h, fetching it from the remote if it is not already in gstor.
+func (e *Executor) fetchGitCommit(jc wantjob.Ctx, gstor storage.Storer, rawURL string, h plumbing.Hash) (*object.Commit, error) {
+	if commit, err := object.GetCommit(gstor, h); err == nil {
+		jc.Infof("git: found %v in cache", h)
+		return commit, nil
+	} else if !errors.Is(err, plumbing.ErrObjectNotFound) {
 		return nil, err
 	}
-	h := plumbing.NewHash(spec.CommitHash)
-	co, exists := gstor.Commits[h]
-	if !exists {
-		return nil, fmt.Errorf("could not find commit %v", h)
+	if e.cfg.Offline {
+		return nil, ErrOffline
+	}
+	var errs []error
+	for _, u := range e.cfg.mirrorURLs(rawURL) {
+		r := git.NewRemote(gstor, &config.RemoteConfig{
+			Name: "origin",
+			URLs: []string{u},
+		})
+		err := r.FetchContext(jc.Context, &git.FetchOptions{
+			RemoteName: "origin",
+			Depth:      1,
+			RefSpecs: []config.RefSpec{
+				config.RefSpec(fmt.Sprintf("%s:refs/want/%s", h, h)),
+			},
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			jc.Infof("git fetch %v: %v", u, err)
+			errs = append(errs, err)
+			continue
+		}
+		commit, err := object.GetCommit(gstor, h)
+		if err != nil {
+			return nil, fmt.Errorf("could not find commit %v: %w", h, err)
+		}
+		return commit, nil
+	}
+	return nil, fmt.Errorf("git fetch failed from all sources: %w", errors.Join(errs...))
+}
+
+// gitLocks serializes access to each on-disk git object store.
+var gitLocks sync.Map
+
+// withGitStorage calls fn with the object store for the remote at rawURL.
+// If the Executor has a GitCacheDir, the store persists between calls, otherwise it is in memory.
+func (e *Executor) withGitStorage(rawURL string, fn func(storage.Storer) (*glfs.Ref, error)) (*glfs.Ref, error) {
+	if e.cfg.GitCacheDir == "" {
+		return fn(memory.NewStorage())
+	}
+	sum := sha256.Sum256([]byte(rawURL))
+	dir := filepath.Join(e.cfg.GitCacheDir, hex.EncodeToString(sum[:]))
+	mu, _ := gitLocks.LoadOrStore(dir, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+	return fn(filesystem.NewStorage(osfs.New(dir), cache.NewObjectLRUDefault()))
+}
+
+// loadGitModules parses the .gitmodules file in tree, if there is one.
+func loadGitModules(tree *object.Tree) ([]*config.Submodule, error) {
+	f, err := tree.File(".gitmodules")
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	data, err := f.Contents()
+	if err != nil {
+		return nil, err
 	}
-	commit, err := object.DecodeCommit(gstor, co)
+	mods := config.NewModules()
+	if err := mods.Unmarshal([]byte(data)); err != nil {
+		return nil, fmt.Errorf("parsing .gitmodules: %w", err)
+	}
+	var ret []*config.Submodule
+	for _, sm := range mods.Submodules {
+		ret = append(ret, sm)
+	}
+	return ret, nil
+}
+
+// resolveSubmoduleURL resolves a submodule URL, which may be relative to the URL of the superproject.
+func resolveSubmoduleURL(parent, x string) (string, error) {
+	if !strings.HasPrefix(x, "./") && !strings.HasPrefix(x, "../") {
+		return x, nil
+	}
+	u, err := url.Parse(parent)
+	if err != nil {
+		return "", err
+	}
+	u.Path = path.Join(u.Path, x)
+	return u.String(), nil
+}
+
+// lfsPointer is the content of a Git LFS pointer file.
+type lfsPointer struct {
+	OID  string
+	Size int64
+}
+
+const maxLFSPointerSize = 1024
+
+// parseLFSPointer parses data as an LFS pointer file, returning false if it is not one.
+func parseLFSPointer(data []byte) (*lfsPointer, bool) {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) < 3 || !strings.HasPrefix(lines[0], "version https://git-lfs.github.com/spec/") {
+		return nil, false
+	}
+	var ptr lfsPointer
+	for _, line := range lines[1:] {
+		k, v, _ := strings.Cut(line, " ")
+		switch k {
+		case "oid":
+			oid, ok := strings.CutPrefix(v, "sha256:")
+			if !ok {
+				return nil, false
+			}
+			ptr.OID = oid
+		case "size":
+			if _, err := fmt.Sscanf(v, "%d", &ptr.Size); err != nil {
+				return nil, false
+			}
+		}
+	}
+	if len(ptr.OID) != 64 {
+		return nil, false
+	}
+	return &ptr, true
+}
+
+// importLFSBlob imports gb, replacing it with the LFS object it points to if it is an LFS pointer.
+func (e *Executor) importLFSBlob(jc wantjob.Ctx, dst cadata.PostExister, endpoint string, gb *object.Blob) (*glfs.Ref, error) {
+	ctx := jc.Context
+	if gb.Size > maxLFSPointerSize {
+		return glfsgit.ImportBlob(ctx, dst, nil, gb)
+	}
+	rc, err := gb.Reader()
 	if err != nil {
 		return nil, err
 	}
-	tree, err := commit.Tree()
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
 	if err != nil {
 		return nil, err
 	}
-	return glfsgit.ImportTree(ctx, dst, gstor, tree)
+	ptr, ok := parseLFSPointer(data)
+	if !ok {
+		return glfs.PostBlob(ctx, dst, bytes.NewReader(data))
+	}
+	return e.fetchLFS(jc, dst, endpoint, *ptr)
 }
@@ -0,0 +1,136 @@
+package importops
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"blobcache.io/glfs"
+	"github.com/stretchr/testify/require"
+
+	"wantbuild.io/want/src/internal/stores"
+	"wantbuild.io/want/src/internal/testutil"
+	"wantbuild.io/want/src/wantjob"
+)
+
+func TestImportGitSubmodulesLFS(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	ctx := testutil.Context(t)
+	lfsData := []byte("large file contents")
+	lfsSum := sha256.Sum256(lfsData)
+	lfsOID := hex.EncodeToString(lfsSum[:])
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lfs/objects/batch":
+			var req lfsBatchRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			var res lfsBatchResponse
+			for _, obj := range req.Objects {
+				obj.Actions.Download = &struct {
+					Href   string            `json:"href"`
+					Header map[string]string `json:"header"`
+				}{Href: srv.URL + "/objects/" + obj.OID}
+				res.Objects = append(res.Objects, obj)
+			}
+			w.Header().Set("Content-Type", lfsMediaType)
+			json.NewEncoder(w).Encode(res)
+		case "/objects/" + lfsOID:
+			w.Write(lfsData)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	subDir := filepath.Join(dir, "sub")
+	gitCmd(t, "", "init", "-q", subDir)
+	gitCmd(t, subDir, "config", "uploadpack.allowAnySHA1InWant", "true")
+	require.NoError(t, os.WriteFile(filepath.Join(subDir, "a.txt"), []byte("in submodule"), 0o644))
+	gitCmd(t, subDir, "add", ".")
+	gitCmd(t, subDir, "commit", "-q", "-m", "sub")
+
+	superDir := filepath.Join(dir, "super")
+	gitCmd(t, "", "init", "-q", superDir)
+	gitCmd(t, superDir, "config", "uploadpack.allowAnySHA1InWant", "true")
+	pointer := fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", lfsOID, len(lfsData))
+	require.NoError(t, os.WriteFile(filepath.Join(superDir, "big.bin"), []byte(pointer), 0o644))
+	gitCmd(t, superDir, "-c", "protocol.file.allow=always", "submodule", "add", "-q", "../sub", "sub")
+	gitCmd(t, superDir, "add", ".")
+	gitCmd(t, superDir, "commit", "-q", "-m", "super")
+	commitHash := strings.TrimSpace(gitCmd(t, superDir, "rev-parse", "HEAD"))
+
+	task := ImportGitTask{
+		URL:        superDir,
+		CommitHash: commitHash,
+		Submodules: true,
+		LFS:        srv.URL + "/lfs",
+	}
+	cacheDir := t.TempDir()
+	s := stores.NewMem()
+	jc := wantjob.Ctx{Context: ctx, Dst: s}
+	ref, err := NewExecutor(Config{GitCacheDir: cacheDir}).ImportGit(jc, s, task)
+	require.NoError(t, err)
+
+	subRef, err := glfs.GetAtPath(ctx, s, *ref, "sub/a.txt")
+	require.NoError(t, err)
+	data, err := glfs.GetBlobBytes(ctx, s, *subRef, 1024)
+	require.NoError(t, err)
+	require.Equal(t, "in submodule", string(data))
+
+	bigRef, err := glfs.GetAtPath(ctx, s, *ref, "big.bin")
+	require.NoError(t, err)
+	data, err = glfs.GetBlobBytes(ctx, s, *bigRef, 1024)
+	require.NoError(t, err)
+	require.Equal(t, lfsData, data)
+
+	// the objects are in the cache, so the same commit can be imported without the network.
+	task.LFS = ""
+	ref2, err := NewExecutor(Config{GitCacheDir: cacheDir, Offline: true}).ImportGit(jc, s, task)
+	require.NoError(t, err)
+	subRef2, err := glfs.GetAtPath(ctx, s, *ref2, "sub/a.txt")
+	require.NoError(t, err)
+	require.Equal(t, *subRef, *subRef2)
+}
+
+func TestParseLFSPointer(t *testing.T) {
+	ptr, ok := parseLFSPointer([]byte("version https://git-lfs.github.com/spec/v1\noid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\nsize 12345\n"))
+	require.True(t, ok)
+	require.Equal(t, lfsPointer{OID: "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393", Size: 12345}, *ptr)
+
+	_, ok = parseLFSPointer([]byte("hello world\n"))
+	require.False(t, ok)
+}
+
+func TestResolveSubmoduleURL(t *testing.T) {
+	for _, tc := range []struct{ Parent, X, Out string }{
+		{"https://example.com/org/repo.git", "../other.git", "https://example.com/org/other.git"},
+		{"https://example.com/org/repo", "./nested", "https://example.com/org/repo/nested"},
+		{"https://example.com/org/repo", "https://other.com/x.git", "https://other.com/x.git"},
+	} {
+		out, err := resolveSubmoduleURL(tc.Parent, tc.X)
+		require.NoError(t, err)
+		require.Equal(t, tc.Out, out)
+	}
+}
+
+func gitCmd(t testing.TB, dir string, args ...string) string {
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return string(out)
+}
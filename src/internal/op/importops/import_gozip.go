@@ -2,11 +2,12 @@ package importops
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/url"
 	"os"
+	"strings"
 
 	"blobcache.io/glfs"
 	"go.brendoncarroll.net/state/cadata"
@@ -36,21 +37,21 @@ func MarshalGoZipTask(x ImportGoZipTask) []byte {
 
 // ImportGoZip imports and validates a go module zip file
 func (e *Executor) ImportGoZip(jc wantjob.Ctx, s cadata.Getter, x ImportGoZipTask) (*glfs.Ref, error) {
-	const proxy = "https://proxy.golang.org"
-	escapedPath, err := module.EscapePath(x.Path)
-	if err != nil {
-		return nil, err
-	}
-	escapedVersion, err := module.EscapeVersion(x.Version)
+	algo, sum, err := parseGoZipHash(x.Hash)
 	if err != nil {
 		return nil, err
 	}
-	rawURL := fmt.Sprintf("%s/%s/@v/%s.zip", proxy, escapedPath, escapedVersion)
-	u, err := url.Parse(rawURL)
-	if err != nil {
-		panic(err)
+	if e.vendor != nil {
+		f, err := e.vendor.Open("gozip", algo, sum)
+		if err == nil {
+			defer f.Close()
+			jc.Infof("vendor %s@%s", x.Path, x.Version)
+			return e.importGoZip(jc, x, f)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
 	}
-	rc, err := e.downloadHTTP(jc, u)
+	rc, err := e.fetchGoZip(jc, x)
 	if err != nil {
 		return nil, err
 	}
@@ -64,15 +65,80 @@ func (e *Executor) ImportGoZip(jc wantjob.Ctx, s cadata.Getter, x ImportGoZipTas
 	if _, err := io.Copy(zipFile, rc); err != nil {
 		return nil, err
 	}
-	actual, err := dirhash.HashZip(zipFile.Name(), dirhash.Hash1)
-	if err != nil {
+	if _, err := zipFile.Seek(0, io.SeekStart); err != nil {
 		return nil, err
 	}
-	if actual != x.Hash {
-		return nil, fmt.Errorf("dirHash for %s@%s does not match HAVE: %v, WANT: %v", x.Path, x.Version, actual, x.Hash)
+	return e.importGoZip(jc, x, zipFile)
+}
+
+// VendorGoZip downloads the module zip for x into the vendor directory, if it is not already there.
+func (e *Executor) VendorGoZip(jc wantjob.Ctx, x ImportGoZipTask) error {
+	algo, sum, err := parseGoZipHash(x.Hash)
+	if err != nil {
+		return err
 	}
-	if _, err := zipFile.Seek(0, io.SeekStart); err != nil {
+	if yes, err := e.vendor.Has("gozip", algo, sum); err != nil || yes {
+		return err
+	}
+	rc, err := e.fetchGoZip(jc, x)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return e.vendor.Put("gozip", algo, sum, rc, func(f *os.File) error {
+		return checkGoZip(x, f)
+	})
+}
+
+func (e *Executor) importGoZip(jc wantjob.Ctx, x ImportGoZipTask, zipFile *os.File) (*glfs.Ref, error) {
+	if err := checkGoZip(x, zipFile); err != nil {
 		return nil, err
 	}
 	return importStream(jc.Context, jc.Dst, zipFile, nil, []string{"unzip"})
 }
+
+// fetchGoZip downloads the module zip from the first configured proxy which has it.
+func (e *Executor) fetchGoZip(jc wantjob.Ctx, x ImportGoZipTask) (io.ReadCloser, error) {
+	escapedPath, err := module.EscapePath(x.Path)
+	if err != nil {
+		return nil, err
+	}
+	escapedVersion, err := module.EscapeVersion(x.Version)
+	if err != nil {
+		return nil, err
+	}
+	var urls []string
+	for _, proxy := range e.cfg.goProxies() {
+		urls = append(urls, fmt.Sprintf("%s/%s/@v/%s.zip", proxy, escapedPath, escapedVersion))
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no Go module proxies configured for %s@%s", x.Path, x.Version)
+	}
+	return e.fetch(jc, urls)
+}
+
+// checkGoZip checks the dirhash of zipFile, and leaves it seeked to the start.
+func checkGoZip(x ImportGoZipTask, zipFile *os.File) error {
+	actual, err := dirhash.HashZip(zipFile.Name(), dirhash.Hash1)
+	if err != nil {
+		return err
+	}
+	if actual != x.Hash {
+		return fmt.Errorf("dirHash for %s@%s does not match HAVE: %v, WANT: %v", x.Path, x.Version, actual, x.Hash)
+	}
+	_, err = zipFile.Seek(0, io.SeekStart)
+	return err
+}
+
+// parseGoZipHash splits a go.sum style hash into an algorithm and a sum.
+func parseGoZipHash(x string) (string, []byte, error) {
+	algo, b64, ok := strings.Cut(x, ":")
+	if !ok {
+		return "", nil, fmt.Errorf("invalid go module hash %q", x)
+	}
+	sum, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid go module hash %q: %w", x, err)
+	}
+	return algo, sum, nil
+}
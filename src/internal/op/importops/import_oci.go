@@ -2,21 +2,26 @@ package importops
 
 import (
 	"archive/tar"
+	"bytes"
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"strconv"
 
 	"blobcache.io/glfs"
-	"blobcache.io/glfs/glfstar"
 	crname "github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"go.brendoncarroll.net/state/cadata"
 
+	"wantbuild.io/want/src/internal/glfsport"
 	"wantbuild.io/want/src/wantjob"
 )
 
@@ -32,71 +37,145 @@ func GetImportOCIImageTask(ctx context.Context, s cadata.Getter, x glfs.Ref) (*I
 
 func (e *Executor) ImportOCIImage(jc wantjob.Ctx, s cadata.Getter, task ImportOCIImageTask) (*glfs.Ref, error) {
 	ag := glfs.NewAgent()
-	if len(task.Hash) < 40 {
-		return nil, fmt.Errorf("hash too short %q len=%d", task.Hash, len(task.Hash))
-	}
-	if _, err := hex.DecodeString(task.Hash); err != nil {
-		return nil, err
-	}
-	ref, err := crname.ParseReference(fmt.Sprintf("%s@%s:%s", task.Name, task.Algo, task.Hash), crname.StrictValidation)
+	img, err := e.openOCIImage(jc, task)
 	if err != nil {
 		return nil, err
 	}
-	img, err := remote.Image(ref)
-	if err != nil {
-		return nil, fmt.Errorf("reading image failed: %w", err)
-	}
-	jc.Infof("oci: %v", ref)
 	rc := mutate.Extract(img)
 	defer rc.Close()
 	tr := tar.NewReader(rc)
 	ctx := jc.Context
-	return glfstar.ReadTAR(ctx, ag, jc.Dst, tr)
+	return readTAR(ctx, ag, jc.Dst, tr)
 }
 
-type ImportOCIManifestTask struct {
-	Name string
-	Algo string
-	Hash string
+// openOCIImage returns the image for task from the vendor directory if it is there, or from a registry.
+func (e *Executor) openOCIImage(jc wantjob.Ctx, task ImportOCIImageTask) (v1.Image, error) {
+	sum, err := parseOCIHash(task.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if e.vendor != nil {
+		if yes, err := e.vendor.Has("oci", task.Algo, sum); err != nil {
+			return nil, err
+		} else if yes {
+			jc.Infof("vendor %s@%s:%s", task.Name, task.Algo, task.Hash)
+			return e.vendoredOCIImage(task, sum)
+		}
+	}
+	return e.fetchOCIImage(jc, task)
 }
 
-func (e *Executor) ImportOCIManifest(ctx context.Context, _ *wantjob.Ctx, s cadata.Getter, task ImportOCIManifestTask) (*v1.Manifest, error) {
-	if len(task.Hash) < 40 {
-		return nil, fmt.Errorf("hash too short %q len=%d", task.Hash, len(task.Hash))
+func (e *Executor) vendoredOCIImage(task ImportOCIImageTask, sum []byte) (v1.Image, error) {
+	p, err := layout.FromPath(e.vendor.path("oci", task.Algo, sum))
+	if err != nil {
+		return nil, err
 	}
-	if _, err := hex.DecodeString(task.Hash); err != nil {
+	want := v1.Hash{Algorithm: task.Algo, Hex: task.Hash}
+	img, err := p.Image(want)
+	if err != nil {
 		return nil, err
 	}
-	ref, err := crname.ParseReference(fmt.Sprintf("%s@%s:%s", task.Name, task.Algo, task.Hash), crname.StrictValidation)
+	actual, err := img.Digest()
 	if err != nil {
 		return nil, err
 	}
-	// Create an anonymous (unauthenticated) client
-	img, err := remote.Image(ref)
+	if actual != want {
+		return nil, fmt.Errorf("oci: vendored image digest does not match HAVE: %v WANT: %v", actual, want)
+	}
+	return img, nil
+}
+
+// fetchOCIImage tries each mirror of the image name in order.
+// The image is always referenced by digest, so the registry's content is verified.
+func (e *Executor) fetchOCIImage(jc wantjob.Ctx, task ImportOCIImageTask) (v1.Image, error) {
+	if e.cfg.Offline {
+		return nil, ErrOffline
+	}
+	var errs []error
+	for _, name := range e.cfg.mirrorURLs(task.Name) {
+		ref, err := crname.ParseReference(fmt.Sprintf("%s@%s:%s", name, task.Algo, task.Hash), crname.StrictValidation)
+		if err != nil {
+			return nil, err
+		}
+		img, err := remote.Image(ref, remote.WithContext(jc.Context))
+		if err != nil {
+			jc.Infof("oci %v: %v", ref, err)
+			errs = append(errs, err)
+			continue
+		}
+		jc.Infof("oci: %v", ref)
+		return img, nil
+	}
+	return nil, fmt.Errorf("reading image failed: %w", errors.Join(errs...))
+}
+
+// VendorOCIImage writes the image for task to the vendor directory, if it is not already there.
+func (e *Executor) VendorOCIImage(jc wantjob.Ctx, task ImportOCIImageTask) error {
+	sum, err := parseOCIHash(task.Hash)
 	if err != nil {
-		return nil, fmt.Errorf("reading image failed: %w", err)
+		return err
+	}
+	if yes, err := e.vendor.Has("oci", task.Algo, sum); err != nil || yes {
+		return err
 	}
-	// Fetch and print the manifest
-	manifest, err := img.Manifest()
+	img, err := e.fetchOCIImage(jc, task)
 	if err != nil {
-		return nil, fmt.Errorf("fetching manifest failed: %w", err)
+		return err
 	}
-	return manifest, nil
+	return e.vendor.put("oci", task.Algo, sum, func(p string) error {
+		lp, err := layout.Write(p, empty.Index)
+		if err != nil {
+			return err
+		}
+		return lp.AppendImage(img)
+	})
 }
 
-type ImportOCILayerTask struct {
-	Name       string
-	Descriptor v1.Descriptor
+func parseOCIHash(x string) ([]byte, error) {
+	if len(x) < 40 {
+		return nil, fmt.Errorf("hash too short %q len=%d", x, len(x))
+	}
+	return hex.DecodeString(x)
 }
 
-func (e *Executor) ImportOCILayer(jc *wantjob.Ctx, dst cadata.Store, s cadata.Getter, task ImportOCILayerTask) (*glfs.Ref, error) {
-	jc.Infof("name: %v", task.Name)
-	jc.Infof("descriptor: %v", task.Descriptor)
-	dig, err := crname.NewDigest(task.Name+"@"+task.Descriptor.Digest.String(), crname.StrictValidation)
+// ImportOCIManifestTask imports the raw manifest of an image.
+type ImportOCIManifestTask struct {
+	Name string `json:"name"`
+	Algo string `json:"algo"`
+	Hash string `json:"hash"`
+}
+
+func GetImportOCIManifestTask(ctx context.Context, s cadata.Getter, x glfs.Ref) (*ImportOCIManifestTask, error) {
+	return loadJSON[ImportOCIManifestTask](ctx, s, x)
+}
+
+// ImportOCIManifest returns a blob containing the image manifest exactly as it is stored in the registry.
+func (e *Executor) ImportOCIManifest(jc wantjob.Ctx, task ImportOCIManifestTask) (*glfs.Ref, error) {
+	img, err := e.openOCIImage(jc, ImportOCIImageTask(task))
 	if err != nil {
 		return nil, err
 	}
-	layer, err := remote.Layer(dig)
+	data, err := img.RawManifest()
+	if err != nil {
+		return nil, fmt.Errorf("fetching manifest failed: %w", err)
+	}
+	return glfs.PostBlob(jc.Context, jc.Dst, bytes.NewReader(data))
+}
+
+// ImportOCILayerTask imports a single layer of an image.
+type ImportOCILayerTask struct {
+	Name       string        `json:"name"`
+	Descriptor v1.Descriptor `json:"descriptor"`
+}
+
+func GetImportOCILayerTask(ctx context.Context, s cadata.Getter, x glfs.Ref) (*ImportOCILayerTask, error) {
+	return loadJSON[ImportOCILayerTask](ctx, s, x)
+}
+
+// ImportOCILayer imports the layer as a tree, without applying whiteouts.
+// Layers are merged with MergeOCILayers.
+func (e *Executor) ImportOCILayer(jc wantjob.Ctx, task ImportOCILayerTask) (*glfs.Ref, error) {
+	layer, err := e.openOCILayer(jc, task)
 	if err != nil {
 		return nil, err
 	}
@@ -107,15 +186,124 @@ func (e *Executor) ImportOCILayer(jc *wantjob.Ctx, dst cadata.Store, s cadata.Ge
 	defer rc.Close()
 	ag := glfs.NewAgent()
 	tr := tar.NewReader(rc)
-	ctx := jc.Context
-	return glfstar.ReadTAR(ctx, ag, dst, tr)
+	return readTAR(jc.Context, ag, jc.Dst, tr)
+}
+
+// openOCILayer returns the layer from the vendor directory if it is there, or from a registry.
+// Either way the layer's content is checked against its digest as it is read.
+func (e *Executor) openOCILayer(jc wantjob.Ctx, task ImportOCILayerTask) (v1.Layer, error) {
+	dig := task.Descriptor.Digest
+	sum, err := parseOCIHash(dig.Hex)
+	if err != nil {
+		return nil, err
+	}
+	if e.vendor != nil {
+		if yes, err := e.vendor.Has("ocilayer", dig.Algorithm, sum); err != nil {
+			return nil, err
+		} else if yes {
+			jc.Infof("vendor %s@%v", task.Name, dig)
+			layer, err := tarball.LayerFromFile(e.vendor.path("ocilayer", dig.Algorithm, sum))
+			if err != nil {
+				return nil, err
+			}
+			actual, err := layer.Digest()
+			if err != nil {
+				return nil, err
+			}
+			if actual != dig {
+				return nil, fmt.Errorf("oci: vendored layer digest does not match HAVE: %v WANT: %v", actual, dig)
+			}
+			return layer, nil
+		}
+	}
+	return e.fetchOCILayer(jc, task)
+}
+
+func (e *Executor) fetchOCILayer(jc wantjob.Ctx, task ImportOCILayerTask) (v1.Layer, error) {
+	if e.cfg.Offline {
+		return nil, ErrOffline
+	}
+	var errs []error
+	for _, name := range e.cfg.mirrorURLs(task.Name) {
+		dig, err := crname.NewDigest(name+"@"+task.Descriptor.Digest.String(), crname.StrictValidation)
+		if err != nil {
+			return nil, err
+		}
+		layer, err := remote.Layer(dig, remote.WithContext(jc.Context))
+		if err != nil {
+			jc.Infof("oci %v: %v", dig, err)
+			errs = append(errs, err)
+			continue
+		}
+		jc.Infof("oci: %v", dig)
+		return layer, nil
+	}
+	return nil, fmt.Errorf("reading layer failed: %w", errors.Join(errs...))
 }
 
+// VendorOCILayer writes the compressed layer to the vendor directory, if it is not already there.
+func (e *Executor) VendorOCILayer(jc wantjob.Ctx, task ImportOCILayerTask) error {
+	dig := task.Descriptor.Digest
+	sum, err := parseOCIHash(dig.Hex)
+	if err != nil {
+		return err
+	}
+	if yes, err := e.vendor.Has("ocilayer", dig.Algorithm, sum); err != nil || yes {
+		return err
+	}
+	layer, err := e.fetchOCILayer(jc, task)
+	if err != nil {
+		return err
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return e.vendor.Put("ocilayer", dig.Algorithm, sum, rc, func(f *os.File) error {
+		actual, _, err := v1.SHA256(f)
+		if err != nil {
+			return err
+		}
+		if actual != dig {
+			return fmt.Errorf("oci: layer digest does not match HAVE: %v WANT: %v", actual, dig)
+		}
+		return nil
+	})
+}
+
+// MergeOCILayersTask applies layers in order, to produce a root filesystem.
 type MergeOCILayersTask struct {
 	Layers []glfs.Ref
 }
 
-func (e *Executor) MergeOCILayers(jc *wantjob.Ctx, dst cadata.PostExister, s cadata.Getter, task MergeOCILayersTask) (*glfs.Ref, error) {
+// PostMergeOCILayersTask posts a tree with an entry for each layer, named by its index.
+func PostMergeOCILayersTask(ctx context.Context, s cadata.PostExister, task MergeOCILayersTask) (*glfs.Ref, error) {
+	var ents []glfs.TreeEntry
+	for i, layer := range task.Layers {
+		ents = append(ents, glfs.TreeEntry{Name: strconv.Itoa(i), FileMode: 0o755, Ref: layer})
+	}
+	return glfs.PostTreeSlice(ctx, s, ents)
+}
+
+func GetMergeOCILayersTask(ctx context.Context, s cadata.Getter, x glfs.Ref) (*MergeOCILayersTask, error) {
+	ents, err := glfs.GetTreeSlice(ctx, s, x, 1e6)
+	if err != nil {
+		return nil, err
+	}
+	layers := make([]glfs.Ref, len(ents))
+	for _, ent := range ents {
+		i, err := strconv.Atoi(ent.Name)
+		if err != nil || i < 0 || i >= len(ents) {
+			return nil, fmt.Errorf("merge oci layers: entries must be named 0 to %d, found %q", len(ents)-1, ent.Name)
+		}
+		layers[i] = ent.Ref
+	}
+	return &MergeOCILayersTask{Layers: layers}, nil
+}
+
+// MergeOCILayers applies the layers in order, including whiteouts.
+func (e *Executor) MergeOCILayers(jc wantjob.Ctx, s cadata.Getter, task MergeOCILayersTask) (*glfs.Ref, error) {
 	ctx := jc.Context
 	ag := glfs.NewAgent()
 	img := empty.Image
@@ -123,7 +311,7 @@ func (e *Executor) MergeOCILayers(jc *wantjob.Ctx, dst cadata.PostExister, s cad
 		l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
 			rc := invertStream(func(w io.Writer) error {
 				tw := tar.NewWriter(w)
-				if err := glfstar.WriteTAR(ctx, ag, s, layer, tw); err != nil {
+				if err := glfsport.WriteTAR(ctx, s, layer, tw); err != nil {
 					return err
 				}
 				return tw.Close()
@@ -141,7 +329,7 @@ func (e *Executor) MergeOCILayers(jc *wantjob.Ctx, dst cadata.PostExister, s cad
 	rc := mutate.Extract(img)
 	defer rc.Close()
 	tr := tar.NewReader(rc)
-	return glfstar.ReadTAR(ctx, ag, dst, tr)
+	return readTAR(ctx, ag, jc.Dst, tr)
 }
 
 func invertStream(fn func(w io.Writer) error) io.ReadCloser {
@@ -16,13 +16,10 @@ import (
 	"fmt"
 	"hash"
 	"io"
-	"net/http"
 	"net/url"
 	"os"
 
 	"blobcache.io/glfs"
-	"blobcache.io/glfs/glfstar"
-	"blobcache.io/glfs/glfszip"
 	"github.com/klauspost/compress/zstd"
 	"github.com/pkg/errors"
 	"github.com/ulikunitz/xz"
@@ -79,8 +76,7 @@ func DeriveFromURL(ctx context.Context, gb GraphBuilder, s cadata.Poster, x Impo
 
 // ImportURL imports data from a URL, checking that it matches a hash, and applying any transformations.
 func (e *Executor) ImportURL(jc wantjob.Ctx, spec ImportURLTask) (*glfs.Ref, error) {
-	u, err := url.Parse(spec.URL)
-	if err != nil {
+	if _, err := url.Parse(spec.URL); err != nil {
 		return nil, err
 	}
 	newHash, err := makeHashFactory(spec.Algo)
@@ -91,10 +87,13 @@ func (e *Executor) ImportURL(jc wantjob.Ctx, spec ImportURLTask) (*glfs.Ref, err
 	if err != nil {
 		return nil, err
 	}
-	var stages = []pipelineStage{
-		checkHash(newHash(), sum),
+	var stages []pipelineStage
+	if sum != nil {
+		stages = append(stages, checkHash(newHash(), sum))
+	} else {
+		jc.Infof("import-url: %s has no hash, so its contents are not verified", spec.URL)
 	}
-	r, err := e.download(jc, u)
+	r, err := e.openURL(jc, spec, sum)
 	if err != nil {
 		return nil, err
 	}
@@ -102,6 +101,43 @@ func (e *Executor) ImportURL(jc wantjob.Ctx, spec ImportURLTask) (*glfs.Ref, err
 	return importStream(jc.Context, jc.Dst, r, stages, spec.Transforms)
 }
 
+// openURL returns the content for spec from the vendor directory if it is there, or from the network.
+func (e *Executor) openURL(jc wantjob.Ctx, spec ImportURLTask, sum []byte) (io.ReadCloser, error) {
+	if e.vendor != nil && len(sum) > 0 {
+		f, err := e.vendor.Open("url", spec.Algo, sum)
+		if err == nil {
+			jc.Infof("vendor %v", spec.URL)
+			return f, nil
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return e.fetch(jc, e.cfg.mirrorURLs(spec.URL))
+}
+
+// VendorURL downloads the content for spec into the vendor directory, if it is not already there.
+func (e *Executor) VendorURL(jc wantjob.Ctx, spec ImportURLTask) error {
+	newHash, err := makeHashFactory(spec.Algo)
+	if err != nil {
+		return err
+	}
+	sum, err := parseHash(newHash().Size(), spec.Hash)
+	if err != nil {
+		return err
+	}
+	if yes, err := e.vendor.Has("url", spec.Algo, sum); err != nil || yes {
+		return err
+	}
+	rc, err := e.fetch(jc, e.cfg.mirrorURLs(spec.URL))
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return e.vendor.Put("url", spec.Algo, sum, rc, func(f *os.File) error {
+		return checkHash(newHash(), sum)(io.Discard, f)
+	})
+}
+
 type pipelineStage = func(w io.Writer, r io.Reader) error
 
 func importStream(ctx context.Context, dst cadata.PostExister, r io.Reader, stages []pipelineStage, transforms []string) (*glfs.Ref, error) {
@@ -197,7 +233,7 @@ func checkHash(h hash.Hash, sum []byte) pipelineStage {
 
 func untar(ctx context.Context, op *glfs.Agent, s cadata.PostExister, r io.Reader) (*glfs.Ref, error) {
 	tr := tar.NewReader(r)
-	ref, err := glfstar.ReadTAR(ctx, op, s, tr)
+	ref, err := readTAR(ctx, op, s, tr)
 	if err != nil {
 		return nil, err
 	}
@@ -224,7 +260,7 @@ func unzip(ctx context.Context, op *glfs.Agent, s cadata.PostExister, r io.Reade
 	if err != nil {
 		return nil, err
 	}
-	return glfszip.Import(ctx, op, s, zr)
+	return readZip(ctx, op, s, zr)
 }
 
 func pipeline(r io.Reader, stages []func(w io.Writer, r io.Reader) error, collect func(r io.Reader) (*glfs.Ref, error)) (*glfs.Ref, error) {
@@ -259,32 +295,6 @@ func pipeline(r io.Reader, stages []func(w io.Writer, r io.Reader) error, collec
 	return ret, nil
 }
 
-func (e *Executor) download(jc wantjob.Ctx, u *url.URL) (io.ReadCloser, error) {
-	switch u.Scheme {
-	case "http", "https":
-		return e.downloadHTTP(jc, u)
-	default:
-		return nil, errors.New("url scheme not supported")
-	}
-}
-
-func (e *Executor) downloadHTTP(jc wantjob.Ctx, u *url.URL) (io.ReadCloser, error) {
-	req, err := http.NewRequestWithContext(jc.Context, http.MethodGet, u.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	res, err := e.hc.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	jc.Infof("http %v %v", res.Status, u.String())
-	if res.StatusCode != 200 {
-		res.Body.Close()
-		return nil, errors.Errorf("got non 200 status %s", res.Status)
-	}
-	return res.Body, nil
-}
-
 func makeHashFactory(algo string) (func() hash.Hash, error) {
 	var y func() hash.Hash
 	switch algo {
@@ -18,17 +18,34 @@ const (
 	OpFromGoZip    = wantjob.OpName("fromGoZip")
 	OpFromOCIImage = wantjob.OpName("fromOCIImage")
 
+	OpFromOCIManifest = wantjob.OpName("fromOCIManifest")
+	OpFromOCILayer    = wantjob.OpName("fromOCILayer")
+	OpMergeOCILayers  = wantjob.OpName("mergeOCILayers")
+
+	OpFromNPMLock   = wantjob.OpName("fromNPMLock")
+	OpFromCargoLock = wantjob.OpName("fromCargoLock")
+
 	OpUnpack = wantjob.OpName("unpack")
 )
 
 var _ wantjob.Executor = &Executor{}
 
 type Executor struct {
-	hc *http.Client
+	// FromURLOp is the name of OpFromURL in the job system.
+	// If set, each package pinned by a lockfile is imported in its own job.
+	FromURLOp wantjob.OpName
+
+	hc     *http.Client
+	cfg    Config
+	vendor *Vendor
 }
 
-func NewExecutor() *Executor {
-	return &Executor{hc: http.DefaultClient}
+func NewExecutor(cfg Config) *Executor {
+	e := &Executor{hc: http.DefaultClient, cfg: cfg}
+	if cfg.VendorDir != "" {
+		e.vendor = NewVendor(cfg.VendorDir)
+	}
+	return e
 }
 
 func (e *Executor) Execute(jc wantjob.Ctx, s cadata.Getter, x wantjob.Task) wantjob.Result {
@@ -49,7 +66,7 @@ func (e *Executor) Execute(jc wantjob.Ctx, s cadata.Getter, x wantjob.Task) want
 			if err != nil {
 				return nil, err
 			}
-			return e.ImportGit(ctx, jc.Dst, *spec)
+			return e.ImportGit(jc, jc.Dst, *spec)
 		})
 	case OpUnpack:
 		return glfstasks.Exec(x.Input, func(x glfs.Ref) (*glfs.Ref, error) {
@@ -75,6 +92,38 @@ func (e *Executor) Execute(jc wantjob.Ctx, s cadata.Getter, x wantjob.Task) want
 			}
 			return e.ImportOCIImage(jc, s, *spec)
 		})
+	case OpFromOCIManifest:
+		return glfstasks.Exec(x.Input, func(x glfs.Ref) (*glfs.Ref, error) {
+			spec, err := GetImportOCIManifestTask(ctx, s, x)
+			if err != nil {
+				return nil, err
+			}
+			return e.ImportOCIManifest(jc, *spec)
+		})
+	case OpFromOCILayer:
+		return glfstasks.Exec(x.Input, func(x glfs.Ref) (*glfs.Ref, error) {
+			spec, err := GetImportOCILayerTask(ctx, s, x)
+			if err != nil {
+				return nil, err
+			}
+			return e.ImportOCILayer(jc, *spec)
+		})
+	case OpMergeOCILayers:
+		return glfstasks.Exec(x.Input, func(x glfs.Ref) (*glfs.Ref, error) {
+			spec, err := GetMergeOCILayersTask(ctx, s, x)
+			if err != nil {
+				return nil, err
+			}
+			return e.MergeOCILayers(jc, s, *spec)
+		})
+	case OpFromNPMLock:
+		return glfstasks.Exec(x.Input, func(x glfs.Ref) (*glfs.Ref, error) {
+			return e.ImportNPMLock(jc, s, x)
+		})
+	case OpFromCargoLock:
+		return glfstasks.Exec(x.Input, func(x glfs.Ref) (*glfs.Ref, error) {
+			return e.ImportCargoLock(jc, s, x)
+		})
 	default:
 		return *wantjob.Result_ErrExec(wantjob.NewErrUnknownOperator(x.Op))
 	}
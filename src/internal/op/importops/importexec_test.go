@@ -1,10 +1,12 @@
 package importops
 
 import (
+	"bytes"
 	"strconv"
 	"testing"
 
 	"blobcache.io/glfs"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
 
@@ -35,7 +37,7 @@ func TestImportURL(t *testing.T) {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
 			t.Parallel()
 			s := stores.NewMem()
-			e := NewExecutor()
+			e := NewExecutor(Config{})
 			jc := wantjob.Ctx{Context: ctx, Dst: s}
 			y, err := e.ImportURL(jc, tc)
 			require.NoError(t, err)
@@ -59,7 +61,7 @@ func TestImportGoZip(t *testing.T) {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
 			t.Parallel()
 			s := stores.NewMem()
-			e := NewExecutor()
+			e := NewExecutor(Config{})
 			jc := wantjob.Ctx{Context: ctx, Dst: s}
 			y, err := e.ImportGoZip(jc, s, tc)
 			require.NoError(t, err)
@@ -87,7 +89,7 @@ func TestImportOCIImage(t *testing.T) {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
 			t.Parallel()
 			s := stores.NewMem()
-			e := NewExecutor()
+			e := NewExecutor(Config{})
 			jc := wantjob.Ctx{Context: ctx, Dst: s}
 			ref, err := e.ImportOCIImage(jc, s, tc)
 			require.NoError(t, err)
@@ -112,11 +114,14 @@ func TestImportOCILayers(t *testing.T) {
 	for i, tc := range tcs {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
 			s := stores.NewMem()
-			e := NewExecutor()
+			e := NewExecutor(Config{})
 			jc := wantjob.Ctx{Context: ctx, Dst: s}
-			mf, err := e.ImportOCIManifest(ctx, &jc, s, tc)
+			mfRef, err := e.ImportOCIManifest(jc, tc)
+			require.NoError(t, err)
+			mfData, err := glfs.GetBlobBytes(ctx, s, *mfRef, MaxConfigSize)
+			require.NoError(t, err)
+			mf, err := v1.ParseManifest(bytes.NewReader(mfData))
 			require.NoError(t, err)
-			require.NotNil(t, mf)
 
 			eg, _ := errgroup.WithContext(ctx)
 			layers := make([]glfs.Ref, len(mf.Layers))
@@ -124,7 +129,7 @@ func TestImportOCILayers(t *testing.T) {
 				i := i
 				desc := desc
 				eg.Go(func() error {
-					ref, err := e.ImportOCILayer(&jc, s, s, ImportOCILayerTask{
+					ref, err := e.ImportOCILayer(jc, ImportOCILayerTask{
 						Name:       tc.Name,
 						Descriptor: desc,
 					})
@@ -135,7 +140,7 @@ func TestImportOCILayers(t *testing.T) {
 				})
 			}
 			require.NoError(t, eg.Wait())
-			ref, err := e.MergeOCILayers(&jc, s, s, MergeOCILayersTask{
+			ref, err := e.MergeOCILayers(jc, s, MergeOCILayersTask{
 				Layers: layers,
 			})
 			require.NoError(t, err)
@@ -159,8 +164,9 @@ func TestImportGit(t *testing.T) {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
 			t.Parallel()
 			s := stores.NewMem()
-			e := NewExecutor()
-			y, err := e.ImportGit(ctx, s, tc)
+			e := NewExecutor(Config{})
+			jc := wantjob.Ctx{Context: ctx, Dst: s}
+			y, err := e.ImportGit(jc, s, tc)
 			require.NoError(t, err)
 			require.NotNil(t, y)
 		})
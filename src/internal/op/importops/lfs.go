@@ -0,0 +1,142 @@
+package importops
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"blobcache.io/glfs"
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/wantjob"
+)
+
+const lfsMediaType = "application/vnd.git-lfs+json"
+
+type lfsBatchRequest struct {
+	Operation string      `json:"operation"`
+	Transfers []string    `json:"transfers"`
+	Objects   []lfsObject `json:"objects"`
+	HashAlgo  string      `json:"hash_algo"`
+}
+
+type lfsBatchResponse struct {
+	Objects []lfsObject `json:"objects"`
+}
+
+type lfsObject struct {
+	OID     string `json:"oid"`
+	Size    int64  `json:"size"`
+	Actions struct {
+		Download *struct {
+			Href   string            `json:"href"`
+			Header map[string]string `json:"header"`
+		} `json:"download"`
+	} `json:"actions,omitempty"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// fetchLFS downloads the object for ptr using the LFS batch API at endpoint.
+// The object's hash and size are checked against the pointer.
+func (e *Executor) fetchLFS(jc wantjob.Ctx, dst cadata.PostExister, endpoint string, ptr lfsPointer) (*glfs.Ref, error) {
+	if e.cfg.Offline {
+		return nil, ErrOffline
+	}
+	obj, err := e.lfsBatch(jc, endpoint, ptr)
+	if err != nil {
+		return nil, err
+	}
+	dl := obj.Actions.Download
+	req, err := http.NewRequestWithContext(jc.Context, http.MethodGet, dl.Href, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range dl.Header {
+		req.Header.Set(k, v)
+	}
+	res, err := e.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	jc.Infof("http %v lfs %v", res.Status, ptr.OID)
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("lfs: got non 200 status %s", res.Status)
+	}
+	h := sha256.New()
+	cr := &countingReader{r: io.TeeReader(io.LimitReader(res.Body, ptr.Size+1), h)}
+	ref, err := glfs.PostBlob(jc.Context, dst, cr)
+	if err != nil {
+		return nil, err
+	}
+	if cr.n != ptr.Size {
+		return nil, fmt.Errorf("lfs: object %s has size %d, pointer has size %d", ptr.OID, cr.n, ptr.Size)
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != ptr.OID {
+		return nil, fmt.Errorf("lfs: checksum does not match. HAVE: %s WANT: %s", actual, ptr.OID)
+	}
+	return ref, nil
+}
+
+func (e *Executor) lfsBatch(jc wantjob.Ctx, endpoint string, ptr lfsPointer) (*lfsObject, error) {
+	reqData, err := json.Marshal(lfsBatchRequest{
+		Operation: "download",
+		Transfers: []string{"basic"},
+		Objects:   []lfsObject{{OID: ptr.OID, Size: ptr.Size}},
+		HashAlgo:  "sha256",
+	})
+	if err != nil {
+		return nil, err
+	}
+	batchURL := strings.TrimSuffix(endpoint, "/") + "/objects/batch"
+	req, err := http.NewRequestWithContext(jc.Context, http.MethodPost, batchURL, bytes.NewReader(reqData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", lfsMediaType)
+	req.Header.Set("Content-Type", lfsMediaType)
+	res, err := e.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("lfs batch: got non 200 status %s", res.Status)
+	}
+	var batch lfsBatchResponse
+	if err := json.NewDecoder(io.LimitReader(res.Body, MaxConfigSize)).Decode(&batch); err != nil {
+		return nil, fmt.Errorf("lfs batch: %w", err)
+	}
+	for _, obj := range batch.Objects {
+		if obj.OID != ptr.OID {
+			continue
+		}
+		if obj.Error != nil {
+			return nil, fmt.Errorf("lfs batch: object %s: %d %s", ptr.OID, obj.Error.Code, obj.Error.Message)
+		}
+		if obj.Actions.Download == nil {
+			return nil, fmt.Errorf("lfs batch: no download action for object %s", ptr.OID)
+		}
+		return &obj, nil
+	}
+	return nil, fmt.Errorf("lfs batch: object %s missing from response", ptr.OID)
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,82 @@
+package importops
+
+import (
+	"fmt"
+
+	"blobcache.io/glfs"
+	"golang.org/x/sync/errgroup"
+
+	"wantbuild.io/want/src/internal/glfstasks"
+	"wantbuild.io/want/src/wantjob"
+)
+
+// maxLockfileSize is the largest lockfile that will be read.
+const maxLockfileSize = 64e6
+
+// lockedPackage is a package pinned by a lockfile.
+type lockedPackage struct {
+	// Path is where the package is placed in the output.
+	Path string
+	// Archive fetches the package as a gzipped tarball with a single top level directory.
+	Archive ImportURLTask
+}
+
+// importLocked imports each package as a child job, and places its contents at its path in b.
+func (e *Executor) importLocked(jc wantjob.Ctx, b *treeBuilder, pkgs []lockedPackage) error {
+	refs := make([]glfs.Ref, len(pkgs))
+	eg := errgroup.Group{}
+	eg.SetLimit(16)
+	for i, pkg := range pkgs {
+		eg.Go(func() error {
+			ref, err := e.importPackage(jc, pkg.Archive)
+			if err != nil {
+				return fmt.Errorf("importing %s: %w", pkg.Path, err)
+			}
+			refs[i] = *ref
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+	for i, pkg := range pkgs {
+		if err := b.putTree(pkg.Path, refs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importPackage imports the archive for a single package, and returns its top level directory.
+// If the Executor has a FromURLOp, the archive is imported in a child job, so it is cached independently.
+func (e *Executor) importPackage(jc wantjob.Ctx, task ImportURLTask) (*glfs.Ref, error) {
+	ctx := jc.Context
+	var ref *glfs.Ref
+	if e.FromURLOp == "" {
+		var err error
+		if ref, err = e.ImportURL(jc, task); err != nil {
+			return nil, err
+		}
+	} else {
+		taskRef, err := PostImportURLTask(ctx, jc.Dst, task)
+		if err != nil {
+			return nil, err
+		}
+		out, outSrc, err := glfstasks.Do(ctx, jc.System, jc.Dst, e.FromURLOp, *taskRef)
+		if err != nil {
+			return nil, err
+		}
+		if err := glfstasks.FastSync(ctx, jc.Dst, outSrc, *out); err != nil {
+			return nil, err
+		}
+		ref = out
+	}
+	ents, err := glfs.GetTreeSlice(ctx, jc.Dst, *ref, 1e6)
+	if err != nil {
+		return nil, err
+	}
+	if len(ents) != 1 || !ents[0].FileMode.IsDir() {
+		return nil, fmt.Errorf("package archive %s should contain a single directory", task.URL)
+	}
+	return &ents[0].Ref, nil
+}
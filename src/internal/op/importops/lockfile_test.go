@@ -0,0 +1,170 @@
+package importops
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"blobcache.io/glfs"
+	"github.com/stretchr/testify/require"
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/internal/stores"
+	"wantbuild.io/want/src/internal/testutil"
+	"wantbuild.io/want/src/wantjob"
+)
+
+func TestImportNPMLock(t *testing.T) {
+	ctx := testutil.Context(t)
+	files := map[string][]byte{
+		"/a.tgz": writeTestTGZ(t, "package", map[string]string{"package.json": `{"name":"a"}`, "cli.js": "#!/usr/bin/env node"}),
+		"/b.tgz": writeTestTGZ(t, "b", map[string]string{"package.json": `{"name":"b"}`}),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer srv.Close()
+	integrity := func(p string) string {
+		sum := sha512.Sum512(files[p])
+		return "sha1-AAAA sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+	}
+	lock := fmt.Sprintf(`{
+		"lockfileVersion": 3,
+		"packages": {
+			"": {"name": "root"},
+			"node_modules/a": {"version": "1.0.0", "resolved": %q, "integrity": %q, "bin": {"a-cli": "./cli.js"}},
+			"node_modules/a/node_modules/b": {"version": "2.0.0", "resolved": %q, "integrity": %q},
+			"node_modules/ws": {"resolved": "packages/ws", "link": true},
+			"packages/ws": {"version": "0.0.1"}
+		}
+	}`, srv.URL+"/a.tgz", integrity("/a.tgz"), srv.URL+"/b.tgz", integrity("/b.tgz"))
+
+	s := stores.NewMem()
+	x := testutil.PostFS(t, s, map[string][]byte{"package-lock.json": []byte(lock)})
+	jc := wantjob.Ctx{Context: ctx, Dst: s}
+	ref, err := NewExecutor(Config{}).ImportNPMLock(jc, s, x)
+	require.NoError(t, err)
+
+	requireBlob(t, s, *ref, "a/package.json", `{"name":"a"}`)
+	requireBlob(t, s, *ref, "a/node_modules/b/package.json", `{"name":"b"}`)
+	requireSymlink(t, s, *ref, ".bin/a-cli", "../a/cli.js")
+	requireSymlink(t, s, *ref, "ws", "../packages/ws")
+}
+
+func TestImportCargoLock(t *testing.T) {
+	ctx := testutil.Context(t)
+	crate := writeTestTGZ(t, "foo-1.2.3", map[string]string{"Cargo.toml": "[package]\nname = \"foo\"\n"})
+	crateSum := sha256.Sum256(crate)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/crates/foo/foo-1.2.3.crate" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(crate)
+	}))
+	defer srv.Close()
+	lock := fmt.Sprintf(`# This file is automatically @generated by Cargo.
+version = 3
+
+[[package]]
+name = "foo"
+version = "1.2.3"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+checksum = "%s"
+
+[[package]]
+name = "mycrate"
+version = "0.1.0"
+dependencies = [
+ "foo",
+]
+`, hex.EncodeToString(crateSum[:]))
+
+	s := stores.NewMem()
+	x := testutil.PostFS(t, s, map[string][]byte{"Cargo.lock": []byte(lock)})
+	jc := wantjob.Ctx{Context: ctx, Dst: s}
+	e := NewExecutor(Config{
+		Mirrors: map[string][]string{CratesIOBaseURL + "/": {srv.URL + "/crates/"}},
+	})
+	ref, err := e.ImportCargoLock(jc, s, x)
+	require.NoError(t, err)
+
+	requireBlob(t, s, *ref, "foo-1.2.3/Cargo.toml", "[package]\nname = \"foo\"\n")
+	requireBlob(t, s, *ref, "foo-1.2.3/.cargo-checksum.json", fmt.Sprintf(`{"files":{},"package":"%x"}`, crateSum))
+	ents, err := glfs.GetTreeSlice(ctx, s, *ref, 10)
+	require.NoError(t, err)
+	require.Len(t, ents, 1)
+}
+
+func TestParseCargoLockV1(t *testing.T) {
+	pkgs, err := parseCargoLock([]byte(`[[package]]
+name = "foo"
+version = "1.0.0"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+
+[metadata]
+"checksum foo 1.0.0 (registry+https://github.com/rust-lang/crates.io-index)" = "abcd"
+`))
+	require.NoError(t, err)
+	require.Equal(t, []cargoPackage{{
+		Name:     "foo",
+		Version:  "1.0.0",
+		Source:   "registry+https://github.com/rust-lang/crates.io-index",
+		Checksum: "abcd",
+	}}, pkgs)
+}
+
+func writeTestTGZ(t testing.TB, dir string, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, data := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     dir + "/" + name,
+			Typeflag: tar.TypeReg,
+			Mode:     0o644,
+			Size:     int64(len(data)),
+		}))
+		_, err := tw.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	return buf.Bytes()
+}
+
+func requireBlob(t testing.TB, s cadata.Getter, root glfs.Ref, p string, expected string) {
+	ctx := testutil.Context(t)
+	ref, err := glfs.GetAtPath(ctx, s, root, p)
+	require.NoError(t, err)
+	data, err := glfs.GetBlobBytes(ctx, s, *ref, 1e6)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(data))
+}
+
+func requireSymlink(t testing.TB, s cadata.Getter, root glfs.Ref, p string, target string) {
+	ctx := testutil.Context(t)
+	dirRef, err := glfs.GetAtPath(ctx, s, root, path.Dir(p))
+	require.NoError(t, err)
+	ents, err := glfs.GetTreeSlice(ctx, s, *dirRef, 1e6)
+	require.NoError(t, err)
+	ent := glfs.Lookup(ents, path.Base(p))
+	require.NotNil(t, ent, p)
+	require.NotZero(t, ent.FileMode&fs.ModeSymlink, p)
+	requireBlob(t, s, root, p, target)
+}
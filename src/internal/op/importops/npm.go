@@ -0,0 +1,178 @@
+package importops
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"blobcache.io/glfs"
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/wantjob"
+)
+
+const npmLockfile = "package-lock.json"
+
+// npmLock is the subset of package-lock.json used for importing.
+// Only lockfileVersion 2 and 3 are supported, which list every package under "packages".
+type npmLock struct {
+	LockfileVersion int                       `json:"lockfileVersion"`
+	Packages        map[string]npmLockPackage `json:"packages"`
+}
+
+type npmLockPackage struct {
+	Version          string          `json:"version"`
+	Resolved         string          `json:"resolved"`
+	Integrity        string          `json:"integrity"`
+	Link             bool            `json:"link"`
+	InBundle         bool            `json:"inBundle"`
+	HasInstallScript bool            `json:"hasInstallScript"`
+	Bin              json.RawMessage `json:"bin"`
+}
+
+// ImportNPMLock reads the package-lock.json at the root of x, and imports every package it pins.
+// The output is a node_modules directory, including .bin links. Install scripts are not run.
+func (e *Executor) ImportNPMLock(jc wantjob.Ctx, s cadata.Getter, x glfs.Ref) (*glfs.Ref, error) {
+	ctx := jc.Context
+	lockRef, err := glfs.GetAtPath(ctx, s, x, npmLockfile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := glfs.GetBlobBytes(ctx, s, *lockRef, maxLockfileSize)
+	if err != nil {
+		return nil, err
+	}
+	var lock npmLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", npmLockfile, err)
+	}
+	if lock.LockfileVersion < 2 {
+		return nil, fmt.Errorf("%s: lockfileVersion %d is not supported, regenerate it with npm 7 or later", npmLockfile, lock.LockfileVersion)
+	}
+	keys := make([]string, 0, len(lock.Packages))
+	for k := range lock.Packages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b := newTreeBuilder()
+	b.src = jc.Dst
+	var pkgs []lockedPackage
+	for _, key := range keys {
+		pkg := lock.Packages[key]
+		p, ok := strings.CutPrefix(key, "node_modules/")
+		if !ok || pkg.InBundle {
+			// the root package, workspace sources and bundled dependencies are not fetched.
+			continue
+		}
+		if _, err := archivePath(p); err != nil {
+			return nil, err
+		}
+		if pkg.Link {
+			// workspace packages are linked to their source in the module.
+			target, err := relPath(path.Dir(key), pkg.Resolved)
+			if err != nil {
+				return nil, err
+			}
+			if err := b.putSymlink(ctx, jc.Dst, p, target); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		algo, hash, err := parseIntegrity(pkg.Integrity)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", npmLockfile, key, err)
+		}
+		if pkg.Resolved == "" {
+			return nil, fmt.Errorf("%s: %s has no resolved URL", npmLockfile, key)
+		}
+		if pkg.HasInstallScript {
+			jc.Infof("%s has an install script, which will not be run", key)
+		}
+		pkgs = append(pkgs, lockedPackage{
+			Path: p,
+			Archive: ImportURLTask{
+				URL:        pkg.Resolved,
+				Algo:       algo,
+				Hash:       hash,
+				Transforms: []string{"ungzip", "untar"},
+			},
+		})
+		bins, err := npmBins(key, pkg.Bin)
+		if err != nil {
+			return nil, err
+		}
+		for name, target := range bins {
+			if strings.Contains(name, "/") {
+				return nil, fmt.Errorf("%s: %s has invalid bin name %q", npmLockfile, key, name)
+			}
+			binPath := path.Join(path.Dir(p), ".bin", name)
+			if err := b.putSymlink(ctx, jc.Dst, binPath, target); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := e.importLocked(jc, b, pkgs); err != nil {
+		return nil, err
+	}
+	return b.finish(ctx, glfs.NewAgent(), jc.Dst)
+}
+
+// npmBins returns the .bin link targets for the package at key, relative to the .bin directory.
+// bin is either a map from command names to paths, or a single path named after the package.
+func npmBins(key string, bin json.RawMessage) (map[string]string, error) {
+	if len(bin) == 0 {
+		return nil, nil
+	}
+	pkgName := key[strings.LastIndex(key, "node_modules/")+len("node_modules/"):]
+	bins := map[string]string{}
+	var single string
+	if err := json.Unmarshal(bin, &single); err == nil {
+		bins[path.Base(pkgName)] = single
+	} else if err := json.Unmarshal(bin, &bins); err != nil {
+		return nil, fmt.Errorf("%s: %s has invalid bin: %w", npmLockfile, key, err)
+	}
+	ret := make(map[string]string, len(bins))
+	for name, p := range bins {
+		p = path.Clean(p)
+		if path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+			return nil, fmt.Errorf("%s: %s has unsafe bin path %q", npmLockfile, key, p)
+		}
+		ret[name] = path.Join("..", pkgName, p)
+	}
+	return ret, nil
+}
+
+// parseIntegrity picks the strongest hash from a Subresource Integrity string, e.g. "sha512-<base64>".
+func parseIntegrity(x string) (algo, hash string, _ error) {
+	rank := map[string]int{"sha1": 1, "sha256": 2, "sha512": 3}
+	best := 0
+	for _, field := range strings.Fields(x) {
+		name, sum, ok := strings.Cut(field, "-")
+		if !ok || rank[name] <= best {
+			continue
+		}
+		best = rank[name]
+		algo, hash = strings.ToUpper(name), sum
+	}
+	if best == 0 {
+		return "", "", fmt.Errorf("no supported hash in integrity %q", x)
+	}
+	return algo, hash, nil
+}
+
+// relPath returns the path to target, relative to the directory dir.
+// Both paths are relative to the same root.
+func relPath(dir, target string) (string, error) {
+	target = path.Clean(target)
+	if path.IsAbs(target) || target == ".." || strings.HasPrefix(target, "../") {
+		return "", fmt.Errorf("link target %q is outside of the module", target)
+	}
+	var up []string
+	for d := path.Clean(dir); d != "."; d = path.Dir(d) {
+		up = append(up, "..")
+	}
+	return path.Join(append(up, target)...), nil
+}
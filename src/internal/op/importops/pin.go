@@ -0,0 +1,196 @@
+package importops
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"golang.org/x/mod/sumdb/dirhash"
+
+	"wantbuild.io/want/src/wantjob"
+)
+
+// ErrNotPinned is returned for import tasks which do not identify their content by a hash.
+type ErrNotPinned struct {
+	Op wantjob.OpName
+	// Source is the URL or name of the content.
+	Source string
+	// Param is the parameter of the task which should pin the content.
+	Param string
+}
+
+func (e ErrNotPinned) Error() string {
+	return fmt.Sprintf("%s import of %s is not pinned: %s must be a hash", e.Op, e.Source, e.Param)
+}
+
+var commitHashRe = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// CheckPinned returns ErrNotPinned if the import task for op, with the JSON spec, does not identify its content by a hash.
+// Git imports must name a full commit hash, not a branch or tag.
+// Tasks which are pinned some other way, like by a lockfile, are always pinned.
+func CheckPinned(op wantjob.OpName, spec []byte) error {
+	switch op {
+	case OpFromURL:
+		x, err := unmarshalSpec[ImportURLTask](spec)
+		if err != nil {
+			return err
+		}
+		if x.Hash == "" {
+			return ErrNotPinned{Op: op, Source: x.URL, Param: "hash"}
+		}
+	case OpFromGit:
+		x, err := unmarshalSpec[ImportGitTask](spec)
+		if err != nil {
+			return err
+		}
+		if !commitHashRe.MatchString(x.CommitHash) {
+			return ErrNotPinned{Op: op, Source: x.URL, Param: "commitHash"}
+		}
+	case OpFromGoZip:
+		x, err := unmarshalSpec[ImportGoZipTask](spec)
+		if err != nil {
+			return err
+		}
+		if x.Hash == "" {
+			return ErrNotPinned{Op: op, Source: x.Path + "@" + x.Version, Param: "hash"}
+		}
+	case OpFromOCIImage:
+		x, err := unmarshalSpec[ImportOCIImageTask](spec)
+		if err != nil {
+			return err
+		}
+		if x.Hash == "" {
+			return ErrNotPinned{Op: op, Source: x.Name, Param: "hash"}
+		}
+	}
+	return nil
+}
+
+// Pin is the value which pins the content of an import task.
+type Pin struct {
+	// Args identify the import, by the values of other parameters of the task.
+	Args map[string]string
+	// Param is the parameter which pins the content, and Value is its pinned value.
+	Param string
+	Value string
+}
+
+// Pin fetches the content for the import task for op, with the JSON spec, and returns the value which pins it.
+// URLs and Go module zips are hashed, and Git branches are resolved to the commit they point to.
+func (e *Executor) Pin(jc wantjob.Ctx, op wantjob.OpName, spec []byte) (*Pin, error) {
+	switch op {
+	case OpFromURL:
+		x, err := unmarshalSpec[ImportURLTask](spec)
+		if err != nil {
+			return nil, err
+		}
+		sum, err := e.hashURL(jc, *x)
+		if err != nil {
+			return nil, err
+		}
+		return &Pin{Args: map[string]string{"url": x.URL, "algo": x.Algo}, Param: "hash", Value: hex.EncodeToString(sum)}, nil
+	case OpFromGit:
+		x, err := unmarshalSpec[ImportGitTask](spec)
+		if err != nil {
+			return nil, err
+		}
+		h, err := e.resolveGitBranch(jc, x.URL, x.Branch)
+		if err != nil {
+			return nil, err
+		}
+		return &Pin{Args: map[string]string{"url": x.URL, "branch": x.Branch}, Param: "commitHash", Value: h.String()}, nil
+	case OpFromGoZip:
+		x, err := unmarshalSpec[ImportGoZipTask](spec)
+		if err != nil {
+			return nil, err
+		}
+		h, err := e.hashGoZip(jc, *x)
+		if err != nil {
+			return nil, err
+		}
+		return &Pin{Args: map[string]string{"path": x.Path, "version": x.Version}, Param: "hash", Value: h}, nil
+	default:
+		return nil, fmt.Errorf("%s imports cannot be pinned automatically", op)
+	}
+}
+
+// hashURL downloads the content for x, and returns its hash using x.Algo.
+func (e *Executor) hashURL(jc wantjob.Ctx, x ImportURLTask) ([]byte, error) {
+	newHash, err := makeHashFactory(x.Algo)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := e.fetch(jc, e.cfg.mirrorURLs(x.URL))
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	h := newHash()
+	if _, err := io.Copy(h, rc); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// hashGoZip downloads the module zip for x, and returns its go.sum style hash.
+func (e *Executor) hashGoZip(jc wantjob.Ctx, x ImportGoZipTask) (string, error) {
+	rc, err := e.fetchGoZip(jc, x)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	zipFile, err := os.CreateTemp("", "go-zip-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(zipFile.Name())
+	defer zipFile.Close()
+	if _, err := io.Copy(zipFile, rc); err != nil {
+		return "", err
+	}
+	return dirhash.HashZip(zipFile.Name(), dirhash.Hash1)
+}
+
+// resolveGitBranch returns the commit that branch points to in the remote at rawURL.
+func (e *Executor) resolveGitBranch(jc wantjob.Ctx, rawURL, branch string) (plumbing.Hash, error) {
+	if e.cfg.Offline {
+		return plumbing.ZeroHash, ErrOffline
+	}
+	name := plumbing.NewBranchReferenceName(branch)
+	var errs []error
+	for _, u := range e.cfg.mirrorURLs(rawURL) {
+		r := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+			Name: "origin",
+			URLs: []string{u},
+		})
+		refs, err := r.ListContext(jc.Context, &git.ListOptions{})
+		if err != nil {
+			jc.Infof("git ls-remote %v: %v", u, err)
+			errs = append(errs, err)
+			continue
+		}
+		for _, ref := range refs {
+			if ref.Name() == name {
+				return ref.Hash(), nil
+			}
+		}
+		return plumbing.ZeroHash, fmt.Errorf("git: %s has no branch %q", rawURL, branch)
+	}
+	return plumbing.ZeroHash, fmt.Errorf("git ls-remote failed from all sources: %w", errors.Join(errs...))
+}
+
+func unmarshalSpec[T any](data []byte) (*T, error) {
+	var ret T
+	if err := json.Unmarshal(data, &ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
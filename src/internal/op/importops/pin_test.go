@@ -0,0 +1,122 @@
+package importops
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"wantbuild.io/want/src/internal/stores"
+	"wantbuild.io/want/src/internal/testutil"
+	"wantbuild.io/want/src/wantjob"
+)
+
+func TestCheckPinned(t *testing.T) {
+	commit := strings.Repeat("a", 40)
+	tcs := []struct {
+		Op     wantjob.OpName
+		Spec   any
+		Pinned bool
+	}{
+		{OpFromURL, ImportURLTask{URL: "https://example.com/x", Algo: "SHA256", Hash: "abcd"}, true},
+		{OpFromURL, ImportURLTask{URL: "https://example.com/x", Algo: "SHA256"}, false},
+		{OpFromGit, ImportGitTask{URL: "https://example.com/x.git", CommitHash: commit}, true},
+		{OpFromGit, ImportGitTask{URL: "https://example.com/x.git", CommitHash: "main"}, false},
+		{OpFromGit, ImportGitTask{URL: "https://example.com/x.git", CommitHash: strings.Repeat("refs/heads/main", 3)}, false},
+		{OpFromGoZip, ImportGoZipTask{Path: "example.com/m", Version: "v1.0.0"}, false},
+		{OpFromOCIImage, ImportOCIImageTask{Name: "example.com/img", Algo: "sha256"}, false},
+		{OpFromNPMLock, nil, true},
+	}
+	for _, tc := range tcs {
+		spec, err := json.Marshal(tc.Spec)
+		require.NoError(t, err)
+		err = CheckPinned(tc.Op, spec)
+		if tc.Pinned {
+			require.NoError(t, err, "%v %s", tc.Op, spec)
+		} else {
+			require.ErrorAs(t, err, &ErrNotPinned{}, "%v %s", tc.Op, spec)
+		}
+	}
+}
+
+func TestPinURL(t *testing.T) {
+	ctx := testutil.Context(t)
+	data := []byte("hello world\n")
+	sum := sha256.Sum256(data)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer srv.Close()
+	s := stores.NewMem()
+	jc := wantjob.Ctx{Context: ctx, Dst: s}
+	e := NewExecutor(Config{})
+
+	// an unpinned import is not verified, but still imports the content.
+	task := ImportURLTask{URL: srv.URL + "/file.txt", Algo: "SHA256"}
+	expected, err := e.ImportURL(jc, task)
+	require.NoError(t, err)
+
+	spec, err := json.Marshal(task)
+	require.NoError(t, err)
+	pin, err := e.Pin(jc, OpFromURL, spec)
+	require.NoError(t, err)
+	require.Equal(t, &Pin{
+		Args:  map[string]string{"url": task.URL, "algo": "SHA256"},
+		Param: "hash",
+		Value: hex.EncodeToString(sum[:]),
+	}, pin)
+
+	task.Hash = pin.Value
+	actual, err := e.ImportURL(jc, task)
+	require.NoError(t, err)
+	require.Equal(t, *expected, *actual)
+}
+
+func TestPinGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	ctx := testutil.Context(t)
+	dir := t.TempDir()
+	gitCmd(t, "", "init", "-q", "-b", "main", dir)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644))
+	gitCmd(t, dir, "add", ".")
+	gitCmd(t, dir, "commit", "-q", "-m", "a")
+	commitHash := strings.TrimSpace(gitCmd(t, dir, "rev-parse", "HEAD"))
+
+	e := NewExecutor(Config{})
+	jc := wantjob.Ctx{Context: ctx, Dst: stores.NewMem()}
+	spec, err := json.Marshal(ImportGitTask{URL: dir, Branch: "main"})
+	require.NoError(t, err)
+	pin, err := e.Pin(jc, OpFromGit, spec)
+	require.NoError(t, err)
+	require.Equal(t, &Pin{
+		Args:  map[string]string{"url": dir, "branch": "main"},
+		Param: "commitHash",
+		Value: commitHash,
+	}, pin)
+
+	// the same repository at another branch is pinned by different calls.
+	gitCmd(t, dir, "checkout", "-q", "-b", "dev")
+	gitCmd(t, dir, "commit", "-q", "--allow-empty", "-m", "dev")
+	spec, err = json.Marshal(ImportGitTask{URL: dir, Branch: "dev"})
+	require.NoError(t, err)
+	pin, err = e.Pin(jc, OpFromGit, spec)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"url": dir, "branch": "dev"}, pin.Args)
+	require.Equal(t, strings.TrimSpace(gitCmd(t, dir, "rev-parse", "HEAD")), pin.Value)
+	require.NotEqual(t, commitHash, pin.Value)
+
+	spec, err = json.Marshal(ImportGitTask{URL: dir, Branch: "other"})
+	require.NoError(t, err)
+	_, err = e.Pin(jc, OpFromGit, spec)
+	require.Error(t, err)
+}
@@ -0,0 +1,141 @@
+package importops
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/internal/glfstasks"
+	"wantbuild.io/want/src/wantjob"
+)
+
+// Vendor is a directory of downloaded content, addressed by the algorithm and hash declared for it.
+// Content is stored exactly as it was downloaded, before any transforms, so the usual integrity checks
+// are performed when it is imported.
+//
+// The layout is <kind>/<algo>/<hex hash>, where kind is "url", "gozip", "oci" or "ocilayer".
+// oci entries are OCI image layout directories, and the rest are regular files.
+type Vendor struct {
+	dir string
+}
+
+func NewVendor(dir string) *Vendor {
+	return &Vendor{dir: dir}
+}
+
+func (v *Vendor) path(kind, algo string, sum []byte) string {
+	return filepath.Join(v.dir, kind, algo, hex.EncodeToString(sum))
+}
+
+// Open returns the vendored content, or an error satisfying errors.Is(err, os.ErrNotExist).
+func (v *Vendor) Open(kind, algo string, sum []byte) (*os.File, error) {
+	return os.Open(v.path(kind, algo, sum))
+}
+
+// Has returns true if there is vendored content for the key.
+func (v *Vendor) Has(kind, algo string, sum []byte) (bool, error) {
+	_, err := os.Stat(v.path(kind, algo, sum))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Put writes the content from r to the vendor directory.
+// verify is called with the complete file, and the content is only made visible if it returns nil.
+func (v *Vendor) Put(kind, algo string, sum []byte, r io.Reader, verify func(f *os.File) error) error {
+	return v.put(kind, algo, sum, func(p string) error {
+		f, err := os.Create(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := io.Copy(f, r); err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if err := verify(f); err != nil {
+			return err
+		}
+		return f.Close()
+	})
+}
+
+// put calls fn to write content to a temporary path, and then moves it into place.
+func (v *Vendor) put(kind, algo string, sum []byte, fn func(p string) error) error {
+	if len(sum) == 0 {
+		return fmt.Errorf("vendor: cannot vendor %s content without a hash", kind)
+	}
+	finalPath := v.path(kind, algo, sum)
+	if err := os.MkdirAll(filepath.Dir(finalPath), 0o755); err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(finalPath), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	p := filepath.Join(tmpDir, "x")
+	if err := fn(p); err != nil {
+		return err
+	}
+	return os.Rename(p, finalPath)
+}
+
+// VendorTask downloads the content for an import task into the Vendor directory.
+// It returns false if the task's content cannot be vendored.
+func (e *Executor) VendorTask(jc wantjob.Ctx, s cadata.Getter, x wantjob.Task) (bool, error) {
+	if e.vendor == nil {
+		return false, errors.New("importops: no vendor directory configured")
+	}
+	ctx := jc.Context
+	in, err := glfstasks.ParseGLFSRef(x.Input)
+	if err != nil {
+		return false, err
+	}
+	switch x.Op {
+	case OpFromURL:
+		spec, err := GetImportURLTask(ctx, s, *in)
+		if err != nil {
+			return false, err
+		}
+		if spec.Hash == "" {
+			// there is no hash to store the content under.
+			return false, nil
+		}
+		return true, e.VendorURL(jc, *spec)
+	case OpFromGoZip:
+		spec, err := GetImportGoZipTask(ctx, s, *in)
+		if err != nil {
+			return false, err
+		}
+		return true, e.VendorGoZip(jc, *spec)
+	case OpFromOCIImage:
+		spec, err := GetImportOCIImageTask(ctx, s, *in)
+		if err != nil {
+			return false, err
+		}
+		return true, e.VendorOCIImage(jc, *spec)
+	case OpFromOCIManifest:
+		spec, err := GetImportOCIManifestTask(ctx, s, *in)
+		if err != nil {
+			return false, err
+		}
+		return true, e.VendorOCIImage(jc, ImportOCIImageTask(*spec))
+	case OpFromOCILayer:
+		spec, err := GetImportOCILayerTask(ctx, s, *in)
+		if err != nil {
+			return false, err
+		}
+		return true, e.VendorOCILayer(jc, *spec)
+	default:
+		return false, nil
+	}
+}
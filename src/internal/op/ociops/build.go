@@ -0,0 +1,231 @@
+package ociops
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"blobcache.io/glfs"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/internal/glfsport"
+	"wantbuild.io/want/src/wantjob"
+)
+
+const MaxConfigSize = 1e6
+
+// BuildTask assembles a root filesystem and a config into an OCI image.
+type BuildTask struct {
+	Rootfs glfs.Ref
+	Config ImageConfig
+}
+
+// ImageConfig is the part of the OCI image config which is set by the user.
+// The rootfs and history are derived from the image contents.
+type ImageConfig struct {
+	// Architecture defaults to amd64
+	Architecture string `json:"architecture,omitempty"`
+	// OS defaults to linux
+	OS     string    `json:"os,omitempty"`
+	Config v1.Config `json:"config"`
+}
+
+func PostBuildTask(ctx context.Context, s cadata.PostExister, x BuildTask) (*glfs.Ref, error) {
+	data, err := json.Marshal(x.Config)
+	if err != nil {
+		return nil, err
+	}
+	configRef, err := glfs.PostBlob(ctx, s, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return glfs.PostTreeSlice(ctx, s, []glfs.TreeEntry{
+		{Name: "rootfs", FileMode: 0o777, Ref: x.Rootfs},
+		{Name: "config.json", FileMode: 0o644, Ref: *configRef},
+	})
+}
+
+func GetBuildTask(ctx context.Context, s cadata.Getter, x glfs.Ref) (*BuildTask, error) {
+	rootfs, err := glfs.GetAtPath(ctx, s, x, "rootfs")
+	if err != nil {
+		return nil, err
+	}
+	configRef, err := glfs.GetAtPath(ctx, s, x, "config.json")
+	if err != nil {
+		return nil, err
+	}
+	data, err := glfs.GetBlobBytes(ctx, s, *configRef, MaxConfigSize)
+	if err != nil {
+		return nil, err
+	}
+	var config ImageConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	return &BuildTask{Rootfs: *rootfs, Config: config}, nil
+}
+
+// Build produces an OCI image layout tree containing a single image, with the rootfs as its only layer.
+// The output only depends on the task, so the same task always produces the same image digest.
+func Build(jc wantjob.Ctx, src cadata.Getter, task BuildTask) (*glfs.Ref, error) {
+	ctx := jc.Context
+	dst := jc.Dst
+	b := layoutBuilder{blobs: map[string]glfs.Ref{}}
+
+	layerDesc, diffID, err := b.addLayer(ctx, dst, src, task.Rootfs)
+	if err != nil {
+		return nil, err
+	}
+	cfg := task.Config
+	if cfg.Architecture == "" {
+		cfg.Architecture = "amd64"
+	}
+	if cfg.OS == "" {
+		cfg.OS = "linux"
+	}
+	configDesc, err := b.addJSON(ctx, dst, types.OCIConfigJSON, v1.ConfigFile{
+		Architecture: cfg.Architecture,
+		OS:           cfg.OS,
+		Config:       cfg.Config,
+		RootFS: v1.RootFS{
+			Type:    "layers",
+			DiffIDs: []v1.Hash{diffID},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	mfDesc, err := b.addJSON(ctx, dst, types.OCIManifestSchema1, v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIManifestSchema1,
+		Config:        *configDesc,
+		Layers:        []v1.Descriptor{*layerDesc},
+	})
+	if err != nil {
+		return nil, err
+	}
+	jc.Infof("oci: built image %v", mfDesc.Digest)
+	return b.finish(ctx, dst, v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIImageIndex,
+		Manifests:     []v1.Descriptor{*mfDesc},
+	})
+}
+
+// layoutBuilder accumulates the blobs in an OCI image layout.
+type layoutBuilder struct {
+	blobs map[string]glfs.Ref
+}
+
+// addLayer writes rootfs as a gzipped tar layer, and returns its descriptor and diff ID.
+func (b *layoutBuilder) addLayer(ctx context.Context, dst cadata.PostExister, src cadata.Getter, rootfs glfs.Ref) (*v1.Descriptor, v1.Hash, error) {
+	f, err := os.CreateTemp("", "oci-layer-")
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	compressedHash := sha256.New()
+	uncompressedHash := sha256.New()
+	gw := gzip.NewWriter(io.MultiWriter(f, compressedHash))
+	tw := tar.NewWriter(io.MultiWriter(gw, uncompressedHash))
+	if err := glfsport.WriteTAR(ctx, src, rootfs, tw); err != nil {
+		return nil, v1.Hash{}, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, v1.Hash{}, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, v1.Hash{}, err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, v1.Hash{}, err
+	}
+	ref, err := glfs.PostBlob(ctx, dst, f)
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	desc := b.add(types.OCILayer, compressedHash, size, *ref)
+	return &desc, sha256Hash(uncompressedHash), nil
+}
+
+func (b *layoutBuilder) addJSON(ctx context.Context, dst cadata.PostExister, mt types.MediaType, x any) (*v1.Descriptor, error) {
+	data, err := json.Marshal(x)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := glfs.PostBlob(ctx, dst, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	h.Write(data)
+	desc := b.add(mt, h, int64(len(data)), *ref)
+	return &desc, nil
+}
+
+func (b *layoutBuilder) add(mt types.MediaType, h hash.Hash, size int64, ref glfs.Ref) v1.Descriptor {
+	digest := sha256Hash(h)
+	b.blobs[digest.Hex] = ref
+	return v1.Descriptor{MediaType: mt, Size: size, Digest: digest}
+}
+
+// finish writes the index and returns the layout tree.
+func (b *layoutBuilder) finish(ctx context.Context, dst cadata.PostExister, index v1.IndexManifest) (*glfs.Ref, error) {
+	indexData, err := json.Marshal(index)
+	if err != nil {
+		return nil, err
+	}
+	indexRef, err := glfs.PostBlob(ctx, dst, bytes.NewReader(indexData))
+	if err != nil {
+		return nil, err
+	}
+	layoutRef, err := glfs.PostBlob(ctx, dst, bytes.NewReader([]byte(`{"imageLayoutVersion":"1.0.0"}`)))
+	if err != nil {
+		return nil, err
+	}
+	sha256Blobs, err := glfs.PostTreeMap(ctx, dst, b.blobs)
+	if err != nil {
+		return nil, err
+	}
+	blobs, err := glfs.PostTreeSlice(ctx, dst, []glfs.TreeEntry{
+		{Name: "sha256", FileMode: 0o755, Ref: *sha256Blobs},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return glfs.PostTreeSlice(ctx, dst, []glfs.TreeEntry{
+		{Name: "oci-layout", FileMode: 0o644, Ref: *layoutRef},
+		{Name: "index.json", FileMode: 0o644, Ref: *indexRef},
+		{Name: "blobs", FileMode: 0o755, Ref: *blobs},
+	})
+}
+
+func sha256Hash(h hash.Hash) v1.Hash {
+	return v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(h.Sum(nil))}
+}
+
+// checkLayout returns an error if x is not an OCI image layout tree.
+func checkLayout(ctx context.Context, s cadata.Getter, x glfs.Ref) error {
+	for _, p := range []string{"oci-layout", "index.json", "blobs"} {
+		if _, err := glfs.GetAtPath(ctx, s, x, p); err != nil {
+			return fmt.Errorf("not an OCI image layout: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package ociops
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+	"github.com/stretchr/testify/require"
+
+	"wantbuild.io/want/src/internal/stores"
+	"wantbuild.io/want/src/internal/testutil"
+	"wantbuild.io/want/src/wantjob"
+)
+
+func TestBuild(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+	jc := wantjob.Ctx{Context: ctx, Dst: s}
+	rootfs := testutil.PostFS(t, s, map[string][]byte{
+		"bin/hello":  []byte("#!/bin/sh\necho hello\n"),
+		"etc/motd":   []byte("hello world\n"),
+		"root/.keep": nil,
+	})
+	task := BuildTask{
+		Rootfs: rootfs,
+		Config: ImageConfig{
+			Config: v1.Config{Entrypoint: []string{"/bin/hello"}},
+		},
+	}
+	out1, err := Build(jc, s, task)
+	require.NoError(t, err)
+	out2, err := Build(jc, s, task)
+	require.NoError(t, err)
+	require.Equal(t, *out1, *out2, "build should be reproducible")
+
+	dir := t.TempDir()
+	require.NoError(t, ExportLayout(ctx, s, *out1, dir))
+	p, err := layout.FromPath(dir)
+	require.NoError(t, err)
+	idx, err := p.ImageIndex()
+	require.NoError(t, err)
+	im, err := idx.IndexManifest()
+	require.NoError(t, err)
+	require.Len(t, im.Manifests, 1)
+	img, err := idx.Image(im.Manifests[0].Digest)
+	require.NoError(t, err)
+	require.NoError(t, validate.Image(img))
+
+	cfg, err := img.ConfigFile()
+	require.NoError(t, err)
+	require.Equal(t, "amd64", cfg.Architecture)
+	require.Equal(t, []string{"/bin/hello"}, cfg.Config.Entrypoint)
+}
+
+func TestBuildTaskRoundTrip(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+	x := BuildTask{
+		Rootfs: testutil.PostFS(t, s, map[string][]byte{"a.txt": []byte("a")}),
+		Config: ImageConfig{OS: "linux", Config: v1.Config{Cmd: []string{"sh"}}},
+	}
+	ref, err := PostBuildTask(ctx, s, x)
+	require.NoError(t, err)
+	y, err := GetBuildTask(ctx, s, *ref)
+	require.NoError(t, err)
+	require.Equal(t, x.Config, y.Config)
+	require.Equal(t, x.Rootfs, y.Rootfs)
+}
@@ -0,0 +1,33 @@
+package ociops
+
+import (
+	"blobcache.io/glfs"
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/internal/glfstasks"
+	"wantbuild.io/want/src/wantjob"
+)
+
+const (
+	OpBuild = wantjob.OpName("build")
+)
+
+var _ wantjob.Executor = Executor{}
+
+type Executor struct{}
+
+func (e Executor) Execute(jc wantjob.Ctx, src cadata.Getter, x wantjob.Task) wantjob.Result {
+	ctx := jc.Context
+	switch x.Op {
+	case OpBuild:
+		return glfstasks.Exec(x.Input, func(x glfs.Ref) (*glfs.Ref, error) {
+			task, err := GetBuildTask(ctx, src, x)
+			if err != nil {
+				return nil, err
+			}
+			return Build(jc, src, *task)
+		})
+	default:
+		return *wantjob.Result_ErrExec(wantjob.NewErrUnknownOperator(x.Op))
+	}
+}
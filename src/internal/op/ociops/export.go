@@ -0,0 +1,69 @@
+package ociops
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"blobcache.io/glfs"
+	"github.com/google/go-containerregistry/pkg/authn"
+	crname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/internal/glfsport"
+)
+
+// ExportLayout writes the OCI image layout tree x to dir, which must not exist or be empty.
+func ExportLayout(ctx context.Context, src cadata.Getter, x glfs.Ref, dir string) error {
+	if err := checkLayout(ctx, src, x); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	exp := glfsport.Exporter{
+		Dir:   dir,
+		Store: src,
+		Cache: glfsport.NullCache{},
+	}
+	return exp.Export(ctx, x, "")
+}
+
+// PushLayout pushes the image in the OCI image layout tree x to a registry.
+// Credentials are taken from the default keychain, the same as docker.
+func PushLayout(ctx context.Context, src cadata.Getter, x glfs.Ref, dst string) error {
+	ref, err := crname.ParseReference(dst)
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp("", "oci-layout-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := ExportLayout(ctx, src, x, dir); err != nil {
+		return err
+	}
+	p, err := layout.FromPath(dir)
+	if err != nil {
+		return err
+	}
+	idx, err := p.ImageIndex()
+	if err != nil {
+		return err
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return err
+	}
+	if len(im.Manifests) != 1 {
+		return fmt.Errorf("oci layout must contain exactly 1 image to push, found %d", len(im.Manifests))
+	}
+	img, err := idx.Image(im.Manifests[0].Digest)
+	if err != nil {
+		return err
+	}
+	return remote.Write(ref, img, remote.WithContext(ctx), remote.WithAuthFromKeychain(authn.DefaultKeychain))
+}
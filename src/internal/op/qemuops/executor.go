@@ -44,6 +44,15 @@ type Config struct {
 	// InstallDir contains the binaries needed to execute the VM operations
 	InstallDir string
 	MemLimit   int64
+	// Console, if not nil, is connected to any console serial ports.
+	// Otherwise console output is written to the job's qemu/stdout log.
+	Console *Console
+}
+
+// Console is a terminal which can be attached to a VM's console serial port.
+type Console struct {
+	In  io.Reader
+	Out io.Writer
 }
 
 func NewExecutor(cfg Config) *Executor {
@@ -78,6 +78,10 @@ func (e *Executor) newVM(jc wantjob.Ctx, dir string, vmcfg vmConfig) *vm {
 		cmd := e.systemx86Cmd(args...)
 		cmd.Stdout = jc.Writer("qemu/stdout")
 		cmd.Stderr = jc.Writer("qemu/stderr")
+		if _, exists := vmcfg.CharDevs["console"]; exists && e.cfg.Console != nil {
+			cmd.Stdin = e.cfg.Console.In
+			cmd.Stdout = e.cfg.Console.Out
+		}
 		return cmd
 	}()
 
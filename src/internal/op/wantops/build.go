@@ -7,11 +7,11 @@ import (
 
 	"blobcache.io/glfs"
 	"go.brendoncarroll.net/state/cadata"
-	"golang.org/x/sync/errgroup"
 
 	"wantbuild.io/want/src/internal/glfstasks"
 	"wantbuild.io/want/src/internal/stores"
 	"wantbuild.io/want/src/internal/wantc"
+	"wantbuild.io/want/src/internal/wantdag"
 	"wantbuild.io/want/src/wantcfg"
 	"wantbuild.io/want/src/wantjob"
 )
@@ -32,6 +32,7 @@ func (e *Executor) Build(jc wantjob.Ctx, src cadata.Getter, buildTask BuildTask)
 	plan, planStore, err := DoCompile(ctx, jc.System, e.CompileOp, stores.Union{jc.Dst, src}, wantc.CompileTask{
 		Module:   buildTask.Main,
 		Metadata: buildTask.Metadata,
+		Params:   buildTask.Params,
 		Deps:     deps,
 	})
 	if err != nil {
@@ -39,42 +40,17 @@ func (e *Executor) Build(jc wantjob.Ctx, src cadata.Getter, buildTask BuildTask)
 	}
 	// filter targets
 	var targets []wantc.Target
-	var dags []glfs.Ref
+	var roots []wantdag.NodeID
 	for _, target := range plan.Targets {
 		if wantc.Intersects(target.To, buildTask.Query) {
 			targets = append(targets, target)
-			dags = append(dags, target.DAG)
+			roots = append(roots, target.Node)
 		}
 	}
 	// execute
-	var errorsOccured bool
-	results := make([]wantjob.Result, len(dags))
 	src2 := stores.Union{src, jc.Dst, planStore}
-	if err := func() error {
-		eg, ctx := errgroup.WithContext(jc.Context)
-		for i := range dags {
-			i := i
-			eg.Go(func() error {
-				defer jc.InfoSpan("build " + targets[i].DefinedIn)()
-				res, dagStore, err := wantjob.Do(ctx, jc.System, src2, wantjob.Task{
-					Op:    e.DAGExecOp,
-					Input: glfstasks.MarshalGLFSRef(dags[i]),
-				})
-				if err != nil {
-					return err
-				}
-				errorsOccured = errorsOccured || res.ErrCode > 0
-				if ref, err := glfstasks.ParseGLFSRef(res.Root); err == nil {
-					if err := glfstasks.FastSync(ctx, jc.Dst, dagStore, *ref); err != nil {
-						return err
-					}
-				}
-				results[i] = *res
-				return nil
-			})
-		}
-		return eg.Wait()
-	}(); err != nil {
+	results, err := e.execTargets(jc, src2, plan.DAG, roots)
+	if err != nil {
 		return nil, err
 	}
 
@@ -104,6 +80,57 @@ func (e *Executor) Build(jc wantjob.Ctx, src cadata.Getter, buildTask BuildTask)
 	}, nil
 }
 
+// execTargets executes the nodes in roots, and the parts of the nodes they depend on which they need, using a single job.
+// Nodes which are shared by several roots are only executed once.
+// The outputs of the roots are synced into jc.Dst.
+func (e *Executor) execTargets(jc wantjob.Ctx, src cadata.Getter, dagRef glfs.Ref, roots []wantdag.NodeID) ([]wantjob.Result, error) {
+	ctx := jc.Context
+	if len(roots) == 0 {
+		return nil, nil
+	}
+	dag, err := wantdag.GetDAG(ctx, src, dagRef)
+	if err != nil {
+		return nil, err
+	}
+	sub, ids, err := wantdag.Narrow(ctx, src, dag, roots, e.Narrowers)
+	if err != nil {
+		return nil, err
+	}
+	subRef, err := wantdag.PostDAG(ctx, jc.Dst, sub)
+	if err != nil {
+		return nil, err
+	}
+	defer jc.InfoSpan(fmt.Sprintf("build %d targets, %d nodes", len(roots), len(sub)))()
+	res, dagStore, err := wantjob.Do(ctx, jc.System, stores.Union{src, jc.Dst}, wantjob.Task{
+		Op:    e.DAGExecAllOp,
+		Input: glfstasks.MarshalGLFSRef(*subRef),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := res.Err(); err != nil {
+		return nil, err
+	}
+	resultsRef, err := glfstasks.ParseGLFSRef(res.Root)
+	if err != nil {
+		return nil, err
+	}
+	nodeResults, err := wantdag.GetNodeResults(ctx, dagStore, *resultsRef)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]wantjob.Result, len(roots))
+	for i, id := range ids {
+		results[i] = nodeResults[id]
+		if ref, err := glfstasks.ParseGLFSRef(results[i].Root); err == nil {
+			if err := glfstasks.FastSync(ctx, jc.Dst, dagStore, *ref); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return results, nil
+}
+
 func MakeDeps(jc wantjob.Ctx, src cadata.Getter, modRef glfs.Ref, eval func(wantcfg.Expr) (*glfs.Ref, error)) (map[wantc.ExprID]glfs.Ref, error) {
 	deps := make(map[wantc.ExprID]glfs.Ref)
 	if err := dependencyClosure(jc.Context, stores.Union{jc.Dst, src}, modRef, deps, eval); err != nil {
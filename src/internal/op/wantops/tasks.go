@@ -21,6 +21,7 @@ const MaxMetadataSize = 1 << 17
 type BuildConfig struct {
 	Query    wantcfg.PathSet `json:"query"`
 	Metadata wantc.Metadata  `json:"metadata"`
+	Params   wantc.Params    `json:"params,omitempty"`
 }
 
 // BuildTask can be encoded as a GLFS Tree.
@@ -29,12 +30,15 @@ type BuildTask struct {
 
 	Query    wantcfg.PathSet
 	Metadata wantc.Metadata
+	// Params are the build parameters set for the build.
+	Params wantc.Params
 }
 
 func PostBuildTask(ctx context.Context, s cadata.PostExister, x BuildTask) (*glfs.Ref, error) {
 	cfgJson, err := json.Marshal(BuildConfig{
 		Query:    x.Query,
 		Metadata: x.Metadata,
+		Params:   x.Params,
 	})
 	if err != nil {
 		return nil, err
@@ -70,6 +74,7 @@ func GetBuildTask(ctx context.Context, s cadata.Getter, x glfs.Ref) (*BuildTask,
 		Main:     *mainRef,
 		Query:    cfg.Query,
 		Metadata: cfg.Metadata,
+		Params:   cfg.Params,
 	}, nil
 }
 
@@ -189,11 +194,24 @@ func PostCompileTask(ctx context.Context, s cadata.PostExister, x wantc.CompileT
 	if err != nil {
 		return nil, err
 	}
-	return glfs.PostTreeMap(ctx, s, map[string]glfs.Ref{
+	m := map[string]glfs.Ref{
 		"module":    x.Module,
 		"meta.json": *mdRef,
 		"deps":      *depsRef,
-	})
+	}
+	// params.json is omitted when there are no params, so tasks without them are the same as before params existed.
+	if len(x.Params) > 0 {
+		paramsJson, err := json.Marshal(x.Params)
+		if err != nil {
+			return nil, err
+		}
+		paramsRef, err := glfs.PostBlob(ctx, s, bytes.NewReader(paramsJson))
+		if err != nil {
+			return nil, err
+		}
+		m["params.json"] = *paramsRef
+	}
+	return glfs.PostTreeMap(ctx, s, m)
 }
 
 func GetCompileTask(ctx context.Context, s cadata.Getter, x glfs.Ref) (*wantc.CompileTask, error) {
@@ -213,6 +231,20 @@ func GetCompileTask(ctx context.Context, s cadata.Getter, x glfs.Ref) (*wantc.Co
 	if err := json.Unmarshal(data, &md); err != nil {
 		return nil, fmt.Errorf("meta.json did not contain valid json: %q, %w", data, err)
 	}
+	var params wantc.Params
+	paramsRef, err := glfs.GetAtPath(ctx, s, x, "params.json")
+	if err != nil && !glfs.IsErrNoEnt(err) {
+		return nil, err
+	}
+	if paramsRef != nil {
+		data, err := glfs.GetBlobBytes(ctx, s, *paramsRef, MaxMetadataSize)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &params); err != nil {
+			return nil, fmt.Errorf("params.json did not contain valid json: %q, %w", data, err)
+		}
+	}
 	depsRef, err := glfs.GetAtPath(ctx, s, x, "deps")
 	if err != nil && !glfs.IsErrNoEnt(err) {
 		return nil, err
@@ -234,6 +266,7 @@ func GetCompileTask(ctx context.Context, s cadata.Getter, x glfs.Ref) (*wantc.Co
 	return &wantc.CompileTask{
 		Module:   *moduleRef,
 		Metadata: md,
+		Params:   params,
 		Deps:     deps,
 	}, nil
 }
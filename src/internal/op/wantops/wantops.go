@@ -29,13 +29,17 @@ var _ wantjob.Executor = &Executor{}
 type Executor struct {
 	CompileOp wantjob.OpName
 	DAGExecOp wantjob.OpName
+	// DAGExecAllOp is used to build all of the selected targets at once.
+	DAGExecAllOp wantjob.OpName
+	// Narrowers are used to compute only the parts of each node which the selected targets need.
+	Narrowers map[wantjob.OpName]wantdag.Narrower
 }
 
 func (e Executor) Execute(jc wantjob.Ctx, src cadata.Getter, x wantjob.Task) wantjob.Result {
 	ctx := jc.Context
 	switch x.Op {
 	case OpBuild:
-		return glfstasks.Exec(x.Input, func(x glfs.Ref) (*glfs.Ref, error) {
+		return execDiagnostic(x.Input, func(x glfs.Ref) (*glfs.Ref, error) {
 			buildTask, err := GetBuildTask(ctx, src, x)
 			if err != nil {
 				return nil, err
@@ -57,7 +61,7 @@ func (e Executor) Execute(jc wantjob.Ctx, src cadata.Getter, x wantjob.Task) wan
 			return outRef, err
 		})
 	case OpCompile:
-		return glfstasks.Exec(x.Input, func(x glfs.Ref) (*glfs.Ref, error) {
+		return execDiagnostic(x.Input, func(x glfs.Ref) (*glfs.Ref, error) {
 			return e.Compile(jc, src, x)
 		})
 	case OpCompileSnippet:
@@ -109,7 +113,7 @@ func DoCompile(ctx context.Context, sys wantjob.System, compileOp wantjob.OpName
 	if err != nil {
 		return nil, nil, err
 	}
-	planRef, planStore, err := glfstasks.Do(ctx, sys, stores.Union{src, scratch}, compileOp, *ctRef)
+	planRef, planStore, err := Do(ctx, sys, stores.Union{src, scratch}, compileOp, *ctRef)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -120,6 +124,58 @@ func DoCompile(ctx context.Context, sys wantjob.System, compileOp wantjob.OpName
 	return plan, planStore, nil
 }
 
+// Do is like glfstasks.Do, but if the job fails because of a compiler diagnostic, it is returned as a *wantc.Diagnostic
+func Do(ctx context.Context, sys wantjob.System, src cadata.Getter, op wantjob.OpName, x glfs.Ref) (*glfs.Ref, cadata.Getter, error) {
+	_, ref, dst, err := DoJob(ctx, sys, src, op, x)
+	return ref, dst, err
+}
+
+// DoJob is like Do, but also returns the job in sys which performed the task.
+func DoJob(ctx context.Context, sys wantjob.System, src cadata.Getter, op wantjob.OpName, x glfs.Ref) (wantjob.Idx, *glfs.Ref, cadata.Getter, error) {
+	idx, err := sys.Spawn(ctx, src, wantjob.Task{
+		Op:    op,
+		Input: glfstasks.MarshalGLFSRef(x),
+	})
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if err := sys.Await(ctx, idx); err != nil {
+		return 0, nil, nil, err
+	}
+	res, dst, err := sys.ViewResult(ctx, idx)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if res.ErrCode == wantjob.EXEC_ERROR {
+		if diag, err := wantc.ParseDiagnostic(res.Root); err == nil {
+			return 0, nil, nil, diag
+		}
+	}
+	if err := res.Err(); err != nil {
+		return 0, nil, nil, err
+	}
+	ref, err := glfstasks.ParseGLFSRef(res.Root)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return idx, ref, dst, nil
+}
+
+// execDiagnostic is like glfstasks.Exec, but compiler diagnostics are returned as data,
+// so they can be shown to the user along with the source.
+func execDiagnostic(x []byte, fn func(x glfs.Ref) (*glfs.Ref, error)) wantjob.Result {
+	var diag *wantc.Diagnostic
+	res := glfstasks.Exec(x, func(x glfs.Ref) (*glfs.Ref, error) {
+		out, err := fn(x)
+		errors.As(err, &diag)
+		return out, err
+	})
+	if diag != nil {
+		return wantjob.Result{ErrCode: wantjob.EXEC_ERROR, Root: wantc.MarshalDiagnostic(*diag)}
+	}
+	return res
+}
+
 func (e Executor) EvalExpr(jc wantjob.Ctx, src cadata.Getter, expr wantcfg.Expr) (*glfs.Ref, error) {
 	ctx := jc.Context
 	c := wantc.NewCompiler()
@@ -24,6 +24,7 @@ func TestPostGetBuildTask(t *testing.T) {
 			"b": "b",
 			"c": "c",
 		},
+		Params: map[string]any{"release": true},
 	}
 	ref, err := PostBuildTask(ctx, s, x)
 	require.NoError(t, err)
@@ -41,6 +42,7 @@ func TestPostGetCompileTask(t *testing.T) {
 	x := wantc.CompileTask{
 		Module:   *modRef,
 		Metadata: map[string]any{"abc": "123"},
+		Params:   map[string]any{"arch": "arm64", "jobs": 4.0},
 		Deps:     map[wantc.ExprID]glfs.Ref{},
 	}
 	ref, err := PostCompileTask(ctx, s, x)
@@ -50,3 +52,24 @@ func TestPostGetCompileTask(t *testing.T) {
 
 	require.Equal(t, x, *y)
 }
+
+func TestPostCompileTaskNoParams(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+
+	modRef, err := glfs.PostTreeSlice(ctx, s, nil)
+	require.NoError(t, err)
+	x := wantc.CompileTask{
+		Module:   *modRef,
+		Metadata: map[string]any{"abc": "123"},
+		Deps:     map[wantc.ExprID]glfs.Ref{},
+	}
+	ref, err := PostCompileTask(ctx, s, x)
+	require.NoError(t, err)
+	_, err = glfs.GetAtPath(ctx, s, *ref, "params.json")
+	require.True(t, glfs.IsErrNoEnt(err))
+	y, err := GetCompileTask(ctx, s, *ref)
+	require.NoError(t, err)
+
+	require.Equal(t, x, *y)
+}
@@ -0,0 +1,141 @@
+package wasmops
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"blobcache.io/glfs"
+	"github.com/tetratelabs/wazero"
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/wantjob"
+)
+
+// ExecWASIp1Component runs a component built from a wasip1 core module, with the same filesystem contract as ExecWASIp1.
+//
+// wazero does not implement the component model or the canonical ABI, so the component itself is never instantiated.
+// Components built by wrapping a wasip1 module with the wasi_snapshot_preview1 adapter still contain that module.
+// ExecWASIp1Component extracts it, and runs it as a wasip1 program in place of the component.
+// Components whose core modules import WASI preview 2 interfaces directly,
+// like those built for wasm32-wasip2 by Rust or TinyGo, are not supported.
+// A plain wasip1 core module is also accepted, and run as is.
+func (e *Executor) ExecWASIp1Component(jc wantjob.Ctx, src cadata.Getter, task WASIp1ComponentTask) (*glfs.Ref, error) {
+	prog, err := e.adaptComponent(jc.Context, task.Program)
+	if err != nil {
+		return nil, err
+	}
+	return e.ExecWASIp1(jc, src, WASIp1Task{
+		Memory:  task.Memory,
+		Program: prog,
+		Input:   task.Input,
+		Args:    task.Args,
+		Env:     task.Env,
+	})
+}
+
+var (
+	wasmMagic = []byte{0x00, 0x61, 0x73, 0x6d}
+	// coreVersion is the version field of a core module.
+	coreVersion = []byte{0x01, 0x00, 0x00, 0x00}
+	// componentVersion is the version and layer fields of a component.
+	componentVersion = []byte{0x0d, 0x00, 0x01, 0x00}
+)
+
+const (
+	componentSectionCoreModule = 1
+)
+
+// adaptComponent returns the wasip1 core module which can be run in place of the component x.
+// It is the only core module which exports _start and imports nothing except wasi_snapshot_preview1.
+// If x is already a core module, then it is returned as long as it only imports wasip1.
+func (e *Executor) adaptComponent(ctx context.Context, x []byte) ([]byte, error) {
+	if len(x) < 8 || !bytes.Equal(x[:4], wasmMagic) {
+		return nil, errors.New("wasip1Component: program is not a WebAssembly binary")
+	}
+	var candidates [][]byte
+	switch {
+	case bytes.Equal(x[4:8], coreVersion):
+		candidates = [][]byte{x}
+	case bytes.Equal(x[4:8], componentVersion):
+		var err error
+		if candidates, err = componentCoreModules(x); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("wasip1Component: unsupported binary version %x", x[4:8])
+	}
+
+	// The module is only compiled here, so allow the largest possible memory.
+	r := wazero.NewRuntimeWithConfig(ctx, e.runtimeConfig(1<<32))
+	defer r.Close(ctx)
+	var unsupported []string
+	var ret []byte
+	for _, mod := range candidates {
+		cm, err := r.CompileModule(ctx, mod)
+		if err != nil {
+			return nil, fmt.Errorf("wasip1Component: compiling core module: %w", err)
+		}
+		if _, exists := cm.ExportedFunctions()["_start"]; !exists {
+			continue
+		}
+		imports := importedModules(cm)
+		delete(imports, "wasi_snapshot_preview1")
+		if len(imports) == 0 {
+			if ret != nil {
+				return nil, errors.New("wasip1Component: component contains more than one wasip1 module exporting _start")
+			}
+			ret = mod
+			continue
+		}
+		for name := range imports {
+			unsupported = append(unsupported, name)
+		}
+	}
+	if ret != nil {
+		return ret, nil
+	}
+	if len(unsupported) > 0 {
+		slices.Sort(unsupported)
+		return nil, fmt.Errorf("wasip1Component: component imports %v directly, only components adapted from wasip1 are supported", unsupported)
+	}
+	return nil, errors.New("wasip1Component: component does not contain a core module exporting _start")
+}
+
+// componentCoreModules returns the core modules defined at the top level of the component x.
+func componentCoreModules(x []byte) ([][]byte, error) {
+	var ret [][]byte
+	rest := x[8:]
+	for len(rest) > 0 {
+		id := rest[0]
+		size, n := binary.Uvarint(rest[1:])
+		if n <= 0 || size > uint64(len(rest)-1-n) {
+			return nil, errors.New("wasip1Component: malformed component section")
+		}
+		payload := rest[1+n : 1+n+int(size)]
+		if id == componentSectionCoreModule {
+			ret = append(ret, payload)
+		}
+		rest = rest[1+n+int(size):]
+	}
+	return ret, nil
+}
+
+// importedModules returns the set of module names which cm imports from.
+func importedModules(cm wazero.CompiledModule) map[string]struct{} {
+	ret := make(map[string]struct{})
+	for _, fd := range cm.ImportedFunctions() {
+		if modName, _, isImport := fd.Import(); isImport {
+			ret[modName] = struct{}{}
+		}
+	}
+	for _, md := range cm.ImportedMemories() {
+		if modName, _, isImport := md.Import(); isImport {
+			ret[modName] = struct{}{}
+		}
+	}
+	return ret
+}
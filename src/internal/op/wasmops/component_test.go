@@ -0,0 +1,134 @@
+package wasmops
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"blobcache.io/glfs"
+	"github.com/stretchr/testify/require"
+
+	"wantbuild.io/want/src/internal/stores"
+	"wantbuild.io/want/src/internal/testutil"
+)
+
+func TestWASIp1Component(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+
+	e := newTestExecutor(t)
+	jc := NewTestJobCtx(t, ctx, s)
+	fs1 := testutil.PostTree(t, s, []glfs.TreeEntry{
+		{Name: "a.txt", FileMode: 0o600, Ref: testutil.PostBlob(t, s, []byte("aaaaa"))},
+		{Name: "b.txt", FileMode: 0o600, Ref: testutil.PostBlob(t, s, []byte("bbbbbbbb"))},
+	})
+	core := buildWASMBin(t, "testdata/copy/copy.go")
+
+	for _, prog := range [][]byte{core, adaptedComponent(core)} {
+		out, err := e.ExecWASIp1Component(jc, s, WASIp1ComponentTask{
+			Program: prog,
+			Input:   fs1,
+		})
+		require.NoError(t, err)
+		require.Equal(t, fs1, *out)
+	}
+}
+
+func TestAdaptComponent(t *testing.T) {
+	ctx := testutil.Context(t)
+	e := newTestExecutor(t)
+	main := coreModule([][2]string{{"wasi_snapshot_preview1", "fd_write"}}, []string{"_start"})
+
+	out, err := e.adaptComponent(ctx, adaptedComponent(main))
+	require.NoError(t, err)
+	require.Equal(t, main, out)
+
+	// a core module built for wasip2 imports the interfaces directly.
+	p2 := coreModule([][2]string{{"wasi:cli/stdout@0.2.0", "get-stdout"}, {"wasi:cli/exit@0.2.0", "exit"}}, []string{"_start"})
+	_, err = e.adaptComponent(ctx, component(p2))
+	require.ErrorContains(t, err, "[wasi:cli/exit@0.2.0 wasi:cli/stdout@0.2.0] directly")
+
+	_, err = e.adaptComponent(ctx, component(main, main))
+	require.ErrorContains(t, err, "more than one")
+	_, err = e.adaptComponent(ctx, []byte("not wasm"))
+	require.Error(t, err)
+	// A component with no core modules.
+	_, err = e.adaptComponent(ctx, component())
+	require.Error(t, err)
+}
+
+// adaptedComponent returns a component laid out like the output of wasm-tools component new,
+// when main is adapted with the wasi_snapshot_preview1 command adapter.
+// The sections which instantiate the modules and lower the imports are not included, since they are never read.
+func adaptedComponent(main []byte) []byte {
+	const environment = "wasi:cli/environment@0.2.0"
+	adapter := coreModule(
+		[][2]string{{environment, "get-environment"}, {"__main_module__", "_start"}},
+		[]string{"environ_get", "fd_write", "wasi:cli/run@0.2.0#run"},
+	)
+	fixups := coreModule(nil, nil)
+
+	var ret []byte
+	// an empty instance type, and an import of it.
+	ret = appendSection(ret, 7, []byte{1, 0x42, 0})
+	ret = appendSection(ret, 10, append(appendName([]byte{1, 0}, environment), 0x05, 0))
+	ret = appendSection(ret, componentSectionCoreModule, main)
+	ret = appendSection(ret, componentSectionCoreModule, adapter)
+	ret = appendSection(ret, componentSectionCoreModule, fixups)
+	ret = appendSection(ret, 0, appendName(nil, "component-name"))
+	return append(component(), ret...)
+}
+
+// component returns a component binary containing the core modules mods.
+func component(mods ...[]byte) []byte {
+	ret := append([]byte{}, wasmMagic...)
+	ret = append(ret, componentVersion...)
+	for _, mod := range mods {
+		ret = appendSection(ret, componentSectionCoreModule, mod)
+	}
+	return ret
+}
+
+// coreModule returns a core module which imports and exports functions which take no parameters and return nothing.
+// The exported functions do nothing.
+func coreModule(imports [][2]string, exports []string) []byte {
+	ret := append([]byte{}, wasmMagic...)
+	ret = append(ret, coreVersion...)
+	ret = appendSection(ret, 1, []byte{1, 0x60, 0, 0})
+	if len(imports) > 0 {
+		sec := binary.AppendUvarint(nil, uint64(len(imports)))
+		for _, imp := range imports {
+			sec = appendName(sec, imp[0])
+			sec = appendName(sec, imp[1])
+			sec = append(sec, 0, 0)
+		}
+		ret = appendSection(ret, 2, sec)
+	}
+	if len(exports) > 0 {
+		funcs := binary.AppendUvarint(nil, uint64(len(exports)))
+		exps := binary.AppendUvarint(nil, uint64(len(exports)))
+		code := binary.AppendUvarint(nil, uint64(len(exports)))
+		for i, name := range exports {
+			funcs = append(funcs, 0)
+			exps = appendName(exps, name)
+			exps = append(exps, 0)
+			exps = binary.AppendUvarint(exps, uint64(len(imports)+i))
+			// no locals, and end.
+			code = append(code, 2, 0, 0x0b)
+		}
+		ret = appendSection(ret, 3, funcs)
+		ret = appendSection(ret, 7, exps)
+		ret = appendSection(ret, 10, code)
+	}
+	return ret
+}
+
+func appendSection(out []byte, id byte, payload []byte) []byte {
+	out = append(out, id)
+	out = binary.AppendUvarint(out, uint64(len(payload)))
+	return append(out, payload...)
+}
+
+func appendName(out []byte, name string) []byte {
+	out = binary.AppendUvarint(out, uint64(len(name)))
+	return append(out, name...)
+}
@@ -2,6 +2,7 @@ package wasmops
 
 import (
 	"blobcache.io/glfs"
+	"github.com/tetratelabs/wazero"
 	"go.brendoncarroll.net/state/cadata"
 
 	"wantbuild.io/want/src/internal/glfstasks"
@@ -10,23 +11,41 @@ import (
 )
 
 const (
-	OpWASIp1     = wantjob.OpName("wasip1")
-	OpNativeGLFS = wantjob.OpName("nativeGLFS")
+	OpWASIp1          = wantjob.OpName("wasip1")
+	OpWASIp1Component = wantjob.OpName("wasip1Component")
+	OpNativeGLFS      = wantjob.OpName("nativeGLFS")
 )
 
 type (
-	WASIp1Task     = wantwasm.WASIp1Task
-	NativeGLFSTask = wantwasm.NativeGLFSTask
+	WASIp1Task          = wantwasm.WASIp1Task
+	WASIp1ComponentTask = wantwasm.WASIp1ComponentTask
+	NativeGLFSTask      = wantwasm.NativeGLFSTask
 )
 
 var _ wantjob.Executor = &Executor{}
 
+type Config struct {
+	// CacheDir is where compiled modules are persisted between runs.
+	// If empty, compiled modules are only cached in memory.
+	CacheDir string
+}
+
 type Executor struct {
-	ag *glfs.Agent
+	ag    *glfs.Agent
+	cache wazero.CompilationCache
 }
 
-func NewExecutor() *Executor {
-	return &Executor{ag: glfs.NewAgent()}
+// NewExecutor returns an Executor with a compilation cache, which is shared by all the jobs it executes.
+// Compiled modules are keyed by the hash of the program.
+func NewExecutor(cfg Config) (*Executor, error) {
+	cache := wazero.NewCompilationCache()
+	if cfg.CacheDir != "" {
+		var err error
+		if cache, err = wazero.NewCompilationCacheWithDir(cfg.CacheDir); err != nil {
+			return nil, err
+		}
+	}
+	return &Executor{ag: glfs.NewAgent(), cache: cache}, nil
 }
 
 func (e *Executor) Execute(jc wantjob.Ctx, src cadata.Getter, x wantjob.Task) wantjob.Result {
@@ -38,7 +57,15 @@ func (e *Executor) Execute(jc wantjob.Ctx, src cadata.Getter, x wantjob.Task) wa
 			if err != nil {
 				return nil, err
 			}
-			return ExecWASIp1(jc, src, *task)
+			return e.ExecWASIp1(jc, src, *task)
+		})
+	case OpWASIp1Component:
+		return glfstasks.Exec(x.Input, func(x glfs.Ref) (*glfs.Ref, error) {
+			task, err := wantwasm.GetWASIp1ComponentTask(ctx, e.ag, src, x)
+			if err != nil {
+				return nil, err
+			}
+			return e.ExecWASIp1Component(jc, src, *task)
 		})
 	case OpNativeGLFS:
 		return glfstasks.Exec(x.Input, func(x glfs.Ref) (*glfs.Ref, error) {
@@ -15,12 +15,12 @@ import (
 	"wantbuild.io/want/src/wantjob"
 )
 
-func ExecWASIp1(jc wantjob.Ctx, src cadata.Getter, task WASIp1Task) (*glfs.Ref, error) {
+func (e *Executor) ExecWASIp1(jc wantjob.Ctx, src cadata.Getter, task WASIp1Task) (*glfs.Ref, error) {
 	ctx := jc.Context
 	if task.Memory == 0 {
 		task.Memory = 4 * 1e9
 	}
-	r := wazero.NewRuntimeWithConfig(ctx, newRuntimeConfig(task.Memory))
+	r := wazero.NewRuntimeWithConfig(ctx, e.runtimeConfig(task.Memory))
 	defer r.Close(ctx)
 	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
 		return nil, err
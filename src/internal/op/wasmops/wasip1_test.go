@@ -23,6 +23,7 @@ func TestWASIp1(t *testing.T) {
 	ctx := testutil.Context(t)
 	s := stores.NewMem()
 
+	e := newTestExecutor(t)
 	jc := NewTestJobCtx(t, ctx, s)
 	fs1 := testutil.PostTree(t, s, []glfs.TreeEntry{
 		{Name: "a.txt", FileMode: 0o600, Ref: testutil.PostBlob(t, s, []byte("aaaaa"))},
@@ -45,7 +46,7 @@ func TestWASIp1(t *testing.T) {
 	}
 	for i, tc := range tcs {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
-			out, err := ExecWASIp1(jc, s, tc.Task)
+			out, err := e.ExecWASIp1(jc, s, tc.Task)
 			if tc.Err == nil {
 				require.NoError(t, err)
 			} else {
@@ -63,6 +64,30 @@ func TestWASIp1(t *testing.T) {
 	}
 }
 
+func TestCompilationCache(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+	cacheDir := t.TempDir()
+	prog := buildWASMBin(t, "testdata/copy/copy.go")
+	fs1 := testutil.PostTree(t, s, []glfs.TreeEntry{
+		{Name: "a.txt", FileMode: 0o600, Ref: testutil.PostBlob(t, s, []byte("aaaaa"))},
+	})
+
+	// A second executor using the same directory should reuse the compiled module.
+	for i := 0; i < 2; i++ {
+		e, err := NewExecutor(Config{CacheDir: cacheDir})
+		require.NoError(t, err)
+		jc := NewTestJobCtx(t, ctx, s)
+		out, err := e.ExecWASIp1(jc, s, WASIp1Task{Program: prog, Input: fs1})
+		require.NoError(t, err)
+		require.Equal(t, fs1, *out)
+
+		ents, err := os.ReadDir(cacheDir)
+		require.NoError(t, err)
+		require.NotEmpty(t, ents)
+	}
+}
+
 func buildWASMBin(t testing.TB, dir string) []byte {
 	outPath := filepath.Join(t.TempDir(), "main-bin")
 	defer os.Remove(outPath)
@@ -92,6 +117,12 @@ func buildWASMBin(t testing.TB, dir string) []byte {
 	return data
 }
 
+func newTestExecutor(t testing.TB) *Executor {
+	e, err := NewExecutor(Config{})
+	require.NoError(t, err)
+	return e
+}
+
 func NewTestJobCtx(t testing.TB, ctx context.Context, dst cadata.Store) wantjob.Ctx {
 	t.Helper()
 	return wantjob.Ctx{
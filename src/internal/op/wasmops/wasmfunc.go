@@ -20,7 +20,7 @@ func (e *Executor) ExecNativeGLFS(jc wantjob.Ctx, s cadata.Getter, task wantwasm
 	if task.Memory == 0 {
 		task.Memory = 1e9
 	}
-	r := wazero.NewRuntimeWithConfig(ctx, newRuntimeConfig(task.Memory))
+	r := wazero.NewRuntimeWithConfig(ctx, e.runtimeConfig(task.Memory))
 	defer r.Close(ctx)
 	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
 		return nil, err
@@ -128,10 +128,11 @@ func (e *Executor) ExecNativeGLFS(jc wantjob.Ctx, s cadata.Getter, task wantwasm
 	return out, nil
 }
 
-func newRuntimeConfig(memory uint64) wazero.RuntimeConfig {
+func (e *Executor) runtimeConfig(memory uint64) wazero.RuntimeConfig {
 	const pageSize = 1 << 16
 	rtCfg := wazero.NewRuntimeConfig().
 		WithMemoryLimitPages(uint32(memory / pageSize)).
-		WithDebugInfoEnabled(true)
+		WithDebugInfoEnabled(true).
+		WithCompilationCache(e.cache)
 	return rtCfg
 }
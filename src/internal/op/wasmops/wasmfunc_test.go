@@ -16,7 +16,8 @@ import (
 func TestHarness(t *testing.T) {
 	ctx := testutil.Context(t)
 	s := stores.NewMem()
-	e := wasmops.NewExecutor()
+	e, err := wasmops.NewExecutor(wasmops.Config{})
+	require.NoError(t, err)
 	jc := wasmops.NewTestJobCtx(t, ctx, s)
 
 	out, err := e.ExecNativeGLFS(jc, s, wantwasm.NativeGLFSTask{
@@ -46,7 +46,7 @@ func (p Prefix) superset(x Set) maybe.Maybe[bool] {
 	case Suffix:
 		y = p == "" && x == ""
 	default:
-		return maybe.Nothing[bool]()
+		return boundsSuperset(string(p), "", x)
 	}
 	return maybe.Just(y)
 }
@@ -98,7 +98,7 @@ func (s Suffix) superset(x Set) maybe.Maybe[bool] {
 	case Suffix:
 		y = strings.HasSuffix(string(x), string(s))
 	default:
-		return maybe.Nothing[bool]()
+		return boundsSuperset("", string(s), x)
 	}
 	return maybe.Just(y)
 }
@@ -0,0 +1,321 @@
+package stringsets
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"go.brendoncarroll.net/exp/maybe"
+)
+
+// Glob is the set of strings which match a glob pattern.
+// "*" matches any text within a path element, "?" matches a single character other than "/",
+// and "[...]" matches a class of characters other than "/", with the same syntax as path.Match.
+// "**" as a whole path element matches any number of path elements, including none.
+//
+// Globs are not simplified against other sets.
+// Intersects and Superset compare them by the literal text before and after their wildcards,
+// which is exact for Units, and otherwise assumes that they may intersect, and are not supersets.
+type Glob string
+
+func (g Glob) Contains(x string) bool {
+	re, err := g.Regexp()
+	return err == nil && re.MatchString(x)
+}
+
+// Regexp returns a regular expression which matches the same strings as g.
+func (g Glob) Regexp() (*regexp.Regexp, error) {
+	return cachedRegexp("glob:"+string(g), func() (*regexp.Regexp, error) {
+		expr, err := globToRegexp(string(g))
+		if err != nil {
+			return nil, err
+		}
+		return regexp.Compile(expr)
+	})
+}
+
+func (g Glob) String() string {
+	return ToPathSet(g).String()
+}
+
+func (g Glob) bounds() (prefix, suffix string) {
+	const meta = `*?[]\`
+	s := string(g)
+	i := strings.IndexAny(s, meta)
+	if i < 0 {
+		return s, s
+	}
+	j := strings.LastIndexAny(s, meta)
+	suffix = s[j+1:]
+	if before := s[:j+1]; before == "**" || strings.HasSuffix(before, "/**") {
+		// a ** element can match no elements at all, along with the / after it.
+		suffix = strings.TrimPrefix(suffix, "/")
+	}
+	return s[:i], suffix
+}
+
+func (g Glob) intersects(x Set) maybe.Maybe[bool] {
+	return patternIntersects(g, x)
+}
+
+func (g Glob) superset(x Set) maybe.Maybe[bool] {
+	return patternSuperset(g, x)
+}
+
+func (g Glob) simplify() Set {
+	return g
+}
+
+func (g Glob) isDNF() bool {
+	return true
+}
+
+// Regex is the set of strings which entirely match a regular expression, in the syntax of the regexp package.
+//
+// Like Glob, Regex is compared with other sets using the literal text it must begin with.
+type Regex string
+
+func (r Regex) Contains(x string) bool {
+	re, err := r.Regexp()
+	return err == nil && re.MatchString(x)
+}
+
+// Regexp returns r compiled, and anchored to match entire strings.
+func (r Regex) Regexp() (*regexp.Regexp, error) {
+	return cachedRegexp("regex:"+string(r), func() (*regexp.Regexp, error) {
+		return regexp.Compile(`^(?:` + string(r) + `)$`)
+	})
+}
+
+func (r Regex) String() string {
+	return ToPathSet(r).String()
+}
+
+func (r Regex) bounds() (prefix, suffix string) {
+	re, err := regexp.Compile(string(r))
+	if err != nil {
+		return "", ""
+	}
+	prefix, complete := re.LiteralPrefix()
+	if complete {
+		return prefix, prefix
+	}
+	return prefix, ""
+}
+
+func (r Regex) intersects(x Set) maybe.Maybe[bool] {
+	return patternIntersects(r, x)
+}
+
+func (r Regex) superset(x Set) maybe.Maybe[bool] {
+	return patternSuperset(r, x)
+}
+
+func (r Regex) simplify() Set {
+	return r
+}
+
+func (r Regex) isDNF() bool {
+	return true
+}
+
+// pattern is a Set which is defined by a pattern, and can only be compared with other sets approximately.
+type pattern interface {
+	Set
+	// bounds returns literal text, which every element of the set begins and ends with.
+	bounds() (prefix, suffix string)
+}
+
+// boundsSet returns a superset of p, which can be compared with other sets.
+func boundsSet(p pattern) Set {
+	prefix, suffix := p.bounds()
+	return And{Prefix(prefix), Suffix(suffix)}
+}
+
+func patternIntersects(p pattern, x Set) maybe.Maybe[bool] {
+	switch x := x.(type) {
+	case Empty:
+		return maybe.Just(false)
+	case Unit:
+		return maybe.Just(p.Contains(string(x)))
+	}
+	if isFalse(intersects(boundsSet(p), x)) {
+		return maybe.Just(false)
+	}
+	return maybe.Nothing[bool]()
+}
+
+func patternSuperset(p pattern, x Set) maybe.Maybe[bool] {
+	switch x := x.(type) {
+	case Empty:
+		return maybe.Just(true)
+	case Unit:
+		return maybe.Just(p.Contains(string(x)))
+	}
+	if p == x {
+		return maybe.Just(true)
+	}
+	if isFalse(superset(boundsSet(p), x)) {
+		return maybe.Just(false)
+	}
+	return maybe.Nothing[bool]()
+}
+
+// boundsSuperset returns true if every element of x is known to begin with prefix and end with suffix.
+func boundsSuperset(prefix, suffix string, x Set) maybe.Maybe[bool] {
+	p, ok := x.(pattern)
+	if !ok {
+		return maybe.Nothing[bool]()
+	}
+	xp, xs := p.bounds()
+	if strings.HasPrefix(xp, prefix) && strings.HasSuffix(xs, suffix) {
+		return maybe.Just(true)
+	}
+	return maybe.Nothing[bool]()
+}
+
+var regexpCache sync.Map
+
+type cachedResult struct {
+	re  *regexp.Regexp
+	err error
+}
+
+// cachedRegexp returns the regexp for key, calling compile the first time it is needed.
+func cachedRegexp(key string, compile func() (*regexp.Regexp, error)) (*regexp.Regexp, error) {
+	if x, ok := regexpCache.Load(key); ok {
+		res := x.(cachedResult)
+		return res.re, res.err
+	}
+	re, err := compile()
+	regexpCache.Store(key, cachedResult{re: re, err: err})
+	return re, err
+}
+
+// globToRegexp translates the glob pattern g into a regular expression which matches the same strings.
+func globToRegexp(g string) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	elems := strings.Split(g, "/")
+	for i, elem := range elems {
+		last := i == len(elems)-1
+		if elem == "**" {
+			if last {
+				sb.WriteString(".*")
+			} else {
+				sb.WriteString("(?:[^/]*/)*")
+			}
+			continue
+		}
+		for j := 0; j < len(elem); j++ {
+			switch c := elem[j]; c {
+			case '*':
+				sb.WriteString("[^/]*")
+			case '?':
+				sb.WriteString("[^/]")
+			case '\\':
+				if j+1 == len(elem) {
+					return "", fmt.Errorf("invalid glob %q: trailing \\", g)
+				}
+				j++
+				sb.WriteString(regexp.QuoteMeta(elem[j : j+1]))
+			case '[':
+				class, n, err := globClass(elem[j+1:])
+				if err != nil {
+					return "", fmt.Errorf("invalid glob %q: %w", g, err)
+				}
+				sb.WriteString(class)
+				j += n
+			default:
+				sb.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		}
+		if !last {
+			sb.WriteString("/")
+		}
+	}
+	sb.WriteString("$")
+	return sb.String(), nil
+}
+
+// globClass translates the character class at the start of s, which follows a "[", into a regular expression.
+// It returns the expression, and the length of the class in s, including the closing "]".
+// The syntax is the same as path.Match, and like the other wildcards, a class never matches "/".
+func globClass(s string) (string, int, error) {
+	var sb strings.Builder
+	i := 0
+	negate := strings.HasPrefix(s, "^")
+	if negate {
+		i++
+	}
+	var count int
+	for {
+		if i >= len(s) {
+			return "", 0, errors.New("unterminated [")
+		}
+		if s[i] == ']' && count > 0 {
+			i++
+			break
+		}
+		lo, n, err := globClassChar(s[i:])
+		if err != nil {
+			return "", 0, err
+		}
+		i += n
+		hi := lo
+		if strings.HasPrefix(s[i:], "-") {
+			if hi, n, err = globClassChar(s[i+1:]); err != nil {
+				return "", 0, err
+			}
+			i += 1 + n
+		}
+		if lo > hi {
+			return "", 0, fmt.Errorf("invalid range %c-%c", lo, hi)
+		}
+		count++
+		// split the range around /
+		if !negate && lo <= '/' && '/' <= hi {
+			if lo < '/' {
+				sb.WriteString(classRange(lo, '/'-1))
+			}
+			if '/' < hi {
+				sb.WriteString(classRange('/'+1, hi))
+			}
+			continue
+		}
+		sb.WriteString(classRange(lo, hi))
+	}
+	if negate {
+		return `[^/` + sb.String() + `]`, i, nil
+	}
+	return `[` + sb.String() + `]`, i, nil
+}
+
+// globClassChar returns the character at the start of s, in a character class, and its length in s.
+func globClassChar(s string) (rune, int, error) {
+	if s == "" {
+		return 0, 0, errors.New("unterminated [")
+	}
+	n := 0
+	switch s[0] {
+	case '-', ']':
+		return 0, 0, fmt.Errorf("unescaped %c in character class", s[0])
+	case '\\':
+		n = 1
+		if len(s) == 1 {
+			return 0, 0, errors.New("unterminated [")
+		}
+	}
+	r, size := utf8.DecodeRuneInString(s[n:])
+	return r, n + size, nil
+}
+
+func classRange(lo, hi rune) string {
+	if lo == hi {
+		return fmt.Sprintf(`\x{%x}`, lo)
+	}
+	return fmt.Sprintf(`\x{%x}-\x{%x}`, lo, hi)
+}
@@ -15,6 +15,7 @@ func TestComplement(t *testing.T) {
 		Unit("key"),
 		Or{Unit("key1"), Unit("key2")},
 		And{Not{Unit("key")}, Not{Unit("key2")}},
+		Glob("src/**/*.go"),
 	}
 	for i, x := range xs {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
@@ -66,6 +67,17 @@ func TestIntersects(t *testing.T) {
 			Unit(".git/objects/f2"),
 			true,
 		},
+
+		{Glob("src/**/*.go"), Unit("src/a/b.go"), true},
+		{Glob("src/**/*.go"), Unit("src/a/b.js"), false},
+		{Glob("src/**/*.go"), Prefix("docs/"), false},
+		{Glob("src/**/*.go"), Prefix("src/a/"), true},
+		{Glob("src/**/*.go"), Suffix(".js"), false},
+		{Glob("src/**/*.go"), Glob("lib/*.go"), false},
+		{Glob("**/**/c"), Not{Suffix("/c")}, true},
+		{Regex(`src/.*\.go`), Unit("src/a/b.go"), true},
+		{Regex(`src/.*\.go`), Unit("lib/b.go"), false},
+		{Regex(`src/.*\.go`), Prefix("lib/"), false},
 	}
 
 	for i, tc := range tcs {
@@ -124,6 +136,12 @@ func TestSuperset(t *testing.T) {
 		{Unit("key1"), Empty{}},
 		{Prefix("aa"), Prefix("aaa")},
 		{Suffix("zz"), Suffix("zzz")},
+		{Prefix("src/"), Glob("src/**/*.go")},
+		{Suffix(".go"), Glob("src/**/*.go")},
+		{Prefix("src/"), Regex(`src/.*`)},
+		{Glob("src/**/*.go"), Unit("src/a/b.go")},
+		{Glob("src/*.go"), Glob("src/*.go")},
+		{Suffix("c"), Glob("**/**/c")},
 	}
 	for i, tc := range tcs {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
@@ -131,6 +149,8 @@ func TestSuperset(t *testing.T) {
 			require.True(t, Subset(tc.Sub, tc.Super), "%v should be a subset of %v", tc.Sub, tc.Super)
 		})
 	}
+	// "**/**/c" contains "c"
+	require.False(t, Superset(Suffix("/c"), Glob("**/**/c")))
 }
 
 func TestLongestCommon(t *testing.T) {
@@ -154,6 +174,77 @@ func TestLongestCommon(t *testing.T) {
 	}
 }
 
+func TestBoundingPrefix(t *testing.T) {
+	type testCase struct {
+		X      Set
+		Prefix string
+	}
+	tcs := []testCase{
+		{Unit("a/b"), "a/b"},
+		{Or{Prefix("a/b"), Prefix("a/c")}, "a/"},
+		{And{Prefix("a/"), Suffix(".json")}, "a/"},
+		{And{Suffix(".json"), Prefix("a/")}, "a/"},
+		{Suffix(".json"), ""},
+		{Glob("src/**/*.go"), "src/"},
+		{Regex(`src/(a|b)\.go`), "src/"},
+	}
+	for i, tc := range tcs {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			require.Equal(t, tc.Prefix, BoundingPrefix(tc.X))
+		})
+	}
+}
+
+func TestGlob(t *testing.T) {
+	type testCase struct {
+		Glob Glob
+		X    string
+		Yes  bool
+	}
+	tcs := []testCase{
+		{"*.go", "a.go", true},
+		{"*.go", "a/b.go", false},
+		{"src/?.go", "src/a.go", true},
+		{"src/?.go", "src/ab.go", false},
+		{"src/[ab].go", "src/b.go", true},
+		{"src/[^ab].go", "src/b.go", false},
+		{"src/[^ab].go", "src/c.go", true},
+		{"src/[a-c].go", "src/b.go", true},
+		{`src/[\]].go`, "src/].go", true},
+		{`src/[\d].go`, "src/1.go", false},
+		{`src/[\d].go`, "src/d.go", true},
+		{"src/[^a]", "src//", false},
+		{"src/[+-0]", "src/.", true},
+		// like path.Match, there are no named classes
+		{"src/[[:alpha:]]", "src/a", false},
+		{"src/[[:alpha:]]", "src/a]", true},
+		{"**/**/c", "c", true},
+		{"**/**/c", "a/c", true},
+		{"a/**/c", "a/c", true},
+		{"src/**", "src/a/b/c", true},
+		{"src/**/*.pb.go", "src/x.pb.go", true},
+		{"src/**/*.pb.go", "src/a/b/x.pb.go", true},
+		{"src/**/*.pb.go", "src/a/b/x.go", false},
+		{"**/BUILD", "BUILD", true},
+		{"**/BUILD", "a/b/BUILD", true},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+	}
+	for i, tc := range tcs {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			require.Equal(t, tc.Yes, tc.Glob.Contains(tc.X), "%v contains %q", tc.Glob, tc.X)
+			if tc.Yes {
+				// the bounds must contain every element of the glob.
+				require.True(t, boundsSet(tc.Glob).Contains(tc.X))
+			}
+		})
+	}
+	for _, g := range []Glob{"src/[ab", "src/[]", "src/[b-a]", "src/[a-]", `src/[a\`} {
+		_, err := g.Regexp()
+		require.Error(t, err, "%v", g)
+	}
+}
+
 // TestSimplify tests local simplification.
 func TestSimplify(t *testing.T) {
 	type testCase struct {
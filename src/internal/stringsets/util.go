@@ -7,20 +7,6 @@ import (
 	"wantbuild.io/want/src/wantcfg"
 )
 
-func SetFromGlob(g string) Set {
-	parts := strings.Split(g, "*")
-	if len(parts) == 1 {
-		return Unit(g)
-	}
-	if len(parts) == 2 {
-		return And{
-			Prefix(parts[1]),
-			Suffix(parts[2]),
-		}
-	}
-	panic("don't support more than 1 wildcard yet")
-}
-
 func BoundingPrefix(x Set) string {
 	x = Simplify(x)
 	switch x := x.(type) {
@@ -30,6 +16,9 @@ func BoundingPrefix(x Set) string {
 		return string(x)
 	case Suffix:
 		return ""
+	case pattern:
+		prefix, _ := x.bounds()
+		return prefix
 	case Top:
 		return ""
 	case Empty:
@@ -43,10 +32,15 @@ func BoundingPrefix(x Set) string {
 	case And:
 		lp := BoundingPrefix(x.L)
 		rp := BoundingPrefix(x.R)
-		if !(strings.HasPrefix(lp, rp) && strings.HasPrefix(rp, lp)) {
+		// every element has both prefixes, so the longer one bounds the intersection.
+		switch {
+		case strings.HasPrefix(lp, rp):
+			return lp
+		case strings.HasPrefix(rp, lp):
+			return rp
+		default:
 			panic("and statement was not completely simplified: " + x.String())
 		}
-		return longestCommonPrefix(lp, rp)
 	default:
 		panic(x)
 	}
@@ -98,6 +92,10 @@ func FromPathSet(q wantcfg.PathSet) Set {
 		return Prefix(*q.Prefix)
 	case q.Suffix != nil:
 		return Suffix(*q.Suffix)
+	case q.Glob != nil:
+		return Glob(*q.Glob)
+	case q.Regex != nil:
+		return Regex(*q.Regex)
 	case q.Union != nil:
 		xs := slices2.Map(q.Union, func(x wantcfg.PathSet) Set {
 			return FromPathSet(x)
@@ -123,6 +121,10 @@ func ToPathSet(x Set) wantcfg.PathSet {
 		return wantcfg.Prefix(string(x))
 	case Suffix:
 		return wantcfg.Suffix(string(x))
+	case Glob:
+		return wantcfg.Glob(string(x))
+	case Regex:
+		return wantcfg.Regex(string(x))
 
 	case Not:
 		return wantcfg.Not(ToPathSet(x.X))
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"path"
@@ -29,6 +30,7 @@ const (
 	WantFilename       = "WANT"
 	ExprPathSuffix     = ".want"
 	StmtPathSuffix     = ".wants"
+	TestFileSuffix     = "_test"
 	MaxJsonnetFileSize = 1 << 20
 )
 
@@ -46,8 +48,11 @@ func NewCompiler() *Compiler {
 
 type CompileTask struct {
 	Metadata Metadata
-	Module   glfs.Ref
-	Deps     map[ExprID]glfs.Ref
+	// Params are the build parameters which are set for this compilation.
+	// Parameters which are not set have the default from the module config.
+	Params Params
+	Module glfs.Ref
+	Deps   map[ExprID]glfs.Ref
 }
 
 func (c *Compiler) Compile(ctx context.Context, dst cadata.Store, src cadata.Getter, ct CompileTask) (*Plan, error) {
@@ -58,7 +63,7 @@ func (c *Compiler) Compile(ctx context.Context, dst cadata.Store, src cadata.Get
 	if !isMod {
 		return nil, fmt.Errorf("not a want module")
 	}
-	return c.compileModule(ctx, dst, src, ct.Metadata, ct.Module, ct.Deps)
+	return c.compileModule(ctx, dst, src, ct)
 }
 
 // compileCtx holds the state for a single run of the compiler
@@ -67,8 +72,11 @@ type compileCtx struct {
 	src      cadata.Getter
 	dst      cadata.Store
 	buildCtx Metadata
+	params   Params
 	ground   glfs.Ref
 	deps     map[ExprID]glfs.Ref
+	// strict is set for modules which require every import to be pinned.
+	strict bool
 
 	jsImporter   *jsImporter
 	vpMu         sync.Mutex
@@ -87,6 +95,7 @@ type compileCtx struct {
 	known    []glfs.TreeEntry
 	knownRef *glfs.Ref
 	targets  []Target
+	dagRef   *glfs.Ref
 }
 
 func (cs *compileCtx) claimPath(p string) bool {
@@ -146,32 +155,73 @@ func (cs *compileCtx) acquireVFS() (*VFS, func()) {
 	return cs.vfs, cs.vfsMu.Unlock
 }
 
-func (c *Compiler) compileModule(ctx context.Context, dst cadata.Store, src cadata.Getter, metadata Metadata, ground glfs.Ref, deps map[ModuleID]glfs.Ref) (*Plan, error) {
-	cfg, err := GetModuleConfig(ctx, src, ground)
+func (c *Compiler) compileModule(ctx context.Context, dst cadata.Store, src cadata.Getter, ct CompileTask) (*Plan, error) {
+	cs, _, err := c.newCompileCtx(ctx, dst, src, ct)
 	if err != nil {
 		return nil, err
 	}
+	for _, f := range []func(cc *compileCtx) error{
+		c.addSourceFiles,
+		c.checkStmts,
+		c.checkPinned,
+		c.detectCycles,
+		c.lowerSelections,
+		c.makeTargets,
+		c.makeKnown,
+	} {
+		if err := f(cs); err != nil {
+			return nil, err
+		}
+	}
+	return &Plan{
+		Known:   *cs.knownRef,
+		DAG:     *cs.dagRef,
+		Targets: cs.targets,
+	}, nil
+}
+
+// newCompileCtx checks the module's dependencies and build parameters, and prepares to compile the module in ct.
+func (c *Compiler) newCompileCtx(ctx context.Context, dst cadata.Store, src cadata.Getter, ct CompileTask) (*compileCtx, *wantcfg.ModuleConfig, error) {
+	ground, deps := ct.Module, ct.Deps
+	cfg, err := GetModuleConfig(ctx, src, ground)
+	if err != nil {
+		return nil, nil, err
+	}
+	params, err := ResolveParams(*cfg, ct.Params)
+	if err != nil {
+		return nil, nil, &Diagnostic{
+			Pos:     Position{Path: WantFilename},
+			Message: err.Error(),
+			err:     err,
+		}
+	}
 	for name, expr := range cfg.Namespace {
 		eid := NewExprID(expr)
 		if _, exists := deps[eid]; !exists {
-			return nil, ErrMissingDep{Name: name}
+			return nil, nil, &Diagnostic{
+				Pos:     Position{Path: WantFilename},
+				Message: ErrMissingDep{Name: name}.Error(),
+				err:     ErrMissingDep{Name: name},
+			}
 		}
 	}
 	jsCtx, err := newJsonnetCtx(ctx, src, ground, deps)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	modIdx := map[ModuleID]glfs.Ref{}
 	for modRef := range jsCtx.AllModules() {
 		modIdx[NewModuleID(modRef)] = modRef
 	}
-	cs := &compileCtx{
+	return &compileCtx{
 		ctx:      ctx,
 		src:      src,
 		dst:      dst,
-		buildCtx: metadata,
+		buildCtx: ct.Metadata,
+		params:   params,
 		ground:   ground,
 		deps:     deps,
+		strict:   cfg.Strict,
 
 		vfs:          &VFS{},
 		visitedPaths: make(map[string]chan struct{}),
@@ -181,29 +231,13 @@ func (c *Compiler) compileModule(ctx context.Context, dst cadata.Store, src cada
 				return nil, fmt.Errorf("cannot find module %v to import path %v", fqp.Module, fqp.Path)
 			}
 			ref := &modRef
-			ref, err = c.glfs.GetAtPath(ctx, stores.Union{dst, src}, *ref, fqp.Path)
+			ref, err := c.glfs.GetAtPath(ctx, stores.Union{dst, src}, *ref, fqp.Path)
 			if err != nil {
 				return nil, err
 			}
 			return c.glfs.GetBlobBytes(ctx, stores.Union{dst, src}, *ref, MaxJsonnetFileSize)
 		}),
-	}
-	for _, f := range []func(cc *compileCtx) error{
-		c.addSourceFiles,
-		c.checkStmts,
-		c.detectCycles,
-		c.lowerSelections,
-		c.makeTargets,
-		c.makeKnown,
-	} {
-		if err := f(cs); err != nil {
-			return nil, err
-		}
-	}
-	return &Plan{
-		Known:   *cs.knownRef,
-		Targets: cs.targets,
-	}, nil
+	}, cfg, nil
 }
 
 func (c *Compiler) addSourceFiles(cc *compileCtx) error {
@@ -263,11 +297,19 @@ func (c *Compiler) addSourceFile(cc *compileCtx, eg *errgroup.Group, p string, m
 		}
 		vfs, rel := cc.acquireVFS()
 		defer rel()
-		return vfs.Add(VFSEntry{
+		err := vfs.Add(VFSEntry{
 			K:       ks,
 			V:       &value{ref: ref},
 			PlaceAt: p,
 		})
+		var conflict ErrConflict
+		if errors.As(err, &conflict) {
+			return cc.diagnose(p, -1, fmt.Errorf("source file conflicts with %v", conflict.Existing.K), Related{
+				Pos:     cc.entryPos(conflict.Existing),
+				Message: fmt.Sprintf("%v is output here", conflict.Existing.K),
+			})
+		}
+		return err
 	}
 	return nil
 }
@@ -304,14 +346,20 @@ func (c *Compiler) loadStmtFile(cc *compileCtx, p string) error {
 		ks := stmt.Affects()
 		allowed := stringsets.Prefix(strings.TrimLeft(parentPath(p)+"/", "/"))
 		if !stringsets.Subset(ks, allowed) {
-			return fmt.Errorf("statement files can only affect the immediate parent tree and below, but %s affects %v", p, ks)
+			return cc.diagnose(p, i, fmt.Errorf("statement files can only affect the immediate parent tree and below, but %s affects %v", p, ks))
 		}
 		vfs, rel := cc.acquireVFS()
-		if err := vfs.Add(VFSEntry{K: ks, V: stmt.expr(), DefinedIn: p}); err != nil {
-			rel()
-			return fmt.Errorf("statement %d in file %q, outputs to conflicted keyspace %w", i, p, err)
-		}
+		err := vfs.Add(VFSEntry{K: ks, V: stmt.expr(), DefinedIn: p, DefinedNum: i})
 		rel()
+		var conflict ErrConflict
+		if errors.As(err, &conflict) {
+			return cc.diagnose(p, i, fmt.Errorf("statement outputs to %v, which conflicts with %v", ks, conflict.Existing.K), Related{
+				Pos:     cc.entryPos(conflict.Existing),
+				Message: fmt.Sprintf("%v is output here", conflict.Existing.K),
+			})
+		} else if err != nil {
+			return err
+		}
 	}
 	cc.appendStmtSet(ss)
 	return nil
@@ -327,11 +375,14 @@ func (c *Compiler) checkStmts(cc *compileCtx) error {
 				}
 				subModSet := stringsets.Prefix(sm.p)
 				if stringsets.Intersects(subModSet, stmt.Dst) {
-					return ErrSubmoduleConflict{
+					return cc.diagnose(ss.path, i, ErrSubmoduleConflict{
 						DefinedIn:  ss.path,
 						DefinedNum: i,
 						Submodule:  sm.p,
-					}
+					}, Related{
+						Pos:     Position{Path: path.Join(sm.p, WantFilename)},
+						Message: "submodule is defined here",
+					})
 				}
 			}
 		}
@@ -425,14 +476,19 @@ func (c *Compiler) detectCycles(cc *compileCtx) error {
 	defer logStep(cc.ctx, "detecting cycles")()
 	visited := make(map[string]struct{})
 	current := make(map[string]struct{})
-	var path []string
+	// path is the selections being searched.
+	// defs are the entries which made each selection, starting with the root.
+	var path []stringsets.Set
+	var defs []VFSEntry
+	var cycleErr error
 	// dfs returns false to abort search early
 	var dfs func(stringsets.Set) bool
 	var traverseExpr func(Expr) bool
 	traverseExpr = func(x Expr) bool {
 		switch x := x.(type) {
 		case *selection:
-			if !dfs(x.set) {
+			// only selections from the build output can form a cycle
+			if x.derived && !dfs(x.set) {
 				return false
 			}
 		case *compute:
@@ -452,14 +508,18 @@ func (c *Compiler) detectCycles(cc *compileCtx) error {
 
 		// cycle detection
 		if _, exists := current[x.String()]; exists {
+			cycleErr = cc.cycleDiagnostic(append(slices.Clone(path), x), defs)
 			return false
 		}
 		current[x.String()] = struct{}{}
-		path = append(path, x.String())
+		path = append(path, x)
 		defer delete(current, x.String())
 		defer func() { path = path[:len(path)-1] }()
 		for _, ent := range cc.vfs.Get(x) {
-			if !traverseExpr(ent.V) {
+			defs = append(defs, ent)
+			ok := traverseExpr(ent.V)
+			defs = defs[:len(defs)-1]
+			if !ok {
 				return false
 			}
 		}
@@ -467,47 +527,44 @@ func (c *Compiler) detectCycles(cc *compileCtx) error {
 		visited[x.String()] = struct{}{}
 		return true
 	}
-	check := func() error {
-		if len(path) != 0 {
-			return ErrCycle{Cycle: path}
-		}
-		return nil
-	}
 	for _, er := range cc.exprRoots {
+		defs = []VFSEntry{{DefinedIn: er.path}}
 		if !traverseExpr(er.expr) {
-			if err := check(); err != nil {
-				return err
-			}
+			return cycleErr
 		}
 	}
 	for _, ss := range cc.stmtSets {
-		for _, stmt := range ss.stmts {
+		for i, stmt := range ss.stmts {
+			defs = []VFSEntry{{DefinedIn: ss.path, DefinedNum: i}}
 			if !traverseExpr(stmt.expr()) {
-				if err := check(); err != nil {
-					return err
-				}
+				return cycleErr
 			}
 		}
 	}
-	return check()
+	return nil
 }
 
+// makeTargets compiles every expression and statement into a single DAG, and creates a target for each.
+// Identical nodes are only in the DAG once, so work shared between targets is only done once.
+// Each target also has its own DAG, which only contains the nodes it needs.
 func (c *Compiler) makeTargets(cc *compileCtx) error {
 	defer logStep(cc.ctx, "making graph")()
 	var targets []Target
+	gb := NewGraphBuilder(cc.dst)
 
 	// ExprRoots
 	for _, er := range cc.exprRoots {
-		dag, err := c.makeGraphFromExpr(cc.ctx, cc.dst, cc.src, er.expr, er.path)
+		nid, err := c.addToGraph(cc.ctx, gb, cc.src, er.expr, er.path)
 		if err != nil {
 			return err
 		}
 		targets = append(targets, Target{
 			To:   stringsets.ToPathSet(er.Affects()),
-			DAG:  *dag,
+			Node: nid,
 			Expr: er.spec,
 
 			DefinedIn: er.path,
+			IsTest:    IsTestFilePath(er.path),
 		})
 	}
 
@@ -519,22 +576,38 @@ func (c *Compiler) makeTargets(cc *compileCtx) error {
 			if err != nil {
 				return err
 			}
-			dag, err := c.makeGraphFromExpr(cc.ctx, cc.dst, cc.src, expr, "")
+			nid, err := c.addToGraph(cc.ctx, gb, cc.src, expr, "")
 			if err != nil {
 				return err
 			}
 			targets = append(targets, Target{
 				To:   to,
-				DAG:  *dag,
+				Node: nid,
 				Expr: ss.specs[i].Expr(),
 
 				IsStatement: true,
 				DefinedIn:   ss.path,
 				DefinedNum:  i,
+				IsTest:      IsTestFilePath(ss.path) || ss.specs[i].IsTest(),
 			})
 		}
 	}
 
+	dag, ids := wantdag.Dedup(gb.Finish())
+	for i := range targets {
+		targets[i].Node = ids[targets[i].Node]
+		sub, _ := wantdag.Prune(dag, []NodeID{targets[i].Node})
+		ref, err := wantdag.PostDAG(cc.ctx, cc.dst, sub)
+		if err != nil {
+			return err
+		}
+		targets[i].DAG = *ref
+	}
+	dagRef, err := wantdag.PostDAG(cc.ctx, cc.dst, dag)
+	if err != nil {
+		return err
+	}
+
 	slices.SortFunc(targets, func(a, b Target) int {
 		if a.DefinedIn != b.DefinedIn {
 			return strings.Compare(a.DefinedIn, b.DefinedIn)
@@ -542,19 +615,18 @@ func (c *Compiler) makeTargets(cc *compileCtx) error {
 		return a.DefinedNum - b.DefinedNum
 	})
 	cc.targets = targets
+	cc.dagRef = dagRef
 	return nil
 }
 
-func (c *Compiler) makeGraphFromExpr(ctx context.Context, dst cadata.Store, src cadata.Getter, x Expr, place string) (*glfs.Ref, error) {
-	gb := NewGraphBuilder(dst)
+// addToGraph adds the nodes to compute x to gb, and places the result at place.
+// It returns the node for the placed result.
+func (c *Compiler) addToGraph(ctx context.Context, gb *GraphBuilder, src cadata.Getter, x Expr, place string) (NodeID, error) {
 	nid, err := gb.Expr(ctx, src, x)
 	if err != nil {
-		return nil, err
-	}
-	if _, err := gb.place(ctx, place, nid); err != nil {
-		return nil, err
+		return 0, err
 	}
-	return wantdag.PostDAG(ctx, dst, gb.Finish())
+	return gb.place(ctx, place, nid)
 }
 
 func (c *Compiler) makeKnown(cc *compileCtx) error {
@@ -633,6 +705,17 @@ func IsStmtFilePath(p string) bool {
 	return strings.HasSuffix(p, StmtPathSuffix)
 }
 
+// IsTestFilePath returns true if p is an expression or statement file which only defines tests.
+// Test files end in _test before the extension, e.g. foo_test.want
+func IsTestFilePath(p string) bool {
+	for _, suffix := range []string{ExprPathSuffix, ExprPathSuffix + ".jsonnet", StmtPathSuffix} {
+		if base, ok := strings.CutSuffix(p, suffix); ok {
+			return strings.HasSuffix(base, TestFileSuffix)
+		}
+	}
+	return false
+}
+
 func parentPath(p string) string {
 	p = glfs.CleanPath(p)
 	parts := strings.Split(p, "/")
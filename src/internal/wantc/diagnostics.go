@@ -0,0 +1,348 @@
+package wantc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"blobcache.io/glfs"
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+
+	"wantbuild.io/want/src/internal/stringsets"
+)
+
+// Position is a location in a source file.
+type Position struct {
+	// Module is set if the file is not in the module being compiled.
+	Module string `json:"module,omitempty"`
+	// Path is the path of the file within the module.
+	Path string `json:"path"`
+	// Line and Column start at 1, and are 0 if they are unknown.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+}
+
+func (p Position) String() string {
+	s := p.Path
+	if p.Module != "" {
+		s = p.Module + ":" + s
+	}
+	if p.Line > 0 {
+		s += fmt.Sprintf(":%d", p.Line)
+		if p.Column > 0 {
+			s += fmt.Sprintf(":%d", p.Column)
+		}
+	}
+	return s
+}
+
+// Related is another location which is relevant to a Diagnostic.
+type Related struct {
+	Pos     Position `json:"pos"`
+	Message string   `json:"message"`
+}
+
+// Diagnostic is a compiler error, with the location in the source which caused it.
+type Diagnostic struct {
+	Pos Position `json:"pos"`
+	// StmtNum is the index of the statement which caused the error, if it was caused by a statement.
+	StmtNum *int      `json:"stmt_num,omitempty"`
+	Message string    `json:"message"`
+	Related []Related `json:"related,omitempty"`
+
+	// err is the underlying error.  It is lost when the Diagnostic is serialized.
+	err error
+}
+
+func (d *Diagnostic) Error() string {
+	var sb strings.Builder
+	sb.WriteString(d.Pos.String())
+	if d.StmtNum != nil {
+		fmt.Fprintf(&sb, " (statement %d)", *d.StmtNum)
+	}
+	sb.WriteString(": ")
+	sb.WriteString(d.Message)
+	for _, r := range d.Related {
+		fmt.Fprintf(&sb, "\n\t%v: %s", r.Pos, r.Message)
+	}
+	return sb.String()
+}
+
+func (d *Diagnostic) Unwrap() error {
+	return d.err
+}
+
+func MarshalDiagnostic(d Diagnostic) []byte {
+	data, err := json.Marshal(d)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func ParseDiagnostic(data []byte) (*Diagnostic, error) {
+	var d Diagnostic
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	if d.Message == "" || d.Pos.Path == "" {
+		return nil, errors.New("data is not a diagnostic")
+	}
+	return &d, nil
+}
+
+// diagnose creates a Diagnostic for err, which was caused by the file at p.
+// If stmtNum is >= 0, then the error was caused by that statement in a statement file.
+func (cc *compileCtx) diagnose(p string, stmtNum int, err error, related ...Related) *Diagnostic {
+	var diag *Diagnostic
+	if errors.As(err, &diag) {
+		return diag
+	}
+	d := &Diagnostic{
+		Pos:     cc.sourcePos(p, stmtNum),
+		Message: err.Error(),
+		Related: related,
+		err:     err,
+	}
+	if stmtNum >= 0 {
+		d.StmtNum = &stmtNum
+	}
+	return d
+}
+
+// entryPos returns the position of the source which defined a VFSEntry.
+func (cc *compileCtx) entryPos(ent VFSEntry) Position {
+	switch {
+	case ent.DefinedIn == "":
+		return Position{Path: ent.PlaceAt}
+	case IsStmtFilePath(ent.DefinedIn):
+		return cc.sourcePos(ent.DefinedIn, ent.DefinedNum)
+	default:
+		return cc.sourcePos(ent.DefinedIn, -1)
+	}
+}
+
+// sourcePos returns the position of the expression in the file at p.
+// If stmtNum >= 0, then it returns the position of that statement.
+// The file is only parsed, and not evaluated, so it may not be possible to find the statement.
+// In that case the position of the whole file is returned.
+func (cc *compileCtx) sourcePos(p string, stmtNum int) Position {
+	pos := Position{Path: p}
+	if !IsExprFilePath(p) && !IsStmtFilePath(p) {
+		return pos
+	}
+	ref, err := glfs.GetAtPath(cc.ctx, cc.src, cc.ground, p)
+	if err != nil {
+		return pos
+	}
+	data, err := glfs.GetBlobBytes(cc.ctx, cc.src, *ref, MaxJsonnetFileSize)
+	if err != nil {
+		return pos
+	}
+	// the prefix defines GROUND and DERIVED, without it the file would not pass static analysis.
+	fqp := FQPath{Module: cc.ground.CID, Path: p}
+	prefix := LocalPrefix(fqp)
+	node, err := jsonnet.SnippetToAST(p, prefix+string(data))
+	if err != nil {
+		return pos
+	}
+	node = bodyNode(node)
+	if stmtNum >= 0 {
+		elems, ok := arrayElems(node)
+		if !ok || stmtNum >= len(elems) {
+			return pos
+		}
+		node = elems[stmtNum]
+	}
+	if loc := node.Loc(); loc != nil && loc.IsSet() {
+		pos.Line, pos.Column = loc.Begin.Line, loc.Begin.Column
+		if pos.Line == 1 {
+			pos.Column -= len(prefix)
+		}
+	}
+	return pos
+}
+
+// cycleDiagnostic creates a Diagnostic for a cycle found while searching path.
+// The last element of path is the selection which was already being searched.
+// defs[0] is the root which selected path[0], and defs[i] outputs to path[i-1] and selects path[i].
+func (cc *compileCtx) cycleDiagnostic(path []stringsets.Set, defs []VFSEntry) *Diagnostic {
+	last := path[len(path)-1]
+	k := slices.IndexFunc(path, func(x stringsets.Set) bool {
+		return x.String() == last.String()
+	})
+	// cycle[i] is output by ents[i], which selects cycle[i+1]
+	cycle, ents := path[k:], defs[k+1:]
+	var names []string
+	for _, x := range cycle {
+		names = append(names, x.String())
+	}
+	var related []Related
+	for i := 1; i < len(ents); i++ {
+		related = append(related, Related{
+			Pos:     cc.entryPos(ents[i]),
+			Message: fmt.Sprintf("outputs to %v and selects %v", cycle[i], cycle[i+1]),
+		})
+	}
+	d := &Diagnostic{
+		Pos:     cc.entryPos(ents[0]),
+		Message: fmt.Sprintf("dependency cycle: %s", strings.Join(names, " -> ")),
+		Related: related,
+		err:     ErrCycle{Cycle: names},
+	}
+	if IsStmtFilePath(ents[0].DefinedIn) {
+		d.StmtNum = &ents[0].DefinedNum
+	}
+	return d
+}
+
+// jsonnetPos converts a location from a Jsonnet error into a Position.
+func (cc *compileCtx) jsonnetPos(loc ast.LocationRange) (Position, bool) {
+	fqp, err := parseJsonnetPath(loc.FileName)
+	if err != nil || fqp == nil {
+		return Position{}, false
+	}
+	pos := Position{Path: fqp.Path, Line: loc.Begin.Line, Column: loc.Begin.Column}
+	if pos.Line == 1 && HasLocalPrefix(fqp.Path) {
+		// GROUND and DERIVED are prepended to the first line of the file
+		pos.Column -= len(LocalPrefix(*fqp))
+	}
+	if fqp.Module != cc.ground.CID {
+		pos.Module = fqp.Module.String()
+		for _, sm := range cc.subMods {
+			if NewModuleID(sm.root) == fqp.Module {
+				pos.Module = ""
+				pos.Path = path.Join(sm.p, fqp.Path)
+				break
+			}
+		}
+	}
+	return pos, true
+}
+
+// jsonnetDiagnostic creates a Diagnostic from an error evaluating the file at p.
+// The primary position is the innermost location in the module being compiled.
+// The rest of the stack trace is included as related locations.
+func (cc *compileCtx) jsonnetDiagnostic(p string, ef *diagFormatter, err error) *Diagnostic {
+	if ef.msg == "" {
+		return cc.diagnose(p, -1, err)
+	}
+	var primary = -1
+	var related []Related
+	for _, frame := range ef.frames {
+		pos, ok := cc.jsonnetPos(frame.loc)
+		if !ok {
+			continue
+		}
+		if primary < 0 && pos.Module == "" {
+			primary = len(related)
+		}
+		msg := "called from here"
+		if frame.name != "" {
+			msg = "in " + frame.name
+		}
+		related = append(related, Related{Pos: pos, Message: msg})
+	}
+	d := &Diagnostic{
+		Message: ef.msg,
+		err:     err,
+	}
+	switch {
+	case primary >= 0:
+		d.Pos = related[primary].Pos
+		d.Related = slices.Delete(related, primary, primary+1)
+	case len(related) > 0:
+		d.Pos, d.Related = related[0].Pos, related[1:]
+	default:
+		d.Pos = Position{Path: p}
+	}
+	if len(d.Related) == 0 {
+		d.Related = nil
+	}
+	return d
+}
+
+// diagFormatter is a jsonnet.ErrorFormatter which keeps the message and locations of the error it formatted.
+// The VM only returns the formatted string.
+type diagFormatter struct {
+	jsonnet.ErrorFormatter
+
+	msg    string
+	frames []traceFrame
+}
+
+type traceFrame struct {
+	name string
+	loc  ast.LocationRange
+}
+
+func (ef *diagFormatter) Format(err error) string {
+	switch x := err.(type) {
+	case jsonnet.RuntimeError:
+		ef.msg = x.Msg
+		// the stack trace starts with the outermost frame
+		for _, frame := range slices.Backward(x.StackTrace) {
+			ef.frames = append(ef.frames, traceFrame{name: frame.Name, loc: frame.Loc})
+		}
+	case interface{ Loc() ast.LocationRange }:
+		// static errors prefix their message with the location
+		loc := x.Loc()
+		ef.msg = strings.TrimPrefix(err.Error(), loc.String()+" ")
+		ef.frames = append(ef.frames, traceFrame{loc: loc})
+	}
+	return ef.ErrorFormatter.Format(err)
+}
+
+// bodyNode returns the node which n evaluates to, skipping over locals.
+func bodyNode(n ast.Node) ast.Node {
+	for {
+		switch x := n.(type) {
+		case *ast.Local:
+			n = x.Body
+		case *ast.Parens:
+			n = x.Inner
+		default:
+			return n
+		}
+	}
+}
+
+// arrayElems returns the elements of an array literal, or a concatenation of array literals.
+func arrayElems(n ast.Node) ([]ast.Node, bool) {
+	switch x := bodyNode(n).(type) {
+	case *ast.Array:
+		var ret []ast.Node
+		for _, elem := range x.Elements {
+			ret = append(ret, elem.Expr)
+		}
+		return ret, true
+	case *ast.Binary:
+		if x.Op != ast.BopPlus {
+			return nil, false
+		}
+		left, ok := arrayElems(x.Left)
+		if !ok {
+			return nil, false
+		}
+		right, ok := arrayElems(x.Right)
+		if !ok {
+			return nil, false
+		}
+		return append(left, right...), true
+	default:
+		return nil, false
+	}
+}
+
+// HasLocalPrefix returns true if the file at p has the LocalPrefix prepended when it is imported.
+func HasLocalPrefix(p string) bool {
+	switch path.Ext(p) {
+	case ".libsonnet", ".want", ".wants":
+		return true
+	}
+	return false
+}
@@ -16,6 +16,8 @@ func (e ErrCycle) Error() string {
 
 type ErrConflict struct {
 	Overlapping []stringsets.Set
+	// Existing is the entry which was already occupying the space.
+	Existing VFSEntry
 }
 
 func (e ErrConflict) Error() string {
@@ -213,6 +213,9 @@ func (c *Compiler) compileSelection(cc *compileCtx, exprPath string, x wantcfg.S
 		return nil, fmt.Errorf("selection in file %q has empty callerPath", exprPath)
 	}
 	callerPath = glfs.CleanPath(callerPath)
+	if err := CheckPathSet(x.Query); err != nil {
+		return nil, err
+	}
 
 	ks := SetFromQuery(callerPath, x.Query)
 	var sel *selection
@@ -22,19 +22,18 @@ type exprRoot struct {
 // newExpr creates an *ExprRoot from a spec.
 // If the spec specifies any literals they will be posted to the store.
 func (c *Compiler) parseExprRoot(cc *compileCtx, fqp FQPath) (*exprRoot, error) {
-	vm := newJsonnetVM(cc.jsImporter, cc.buildCtx)
 	fqp.Path = strings.Trim(fqp.Path, "/")
-	jsonStr, err := vm.EvaluateFile(mkJsonnetPath(fqp))
+	jsonStr, err := cc.evalFile(fqp)
 	if err != nil {
 		return nil, err
 	}
 	var spec wantcfg.Expr
 	if err := json.Unmarshal([]byte(jsonStr), &spec); err != nil {
-		return nil, fmt.Errorf("error in stage file %q: %w", fqp.Path, err)
+		return nil, cc.diagnose(fqp.Path, -1, fmt.Errorf("expression file does not contain an expression: %w", err))
 	}
 	e, err := c.compileExpr(cc, fqp.Path, spec)
 	if err != nil {
-		return nil, err
+		return nil, cc.diagnose(fqp.Path, -1, err)
 	}
 	return &exprRoot{
 		spec: spec,
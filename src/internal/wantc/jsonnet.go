@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"iter"
-	"path"
 	"slices"
 	"strings"
 	"sync"
@@ -19,11 +18,14 @@ var _ jsonnet.Importer = &jsImporter{}
 
 // jsImporter is a jsonnet.Importer which imports from a build's VFS
 type jsImporter struct {
+	root ModuleID
 	ctxs map[ModuleID]*jsonnetCtx
 	load func(FQPath) ([]byte, error)
 
 	mu    sync.RWMutex
 	cache map[FQPath]jsonnet.Contents
+	// used are the namespace entries of the root module which have been imported
+	used map[string]struct{}
 }
 
 func newImporter(jc *jsonnetCtx, load func(fqp FQPath) ([]byte, error)) *jsImporter {
@@ -43,10 +45,12 @@ func newImporter(jc *jsonnetCtx, load func(fqp FQPath) ([]byte, error)) *jsImpor
 	}
 	index(jc)
 	return &jsImporter{
+		root: jc.ModuleID(),
 		ctxs: ctxs,
 		load: load,
 
 		cache: make(map[FQPath]jsonnet.Contents),
+		used:  make(map[string]struct{}),
 	}
 }
 
@@ -68,6 +72,12 @@ func (imp *jsImporter) Import(importedFrom, importedPath string) (contents jsonn
 		fromFQP = *fromFQP_
 		targetFQP = FQPath{Module: fromFQP.Module, Path: importedPath}
 	}
+	if after, ok := strings.CutPrefix(targetFQP.Path, "@"); ok && targetFQP.Module == imp.root {
+		name, _, _ := strings.Cut(after, "/")
+		imp.mu.Lock()
+		imp.used[name] = struct{}{}
+		imp.mu.Unlock()
+	}
 	// resolve the import
 	jc := imp.ctxs[targetFQP.Module]
 	targetFQP, err = jc.Resolve(fromFQP.Path, targetFQP.Path)
@@ -93,13 +103,8 @@ func (imp *jsImporter) Import(importedFrom, importedPath string) (contents jsonn
 	if c, exists := imp.cache[targetFQP]; exists {
 		return c, mkJsonnetPath(targetFQP), nil
 	} else {
-		switch path.Ext(importedPath) {
-		case ".libsonnet", ".want", ".wants":
-			data = slices.Concat(
-				[]byte(LocalGround(targetFQP)),
-				[]byte(LocalDerived(targetFQP)),
-				data,
-			)
+		if HasLocalPrefix(importedPath) {
+			data = slices.Concat([]byte(LocalPrefix(targetFQP)), data)
 		}
 		c := jsonnet.MakeContentsRaw(data)
 		imp.cache[targetFQP] = c
@@ -107,17 +112,40 @@ func (imp *jsImporter) Import(importedFrom, importedPath string) (contents jsonn
 	}
 }
 
-func newJsonnetVM(imp jsonnet.Importer, md map[string]any) *jsonnet.VM {
+// isUsed returns true if the namespace entry name in the root module has been imported.
+func (imp *jsImporter) isUsed(name string) bool {
+	imp.mu.RLock()
+	defer imp.mu.RUnlock()
+	_, exists := imp.used[name]
+	return exists
+}
+
+func newJsonnetVM(imp jsonnet.Importer, md map[string]any, params Params) *jsonnet.VM {
 	vm := jsonnet.MakeVM()
 	vm.Importer(imp)
-	data, err := json.Marshal(md)
-	if err != nil {
-		panic(err)
+	for name, x := range map[string]any{"metadata": md, "params": params} {
+		data, err := json.Marshal(x)
+		if err != nil {
+			panic(err)
+		}
+		vm.ExtCode(name, string(data))
 	}
-	vm.ExtCode("metadata", string(data))
 	return vm
 }
 
+// evalFile evaluates the Jsonnet file at fqp.
+// Errors are returned as a *Diagnostic.
+func (cc *compileCtx) evalFile(fqp FQPath) (string, error) {
+	vm := newJsonnetVM(cc.jsImporter, cc.buildCtx, cc.params)
+	ef := &diagFormatter{ErrorFormatter: vm.ErrorFormatter}
+	vm.ErrorFormatter = ef
+	jsonStr, err := vm.EvaluateFile(mkJsonnetPath(fqp))
+	if err != nil {
+		return "", cc.jsonnetDiagnostic(fqp.Path, ef, err)
+	}
+	return jsonStr, nil
+}
+
 func LocalGround(fqp FQPath) string {
 	return fmt.Sprintf(`local GROUND = {"__type__":"source","module":"%v","derived":false,"callerPath":"%s"};`, fqp.Module.String(), fqp.Path)
 }
@@ -126,6 +154,11 @@ func LocalDerived(fqp FQPath) string {
 	return fmt.Sprintf(`local DERIVED = {"__type__":"source","module":"%v","derived":true,"callerPath":"%s"};`, fqp.Module.String(), fqp.Path)
 }
 
+// LocalPrefix is prepended to the first line of Jsonnet files, to define GROUND and DERIVED.
+func LocalPrefix(fqp FQPath) string {
+	return LocalGround(fqp) + LocalDerived(fqp)
+}
+
 func mkJsonnetPath(fqp FQPath) string {
 	return fqp.Module.String() + ":" + fqp.Path
 }
@@ -0,0 +1,273 @@
+package wantc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"blobcache.io/glfs"
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/internal/stringsets"
+	"wantbuild.io/want/src/wantcfg"
+)
+
+// Lint loads the module in ct, and reports problems which do not stop it from compiling,
+// but are probably mistakes.
+// If the module does not compile, then the compiler's diagnostic is the only one returned.
+func (c *Compiler) Lint(ctx context.Context, dst cadata.Store, src cadata.Getter, ct CompileTask) ([]*Diagnostic, error) {
+	diags, err := c.lint(ctx, dst, src, ct)
+	var diag *Diagnostic
+	if errors.As(err, &diag) {
+		return []*Diagnostic{diag}, nil
+	}
+	return diags, err
+}
+
+func (c *Compiler) lint(ctx context.Context, dst cadata.Store, src cadata.Getter, ct CompileTask) ([]*Diagnostic, error) {
+	cc, cfg, err := c.newCompileCtx(ctx, dst, src, ct)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range []func(cc *compileCtx) error{
+		c.addSourceFiles,
+		c.checkStmts,
+		c.detectCycles,
+	} {
+		if err := f(cc); err != nil {
+			return nil, err
+		}
+	}
+	groundPaths, err := c.groundPaths(cc)
+	if err != nil {
+		return nil, err
+	}
+	l := &linter{cc: cc, groundPaths: groundPaths}
+	l.checkNamespace(cfg)
+	ignores := unionMembers(cfg.Ignore)
+	for _, er := range cc.exprRoots {
+		l.checkEmpty(er.path, -1, "expression", er.spec)
+		l.checkExpr(er.path, -1, er.spec)
+	}
+	for _, ss := range cc.stmtSets {
+		for i, stmt := range ss.stmts {
+			ks := stmt.Affects()
+			for _, ignore := range ignores {
+				if stringsets.Intersects(ks, SetFromQuery("", ignore)) {
+					l.report(ss.path, i, fmt.Sprintf("statement outputs to %v, which overlaps the ignored paths %v", ks, ignore))
+				}
+			}
+			l.checkEmpty(ss.path, i, "statement source", ss.specs[i].Expr())
+			l.checkExpr(ss.path, i, ss.specs[i].Expr())
+		}
+	}
+	slices.SortStableFunc(l.diags, func(a, b *Diagnostic) int {
+		if a.Pos.Path != b.Pos.Path {
+			return strings.Compare(a.Pos.Path, b.Pos.Path)
+		}
+		return a.Pos.Line - b.Pos.Line
+	})
+	return l.diags, nil
+}
+
+// unionMembers returns the sets which make up x, so they can be reported individually.
+func unionMembers(x wantcfg.PathSet) []wantcfg.PathSet {
+	if x.Union == nil {
+		return []wantcfg.PathSet{x}
+	}
+	var ret []wantcfg.PathSet
+	for _, y := range x.Union {
+		ret = append(ret, unionMembers(y)...)
+	}
+	return ret
+}
+
+// groundPaths returns the path of every file and directory in the module.
+func (c *Compiler) groundPaths(cc *compileCtx) ([]string, error) {
+	var ret []string
+	if err := glfs.WalkTree(cc.ctx, cc.src, cc.ground, func(prefix string, ent glfs.TreeEntry) error {
+		ret = append(ret, path.Join(prefix, ent.Name))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+type linter struct {
+	cc          *compileCtx
+	groundPaths []string
+
+	diags []*Diagnostic
+	seen  map[string]struct{}
+}
+
+// report adds a diagnostic for the file at p, or a statement in it if stmtNum >= 0.
+// The same message is only reported once for each file or statement.
+func (l *linter) report(p string, stmtNum int, msg string) {
+	k := fmt.Sprintf("%s\x00%d\x00%s", p, stmtNum, msg)
+	if l.seen == nil {
+		l.seen = map[string]struct{}{}
+	}
+	if _, exists := l.seen[k]; exists {
+		return
+	}
+	l.seen[k] = struct{}{}
+	l.diags = append(l.diags, l.cc.diagnose(p, stmtNum, errors.New(msg)))
+}
+
+// checkNamespace reports namespace entries which are never imported.
+func (l *linter) checkNamespace(cfg *wantcfg.ModuleConfig) {
+	var names []string
+	for name := range cfg.Namespace {
+		if !l.cc.jsImporter.isUsed(name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		l.diags = append(l.diags, &Diagnostic{
+			Pos:     l.cc.namespacePos(name),
+			Message: fmt.Sprintf("namespace entry %q is never imported", name),
+		})
+	}
+}
+
+// checkEmpty reports x, which is described by what, if it is known to evaluate to an empty tree.
+// Otherwise merges, places and filters within x which evaluate to an empty tree are reported.
+func (l *linter) checkEmpty(p string, stmtNum int, what string, x wantcfg.Expr) {
+	if isEmptyTree(x) {
+		l.report(p, stmtNum, what+" evaluates to an empty tree")
+		return
+	}
+	l.checkNestedEmpty(p, stmtNum, x)
+}
+
+// checkNestedEmpty reports the computes within x which are known to evaluate to an empty tree.
+// Empty tree literals are often passed to computes on purpose, so they are not reported.
+func (l *linter) checkNestedEmpty(p string, stmtNum int, x wantcfg.Expr) {
+	var children []wantcfg.Expr
+	switch {
+	case x.Tree != nil:
+		for _, ent := range x.Tree {
+			children = append(children, ent.Value)
+		}
+	case x.Compute != nil:
+		for _, in := range x.Compute.Inputs {
+			children = append(children, in.From)
+		}
+	}
+	for _, child := range children {
+		if child.Compute != nil && isEmptyTree(child) {
+			l.report(p, stmtNum, child.Compute.Op+" evaluates to an empty tree")
+			continue
+		}
+		l.checkNestedEmpty(p, stmtNum, child)
+	}
+}
+
+// isEmptyTree returns true if x is known to evaluate to an empty tree before the build.
+// Only literal trees, and merges, places and filters of them are known.
+func isEmptyTree(x wantcfg.Expr) bool {
+	switch {
+	case x.Tree != nil:
+		return len(x.Tree) == 0
+	case x.Compute != nil:
+		switch x.Compute.Op {
+		case "glfs.merge":
+			for _, in := range x.Compute.Inputs {
+				if !isEmptyTree(in.From) {
+					return false
+				}
+			}
+			return true
+		case "glfs.place", "glfs.filterPathSet":
+			for _, in := range x.Compute.Inputs {
+				if in.To == "x" {
+					return isEmptyTree(in.From)
+				}
+			}
+		}
+	}
+	return false
+}
+
+// checkExpr reports problems with x, which is defined in the file at p.
+func (l *linter) checkExpr(p string, stmtNum int, x wantcfg.Expr) {
+	switch {
+	case x.Tree != nil:
+		for _, ent := range x.Tree {
+			l.checkExpr(p, stmtNum, ent.Value)
+		}
+	case x.Compute != nil:
+		if x.Compute.Op == "import.fromURL" {
+			l.checkImportURL(p, stmtNum, *x.Compute)
+		}
+		for _, in := range x.Compute.Inputs {
+			l.checkExpr(p, stmtNum, in.From)
+		}
+	case x.Selection != nil:
+		l.checkSelection(p, stmtNum, *x.Selection)
+	}
+}
+
+// checkSelection reports selections from GROUND which do not match any paths in the module.
+func (l *linter) checkSelection(p string, stmtNum int, x wantcfg.Selection) {
+	if x.Source.Derived || x.Source.Module != l.cc.ground.CID.String() {
+		return
+	}
+	ks := stringsets.Simplify(SetFromQuery(glfs.CleanPath(x.Source.CallerPath), x.Query))
+	if slices.ContainsFunc(l.groundPaths, ks.Contains) {
+		return
+	}
+	l.report(p, stmtNum, fmt.Sprintf("selection from GROUND %v does not match any source files", ks))
+}
+
+// checkImportURL reports URL imports which do not have a hash.
+// Their contents are not checked, so they are not reproducible.
+func (l *linter) checkImportURL(p string, stmtNum int, x wantcfg.Compute) {
+	for _, in := range x.Inputs {
+		if in.To != "" || in.From.Blob == nil {
+			continue
+		}
+		var spec struct {
+			URL  string `json:"url"`
+			Hash string `json:"hash"`
+		}
+		if err := json.Unmarshal([]byte(*in.From.Blob), &spec); err != nil {
+			return
+		}
+		if spec.Hash == "" {
+			l.report(p, stmtNum, fmt.Sprintf("importURL of %s has an empty hash, so its contents are not verified", spec.URL))
+		}
+	}
+}
+
+// namespacePos returns the position of the namespace entry name in the WANT file.
+// The file is only searched, since it is not possible to get locations from the evaluated config.
+func (cc *compileCtx) namespacePos(name string) Position {
+	pos := Position{Path: WantFilename}
+	ref, err := glfs.GetAtPath(cc.ctx, cc.src, cc.ground, WantFilename)
+	if err != nil {
+		return pos
+	}
+	data, err := glfs.GetBlobBytes(cc.ctx, cc.src, *ref, MaxJsonnetFileSize)
+	if err != nil {
+		return pos
+	}
+	q := regexp.QuoteMeta(name)
+	re := regexp.MustCompile(`(?m)(?:\b` + q + `|"` + q + `"|'` + q + `')\s*::?:?`)
+	loc := re.FindIndex(data)
+	if loc == nil {
+		return pos
+	}
+	before := data[:loc[0]]
+	pos.Line = strings.Count(string(before), "\n") + 1
+	pos.Column = loc[0] - strings.LastIndexByte(string(before), '\n')
+	return pos
+}
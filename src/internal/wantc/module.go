@@ -3,6 +3,7 @@ package wantc
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"path"
 
 	"blobcache.io/glfs"
@@ -96,5 +97,13 @@ func ParseModuleConfig(x []byte) (*wantcfg.ModuleConfig, error) {
 	if err := json.Unmarshal([]byte(jsonData), &ret); err != nil {
 		return nil, err
 	}
+	if err := CheckPathSet(ret.Ignore); err != nil {
+		return nil, fmt.Errorf("ignore: %w", err)
+	}
+	for name, prof := range ret.Profiles {
+		if err := CheckPathSet(prof.Query); err != nil {
+			return nil, fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
 	return &ret, nil
 }
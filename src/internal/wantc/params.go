@@ -0,0 +1,76 @@
+package wantc
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"wantbuild.io/want/src/wantcfg"
+)
+
+// Params are the build parameters for a compilation, available to Jsonnet as want.params().
+// Values are JSON values, decoded with encoding/json.
+type Params = map[string]any
+
+// ResolveParams returns the build parameters for a module with config cfg, with the values in set replacing the defaults.
+// Every key in set must be declared in cfg, and have the same JSON type as its default, unless the default is null.
+func ResolveParams(cfg wantcfg.ModuleConfig, set Params) (Params, error) {
+	ret := maps.Clone(cfg.Params)
+	if ret == nil {
+		ret = Params{}
+	}
+	for _, k := range slices.Sorted(maps.Keys(set)) {
+		v := set[k]
+		def, exists := cfg.Params[k]
+		if !exists {
+			return nil, ErrUnknownParam{Name: k, Declared: slices.Sorted(maps.Keys(cfg.Params))}
+		}
+		if def != nil && jsonType(def) != jsonType(v) {
+			return nil, ErrParamType{Name: k, Want: jsonType(def), Have: jsonType(v)}
+		}
+		ret[k] = v
+	}
+	return ret, nil
+}
+
+type ErrUnknownParam struct {
+	Name     string
+	Declared []string
+}
+
+func (e ErrUnknownParam) Error() string {
+	if len(e.Declared) == 0 {
+		return fmt.Sprintf("unknown build parameter %q: the module does not declare any params", e.Name)
+	}
+	return fmt.Sprintf("unknown build parameter %q: the module declares %s", e.Name, strings.Join(e.Declared, ", "))
+}
+
+type ErrParamType struct {
+	Name       string
+	Want, Have string
+}
+
+func (e ErrParamType) Error() string {
+	return fmt.Sprintf("build parameter %q must be a %s, not a %s", e.Name, e.Want, e.Have)
+}
+
+// jsonType returns the name of the JSON type of x, which was decoded by encoding/json.
+func jsonType(x any) string {
+	switch x.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", x)
+	}
+}
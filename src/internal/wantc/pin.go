@@ -0,0 +1,257 @@
+package wantc
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+	"github.com/google/go-jsonnet/toolutils"
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/internal/op/importops"
+	"wantbuild.io/want/src/wantcfg"
+	"wantbuild.io/want/src/wantjob"
+)
+
+// Import is an import task in a module.
+type Import struct {
+	// Pos is the position of the expression or statement which contains the import.
+	Pos Position
+	// Op is the import operation, without the import. prefix.
+	Op wantjob.OpName
+	// Spec is the JSON spec for the task.
+	Spec []byte
+}
+
+// Imports loads the module in ct, and returns the import tasks in its expressions and statements.
+// Only tasks with literal specs are returned, tasks computed during the build cannot be known ahead of time.
+// Imports are returned even if the module is strict and they are not pinned.
+func (c *Compiler) Imports(ctx context.Context, dst cadata.Store, src cadata.Getter, ct CompileTask) ([]Import, error) {
+	cc, _, err := c.newCompileCtx(ctx, dst, src, ct)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.addSourceFiles(cc); err != nil {
+		return nil, err
+	}
+	var ret []Import
+	if err := cc.forEachImport(func(p string, stmtNum int, op wantjob.OpName, spec []byte) error {
+		ret = append(ret, Import{Pos: cc.sourcePos(p, stmtNum), Op: op, Spec: spec})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// checkPinned returns an error for the first import which does not identify its content by a hash.
+// It only checks strict modules.
+func (c *Compiler) checkPinned(cc *compileCtx) error {
+	if !cc.strict {
+		return nil
+	}
+	defer logStep(cc.ctx, "checking imports are pinned")()
+	return cc.forEachImport(func(p string, stmtNum int, op wantjob.OpName, spec []byte) error {
+		if err := importops.CheckPinned(op, spec); err != nil {
+			return cc.diagnose(p, stmtNum, err)
+		}
+		return nil
+	})
+}
+
+// forEachImport calls fn for every import task in the module, with the file and statement which contains it.
+// stmtNum is -1 for expression files.
+func (cc *compileCtx) forEachImport(fn func(p string, stmtNum int, op wantjob.OpName, spec []byte) error) error {
+	for _, er := range cc.exprRoots {
+		if err := forEachImport(er.spec, func(op wantjob.OpName, spec []byte) error {
+			return fn(er.path, -1, op, spec)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, ss := range cc.stmtSets {
+		for i, spec := range ss.specs {
+			if err := forEachImport(spec.Expr(), func(op wantjob.OpName, spec []byte) error {
+				return fn(ss.path, i, op, spec)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func forEachImport(x wantcfg.Expr, fn func(op wantjob.OpName, spec []byte) error) error {
+	switch {
+	case x.Tree != nil:
+		for _, ent := range x.Tree {
+			if err := forEachImport(ent.Value, fn); err != nil {
+				return err
+			}
+		}
+	case x.Compute != nil:
+		if op, ok := strings.CutPrefix(x.Compute.Op, "import."); ok {
+			for _, in := range x.Compute.Inputs {
+				if in.To == "" && in.From.Blob != nil {
+					if err := fn(wantjob.OpName(op), []byte(*in.From.Blob)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		for _, in := range x.Compute.Inputs {
+			if err := forEachImport(in.From, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ArgEdit sets an argument in calls to a library function, like want.importURL.
+type ArgEdit struct {
+	// Func is the name of the function.
+	Func string
+	// Params are the names of the function's parameters, in order.
+	Params []string
+	// Match are the values of string arguments which identify the calls to edit.
+	Match map[string]string
+	// Defaults are the values of parameters which calls can leave out.
+	Defaults map[string]string
+	// Param is the parameter to set, and Value is its new value.
+	// Only arguments which are an empty string literal are changed.
+	Param string
+	Value string
+}
+
+// EditCalls applies edits to the Jsonnet file at p, with contents src.
+// It returns the new contents, and the number of calls changed by each edit.
+func EditCalls(p string, src []byte, edits []ArgEdit) ([]byte, []int, error) {
+	var prefix string
+	if HasLocalPrefix(p) {
+		prefix = LocalPrefix(FQPath{Path: p})
+	}
+	text := prefix + string(src)
+	node, err := jsonnet.SnippetToAST(p, text)
+	if err != nil {
+		return nil, nil, err
+	}
+	type replacement struct {
+		begin, end int
+		value      string
+	}
+	var reps []replacement
+	counts := make([]int, len(edits))
+	var visit func(node ast.Node)
+	visit = func(node ast.Node) {
+		if node == nil {
+			return
+		}
+		if apply, ok := node.(*ast.Apply); ok {
+			for i, edit := range edits {
+				if calleeName(apply.Target) != edit.Func {
+					continue
+				}
+				args := callArgs(apply, edit.Params)
+				if !matchArgs(args, edit.Match, edit.Defaults) {
+					continue
+				}
+				lit, ok := args[edit.Param].(*ast.LiteralString)
+				if !ok || lit.Value != "" {
+					continue
+				}
+				loc := lit.LocRange
+				begin, end := lineColOffset(text, loc.Begin), lineColOffset(text, loc.End)
+				if begin < len(prefix) || end < begin {
+					continue
+				}
+				reps = append(reps, replacement{begin: begin - len(prefix), end: end - len(prefix), value: strconv.Quote(edit.Value)})
+				counts[i]++
+			}
+		}
+		for _, child := range toolutils.Children(node) {
+			visit(child)
+		}
+	}
+	visit(node)
+	if len(reps) == 0 {
+		return src, counts, nil
+	}
+	slices.SortFunc(reps, func(a, b replacement) int { return a.begin - b.begin })
+	var out strings.Builder
+	last := 0
+	for _, rep := range reps {
+		if rep.begin < last {
+			return nil, nil, errors.New("overlapping edits")
+		}
+		out.Write(src[last:rep.begin])
+		out.WriteString(rep.value)
+		last = rep.end
+	}
+	out.Write(src[last:])
+	return []byte(out.String()), counts, nil
+}
+
+// calleeName returns the name of the function called by an apply, like importURL for want.importURL(...)
+func calleeName(x ast.Node) string {
+	switch x := x.(type) {
+	case *ast.Var:
+		return string(x.Id)
+	case *ast.Index:
+		if lit, ok := x.Index.(*ast.LiteralString); ok {
+			return lit.Value
+		}
+		if x.Id != nil {
+			return string(*x.Id)
+		}
+	}
+	return ""
+}
+
+// callArgs returns the arguments to apply by parameter name.
+func callArgs(apply *ast.Apply, params []string) map[string]ast.Node {
+	ret := map[string]ast.Node{}
+	for i, arg := range apply.Arguments.Positional {
+		if i < len(params) {
+			ret[params[i]] = arg.Expr
+		}
+	}
+	for _, arg := range apply.Arguments.Named {
+		ret[string(arg.Name)] = arg.Arg
+	}
+	return ret
+}
+
+func matchArgs(args map[string]ast.Node, match, defaults map[string]string) bool {
+	for k, v := range match {
+		arg, exists := args[k]
+		if !exists {
+			if d, ok := defaults[k]; ok && d == v {
+				continue
+			}
+			return false
+		}
+		lit, ok := arg.(*ast.LiteralString)
+		if !ok || lit.Value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// lineColOffset returns the byte offset of loc in text.
+func lineColOffset(text string, loc ast.Location) int {
+	offset := 0
+	for line := 1; line < loc.Line; line++ {
+		i := strings.IndexByte(text[offset:], '\n')
+		if i < 0 {
+			return -1
+		}
+		offset += i + 1
+	}
+	return offset + loc.Column - 1
+}
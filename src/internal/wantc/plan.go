@@ -23,6 +23,8 @@ type Target struct {
 	To wantcfg.PathSet `json:"to"`
 	// DAG contains a compiled program to build the target
 	DAG glfs.Ref `json:"dag"`
+	// Node is the node in the plan's DAG which builds the target.
+	Node NodeID `json:"node"`
 	// Expr is an expression which is equivalent to this target.
 	// Expr will always be context-free. Meaning it does not depend on selections which
 	// are relative to the module it was compiled in.
@@ -35,6 +37,10 @@ type Target struct {
 	IsStatement bool `json:"is_statement"`
 	// If the Target is defined in a statement file, this will be the statement number
 	DefinedNum int `json:"defined_num"`
+
+	// IsTest is true when the Target is a test.
+	// Targets defined in test files are tests, as are statements marked as tests.
+	IsTest bool `json:"is_test,omitempty"`
 }
 
 func (t Target) BoundingPrefix() string {
@@ -43,7 +49,10 @@ func (t Target) BoundingPrefix() string {
 
 // Plan is the result of compilation.
 type Plan struct {
-	Known   glfs.Ref `json:"known"`
+	Known glfs.Ref `json:"known"`
+	// DAG builds every target.
+	// It is the union of the targets' DAGs, with the nodes they share only appearing once.
+	DAG     glfs.Ref `json:"dag"`
 	Targets []Target `json:"targets"`
 }
 
@@ -58,6 +67,7 @@ func PostPlan(ctx context.Context, s cadata.PostExister, x Plan) (*glfs.Ref, err
 	}
 	ents := []glfs.TreeEntry{
 		{Name: "plan.json", FileMode: 0o777, Ref: *planRef},
+		{Name: "dag", FileMode: 0o777, Ref: x.DAG},
 		{Name: "known", FileMode: 0o777, Ref: x.Known},
 	}
 	for i, target := range x.Targets {
@@ -80,6 +90,9 @@ func SyncPlan(ctx context.Context, dst cadata.PostExister, src cadata.Getter, pl
 		if !yield(plan.Known) {
 			return
 		}
+		if !yield(plan.DAG) {
+			return
+		}
 	}
 	for ref := range allRefs {
 		if err := glfstasks.FastSync(ctx, dst, src, ref); err != nil {
@@ -98,6 +111,7 @@ func GetPlan(ctx context.Context, s cadata.Getter, x glfs.Ref) (*Plan, error) {
 		return nil, err
 	}
 	var plan Plan
+	var dag glfs.Ref
 	var dags []glfs.Ref
 	for {
 		ent, err := streams.Next(ctx, tr)
@@ -118,6 +132,8 @@ func GetPlan(ctx context.Context, s cadata.Getter, x glfs.Ref) (*Plan, error) {
 			}
 		case ent.Name == "known":
 			plan.Known = ent.Ref
+		case ent.Name == "dag":
+			dag = ent.Ref
 		case strings.HasPrefix(ent.Name, "dag_"):
 			dags = append(dags, ent.Ref)
 		default:
@@ -127,6 +143,9 @@ func GetPlan(ctx context.Context, s cadata.Getter, x glfs.Ref) (*Plan, error) {
 	if plan.Known == (glfs.Ref{}) {
 		return nil, fmt.Errorf("plan is missing known tree")
 	}
+	if dag == (glfs.Ref{}) || !dag.Equals(plan.DAG) {
+		return nil, fmt.Errorf("plan is missing DAG")
+	}
 	if len(plan.Targets) != len(dags) {
 		return nil, fmt.Errorf("plan has the wrong number of DAGs")
 	}
@@ -3,6 +3,7 @@ package wantc
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 
 	"blobcache.io/glfs"
@@ -118,6 +119,10 @@ func SetFromQuery(from string, q wantcfg.PathSet) stringsets.Set {
 		return stringsets.Prefix(PathFrom(from, *q.Prefix))
 	case q.Suffix != nil:
 		return stringsets.Suffix(*q.Suffix)
+	case q.Glob != nil:
+		return stringsets.Glob(PathFrom(from, *q.Glob))
+	case q.Regex != nil:
+		return stringsets.Regex(*q.Regex)
 	case q.Union != nil:
 		xs := slices2.Map(q.Union, func(x wantcfg.PathSet) stringsets.Set {
 			return SetFromQuery(from, x)
@@ -134,3 +139,25 @@ func SetFromQuery(from string, q wantcfg.PathSet) stringsets.Set {
 		return stringsets.Empty{}
 	}
 }
+
+// CheckPathSet returns an error if q contains a glob or regex which is not valid.
+func CheckPathSet(q wantcfg.PathSet) error {
+	switch {
+	case q.Glob != nil:
+		_, err := stringsets.Glob(*q.Glob).Regexp()
+		return err
+	case q.Regex != nil:
+		if _, err := stringsets.Regex(*q.Regex).Regexp(); err != nil {
+			return fmt.Errorf("invalid regex %q: %w", *q.Regex, err)
+		}
+		return nil
+	case q.Not != nil:
+		return CheckPathSet(*q.Not)
+	}
+	for _, x := range slices.Concat(q.Union, q.Intersect) {
+		if err := CheckPathSet(x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -18,35 +18,20 @@ type stmtSet struct {
 }
 
 func (c *Compiler) parseStmtSet(cc *compileCtx, fqp FQPath) (*stmtSet, error) {
-	vm := newJsonnetVM(cc.jsImporter, cc.buildCtx)
-	jsonStr, err := vm.EvaluateFile(mkJsonnetPath(fqp))
+	jsonStr, err := cc.evalFile(fqp)
 	if err != nil {
 		return nil, err
 	}
-	var specs []wantcfg.Statement
-	if err := json.Unmarshal([]byte(jsonStr), &specs); err != nil {
-		return nil, fmt.Errorf("error in stage file %s: %w", fqp.Path, err)
+	var raws []json.RawMessage
+	if err := json.Unmarshal([]byte(jsonStr), &raws); err != nil {
+		return nil, cc.diagnose(fqp.Path, -1, fmt.Errorf("statement file does not contain a list of statements: %w", err))
 	}
-
+	specs := make([]wantcfg.Statement, len(raws))
 	var stmts []*putStmt
-	for _, spec := range specs {
-		var stmt *putStmt
-		switch {
-		case spec.Put != nil:
-			ks := SetFromQuery(fqp.Path, spec.Put.Dst)
-			e, err := c.compileExpr(cc, fqp.Path, spec.Put.Src)
-			if err != nil {
-				return nil, err
-			}
-			if e, err = c.placeAt(cc.ctx, cc.dst, e, PathFrom(fqp.Path, spec.Put.Place)); err != nil {
-				return nil, err
-			}
-			stmt = &putStmt{
-				Dst: ks,
-				Src: e,
-			}
-		default:
-			return nil, errors.New("empty statement")
+	for i, raw := range raws {
+		stmt, err := c.parseStmt(cc, fqp.Path, raw, &specs[i])
+		if err != nil {
+			return nil, cc.diagnose(fqp.Path, i, err)
 		}
 		stmts = append(stmts, stmt)
 	}
@@ -57,6 +42,32 @@ func (c *Compiler) parseStmtSet(cc *compileCtx, fqp FQPath) (*stmtSet, error) {
 	}, nil
 }
 
+func (c *Compiler) parseStmt(cc *compileCtx, p string, raw json.RawMessage, spec *wantcfg.Statement) (*putStmt, error) {
+	if err := json.Unmarshal(raw, spec); err != nil {
+		return nil, fmt.Errorf("invalid statement: %w", err)
+	}
+	switch {
+	case spec.Put != nil:
+		if err := CheckPathSet(spec.Put.Dst); err != nil {
+			return nil, err
+		}
+		ks := SetFromQuery(p, spec.Put.Dst)
+		e, err := c.compileExpr(cc, p, spec.Put.Src)
+		if err != nil {
+			return nil, err
+		}
+		if e, err = c.placeAt(cc.ctx, cc.dst, e, PathFrom(p, spec.Put.Place)); err != nil {
+			return nil, err
+		}
+		return &putStmt{
+			Dst: ks,
+			Src: e,
+		}, nil
+	default:
+		return nil, errors.New("empty statement")
+	}
+}
+
 func (sl *stmtSet) Needs() stringsets.Set {
 	var ss []stringsets.Set
 	for _, spec := range sl.stmts {
@@ -14,7 +14,7 @@ type VFS struct {
 func (v *VFS) Add(x VFSEntry) error {
 	ents := v.Get(x.K)
 	if len(ents) > 0 {
-		return ErrConflict{Overlapping: []stringsets.Set{ents[0].K, x.K}}
+		return ErrConflict{Overlapping: []stringsets.Set{ents[0].K, x.K}, Existing: ents[0]}
 	}
 	n := v.root.Add(v.nodePath(x.K))
 	n.ents = append(n.ents, x)
@@ -46,6 +46,10 @@ local tree(ents=[]) = {
 local unit(p) = { __type__: "pathSet", unit: p };
 local prefix(p) = { __type__: "pathSet", prefix: p };
 local suffix(s) = { __type__: "pathSet", suffix: s };
+// glob matches paths like path.Match, and "**" as a whole path element matches any number of elements.
+local glob(g) = { __type__: "pathSet", glob: g };
+// regex matches paths relative to the module root, which entirely match the regular expression r.
+local regex(r) = { __type__: "pathSet", regex: r };
 local union(xs) = { __type__: "pathSet", union: xs};
 local not(x) = { __type__: "pathSet", not: x};
 local intersect(xs) = { __type__: "pathSet", intersect: xs};
@@ -74,6 +78,9 @@ local selectFile(source, p) = select(source, unit(p), pick=p);
 
 local metadata() = std.extVar("metadata");
 
+// params returns the build parameters, which are declared in the WANT file and set with want build --set.
+local params() = std.extVar("params");
+
 // merge creates a single node from a list of values
 local merge(vals) =
     local inputs = std.mapWithIndex(function(i, elem)
@@ -84,20 +91,22 @@ local merge(vals) =
 local pass(inputs) = compute("glfs.pass", inputs);
 
 // Statements
-local put(dst, src, place="") = {
+// If test is true, the statement is a test target, which is run by want test.
+local put(dst, src, place="", test=false) = {
     __type__: "stmt",
     put: {
         dst: assertType("pathSet")(dst),
         src: assertType("expr")(src),
         place: place,
+        test: test,
     },
 };
 
 // putFile places the result of src at a single path dst.
-local putFile(dst, src) = put(unit(dst), src, place=dst);
+local putFile(dst, src, test=false) = put(unit(dst), src, place=dst, test=test);
 
 // putDir places the result of src at the prefix dst
-local putDir(dst, src) = put(prefix(dst), src, place=dst);
+local putDir(dst, src, test=false) = put(prefix(dst), src, place=dst, test=test);
 
 // Built-Ins
 
@@ -115,11 +124,15 @@ local importURL(url, algo, hash, transforms=[]) =
         input(to="", from=blob(spec)),
     ]);
 
-local importGit(url, commitHash, branch="master") =
+// importGit only includes submodules and lfs in the spec when they are set,
+// so that imports which do not use them have the same task as before they existed.
+local importGit(url, commitHash, branch="master", submodules=false, lfs="") =
     local spec = std.manifestJsonEx({
         url: url,
         branch: branch,
         commitHash: commitHash,
+        [if submodules then "submodules"]: submodules,
+        [if lfs != "" then "lfs"]: lfs,
     }, "");
     compute("import.fromGit", [
         input(to="", from=blob(spec)),
@@ -145,6 +158,18 @@ local importOCIImage(name, algo, hash) =
         input(to="", from=blob(spec))
     ]);
 
+// importNPMLock imports every package pinned by lockfile, which is a package-lock.json blob.
+// The output is a node_modules directory.
+local importNPMLock(lockfile) = compute("import.fromNPMLock", [
+    input(to="package-lock.json", from=lockfile),
+]);
+
+// importCargoLock imports every crate pinned by lockfile, which is a Cargo.lock blob.
+// The output is a directory of vendored crates.
+local importCargoLock(lockfile) = compute("import.fromCargoLock", [
+    input(to="Cargo.lock", from=lockfile),
+]);
+
 local unpack(x, transforms=[]) =
     local spec = std.manifestJsonEx({
         transforms: transforms
@@ -178,6 +203,13 @@ local diff(left, right) = compute("glfs.diff", [
     input(to="right", from=right),
 ]);
 
+// archive writes tree x to a blob as an archive.
+// format is one of tar, tar.gz, tar.zst, zip, cpio or oci-layout
+local archive(x, format="tar") = compute("glfs.archive", [
+    input(to="x", from=x),
+    input(to="format", from=blob(format)),
+]);
+
 local evalSnippet(snip) = 
     local graph = compute("want.compileSnippet", [input("", snip)]);
     local output = compute("graph.eval", [input("", graph)]);
@@ -196,6 +228,8 @@ local evalSnippet(snip) =
     unit :: unit,
     prefix :: prefix,
     suffix :: suffix,
+    glob :: glob,
+    regex :: regex,
     union :: union,
     intersect :: intersect,
     not :: not,
@@ -226,15 +260,19 @@ local evalSnippet(snip) =
     merge :: merge,
     pass :: pass,
     diff :: diff,
+    archive :: archive,
 
     // Metadata
     metadata :: metadata,
+    params :: params,
 
     // Import
     importURL :: importURL,
     importGit :: importGit,
     importGoZip :: importGoZip,
     importOCIImage :: importOCIImage,
+    importNPMLock :: importNPMLock,
+    importCargoLock :: importCargoLock,
     unpack :: unpack,
 
     // Want
@@ -3,15 +3,21 @@ package wantc
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
 	"testing"
 
 	"blobcache.io/glfs"
 	"github.com/stretchr/testify/require"
 
+	"wantbuild.io/want/src/internal/glfstasks"
+	"wantbuild.io/want/src/internal/op/glfsops"
 	"wantbuild.io/want/src/internal/stores"
 	"wantbuild.io/want/src/internal/stringsets"
 	"wantbuild.io/want/src/internal/testutil"
+	"wantbuild.io/want/src/internal/wantdag"
 	"wantbuild.io/want/src/wantcfg"
+	"wantbuild.io/want/src/wantjob"
 )
 
 func TestConvertSet(t *testing.T) {
@@ -207,6 +213,31 @@ func TestCompile(t *testing.T) {
 				NewExprID(wantcfg.Expr{Blob: ptrTo(LibWant())}): testutil.PostBlob(t, src, []byte(LibWant())),
 			},
 		},
+		{
+			Name: "glob",
+			Module: testutil.PostFSStr(t, src, map[string]string{
+				"WANT": `local want = import "@want";
+				{
+					"namespace": {
+						"want": want.blob(importstr "@want"),
+					},
+				}`,
+				"gen/x.json": `{}`,
+				"gen.wants": `local want = import "@want";
+				[
+					want.put(want.glob("gen/**/*.txt"), want.tree([
+						want.treeEntry("a.txt", "0644", want.blob("1")),
+						want.treeEntry("b/c.txt", "0644", want.blob("2")),
+					]), place="gen"),
+				]`,
+				"txt.want": `local want = import "@want";
+				want.select(DERIVED, want.glob("gen/**/*.txt"))
+				`,
+			}),
+			Deps: map[ExprID]glfs.Ref{
+				NewExprID(wantcfg.Expr{Blob: ptrTo(LibWant())}): testutil.PostBlob(t, src, []byte(LibWant())),
+			},
+		},
 	}
 
 	for i, tc := range tcs {
@@ -227,6 +258,568 @@ func TestCompile(t *testing.T) {
 	}
 }
 
+func TestTestTargets(t *testing.T) {
+	ctx := testutil.Context(t)
+	src := stores.NewMem()
+	module := testutil.PostFSStr(t, src, map[string]string{
+		"WANT":         `{namespace: {want: {blob: importstr "@want"} } }`,
+		"a.want":       `local want = import "@want"; want.blob("a")`,
+		"a_test.want":  `local want = import "@want"; want.blob("a")`,
+		"b_test.wants": `local want = import "@want"; [want.putFile("b.txt", want.blob("b"))]`,
+		"c.wants": `local want = import "@want"; [
+			want.putFile("c1.txt", want.blob("c1")),
+			want.putFile("c2.txt", want.blob("c2"), test=true),
+		]`,
+	})
+	deps := map[ExprID]glfs.Ref{
+		NewExprID(wantcfg.Expr{Blob: ptrTo(LibWant())}): testutil.PostBlob(t, src, []byte(LibWant())),
+	}
+	plan, err := NewCompiler().Compile(ctx, stores.NewMem(), src, CompileTask{Module: module, Deps: deps})
+	require.NoError(t, err)
+	isTest := map[string]bool{}
+	for _, target := range plan.Targets {
+		isTest[fmt.Sprintf("%s[%d]", target.DefinedIn, target.DefinedNum)] = target.IsTest
+	}
+	require.Equal(t, map[string]bool{
+		"a.want[0]":       false,
+		"a_test.want[0]":  true,
+		"b_test.wants[0]": true,
+		"c.wants[0]":      false,
+		"c.wants[1]":      true,
+	}, isTest)
+}
+
+func TestIsTestFilePath(t *testing.T) {
+	require.True(t, IsTestFilePath("recipes/foo_test.want"))
+	require.True(t, IsTestFilePath("foo_test.want.jsonnet"))
+	require.True(t, IsTestFilePath("foo_test.wants"))
+	require.False(t, IsTestFilePath("foo.want"))
+	require.False(t, IsTestFilePath("foo_test.libsonnet"))
+	require.False(t, IsTestFilePath("test.want"))
+}
+
+func TestDiagnostics(t *testing.T) {
+	src := stores.NewMem()
+	const wantCfg = `{namespace: {want: {blob: importstr "@want"} } }`
+	deps := map[ExprID]glfs.Ref{
+		NewExprID(wantcfg.Expr{Blob: ptrTo(LibWant())}): testutil.PostBlob(t, src, []byte(LibWant())),
+	}
+	tcs := []struct {
+		Name  string
+		Files map[string]string
+		Diag  Diagnostic
+	}{
+		{
+			Name: "RuntimeError",
+			Files: map[string]string{
+				"a.want": "local want = import \"@want\";\nlocal x = 1;\n  error \"oops\"\n",
+			},
+			Diag: Diagnostic{
+				Pos:     Position{Path: "a.want", Line: 3, Column: 3},
+				Message: "oops",
+			},
+		},
+		{
+			Name: "FirstLine",
+			Files: map[string]string{
+				"a.want": `local want = import "@want"; error "oops"`,
+			},
+			Diag: Diagnostic{
+				Pos:     Position{Path: "a.want", Line: 1, Column: 30},
+				Message: "oops",
+			},
+		},
+		{
+			Name: "InvalidStatement",
+			Files: map[string]string{
+				"a.wants": "local want = import \"@want\";\n[\n  want.putFile(\"a.txt\", want.blob(\"a\")),\n  {},\n]\n",
+			},
+			Diag: Diagnostic{
+				Pos:     Position{Path: "a.wants", Line: 4, Column: 3},
+				StmtNum: ptrTo(1),
+				Message: "empty statement",
+			},
+		},
+		{
+			Name: "Conflict",
+			Files: map[string]string{
+				"a.wants": "local want = import \"@want\";\n[\n  want.putFile(\"x.txt\", want.blob(\"a\")),\n  want.putFile(\"x.txt\", want.blob(\"b\")),\n]\n",
+			},
+			Diag: Diagnostic{
+				Pos:     Position{Path: "a.wants", Line: 4, Column: 3},
+				StmtNum: ptrTo(1),
+				Message: `statement outputs to {"x.txt"}, which conflicts with {"x.txt"}`,
+				Related: []Related{
+					{Pos: Position{Path: "a.wants", Line: 3, Column: 3}, Message: `{"x.txt"} is output here`},
+				},
+			},
+		},
+		{
+			Name: "Cycle",
+			Files: map[string]string{
+				"a.wants": "local want = import \"@want\";\n[\n  want.putFile(\"x.txt\", want.select(DERIVED, want.unit(\"y.txt\"))),\n  want.putFile(\"y.txt\", want.select(DERIVED, want.unit(\"x.txt\"))),\n]\n",
+			},
+			Diag: Diagnostic{
+				Pos:     Position{Path: "a.wants", Line: 4, Column: 3},
+				StmtNum: ptrTo(1),
+				Message: `dependency cycle: {"y.txt"} -> {"x.txt"} -> {"y.txt"}`,
+				Related: []Related{
+					{Pos: Position{Path: "a.wants", Line: 3, Column: 3}, Message: `outputs to {"x.txt"} and selects {"y.txt"}`},
+				},
+			},
+		},
+		{
+			Name: "GlobConflict",
+			Files: map[string]string{
+				"a.wants": "local want = import \"@want\";\n[\n  want.putFile(\"gen/x.txt\", want.blob(\"a\")),\n  want.put(want.glob(\"gen/*.txt\"), want.blob(\"b\")),\n]\n",
+			},
+			Diag: Diagnostic{
+				Pos:     Position{Path: "a.wants", Line: 4, Column: 3},
+				StmtNum: ptrTo(1),
+				Message: `statement outputs to (glob "gen/*.txt"), which conflicts with {"gen/x.txt"}`,
+				Related: []Related{
+					{Pos: Position{Path: "a.wants", Line: 3, Column: 3}, Message: `{"gen/x.txt"} is output here`},
+				},
+			},
+		},
+		{
+			Name: "InvalidGlob",
+			Files: map[string]string{
+				"a.wants": "local want = import \"@want\";\n[\n  want.put(want.glob(\"gen/[a\"), want.blob(\"b\")),\n]\n",
+			},
+			Diag: Diagnostic{
+				Pos:     Position{Path: "a.wants", Line: 3, Column: 3},
+				StmtNum: ptrTo(0),
+				Message: `invalid glob "gen/[a": unterminated [`,
+			},
+		},
+		{
+			Name: "Submodule",
+			Files: map[string]string{
+				"a.wants":  "local want = import \"@want\";\n[want.putFile(\"sub/x.txt\", want.blob(\"a\"))]\n",
+				"sub/WANT": wantCfg,
+			},
+			Diag: Diagnostic{
+				Pos:     Position{Path: "a.wants", Line: 2, Column: 2},
+				StmtNum: ptrTo(0),
+				Message: "statement a.wants[0] outputs to submodule sub",
+				Related: []Related{
+					{Pos: Position{Path: "sub/WANT"}, Message: "submodule is defined here"},
+				},
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctx := testutil.Context(t)
+			files := map[string]string{"WANT": wantCfg}
+			for k, v := range tc.Files {
+				files[k] = v
+			}
+			module := testutil.PostFSStr(t, src, files)
+			_, err := NewCompiler().Compile(ctx, stores.NewMem(), src, CompileTask{Module: module, Deps: deps})
+			require.Error(t, err)
+			var diag *Diagnostic
+			require.ErrorAs(t, err, &diag, err.Error())
+			diag.err = nil
+			require.Equal(t, tc.Diag, *diag)
+
+			diag2, err := ParseDiagnostic(MarshalDiagnostic(*diag))
+			require.NoError(t, err)
+			require.Equal(t, diag, diag2)
+		})
+	}
+}
+
 func ptrTo[T any](x T) *T {
 	return &x
 }
+
+func TestLint(t *testing.T) {
+	ctx := testutil.Context(t)
+	src := stores.NewMem()
+	deps := map[ExprID]glfs.Ref{
+		NewExprID(wantcfg.Expr{Blob: ptrTo(LibWant())}): testutil.PostBlob(t, src, []byte(LibWant())),
+		NewExprID(wantcfg.Expr{Blob: ptrTo("unused")}):  testutil.PostBlob(t, src, []byte("unused")),
+	}
+	module := testutil.PostFSStr(t, src, map[string]string{
+		"WANT": `local want = import "@want";
+{
+    ignore: want.prefix("out/"),
+    namespace: {
+        want: want.blob(importstr "@want"),
+        other: want.blob("unused"),
+    },
+}
+`,
+		"a.wants": strings.Join([]string{
+			`local want = import "@want";`,
+			`[`,
+			`    want.putFile("out/x.txt", want.blob("x")),`,
+			`    want.putFile("y.txt", want.selectFile(GROUND, "missing.txt")),`,
+			`    want.putFile("z.txt", want.importURL("https://example.com/z.txt", "SHA256", "")),`,
+			`    want.putFile("ok.txt", want.selectFile(GROUND, "src.txt")),`,
+			`    want.putDir("d1", want.merge([want.tree(), want.place(want.tree(), "x")])),`,
+			`    want.putDir("d2", want.merge([want.selectFile(GROUND, "src.txt"), want.filter(want.merge([]), want.prefix("a"))])),`,
+			`]`,
+		}, "\n"),
+		"empty.want": `local want = import "@want"; want.tree()`,
+		"merge.want": `local want = import "@want"; want.merge([want.place(want.merge([]), "a")])`,
+		"src.txt":    "hello",
+	})
+	diags, err := NewCompiler().Lint(ctx, stores.NewMem(), src, CompileTask{Module: module, Deps: deps})
+	require.NoError(t, err)
+	var msgs []string
+	for _, d := range diags {
+		s := fmt.Sprintf("%v", d.Pos)
+		if d.StmtNum != nil {
+			s += fmt.Sprintf("[%d]", *d.StmtNum)
+		}
+		msgs = append(msgs, s+": "+d.Message)
+	}
+	require.Equal(t, []string{
+		`WANT:6:9: namespace entry "other" is never imported`,
+		`a.wants:3:5[0]: statement outputs to {"out/x.txt"}, which overlaps the ignored paths (prefix "out/")`,
+		`a.wants:4:5[1]: selection from GROUND {"missing.txt"} does not match any source files`,
+		`a.wants:5:5[2]: importURL of https://example.com/z.txt has an empty hash, so its contents are not verified`,
+		`a.wants:7:5[4]: statement source evaluates to an empty tree`,
+		`a.wants:8:5[5]: glfs.filterPathSet evaluates to an empty tree`,
+		`empty.want:1:30: expression evaluates to an empty tree`,
+		`merge.want:1:30: expression evaluates to an empty tree`,
+	}, msgs)
+
+	// compiler errors are returned as the only diagnostic
+	module = testutil.PostFSStr(t, src, map[string]string{
+		"WANT":   `{namespace: {want: {blob: importstr "@want"}}}`,
+		"a.want": `error "oops"`,
+	})
+	diags, err = NewCompiler().Lint(ctx, stores.NewMem(), src, CompileTask{Module: module, Deps: deps})
+	require.NoError(t, err)
+	require.Len(t, diags, 1)
+	require.Equal(t, "oops", diags[0].Message)
+}
+
+func TestStrict(t *testing.T) {
+	ctx := testutil.Context(t)
+	src := stores.NewMem()
+	deps := map[ExprID]glfs.Ref{
+		NewExprID(wantcfg.Expr{Blob: ptrTo(LibWant())}): testutil.PostBlob(t, src, []byte(LibWant())),
+	}
+	const wantFile = `{strict: true, namespace: {want: {blob: importstr "@want"}}}`
+	const commit = "0123456789abcdef0123456789abcdef01234567"
+	tcs := []struct {
+		Stmts string
+		Err   string
+	}{
+		{
+			Stmts: `[want.putFile("a", want.importURL("https://example.com/a", "SHA256", "ab"))]`,
+		},
+		{
+			Stmts: `[want.putFile("a", want.importURL("https://example.com/a", "SHA256", ""))]`,
+			Err:   "fromURL import of https://example.com/a is not pinned: hash must be a hash",
+		},
+		{
+			Stmts: `[want.putDir("a", want.importGit("https://example.com/a.git", "` + commit + `"))]`,
+		},
+		{
+			Stmts: `[want.putDir("a", want.importGit("https://example.com/a.git", "main"))]`,
+			Err:   "fromGit import of https://example.com/a.git is not pinned: commitHash must be a hash",
+		},
+	}
+	for i, tc := range tcs {
+		module := testutil.PostFSStr(t, src, map[string]string{
+			"WANT":    wantFile,
+			"a.wants": "local want = import \"@want\";\n" + tc.Stmts,
+		})
+		_, err := NewCompiler().Compile(ctx, stores.NewMem(), src, CompileTask{Module: module, Deps: deps})
+		if tc.Err == "" {
+			require.NoError(t, err, "case %d", i)
+			continue
+		}
+		var diag *Diagnostic
+		require.ErrorAs(t, err, &diag, "case %d", i)
+		require.Equal(t, Position{Path: "a.wants", Line: 2, Column: 2}, diag.Pos)
+		require.Equal(t, tc.Err, diag.Message)
+
+		// the imports can still be listed, so they can be pinned
+		imports, err := NewCompiler().Imports(ctx, stores.NewMem(), src, CompileTask{Module: module, Deps: deps})
+		require.NoError(t, err)
+		require.Len(t, imports, 1)
+	}
+}
+
+func TestImportGitSpec(t *testing.T) {
+	ctx := testutil.Context(t)
+	src := stores.NewMem()
+	deps := map[ExprID]glfs.Ref{
+		NewExprID(wantcfg.Expr{Blob: ptrTo(LibWant())}): testutil.PostBlob(t, src, []byte(LibWant())),
+	}
+	tcs := []struct {
+		Call string
+		Spec string
+	}{
+		{
+			// imports without submodules or LFS have the same spec as before those options existed.
+			Call: `want.importGit("https://example.com/a.git", "1111")`,
+			Spec: "{\n\"branch\": \"master\",\n\"commitHash\": \"1111\",\n\"url\": \"https://example.com/a.git\"\n}",
+		},
+		{
+			Call: `want.importGit("https://example.com/a.git", "1111", submodules=true)`,
+			Spec: "{\n\"branch\": \"master\",\n\"commitHash\": \"1111\",\n\"submodules\": true,\n\"url\": \"https://example.com/a.git\"\n}",
+		},
+		{
+			Call: `want.importGit("https://example.com/a.git", "1111", "dev", lfs="https://example.com/lfs")`,
+			Spec: "{\n\"branch\": \"dev\",\n\"commitHash\": \"1111\",\n\"lfs\": \"https://example.com/lfs\",\n\"url\": \"https://example.com/a.git\"\n}",
+		},
+	}
+	for i, tc := range tcs {
+		module := testutil.PostFSStr(t, src, map[string]string{
+			"WANT":   `{namespace: {want: {blob: importstr "@want"}}}`,
+			"a.want": "local want = import \"@want\";\n" + tc.Call,
+		})
+		imports, err := NewCompiler().Imports(ctx, stores.NewMem(), src, CompileTask{Module: module, Deps: deps})
+		require.NoError(t, err, "case %d", i)
+		require.Len(t, imports, 1, "case %d", i)
+		require.Equal(t, tc.Spec, string(imports[0].Spec), "case %d", i)
+	}
+}
+
+func TestEditCalls(t *testing.T) {
+	src := strings.Join([]string{
+		`local want = import "@want";`,
+		`local u = "https://example.com/b";`,
+		`[`,
+		`    want.importURL("https://example.com/a", "SHA256", ""),`,
+		`    want.importURL(url="https://example.com/a", algo="SHA256", hash=''),`,
+		`    want.importURL("https://example.com/a", "SHA256", "ff"),`,
+		`    want.importURL(u, "SHA256", ""),`,
+		`    want.selectFile(GROUND, "x"),`,
+		`    want.importGit("https://example.com/r.git", ""),`,
+		`    want.importGit("https://example.com/r.git", "", "dev"),`,
+		`]`,
+	}, "\n")
+	gitParams := []string{"url", "commitHash", "branch"}
+	gitDefaults := map[string]string{"branch": "master"}
+	edits := []ArgEdit{
+		{Func: "importURL", Params: []string{"url", "algo", "hash"}, Match: map[string]string{"url": "https://example.com/a"}, Param: "hash", Value: "abcd"},
+		{Func: "importURL", Params: []string{"url", "algo", "hash"}, Match: map[string]string{"url": "https://example.com/b"}, Param: "hash", Value: "ef01"},
+		// the same repository at 2 branches
+		{Func: "importGit", Params: gitParams, Match: map[string]string{"url": "https://example.com/r.git", "branch": "master"}, Defaults: gitDefaults, Param: "commitHash", Value: "1111"},
+		{Func: "importGit", Params: gitParams, Match: map[string]string{"url": "https://example.com/r.git", "branch": "dev"}, Defaults: gitDefaults, Param: "commitHash", Value: "2222"},
+	}
+	out, counts, err := EditCalls("a.wants", []byte(src), edits)
+	require.NoError(t, err)
+	// calls with computed arguments cannot be matched
+	require.Equal(t, []int{2, 0, 1, 1}, counts)
+	require.Equal(t, strings.Join([]string{
+		`local want = import "@want";`,
+		`local u = "https://example.com/b";`,
+		`[`,
+		`    want.importURL("https://example.com/a", "SHA256", "abcd"),`,
+		`    want.importURL(url="https://example.com/a", algo="SHA256", hash="abcd"),`,
+		`    want.importURL("https://example.com/a", "SHA256", "ff"),`,
+		`    want.importURL(u, "SHA256", ""),`,
+		`    want.selectFile(GROUND, "x"),`,
+		`    want.importGit("https://example.com/r.git", "1111"),`,
+		`    want.importGit("https://example.com/r.git", "2222", "dev"),`,
+		`]`,
+	}, "\n"), string(out))
+}
+
+func TestPlanDAG(t *testing.T) {
+	ctx := testutil.Context(t)
+	src := stores.NewMem()
+	module := testutil.PostFSStr(t, src, map[string]string{
+		"WANT": `{namespace: {want: {blob: importstr "@want"} } }`,
+		"a.wants": `local want = import "@want";
+		local x = want.compute("test.op", [want.input("", want.blob("x"))]);
+		[
+			want.putFile("a.txt", x),
+			want.putFile("b.txt", x),
+		]`,
+		"c.want": `local want = import "@want"; want.compute("test.op", [want.input("", want.blob("x"))])`,
+	})
+	deps := map[ExprID]glfs.Ref{
+		NewExprID(wantcfg.Expr{Blob: ptrTo(LibWant())}): testutil.PostBlob(t, src, []byte(LibWant())),
+	}
+	dst := stores.NewMem()
+	plan, err := NewCompiler().Compile(ctx, dst, src, CompileTask{Module: module, Deps: deps})
+	require.NoError(t, err)
+	dag, err := wantdag.GetDAG(ctx, dst, plan.DAG)
+	require.NoError(t, err)
+
+	// the computation is shared by all of the targets, so it is only in the DAG once.
+	var count int
+	for _, node := range dag {
+		if node.Op == "test.op" {
+			count++
+		}
+	}
+	require.Equal(t, 1, count)
+
+	require.Len(t, plan.Targets, 3)
+	seen := map[NodeID]bool{}
+	for _, target := range plan.Targets {
+		require.False(t, seen[target.Node], "targets place their output differently, so they have different nodes")
+		seen[target.Node] = true
+		// each target's own DAG ends with its node
+		tdag, err := wantdag.GetDAG(ctx, dst, target.DAG)
+		require.NoError(t, err)
+		expected, _ := wantdag.Prune(dag, []NodeID{target.Node})
+		require.Equal(t, expected, tdag)
+		require.Equal(t, dag[target.Node].Op, tdag[len(tdag)-1].Op)
+	}
+}
+
+func TestNarrow(t *testing.T) {
+	ctx := testutil.Context(t)
+	src := stores.NewMem()
+	module := testutil.PostFSStr(t, src, map[string]string{
+		"WANT": `{namespace: {want: {blob: importstr "@want"} } }`,
+		"a.want": `local want = import "@want";
+		local big = want.compute("test.big", [want.input("", want.blob("big"))]);
+		local small = want.compute("test.small", [want.input("", want.blob("small"))]);
+		local both = want.pass([want.input("big", big), want.input("small", small)]);
+		want.pick(want.filter(both, want.unit("small/x")), "small/x")`,
+	})
+	deps := map[ExprID]glfs.Ref{
+		NewExprID(wantcfg.Expr{Blob: ptrTo(LibWant())}): testutil.PostBlob(t, src, []byte(LibWant())),
+	}
+	dst := stores.NewMem()
+	plan, err := NewCompiler().Compile(ctx, dst, src, CompileTask{Module: module, Deps: deps})
+	require.NoError(t, err)
+	require.Len(t, plan.Targets, 1)
+	dag, err := wantdag.GetDAG(ctx, dst, plan.Targets[0].DAG)
+	require.NoError(t, err)
+	hasOp := func(x wantdag.DAG, op wantjob.OpName) bool {
+		return slices.ContainsFunc(x, func(n wantdag.Node) bool { return n.Op == op })
+	}
+	require.True(t, hasOp(dag, "test.big"))
+
+	narrowed, _, err := wantdag.Narrow(ctx, dst, dag, []NodeID{NodeID(len(dag) - 1)}, glfsops.Narrowers("glfs."))
+	require.NoError(t, err)
+	require.False(t, hasOp(narrowed, "test.big"), "only small is needed")
+	require.True(t, hasOp(narrowed, "test.small"))
+	require.Equal(t, dag[len(dag)-1].Op, narrowed[len(narrowed)-1].Op)
+}
+
+func TestNarrowMerge(t *testing.T) {
+	ctx := testutil.Context(t)
+	src := stores.NewMem()
+	exprs := map[string]string{
+		"placed.want":   `want.pick(want.merge([want.place(want.blob("big"), "big"), want.place(want.blob("small"), "small")]), "small")`,
+		"override.want": `want.pick(want.merge([want.place(want.blob("1"), "x"), want.place(want.blob("2"), "y"), want.place(want.blob("3"), "x")]), "x")`,
+		"single.want":   `want.pick(want.merge([want.place(want.blob("only"), "a")]), "a")`,
+		"nested.want":   `want.pick(want.merge([want.place(want.blob("a"), "d/a"), want.place(want.merge([want.place(want.blob("b"), "b")]), "d")]), "d/b")`,
+		"filter.want":   `want.filter(want.merge([want.place(want.blob("z"), "z"), want.place(want.blob("y"), "y")]), want.unit("y"))`,
+		"empty.want":    `want.merge([])`,
+	}
+	files := map[string]string{
+		"WANT": `{namespace: {want: {blob: importstr "@want"} } }`,
+	}
+	for name, expr := range exprs {
+		files[name] = "local want = import \"@want\";\n" + expr
+	}
+	module := testutil.PostFSStr(t, src, files)
+	deps := map[ExprID]glfs.Ref{
+		NewExprID(wantcfg.Expr{Blob: ptrTo(LibWant())}): testutil.PostBlob(t, src, []byte(LibWant())),
+	}
+	dst := stores.NewMem()
+	plan, err := NewCompiler().Compile(ctx, dst, src, CompileTask{Module: module, Deps: deps})
+	require.NoError(t, err)
+	require.Len(t, plan.Targets, len(exprs))
+
+	sys := wantjob.NewMem(ctx, wantjob.MultiExecutor{"glfs": glfsops.Executor{}})
+	exec := func(x wantdag.DAG) (*glfs.Ref, error) {
+		jc := wantjob.Ctx{Context: ctx, System: sys, Dst: stores.NewMem()}
+		res, err := wantdag.SerialExecLast(jc, dst, x)
+		require.NoError(t, err)
+		if err := res.Err(); err != nil {
+			return nil, err
+		}
+		return glfstasks.ParseGLFSRef(res.Root)
+	}
+	for _, target := range plan.Targets {
+		dag, err := wantdag.GetDAG(ctx, dst, target.DAG)
+		require.NoError(t, err)
+		narrowed, _, err := wantdag.Narrow(ctx, dst, dag, []NodeID{NodeID(len(dag) - 1)}, glfsops.Narrowers("glfs."))
+		require.NoError(t, err, "narrowing %v", target.To)
+		expected, expectedErr := exec(dag)
+		actual, actualErr := exec(narrowed)
+		if expectedErr != nil {
+			require.Error(t, actualErr, "narrowing %v", target.To)
+			continue
+		}
+		require.NoError(t, actualErr, "narrowing %v", target.To)
+		require.Equal(t, expected, actual, "narrowing %v", target.To)
+	}
+}
+
+func TestParams(t *testing.T) {
+	ctx := testutil.Context(t)
+	src := stores.NewMem()
+	deps := map[ExprID]glfs.Ref{
+		NewExprID(wantcfg.Expr{Blob: ptrTo(LibWant())}): testutil.PostBlob(t, src, []byte(LibWant())),
+	}
+	module := testutil.PostFSStr(t, src, map[string]string{
+		"WANT": `{
+			namespace: {want: {blob: importstr "@want"}},
+			params: {release: false, arch: "amd64", features: null},
+		}`,
+		"a.want": `local want = import "@want";
+		want.blob(std.manifestJsonEx(want.params(), ""))`,
+	})
+	tcs := []struct {
+		Set      Params
+		Expected Params
+		Err      string
+	}{
+		{
+			Expected: Params{"release": false, "arch": "amd64", "features": nil},
+		},
+		{
+			Set:      Params{"release": true, "features": []any{"x"}},
+			Expected: Params{"release": true, "arch": "amd64", "features": []any{"x"}},
+		},
+		{
+			Set: Params{"arch": 1.0},
+			Err: `build parameter "arch" must be a string, not a number`,
+		},
+		{
+			Set: Params{"debug": true},
+			Err: `unknown build parameter "debug": the module declares arch, features, release`,
+		},
+	}
+	var dagRefs []glfs.Ref
+	for i, tc := range tcs {
+		dst := stores.NewMem()
+		plan, err := NewCompiler().Compile(ctx, dst, src, CompileTask{Module: module, Deps: deps, Params: tc.Set})
+		if tc.Err != "" {
+			var diag *Diagnostic
+			require.ErrorAs(t, err, &diag, "case %d", i)
+			require.Equal(t, WantFilename, diag.Pos.Path)
+			require.Equal(t, tc.Err, diag.Message)
+			continue
+		}
+		require.NoError(t, err, "case %d", i)
+		require.Len(t, plan.Targets, 1)
+		dag, err := wantdag.GetDAG(ctx, dst, plan.Targets[0].DAG)
+		require.NoError(t, err)
+		// find the params written by a.want among the blobs in the DAG
+		var actual Params
+		for _, node := range dag {
+			if !node.IsFact() || node.Value.Type != glfs.TypeBlob {
+				continue
+			}
+			data, err := glfs.GetBlobBytes(ctx, dst, *node.Value, 1e6)
+			require.NoError(t, err)
+			if json.Unmarshal(data, &actual) == nil && actual != nil {
+				break
+			}
+		}
+		require.Equal(t, tc.Expected, actual, "case %d", i)
+		dagRefs = append(dagRefs, plan.Targets[0].DAG)
+	}
+	// each variant compiles to a different DAG
+	require.NotEqual(t, dagRefs[0], dagRefs[1])
+}
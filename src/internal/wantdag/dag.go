@@ -146,3 +146,66 @@ func (b *Builder) Count() uint64 {
 func (b *Builder) Finish() DAG {
 	return b.nodes
 }
+
+// Dedup returns a DAG with the same nodes as x, but where identical nodes only appear once.
+// Derived nodes are identical if they have the same operation and the same inputs, after deduplication.
+// ids maps the ID of each node in x to its ID in the returned DAG.
+func Dedup(x DAG) (ret DAG, ids []NodeID) {
+	ids = make([]NodeID, len(x))
+	index := map[string]NodeID{}
+	for i, node := range x {
+		if node.IsDerived() {
+			inputs := make([]NodeInput, len(node.Inputs))
+			for j, in := range node.Inputs {
+				inputs[j] = NodeInput{Name: in.Name, Node: ids[in.Node]}
+			}
+			node.Inputs = inputs
+		}
+		k := node.key()
+		if nid, exists := index[k]; exists {
+			ids[i] = nid
+			continue
+		}
+		ids[i] = NodeID(len(ret))
+		index[k] = ids[i]
+		ret = append(ret, node)
+	}
+	return ret, ids
+}
+
+// Prune returns the nodes of x which are needed to compute the nodes in roots.
+// The nodes stay in the same order, so if there is a single root, it will be the last node.
+// ids are the IDs of the roots in the returned DAG.
+func Prune(x DAG, roots []NodeID) (ret DAG, ids []NodeID) {
+	keep := make([]bool, len(x))
+	for _, root := range roots {
+		keep[root] = true
+	}
+	// inputs always come before the nodes which use them
+	for i := len(x) - 1; i >= 0; i-- {
+		if keep[i] {
+			for _, in := range x[i].Inputs {
+				keep[in.Node] = true
+			}
+		}
+	}
+	newIDs := make([]NodeID, len(x))
+	for i, node := range x {
+		if !keep[i] {
+			continue
+		}
+		if node.IsDerived() {
+			inputs := make([]NodeInput, len(node.Inputs))
+			for j, in := range node.Inputs {
+				inputs[j] = NodeInput{Name: in.Name, Node: newIDs[in.Node]}
+			}
+			node.Inputs = inputs
+		}
+		newIDs[i] = NodeID(len(ret))
+		ret = append(ret, node)
+	}
+	for _, root := range roots {
+		ids = append(ids, newIDs[root])
+	}
+	return ret, ids
+}
@@ -0,0 +1,157 @@
+package wantdag
+
+import (
+	"context"
+	"path"
+	"slices"
+	"strings"
+
+	"go.brendoncarroll.net/state/cadata"
+)
+
+// Demand is the set of paths which are needed from the output of a node.
+// Each element is a path, and everything at or below that path is needed.
+// The empty path is the root, so a Demand containing it needs the whole output.
+type Demand []string
+
+// DemandAll returns a Demand for the whole output.
+func DemandAll() Demand {
+	return Demand{""}
+}
+
+// DemandPaths returns a Demand for the paths ps.
+func DemandPaths(ps ...string) Demand {
+	var ret Demand
+	for _, p := range ps {
+		ret = ret.Union(Demand{cleanPath(p)})
+	}
+	return ret
+}
+
+// All returns true if d needs the whole output.
+func (d Demand) All() bool {
+	return slices.Contains(d, "")
+}
+
+// Contains returns true if p is needed.
+func (d Demand) Contains(p string) bool {
+	p = cleanPath(p)
+	for _, x := range d {
+		if pathCovers(x, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Union returns the paths needed by d or o.
+func (d Demand) Union(o Demand) Demand {
+	var ret Demand
+	for _, p := range slices.Concat(d, o) {
+		if ret.Contains(p) {
+			continue
+		}
+		ret = slices.DeleteFunc(ret, func(x string) bool { return pathCovers(p, x) })
+		ret = append(ret, p)
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+// Intersect returns the paths needed by both d and o.
+func (d Demand) Intersect(o Demand) Demand {
+	var ret Demand
+	for _, a := range d {
+		for _, b := range o {
+			switch {
+			case pathCovers(a, b):
+				ret = ret.Union(Demand{b})
+			case pathCovers(b, a):
+				ret = ret.Union(Demand{a})
+			}
+		}
+	}
+	return ret
+}
+
+// Under returns the Demand on a tree, when d is the demand on the entry at p in that tree.
+func (d Demand) Under(p string) Demand {
+	p = cleanPath(p)
+	var ret Demand
+	for _, x := range d {
+		ret = ret.Union(Demand{path.Join(p, x)})
+	}
+	return ret
+}
+
+// Within returns the Demand on the entry at p, when d is the demand on the tree which contains it.
+// The returned Demand is empty if nothing in the entry is needed.
+func (d Demand) Within(p string) Demand {
+	p = cleanPath(p)
+	var ret Demand
+	for _, x := range d {
+		switch {
+		case pathCovers(x, p):
+			return DemandAll()
+		case pathCovers(p, x):
+			ret = ret.Union(Demand{strings.TrimPrefix(x[len(p):], "/")})
+		}
+	}
+	return ret
+}
+
+// pathCovers returns true if p is at or below dir.
+func pathCovers(dir, p string) bool {
+	return dir == "" || p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+func cleanPath(p string) string {
+	return strings.Trim(path.Clean("/"+p), "/")
+}
+
+// A Narrower rewrites a derived node, so that it only computes the part of its output in demand.
+// It returns the new inputs for the node, and the demand on each of them.
+// Inputs which are not returned are not computed for the node.
+// x is the DAG which contains node, so the Narrower can read facts among its inputs.
+type Narrower func(ctx context.Context, src cadata.Getter, x DAG, node Node, demand Demand) ([]NodeInput, []Demand, error)
+
+// Narrow returns the nodes of x which are needed to compute roots.
+// Demand for paths flows from the roots to their inputs, and nodes with an operation in narrowers are narrowed to compute only what is needed.
+// Nodes are only narrowed after the demand from every node which uses them is known, so shared nodes compute everything any of them need.
+// ids are the IDs of the roots in the returned DAG.
+func Narrow(ctx context.Context, src cadata.Getter, x DAG, roots []NodeID, narrowers map[OpName]Narrower) (ret DAG, ids []NodeID, _ error) {
+	x = slices.Clone(x)
+	needed := make([]bool, len(x))
+	demands := make([]Demand, len(x))
+	for _, root := range roots {
+		needed[root] = true
+		demands[root] = DemandAll()
+	}
+	// inputs always come before the nodes which use them
+	for i := len(x) - 1; i >= 0; i-- {
+		node := x[i]
+		if !needed[i] || !node.IsDerived() {
+			continue
+		}
+		inputs := node.Inputs
+		var inDemands []Demand
+		if fn, exists := narrowers[node.Op]; exists {
+			var err error
+			if inputs, inDemands, err = fn(ctx, src, x, node, demands[i]); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			for range inputs {
+				inDemands = append(inDemands, DemandAll())
+			}
+		}
+		node.Inputs = inputs
+		x[i] = node
+		for j, in := range inputs {
+			needed[in.Node] = true
+			demands[in.Node] = demands[in.Node].Union(inDemands[j])
+		}
+	}
+	ret, ids = Prune(x, roots)
+	return ret, ids, nil
+}
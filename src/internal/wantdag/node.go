@@ -4,9 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"strconv"
 	"strings"
 
 	"blobcache.io/glfs"
+
+	"wantbuild.io/want/src/internal/glfstasks"
 	"wantbuild.io/want/src/wantjob"
 )
 
@@ -57,6 +60,19 @@ func (n *Node) Validate() error {
 	return nil
 }
 
+// key identifies the node by its contents.
+func (n *Node) key() string {
+	if n.IsFact() {
+		return "fact " + string(glfstasks.MarshalGLFSRef(*n.Value))
+	}
+	var sb strings.Builder
+	sb.WriteString(strconv.Quote(string(n.Op)))
+	for _, in := range n.Inputs {
+		fmt.Fprintf(&sb, " %q=%d", in.Name, in.Node)
+	}
+	return sb.String()
+}
+
 func (n *Node) Deps() []NodeID {
 	deps := []NodeID{}
 	for _, ni := range n.Inputs {
@@ -12,7 +12,22 @@ import (
 	"wantbuild.io/want/src/wantjob"
 )
 
+// ParallelExecLast executes the nodes needed by the last node in x, and returns its result.
+// Nodes which the last node does not depend on are not executed.
 func ParallelExecLast(jc wantjob.Ctx, src cadata.Getter, x DAG) (*wantjob.Result, error) {
+	x, _ = Prune(x, []NodeID{NodeID(len(x) - 1)})
+	results, err := ParallelExecAll(jc, src, x)
+	if err != nil {
+		return nil, err
+	}
+	ret := results[len(results)-1]
+	return &ret, nil
+}
+
+// ParallelExecAll executes every node in x, and returns the result of each.
+// Nodes are executed as soon as all of their inputs are available.
+// A node which fails does not cause ParallelExecAll to fail; its error, and the errors of the nodes which depend on it, are in their results.
+func ParallelExecAll(jc wantjob.Ctx, src cadata.Getter, x DAG) ([]wantjob.Result, error) {
 	results := make([]wantjob.Result, len(x))
 	unblocks := make([][]NodeID, len(x))
 	needCount := make([]int32, len(x))
@@ -38,6 +53,10 @@ func ParallelExecLast(jc wantjob.Ctx, src cadata.Getter, x DAG) (*wantjob.Result
 			outRef = node.Value
 			results[id] = *glfstasks.Success(*node.Value)
 		case node.IsDerived():
+			if res := upstreamFailure(node.Inputs, resolve); res != nil {
+				results[id] = *res
+				break
+			}
 			inputRef, err := PrepareInput(ctx, jc.Dst, union, node.Inputs, resolve)
 			if err != nil {
 				return err
@@ -80,6 +99,5 @@ func ParallelExecLast(jc wantjob.Ctx, src cadata.Getter, x DAG) (*wantjob.Result
 	if err := eg.Wait(); err != nil {
 		return nil, err
 	}
-	ret := results[len(results)-1]
-	return &ret, nil
+	return results, nil
 }
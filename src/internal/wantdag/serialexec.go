@@ -9,8 +9,9 @@ import (
 	"wantbuild.io/want/src/wantjob"
 )
 
+// SerialExecLast executes the nodes needed by the last node in x, one at a time, and returns its result.
 func SerialExecLast(jc wantjob.Ctx, s cadata.Getter, x DAG) (wantjob.Result, error) {
-	// TODO: lazy execution
+	x, _ = Prune(x, []NodeID{NodeID(len(x) - 1)})
 	nrs, err := SerialExecAll(jc, s, x)
 	if err != nil {
 		return wantjob.Result{}, err
@@ -18,7 +19,8 @@ func SerialExecLast(jc wantjob.Ctx, s cadata.Getter, x DAG) (wantjob.Result, err
 	return nrs[len(nrs)-1], nil
 }
 
-// ExecAll executes all nodes in the DAG, and returns the result of returning each.
+// SerialExecAll executes all nodes in the DAG, and returns the result of each.
+// Like ParallelExecAll, failed nodes are reported in their results.
 func SerialExecAll(jc wantjob.Ctx, s cadata.Getter, x DAG) ([]wantjob.Result, error) {
 	ctx := jc.Context
 	nodeStores := make([]cadata.Getter, len(x))
@@ -36,6 +38,11 @@ func SerialExecAll(jc wantjob.Ctx, s cadata.Getter, x DAG) ([]wantjob.Result, er
 			nodeStores[i] = s
 			outRef = n.Value
 		case n.IsDerived():
+			if res := upstreamFailure(n.Inputs, resolve); res != nil {
+				nodeResults[i] = *res
+				nodeStores[i] = s
+				break
+			}
 			input, err := PrepareInput(ctx, stores.Fork{W: scratch, R: union}, union, n.Inputs, resolve)
 			if err != nil {
 				return nil, err
@@ -16,6 +16,21 @@ import (
 
 type Resolver = func(NodeID) wantjob.Result
 
+// MaxResultSize is the maximum size of a node's result, as JSON.
+const MaxResultSize = 1 << 20
+
+// upstreamFailure returns a failed Result for a node, if any of its inputs failed, or nil if they all succeeded.
+// Nodes with failed inputs are not executed, but their failure does not stop independent nodes.
+func upstreamFailure(ins []NodeInput, getResult Resolver) *wantjob.Result {
+	for _, in := range ins {
+		res := getResult(in.Node)
+		if err := res.Err(); err != nil {
+			return wantjob.Result_ErrExec(fmt.Errorf("upstream node %d errored: %v", in.Node, err))
+		}
+	}
+	return nil
+}
+
 // PrepareInput prepares the input for a node.
 func PrepareInput(ctx context.Context, dst cadata.PostExister, src cadata.Getter, ins []NodeInput, getResult Resolver) (*glfs.Ref, error) {
 	ents := []glfs.TreeEntry{}
@@ -77,7 +92,7 @@ func GetNodeResults(ctx context.Context, s cadata.Getter, ref glfs.Ref) ([]wantj
 		if NodeID(i) != NodeID(n) {
 			return nil, fmt.Errorf("missing result for %d", n)
 		}
-		data, err := glfs.GetBlobBytes(ctx, s, ent.Ref, 1024)
+		data, err := glfs.GetBlobBytes(ctx, s, ent.Ref, MaxResultSize)
 		if err != nil {
 			return nil, err
 		}
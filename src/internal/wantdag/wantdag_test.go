@@ -1,12 +1,17 @@
 package wantdag
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"go.brendoncarroll.net/state/cadata"
 
+	"wantbuild.io/want/src/internal/glfstasks"
 	"wantbuild.io/want/src/internal/stores"
 	"wantbuild.io/want/src/internal/testutil"
+	"wantbuild.io/want/src/wantjob"
 )
 
 func TestDAGPostGet(t *testing.T) {
@@ -34,3 +39,147 @@ func TestDAGPostGet(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, x, y)
 }
+
+func TestDedupPrune(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+	b := NewBuilder(s)
+	ref := testutil.PostFS(t, s, nil)
+	mustDerived := func(op OpName, ins ...NodeInput) NodeID {
+		nid, err := b.Derived(ctx, op, ins)
+		require.NoError(t, err)
+		return nid
+	}
+	f1, err := b.Fact(ctx, s, ref)
+	require.NoError(t, err)
+	f2, err := b.Fact(ctx, s, ref)
+	require.NoError(t, err)
+	a1 := mustDerived("a", NodeInput{Node: f1})
+	a2 := mustDerived("a", NodeInput{Node: f2})
+	mustDerived("c", NodeInput{Name: "x", Node: a1}, NodeInput{Name: "y", Node: a2})
+	d := mustDerived("d", NodeInput{Node: a2})
+	x := b.Finish()
+
+	y, ids := Dedup(x)
+	require.Equal(t, DAG{
+		{Value: &ref},
+		{Op: "a", Inputs: []NodeInput{{Node: 0}}},
+		{Op: "c", Inputs: []NodeInput{{Name: "x", Node: 1}, {Name: "y", Node: 1}}},
+		{Op: "d", Inputs: []NodeInput{{Node: 1}}},
+	}, y)
+	require.Equal(t, []NodeID{0, 0, 1, 1, 2, 3}, ids)
+
+	z, roots := Prune(y, []NodeID{ids[d]})
+	require.Equal(t, DAG{y[0], y[1], y[3]}, z)
+	require.Equal(t, []NodeID{2}, roots)
+}
+
+func TestDemand(t *testing.T) {
+	d := DemandPaths("a/b", "c", "a/b/c", "/d/")
+	require.Equal(t, Demand{"a/b", "c", "d"}, d)
+	require.True(t, d.Contains("a/b/x"))
+	require.False(t, d.Contains("a"))
+	require.False(t, d.All())
+
+	require.Equal(t, Demand{"b"}, d.Within("a"))
+	require.Equal(t, DemandAll(), d.Within("c/x"))
+	require.Empty(t, d.Within("e"))
+	require.Equal(t, Demand{"x/a/b", "x/c", "x/d"}, d.Under("x"))
+	require.Equal(t, Demand{"x"}, DemandAll().Under("x"))
+
+	require.Equal(t, Demand{"a/b/c", "c"}, d.Intersect(DemandPaths("a/b/c", "c", "e")))
+	require.Equal(t, DemandAll(), d.Union(DemandAll()))
+}
+
+func TestNarrow(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+	b := NewBuilder(s)
+	ref := testutil.PostFS(t, s, nil)
+	mustDerived := func(op OpName, ins ...NodeInput) NodeID {
+		nid, err := b.Derived(ctx, op, ins)
+		require.NoError(t, err)
+		return nid
+	}
+	f, err := b.Fact(ctx, s, ref)
+	require.NoError(t, err)
+	big := mustDerived("big", NodeInput{Node: f})
+	small := mustDerived("small", NodeInput{Node: f})
+	pass := mustDerived("pass", NodeInput{Name: "big", Node: big}, NodeInput{Name: "small", Node: small})
+	pick := mustDerived("pickSmall", NodeInput{Node: pass})
+	mustDerived("unused", NodeInput{Node: big})
+	x := b.Finish()
+
+	var demands []Demand
+	narrowers := map[OpName]Narrower{
+		"pickSmall": func(ctx context.Context, src cadata.Getter, x DAG, node Node, demand Demand) ([]NodeInput, []Demand, error) {
+			return node.Inputs, []Demand{demand.Under("small/a")}, nil
+		},
+		"pass": func(ctx context.Context, src cadata.Getter, x DAG, node Node, demand Demand) ([]NodeInput, []Demand, error) {
+			demands = append(demands, demand)
+			var ins []NodeInput
+			var ds []Demand
+			for _, in := range node.Inputs {
+				if d := demand.Within(in.Name); len(d) > 0 {
+					ins = append(ins, in)
+					ds = append(ds, d)
+				}
+			}
+			return ins, ds, nil
+		},
+	}
+	y, ids, err := Narrow(ctx, s, x, []NodeID{pick}, narrowers)
+	require.NoError(t, err)
+	require.Equal(t, []Demand{{"small/a"}}, demands)
+	require.Equal(t, DAG{
+		{Value: &ref},
+		{Op: "small", Inputs: []NodeInput{{Node: 0}}},
+		{Op: "pass", Inputs: []NodeInput{{Name: "small", Node: 1}}},
+		{Op: "pickSmall", Inputs: []NodeInput{{Node: 2}}},
+	}, y)
+	require.Equal(t, []NodeID{3}, ids)
+	// x is not changed
+	require.Len(t, x[pass].Inputs, 2)
+}
+
+func TestExecAllFailure(t *testing.T) {
+	ctx := testutil.Context(t)
+	s := stores.NewMem()
+	sys := wantjob.NewMem(ctx, wantjob.BasicExecutor{
+		"fail": func(jc wantjob.Ctx, src cadata.Getter, data []byte) wantjob.Result {
+			return *wantjob.Result_ErrExec(errors.New("boom"))
+		},
+		"ok": func(jc wantjob.Ctx, src cadata.Getter, data []byte) wantjob.Result {
+			ref, err := glfstasks.ParseGLFSRef(data)
+			if err != nil {
+				return *wantjob.Result_ErrExec(err)
+			}
+			return *glfstasks.Success(*ref)
+		},
+	})
+	b := NewBuilder(s)
+	f, err := b.Fact(ctx, s, testutil.PostFS(t, s, nil))
+	require.NoError(t, err)
+	failed, err := b.Derived(ctx, "fail", []NodeInput{{Node: f}})
+	require.NoError(t, err)
+	downstream, err := b.Derived(ctx, "ok", []NodeInput{{Node: failed}})
+	require.NoError(t, err)
+	independent, err := b.Derived(ctx, "ok", []NodeInput{{Node: f}})
+	require.NoError(t, err)
+	x := b.Finish()
+
+	for name, exec := range map[string]func(wantjob.Ctx, cadata.Getter, DAG) ([]wantjob.Result, error){
+		"serial":   SerialExecAll,
+		"parallel": ParallelExecAll,
+	} {
+		t.Run(name, func(t *testing.T) {
+			jc := wantjob.Ctx{Context: ctx, System: sys, Dst: stores.NewMem()}
+			results, err := exec(jc, s, x)
+			require.NoError(t, err)
+			require.Len(t, results, len(x))
+			require.Error(t, results[failed].Err())
+			require.ErrorContains(t, results[downstream].Err(), "upstream node")
+			require.NoError(t, results[independent].Err())
+		})
+	}
+}
@@ -0,0 +1,6 @@
+-- Jobs which are executed without reading the cache do not write to it either,
+-- so that rerunning a task cannot replace its cached result.
+ALTER TABLE jobs ADD COLUMN write_cache INT NOT NULL DEFAULT 1;
+
+DROP INDEX idx_job_cache;
+CREATE INDEX idx_job_cache ON jobs (task) WHERE "state" = 3 AND errcode = 0 AND write_cache = 1;
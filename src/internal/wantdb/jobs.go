@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
 
 	"github.com/jmoiron/sqlx"
 	"go.brendoncarroll.net/state/cadata"
@@ -13,8 +14,12 @@ import (
 	"wantbuild.io/want/src/wantjob"
 )
 
-func CreateRootJob(tx *sqlx.Tx, task wantjob.Task) (wantjob.Idx, error) {
-	rowid, err := createJob(tx, task)
+// CreateRootJob creates a new job without a parent.
+// If readCache is true, and a successful job has already completed the same task, then
+// the new job is created in the DONE state with the cached result.
+// If readCache is false, then the job's result is never used as a cached result for the task.
+func CreateRootJob(tx *sqlx.Tx, task wantjob.Task, readCache bool) (wantjob.Idx, error) {
+	rowid, err := createJob(tx, task, readCache)
 	if err != nil {
 		return 0, err
 	}
@@ -22,7 +27,9 @@ func CreateRootJob(tx *sqlx.Tx, task wantjob.Task) (wantjob.Idx, error) {
 	return dbutil.GetTx[wantjob.Idx](tx, `INSERT INTO job_roots (job_row) VALUES (?) RETURNING idx`, rowid)
 }
 
-func CreateChildJob(tx *sqlx.Tx, parentID wantjob.JobID, task wantjob.Task) (wantjob.Idx, error) {
+// CreateChildJob creates a new job as a child of parentID.
+// readCache has the same meaning as in CreateRootJob
+func CreateChildJob(tx *sqlx.Tx, parentID wantjob.JobID, task wantjob.Task, readCache bool) (wantjob.Idx, error) {
 	parentRow, err := lookupJobRowID(tx, parentID)
 	if err != nil {
 		return 0, err
@@ -32,7 +39,7 @@ func CreateChildJob(tx *sqlx.Tx, parentID wantjob.JobID, task wantjob.Task) (wan
 		return 0, err
 	}
 	nextIdx--
-	childRow, err := createJob(tx, task)
+	childRow, err := createJob(tx, task, readCache)
 	if err != nil {
 		return 0, err
 	}
@@ -42,15 +49,20 @@ func CreateChildJob(tx *sqlx.Tx, parentID wantjob.JobID, task wantjob.Task) (wan
 	return nextIdx, nil
 }
 
-func createJob(tx *sqlx.Tx, task wantjob.Task) (int64, error) {
+func createJob(tx *sqlx.Tx, task wantjob.Task, readCache bool) (int64, error) {
 	taskID, err := ensureTask(tx, task)
 	if err != nil {
 		return 0, err
 	}
 
-	data, sid, err := cacheRead(tx, task.ID())
-	if err != nil {
-		return 0, err
+	var (
+		data []byte
+		sid  StoreID
+	)
+	if readCache {
+		if data, sid, err = cacheRead(tx, task.ID()); err != nil {
+			return 0, err
+		}
 	}
 	cacheHit := len(data) > 0
 	if !cacheHit {
@@ -61,7 +73,8 @@ func createJob(tx *sqlx.Tx, task wantjob.Task) (int64, error) {
 	}
 
 	now := tai64.Now()
-	rowid, err := dbutil.GetTx[int64](tx, `INSERT INTO jobs (task, store_id, created_at) VALUES (?, ?, ?) RETURNING rowid`, taskID, sid, now.Marshal())
+	// jobs which do not read from the cache do not write to it either.
+	rowid, err := dbutil.GetTx[int64](tx, `INSERT INTO jobs (task, store_id, created_at, write_cache) VALUES (?, ?, ?, ?) RETURNING rowid`, taskID, sid, now.Marshal(), readCache)
 	if err != nil {
 		return 0, err
 	}
@@ -119,7 +132,7 @@ func DropJob(tx *sqlx.Tx, jobid wantjob.JobID) error {
 		return err
 	}
 	for _, idx := range idxs {
-		childid := append(jobid, idx)
+		childid := append(slices.Clone(jobid), idx)
 		if err := DropJob(tx, childid); err != nil {
 			return err
 		}
@@ -128,10 +141,13 @@ func DropJob(tx *sqlx.Tx, jobid wantjob.JobID) error {
 	if err != nil {
 		return err
 	}
-	if _, err := tx.Exec(`DELETE FROM jobs WHERE rowid = ?`, rid); err != nil {
+	if _, err := tx.Exec(`DELETE FROM job_children WHERE parent = ? OR child = ?`, rid, rid); err != nil {
 		return err
 	}
-	if _, err := tx.Exec(`DELETE FROM job_roots WHERE jobrow = ?`, rid); err != nil {
+	if _, err := tx.Exec(`DELETE FROM job_roots WHERE job_row = ?`, rid); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM jobs WHERE rowid = ?`, rid); err != nil {
 		return err
 	}
 	return nil
@@ -167,7 +183,6 @@ func mkJobFromRow(row jobRow) (*wantjob.Job, error) {
 		endAt = &ea
 	}
 	return &wantjob.Job{
-		// TODO: GetTask
 		State:     row.State,
 		CreatedAt: createdAt,
 
@@ -185,7 +200,14 @@ func InspectJob(tx *sqlx.Tx, jobid wantjob.JobID) (*wantjob.Job, error) {
 	if err := tx.Get(&row, `SELECT task, state, created_at, errcode, res_data, end_at FROM jobs WHERE rowid = ?`, rowid); err != nil {
 		return nil, err
 	}
-	return mkJobFromRow(row)
+	j, err := mkJobFromRow(row)
+	if err != nil {
+		return nil, err
+	}
+	if j.Task, err = getTask(tx, row.TaskID); err != nil {
+		return nil, err
+	}
+	return j, nil
 }
 
 func ViewResult(tx *sqlx.Tx, jobid wantjob.JobID) (*wantjob.Result, StoreID, error) {
@@ -249,7 +271,7 @@ func cacheRead(tx *sqlx.Tx, taskID cadata.ID) ([]byte, StoreID, error) {
 		Store StoreID `db:"store_id"`
 	}
 	err := tx.Get(&row, `SELECT res_data, store_id FROM jobs
-		WHERE task = ? AND state = 3 AND errcode = 0
+		WHERE task = ? AND state = 3 AND errcode = 0 AND write_cache = 1
 		LIMIT 1`, taskID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
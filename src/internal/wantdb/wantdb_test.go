@@ -57,14 +57,31 @@ func TestCreateJobs(t *testing.T) {
 
 	require.NoError(t, dbutil.DoTx(ctx, db, func(tx *sqlx.Tx) error {
 		task := wantjob.Task{Op: "noop"}
-		idx, err := CreateRootJob(tx, task)
+		idx, err := CreateRootJob(tx, task, true)
 		require.NoError(t, err)
 		id := wantjob.JobID{idx}
 		for i := 0; i < 10; i++ {
-			idx, err := CreateChildJob(tx, id, task)
+			idx, err := CreateChildJob(tx, id, task, true)
 			require.NoError(t, err)
 			id = append(id, idx)
 		}
 		return nil
 	}))
 }
+
+func TestInspectJob(t *testing.T) {
+	ctx := testutil.Context(t)
+	db := NewMemory()
+	require.NoError(t, Setup(ctx, db))
+
+	require.NoError(t, dbutil.DoTx(ctx, db, func(tx *sqlx.Tx) error {
+		task := wantjob.Task{Op: "noop", Input: []byte("input")}
+		idx, err := CreateRootJob(tx, task, true)
+		require.NoError(t, err)
+		j, err := InspectJob(tx, wantjob.JobID{idx})
+		require.NoError(t, err)
+		require.Equal(t, task, j.Task)
+		require.Equal(t, wantjob.QUEUED, j.State)
+		return nil
+	}))
+}
@@ -0,0 +1,42 @@
+package wantfmt
+
+import (
+	"path"
+	"strings"
+
+	"github.com/google/go-jsonnet/formatter"
+)
+
+// JsonnetOptions are the options used to format Jsonnet files in a module.
+var JsonnetOptions = formatter.Options{
+	Indent:           4,
+	MaxBlankLines:    2,
+	StringStyle:      formatter.StringStyleDouble,
+	CommentStyle:     formatter.CommentStyleSlash,
+	UseImplicitPlus:  true,
+	PrettyFieldNames: true,
+	PadObjects:       true,
+}
+
+// IsJsonnetPath returns true if the file at p is a Jsonnet file, which is formatted by Jsonnet.
+func IsJsonnetPath(p string) bool {
+	if path.Base(p) == "WANT" {
+		return true
+	}
+	for _, ext := range []string{".want", ".wants", ".libsonnet", ".jsonnet"} {
+		if strings.HasSuffix(p, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Jsonnet formats the Jsonnet source in x.
+// The output only depends on the input, so formatting a file twice does not change it.
+func Jsonnet(p string, x []byte) ([]byte, error) {
+	y, err := formatter.Format(p, string(x), JsonnetOptions)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(y), nil
+}
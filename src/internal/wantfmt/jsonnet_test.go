@@ -0,0 +1,30 @@
+package wantfmt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJsonnet(t *testing.T) {
+	in := "local want = import '@want';\n{ a: want.blob('x'),\n  'b-c' : 1 }\n"
+	out, err := Jsonnet("a.want", []byte(in))
+	require.NoError(t, err)
+	require.Equal(t, "local want = import \"@want\";\n{\n    a: want.blob(\"x\"),\n    \"b-c\": 1,\n}\n", string(out))
+	// formatting is idempotent
+	out2, err := Jsonnet("a.want", out)
+	require.NoError(t, err)
+	require.Equal(t, string(out), string(out2))
+
+	_, err = Jsonnet("a.want", []byte("{"))
+	require.Error(t, err)
+}
+
+func TestIsJsonnetPath(t *testing.T) {
+	for _, p := range []string{"WANT", "a/WANT", "x.want", "x.wants", "x.libsonnet", "x.jsonnet"} {
+		require.True(t, IsJsonnetPath(p), p)
+	}
+	for _, p := range []string{"WANTS", "x.go", "x.json"} {
+		require.False(t, IsJsonnetPath(p), p)
+	}
+}
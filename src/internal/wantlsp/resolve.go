@@ -0,0 +1,244 @@
+package wantlsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+
+	"wantbuild.io/want/src/internal/wantc"
+)
+
+// moduleRoot returns the directory of the module containing the file at p.
+// That is the closest directory above p with a WANT file.
+// If there is no such directory, then the directory containing p is returned.
+func moduleRoot(p string) string {
+	dir := filepath.Dir(p)
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, wantc.WantFilename)); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+// resolveImport returns the path of the file imported as imp from the file at from.
+// Imports are resolved the same way as during compilation:
+// @name imports are looked up in the module's namespace, and other imports are relative to the module root,
+// unless they start with ./ or ../
+// Submodules are directories within the module, so they do not need any special handling.
+func (s *Server) resolveImport(ctx context.Context, from, imp string) (string, error) {
+	root := moduleRoot(from)
+	if after, ok := strings.CutPrefix(imp, "@"); ok {
+		name, rest, _ := strings.Cut(after, "/")
+		nsRoot, err := s.namespaceEntry(ctx, root, name)
+		if err != nil {
+			return "", err
+		}
+		if rest == "" {
+			return nsRoot, nil
+		}
+		return filepath.Join(nsRoot, filepath.FromSlash(rest)), nil
+	}
+	rel, err := filepath.Rel(root, from)
+	if err != nil {
+		return "", err
+	}
+	p := wantc.PathFrom(filepath.ToSlash(rel), imp)
+	return filepath.Join(root, filepath.FromSlash(p)), nil
+}
+
+// namespaceEntry returns the path of a local copy of the namespace entry name, in the module at root.
+func (s *Server) namespaceEntry(ctx context.Context, root, name string) (string, error) {
+	data, err := s.readFile(filepath.Join(root, wantc.WantFilename))
+	if err != nil {
+		return "", err
+	}
+	modCfg, err := wantc.ParseModuleConfig([]byte(data))
+	if err != nil {
+		return "", err
+	}
+	expr, exists := modCfg.Namespace[name]
+	if !exists {
+		return "", fmt.Errorf("no namespace entry for %s", name)
+	}
+	eid := wantc.NewExprID(expr)
+	s.mu.Lock()
+	p, exists := s.namespaces[eid]
+	s.mu.Unlock()
+	if exists {
+		return p, nil
+	}
+	p, err = s.cfg.Materialize(ctx, expr)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.namespaces[eid] = p
+	s.mu.Unlock()
+	return p, nil
+}
+
+// readFile returns the contents of the file at p.
+// Open documents are read from the editor, since they may not have been saved.
+func (s *Server) readFile(p string) (string, error) {
+	s.mu.Lock()
+	text, exists := s.docs[p]
+	s.mu.Unlock()
+	if exists {
+		return text, nil
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// member is a field of the object that a library evaluates to.
+type member struct {
+	Name string
+	// Params is nil if the member is not a function.
+	Params []string
+	Doc    string
+	// Line and Column are the location of the definition.  They start at 1.
+	Line, Column int
+}
+
+func (m member) signature() string {
+	if m.Params == nil {
+		return m.Name
+	}
+	return m.Name + "(" + strings.Join(m.Params, ", ") + ")"
+}
+
+// libMembers returns the members of the library at p.
+// Members which are defined as locals, like `blob :: blob`, are located at the local.
+func libMembers(p, text string) ([]member, error) {
+	node, prefix, err := parseFile(p, text)
+	if err != nil {
+		return nil, err
+	}
+	src := prefix + text
+	locals := map[ast.Identifier]ast.LocalBind{}
+	var obj *ast.DesugaredObject
+	for obj == nil {
+		switch x := node.(type) {
+		case *ast.Local:
+			for _, bind := range x.Binds {
+				locals[bind.Variable] = bind
+			}
+			node = x.Body
+		case *ast.Parens:
+			node = x.Inner
+		case *ast.DesugaredObject:
+			obj = x
+		default:
+			return nil, errors.New("library does not evaluate to an object")
+		}
+	}
+	for _, bind := range obj.Locals {
+		locals[bind.Variable] = bind
+	}
+	var ret []member
+	for _, field := range obj.Fields {
+		name, ok := field.Name.(*ast.LiteralString)
+		if !ok {
+			continue
+		}
+		m := member{Name: name.Value}
+		body, loc := field.Body, field.LocRange
+		for {
+			x, ok := body.(*ast.Local)
+			if !ok {
+				break
+			}
+			body = x.Body
+		}
+		if v, ok := body.(*ast.Var); ok {
+			if bind, exists := locals[v.Id]; exists {
+				body, loc = bind.Body, bind.LocRange
+				if !loc.IsSet() {
+					loc = *bind.Body.Loc()
+				}
+			}
+		}
+		if fn, ok := body.(*ast.Function); ok {
+			m.Params = []string{}
+			for _, param := range fn.Parameters {
+				s := string(param.Name)
+				if param.DefaultArg != nil {
+					s += "=" + sourceText(src, *param.DefaultArg.Loc())
+				}
+				m.Params = append(m.Params, s)
+			}
+		}
+		if loc.IsSet() {
+			m.Line, m.Column = unprefixed(prefix, loc.Begin)
+			m.Doc = docComment(text, m.Line)
+		}
+		ret = append(ret, m)
+	}
+	return ret, nil
+}
+
+// parseFile parses the Jsonnet file at p.
+// Files which have GROUND and DERIVED defined during compilation have them defined here too,
+// otherwise they would not pass static analysis.  The prefix is returned, so locations can be adjusted.
+func parseFile(p, text string) (ast.Node, string, error) {
+	var prefix string
+	if wantc.HasLocalPrefix(p) {
+		prefix = wantc.LocalPrefix(wantc.FQPath{Path: filepath.Base(p)})
+	}
+	node, err := jsonnet.SnippetToAST(p, prefix+text)
+	if err != nil {
+		return nil, "", err
+	}
+	return node, prefix, nil
+}
+
+// unprefixed returns the line and column of loc in the file, without the prefix added by parseFile.
+func unprefixed(prefix string, loc ast.Location) (line, col int) {
+	if loc.Line == 1 {
+		return loc.Line, loc.Column - len(prefix)
+	}
+	return loc.Line, loc.Column
+}
+
+// sourceText returns the text in src covered by loc.
+func sourceText(src string, loc ast.LocationRange) string {
+	if !loc.IsSet() {
+		return "..."
+	}
+	begin, end := byteOffset(src, loc.Begin.Line, loc.Begin.Column), byteOffset(src, loc.End.Line, loc.End.Column)
+	if begin < 0 || end < begin {
+		return "..."
+	}
+	return src[begin:end]
+}
+
+// docComment returns the comment on the lines directly above line.
+func docComment(text string, line int) string {
+	lines := strings.Split(text, "\n")
+	var doc []string
+	for i := line - 2; i >= 0 && i < len(lines); i-- {
+		l := strings.TrimSpace(lines[i])
+		after, ok := strings.CutPrefix(l, "//")
+		if !ok {
+			if after, ok = strings.CutPrefix(l, "#"); !ok {
+				break
+			}
+		}
+		doc = append([]string{strings.TrimPrefix(after, " ")}, doc...)
+	}
+	return strings.Join(doc, "\n")
+}
@@ -0,0 +1,472 @@
+// Package wantlsp implements a Language Server for Want modules.
+package wantlsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"wantbuild.io/want/src/internal/stringsets"
+	"wantbuild.io/want/src/internal/wantc"
+	"wantbuild.io/want/src/wantcfg"
+)
+
+type Config struct {
+	// Root is the directory containing the module being edited.
+	Root string
+	// Compile compiles the module, as it is saved on disk, and returns the targets.
+	// Compiler errors should be returned as a *wantc.Diagnostic.
+	Compile func(ctx context.Context) ([]wantc.Target, error)
+	// Materialize evaluates a namespace entry, and returns the path of a local copy of it.
+	Materialize func(ctx context.Context, x wantcfg.Expr) (string, error)
+}
+
+// Server is a Language Server for Want modules.
+// It provides completion, definitions and hover for the files in a module,
+// and reports compiler diagnostics whenever a file is saved.
+type Server struct {
+	cfg  Config
+	conn *conn
+	wg   sync.WaitGroup
+
+	mu sync.Mutex
+	// docs are the open documents, by path
+	docs       map[string]string
+	namespaces map[wantc.ExprID]string
+	// targets are from the last successful compile
+	targets []wantc.Target
+	// published are the URIs which have diagnostics
+	published map[string]bool
+	compiling bool
+	recompile bool
+	shutdown  bool
+}
+
+// Serve runs a Server reading requests from r and writing responses to w, until the client exits.
+func Serve(ctx context.Context, cfg Config, r io.Reader, w io.Writer) error {
+	s := &Server{
+		cfg:  cfg,
+		conn: newConn(r, w),
+
+		docs:       map[string]string{},
+		namespaces: map[wantc.ExprID]string{},
+		published:  map[string]bool{},
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer s.wg.Wait()
+	defer cancel()
+	for {
+		msg, err := s.conn.read()
+		if err != nil {
+			var rerr *rpcError
+			if errors.As(err, &rerr) {
+				if err := s.conn.reply(nil, nil, rerr); err != nil {
+					return err
+				}
+				continue
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if msg.Method == "" {
+			// a response, the server does not send any requests
+			continue
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		result, err := s.handle(ctx, msg.Method, msg.Params)
+		if msg.ID == nil {
+			continue
+		}
+		if err := s.conn.reply(msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync: TextDocumentSyncOptions{
+					OpenClose: true,
+					Change:    SyncFull,
+				},
+				CompletionProvider: CompletionOptions{TriggerCharacters: []string{"."}},
+				DefinitionProvider: true,
+				HoverProvider:      true,
+			},
+			ServerInfo: ServerInfo{Name: "want"},
+		}, nil
+	case "initialized":
+		s.compile(ctx)
+		return nil, nil
+	case "shutdown":
+		s.mu.Lock()
+		s.shutdown = true
+		s.mu.Unlock()
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var x DidOpenTextDocumentParams
+		return withParams(params, &x, func() (any, error) {
+			s.setDoc(x.TextDocument.URI, &x.TextDocument.Text)
+			return nil, nil
+		})
+	case "textDocument/didChange":
+		var x DidChangeTextDocumentParams
+		return withParams(params, &x, func() (any, error) {
+			if len(x.ContentChanges) > 0 {
+				s.setDoc(x.TextDocument.URI, &x.ContentChanges[len(x.ContentChanges)-1].Text)
+			}
+			return nil, nil
+		})
+	case "textDocument/didClose":
+		var x DidCloseTextDocumentParams
+		return withParams(params, &x, func() (any, error) {
+			s.setDoc(x.TextDocument.URI, nil)
+			return nil, nil
+		})
+	case "textDocument/didSave":
+		s.compile(ctx)
+		return nil, nil
+
+	case "textDocument/completion":
+		var x TextDocumentPositionParams
+		return withParams(params, &x, func() (any, error) {
+			return s.completion(ctx, x)
+		})
+	case "textDocument/definition":
+		var x TextDocumentPositionParams
+		return withParams(params, &x, func() (any, error) {
+			return s.definition(ctx, x)
+		})
+	case "textDocument/hover":
+		var x TextDocumentPositionParams
+		return withParams(params, &x, func() (any, error) {
+			return s.hover(ctx, x)
+		})
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", method)}
+	}
+}
+
+func withParams[T any](params json.RawMessage, x *T, fn func() (any, error)) (any, error) {
+	if err := json.Unmarshal(params, x); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return fn()
+}
+
+// setDoc sets the text of an open document, or forgets it if text is nil.
+func (s *Server) setDoc(uri string, text *string) {
+	p, ok := uriToPath(uri)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if text == nil {
+		delete(s.docs, p)
+	} else {
+		s.docs[p] = *text
+	}
+}
+
+// document returns the path and contents of the document at uri, and the offset of pos.
+func (s *Server) document(x TextDocumentPositionParams) (p, text string, off int, err error) {
+	p, ok := uriToPath(x.TextDocument.URI)
+	if !ok {
+		return "", "", 0, fmt.Errorf("unsupported uri %q", x.TextDocument.URI)
+	}
+	text, err = s.readFile(p)
+	if err != nil {
+		return "", "", 0, err
+	}
+	return p, text, offsetOf(text, x.Position), nil
+}
+
+// importedMembers returns the members of the library imported as target, in the document at p.
+func (s *Server) importedMembers(ctx context.Context, p, text, target string) (string, []member, error) {
+	imp, exists := importBindings(text)[target]
+	if !exists {
+		return "", nil, nil
+	}
+	libPath, err := s.resolveImport(ctx, p, imp)
+	if err != nil {
+		return "", nil, err
+	}
+	libText, err := s.readFile(libPath)
+	if err != nil {
+		return "", nil, err
+	}
+	members, err := libMembers(libPath, libText)
+	if err != nil {
+		return "", nil, err
+	}
+	return libPath, members, nil
+}
+
+func (s *Server) completion(ctx context.Context, x TextDocumentPositionParams) (*CompletionList, error) {
+	ret := &CompletionList{Items: []CompletionItem{}}
+	p, text, off, err := s.document(x)
+	if err != nil {
+		return nil, err
+	}
+	lineBegin := strings.LastIndexByte(text[:off], '\n') + 1
+	m := memberRefRe.FindStringSubmatch(text[lineBegin:off])
+	if m == nil {
+		return ret, nil
+	}
+	_, members, err := s.importedMembers(ctx, p, text, m[1])
+	if err != nil {
+		return nil, err
+	}
+	for _, mem := range members {
+		item := CompletionItem{
+			Label:  mem.Name,
+			Kind:   KindField,
+			Detail: mem.signature(),
+		}
+		if mem.Params != nil {
+			item.Kind = KindFunction
+		}
+		if mem.Doc != "" {
+			item.Documentation = &MarkupContent{Kind: "plaintext", Value: mem.Doc}
+		}
+		ret.Items = append(ret.Items, item)
+	}
+	return ret, nil
+}
+
+func (s *Server) definition(ctx context.Context, x TextDocumentPositionParams) ([]Location, error) {
+	p, text, off, err := s.document(x)
+	if err != nil {
+		return nil, err
+	}
+	// import "path"
+	if str, begin, ok := stringAt(text, off); ok {
+		if !isImportString(text, begin) {
+			return nil, nil
+		}
+		target, err := s.resolveImport(ctx, p, str)
+		if err != nil {
+			return nil, err
+		}
+		return []Location{{URI: pathToURI(target)}}, nil
+	}
+	target, name := refAt(text, off)
+	switch {
+	case name == "":
+		return nil, nil
+	case target != "":
+		// lib.member
+		libPath, members, err := s.importedMembers(ctx, p, text, target)
+		if err != nil {
+			return nil, err
+		}
+		i := slices.IndexFunc(members, func(m member) bool { return m.Name == name })
+		if i < 0 {
+			return nil, nil
+		}
+		libText, err := s.readFile(libPath)
+		if err != nil {
+			return nil, err
+		}
+		pos := lspPosition(libText, members[i].Line, members[i].Column)
+		return []Location{{URI: pathToURI(libPath), Range: Range{Start: pos, End: pos}}}, nil
+	default:
+		// a local in the same file
+		boff, ok := localBinding(text, off, name)
+		if !ok {
+			return nil, nil
+		}
+		line, col := lineCol(text, boff)
+		pos := lspPosition(text, line, col)
+		return []Location{{URI: x.TextDocument.URI, Range: Range{Start: pos, End: pos}}}, nil
+	}
+}
+
+func (s *Server) hover(ctx context.Context, x TextDocumentPositionParams) (*Hover, error) {
+	p, text, off, err := s.document(x)
+	if err != nil {
+		return nil, err
+	}
+	if str, begin, ok := stringAt(text, off); ok {
+		if isImportString(text, begin) {
+			return nil, nil
+		}
+		return s.hoverPath(p, str), nil
+	}
+	target, name := refAt(text, off)
+	if target == "" || name == "" {
+		return nil, nil
+	}
+	_, members, err := s.importedMembers(ctx, p, text, target)
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(members, func(m member) bool { return m.Name == name })
+	if i < 0 {
+		return nil, nil
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "```jsonnet\n%s.%s\n```\n", target, members[i].signature())
+	if members[i].Doc != "" {
+		fmt.Fprintf(&sb, "\n%s\n", members[i].Doc)
+	}
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: sb.String()}}, nil
+}
+
+// hoverPath describes the targets which produce the path x, in the file at p.
+// The targets are from the last successful compile.
+func (s *Server) hoverPath(p, x string) *Hover {
+	rel, err := filepath.Rel(s.cfg.Root, p)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+	target := wantc.PathFrom(filepath.ToSlash(rel), x)
+	dir := stringsets.Prefix(target + "/")
+	s.mu.Lock()
+	targets := s.targets
+	s.mu.Unlock()
+	var sb strings.Builder
+	for _, t := range targets {
+		set := wantc.SetFromQuery(t.DefinedIn, t.To)
+		if !set.Contains(target) && !stringsets.Intersects(set, dir) {
+			continue
+		}
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "`%s` is produced by:\n", target)
+		}
+		if t.IsStatement {
+			fmt.Fprintf(&sb, "- `%s` statement %d, outputs to `%v`\n", t.DefinedIn, t.DefinedNum, set)
+		} else {
+			fmt.Fprintf(&sb, "- `%s`\n", t.DefinedIn)
+		}
+	}
+	if sb.Len() == 0 {
+		return nil
+	}
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: sb.String()}}
+}
+
+// compile compiles the module in the background, and publishes the diagnostics.
+// If a compile is already running, then another one is run after it.
+func (s *Server) compile(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shutdown {
+		return
+	}
+	if s.compiling {
+		s.recompile = true
+		return
+	}
+	s.compiling = true
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			targets, err := s.cfg.Compile(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			s.publish(targets, err)
+			s.mu.Lock()
+			if !s.recompile {
+				s.compiling = false
+				s.mu.Unlock()
+				return
+			}
+			s.recompile = false
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// publish sends the diagnostics from a compile to the client.
+// Files which no longer have any diagnostics have them cleared.
+func (s *Server) publish(targets []wantc.Target, err error) {
+	byURI := map[string][]Diagnostic{}
+	var diag *wantc.Diagnostic
+	switch {
+	case err == nil:
+		s.mu.Lock()
+		s.targets = targets
+		s.mu.Unlock()
+	case errors.As(err, &diag):
+		uri, d := s.convertDiagnostic(*diag)
+		byURI[uri] = append(byURI[uri], d)
+	default:
+		// the error is not from a source file, so report it on the module
+		uri := pathToURI(filepath.Join(s.cfg.Root, wantc.WantFilename))
+		byURI[uri] = append(byURI[uri], Diagnostic{Severity: SeverityError, Source: "want", Message: err.Error()})
+	}
+	s.mu.Lock()
+	for uri := range s.published {
+		if _, exists := byURI[uri]; !exists {
+			byURI[uri] = []Diagnostic{}
+		}
+	}
+	s.published = map[string]bool{}
+	for uri, ds := range byURI {
+		if len(ds) > 0 {
+			s.published[uri] = true
+		}
+	}
+	s.mu.Unlock()
+	for uri, ds := range byURI {
+		if err := s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: ds}); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) convertDiagnostic(d wantc.Diagnostic) (string, Diagnostic) {
+	msg := d.Message
+	if d.StmtNum != nil {
+		msg = fmt.Sprintf("statement %d: %s", *d.StmtNum, msg)
+	}
+	pos := d.Pos
+	if pos.Module != "" {
+		// the error is in another module, which the client cannot open.
+		msg = fmt.Sprintf("%v: %s", pos, msg)
+		pos = wantc.Position{Path: wantc.WantFilename}
+	}
+	uri, rng := s.convertPosition(pos)
+	ret := Diagnostic{
+		Range:    rng,
+		Severity: SeverityError,
+		Source:   "want",
+		Message:  msg,
+	}
+	for _, r := range d.Related {
+		if r.Pos.Module != "" {
+			continue
+		}
+		uri, rng := s.convertPosition(r.Pos)
+		ret.RelatedInformation = append(ret.RelatedInformation, DiagnosticRelatedInformation{
+			Location: Location{URI: uri, Range: rng},
+			Message:  r.Message,
+		})
+	}
+	return uri, ret
+}
+
+func (s *Server) convertPosition(pos wantc.Position) (string, Range) {
+	p := filepath.Join(s.cfg.Root, filepath.FromSlash(pos.Path))
+	text, _ := s.readFile(p)
+	start := lspPosition(text, pos.Line, pos.Column)
+	return pathToURI(p), Range{Start: start, End: start}
+}
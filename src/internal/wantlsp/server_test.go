@@ -0,0 +1,237 @@
+package wantlsp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"wantbuild.io/want/src/internal/testutil"
+	"wantbuild.io/want/src/internal/wantc"
+	"wantbuild.io/want/src/wantcfg"
+)
+
+func TestLibMembers(t *testing.T) {
+	members, err := libMembers("want.libsonnet", wantc.LibWant())
+	require.NoError(t, err)
+	byName := map[string]member{}
+	for _, m := range members {
+		byName[m.Name] = m
+	}
+	require.Contains(t, byName, "blob")
+	require.Equal(t, "input(to, from, mode=\"777\")", byName["input"].signature())
+	require.Equal(t, "input prepares an input to a computation.\nto is the path in the input tree.\nfrom is the value to place in the input tree.", byName["input"].Doc)
+	require.Equal(t, "input(", strings.Split(wantc.LibWant(), "\n")[byName["input"].Line-1][byName["input"].Column-1:][:6])
+}
+
+func TestServer(t *testing.T) {
+	ctx := testutil.Context(t)
+	root := t.TempDir()
+	libPath := filepath.Join(t.TempDir(), "want.libsonnet")
+	require.NoError(t, os.WriteFile(libPath, []byte(wantc.LibWant()), 0o644))
+	writeFile(t, root, "WANT", `{namespace: {want: {blob: importstr "@want"}}}`)
+	writeFile(t, root, "lib.libsonnet", "{\n    // x is a number\n    x :: 1,\n}\n")
+	mainText := strings.Join([]string{
+		`local want = import "@want";`,
+		`local lib = import "./lib.libsonnet";`,
+		`local out = want.selectFile(DERIVED, "gen/out.txt");`,
+		`want.blob(lib.x)`,
+	}, "\n")
+	writeFile(t, root, "main.want", mainText)
+
+	compiled := make(chan struct{}, 10)
+	var compileErr error
+	cfg := Config{
+		Root: root,
+		Compile: func(ctx context.Context) ([]wantc.Target, error) {
+			defer func() { compiled <- struct{}{} }()
+			if compileErr != nil {
+				return nil, compileErr
+			}
+			unit := "gen/out.txt"
+			return []wantc.Target{
+				{DefinedIn: "gen.wants", IsStatement: true, DefinedNum: 2, To: wantcfg.PathSet{Unit: &unit}},
+			}, nil
+		},
+		Materialize: func(ctx context.Context, x wantcfg.Expr) (string, error) {
+			return libPath, nil
+		},
+	}
+	cl := newTestClient(t, ctx, cfg)
+	var initRes InitializeResult
+	cl.call("initialize", InitializeParams{RootURI: pathToURI(root)}, &initRes)
+	require.True(t, initRes.Capabilities.HoverProvider)
+	cl.notify("initialized", struct{}{})
+	<-compiled
+
+	mainURI := pathToURI(filepath.Join(root, "main.want"))
+	cl.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: mainURI, Text: mainText}})
+	at := func(line, char int) TextDocumentPositionParams {
+		return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: mainURI}, Position: Position{Line: line, Character: char}}
+	}
+
+	t.Run("Completion", func(t *testing.T) {
+		// unsaved changes are used
+		text := mainText + "\nwant.sel"
+		cl.notify("textDocument/didChange", DidChangeTextDocumentParams{
+			TextDocument:   TextDocumentIdentifier{URI: mainURI},
+			ContentChanges: []TextDocumentContentChangeEvent{{Text: text}},
+		})
+		var res CompletionList
+		cl.call("textDocument/completion", at(4, 8), &res)
+		var labels []string
+		for _, item := range res.Items {
+			labels = append(labels, item.Label)
+		}
+		require.Contains(t, labels, "selectFile")
+		require.Contains(t, labels, "importGit")
+	})
+	t.Run("DefinitionImport", func(t *testing.T) {
+		var res []Location
+		cl.call("textDocument/definition", at(1, 25), &res)
+		require.Equal(t, []Location{{URI: pathToURI(filepath.Join(root, "lib.libsonnet"))}}, res)
+
+		cl.call("textDocument/definition", at(0, 22), &res)
+		require.Equal(t, []Location{{URI: pathToURI(libPath)}}, res)
+	})
+	t.Run("DefinitionMember", func(t *testing.T) {
+		var res []Location
+		cl.call("textDocument/definition", at(3, 14), &res)
+		require.Len(t, res, 1)
+		require.Equal(t, pathToURI(filepath.Join(root, "lib.libsonnet")), res[0].URI)
+		require.Equal(t, Position{Line: 2, Character: 4}, res[0].Range.Start)
+
+		cl.call("textDocument/definition", at(3, 7), &res)
+		require.Len(t, res, 1)
+		require.Equal(t, pathToURI(libPath), res[0].URI)
+	})
+	t.Run("DefinitionLocal", func(t *testing.T) {
+		var res []Location
+		cl.call("textDocument/definition", at(3, 12), &res)
+		require.Equal(t, []Location{{URI: mainURI, Range: Range{Start: Position{Line: 1, Character: 6}, End: Position{Line: 1, Character: 6}}}}, res)
+	})
+	t.Run("Hover", func(t *testing.T) {
+		var res Hover
+		cl.call("textDocument/hover", at(2, 42), &res)
+		require.Contains(t, res.Contents.Value, "`gen.wants` statement 2")
+
+		cl.call("textDocument/hover", at(3, 7), &res)
+		require.Contains(t, res.Contents.Value, "want.blob(contents)")
+		require.Contains(t, res.Contents.Value, "blob evaluates to a blob literal containing contents")
+	})
+	t.Run("Diagnostics", func(t *testing.T) {
+		stmtNum := 1
+		compileErr = &wantc.Diagnostic{
+			Pos:     wantc.Position{Path: "main.want", Line: 4, Column: 11},
+			StmtNum: &stmtNum,
+			Message: "bad thing",
+			Related: []wantc.Related{{Pos: wantc.Position{Path: "lib.libsonnet", Line: 3, Column: 5}, Message: "called from here"}},
+		}
+		cl.notify("textDocument/didSave", DidSaveTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: mainURI}})
+		var params PublishDiagnosticsParams
+		cl.receive("textDocument/publishDiagnostics", &params)
+		require.Equal(t, mainURI, params.URI)
+		require.Len(t, params.Diagnostics, 1)
+		d := params.Diagnostics[0]
+		require.Equal(t, "statement 1: bad thing", d.Message)
+		require.Equal(t, Position{Line: 3, Character: 10}, d.Range.Start)
+		require.Len(t, d.RelatedInformation, 1)
+		require.Equal(t, Position{Line: 2, Character: 4}, d.RelatedInformation[0].Location.Range.Start)
+		<-compiled
+
+		// fixing the error clears the diagnostics
+		compileErr = nil
+		cl.notify("textDocument/didSave", DidSaveTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: mainURI}})
+		cl.receive("textDocument/publishDiagnostics", &params)
+		require.Equal(t, mainURI, params.URI)
+		require.Empty(t, params.Diagnostics)
+		<-compiled
+	})
+
+	cl.call("shutdown", nil, nil)
+	cl.notify("exit", nil)
+	require.NoError(t, <-cl.done)
+}
+
+func TestPositions(t *testing.T) {
+	text := "ab\n\tαβ = 1\n"
+	require.Equal(t, 6, offsetOf(text, Position{Line: 1, Character: 2}))
+	require.Equal(t, Position{Line: 1, Character: 2}, lspPosition(text, 2, 4))
+	require.Equal(t, 2, byteOffset(text, 1, 10))
+	require.Equal(t, -1, byteOffset(text, 4, 1))
+}
+
+func writeFile(t testing.TB, dir, p, data string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, p), []byte(data), 0o644))
+}
+
+type testClient struct {
+	t    testing.TB
+	conn *conn
+	done chan error
+	next int
+}
+
+func newTestClient(t testing.TB, ctx context.Context, cfg Config) *testClient {
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, cfg, sr, sw)
+		sw.Close()
+	}()
+	t.Cleanup(func() {
+		cw.Close()
+		cr.Close()
+	})
+	return &testClient{t: t, conn: newConn(cr, cw), done: done}
+}
+
+func (c *testClient) call(method string, params, result any) {
+	c.next++
+	id := json.RawMessage(strings.TrimSpace(string(mustMarshal(c.t, c.next))))
+	require.NoError(c.t, c.conn.write(message{ID: &id, Method: method, Params: mustMarshal(c.t, params)}))
+	for {
+		msg, err := c.conn.read()
+		require.NoError(c.t, err)
+		if msg.ID == nil {
+			// a notification, which this call is not waiting for
+			continue
+		}
+		require.Equal(c.t, string(id), string(*msg.ID))
+		if msg.Error != nil {
+			require.NoError(c.t, msg.Error)
+		}
+		if result != nil {
+			require.NoError(c.t, json.Unmarshal(msg.Result, result))
+		}
+		return
+	}
+}
+
+func (c *testClient) notify(method string, params any) {
+	require.NoError(c.t, c.conn.notify(method, params))
+}
+
+// receive waits for a notification from the server.
+func (c *testClient) receive(method string, params any) {
+	for {
+		msg, err := c.conn.read()
+		require.NoError(c.t, err)
+		if msg.Method == method {
+			require.NoError(c.t, json.Unmarshal(msg.Params, params))
+			return
+		}
+	}
+}
+
+func mustMarshal(t testing.TB, x any) json.RawMessage {
+	data, err := json.Marshal(x)
+	require.NoError(t, err)
+	return data
+}
@@ -0,0 +1,188 @@
+package wantlsp
+
+import (
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+func pathToURI(p string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(p)}
+	return u.String()
+}
+
+func uriToPath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
+
+// byteOffset returns the offset in text of a 1-based line and column, where the column is in bytes.
+// It returns -1 if the line does not exist.
+func byteOffset(text string, line, col int) int {
+	off := 0
+	for i := 1; i < line; i++ {
+		j := strings.IndexByte(text[off:], '\n')
+		if j < 0 {
+			return -1
+		}
+		off += j + 1
+	}
+	end := len(text)
+	if j := strings.IndexByte(text[off:], '\n'); j >= 0 {
+		end = off + j
+	}
+	return min(off+max(col-1, 0), end)
+}
+
+// offsetOf returns the byte offset in text of an LSP position.
+func offsetOf(text string, pos Position) int {
+	off := byteOffset(text, pos.Line+1, 1)
+	if off < 0 {
+		return len(text)
+	}
+	units := 0
+	for i, r := range text[off:] {
+		if units >= pos.Character || r == '\n' {
+			return off + i
+		}
+		units += utf16.RuneLen(r)
+	}
+	return len(text)
+}
+
+// lspPosition converts a 1-based line and byte column into an LSP position.
+func lspPosition(text string, line, col int) Position {
+	if line < 1 {
+		return Position{}
+	}
+	pos := Position{Line: line - 1}
+	off := byteOffset(text, line, 1)
+	if off < 0 {
+		return pos
+	}
+	end := byteOffset(text, line, col)
+	for _, r := range text[off:end] {
+		pos.Character += utf16.RuneLen(r)
+	}
+	return pos
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
+
+// refAt returns the identifier at off, and the identifier it is a field of, if it is written as target.name
+func refAt(text string, off int) (target, name string) {
+	begin, end := off, off
+	for begin > 0 && isIdentByte(text[begin-1]) {
+		begin--
+	}
+	for end < len(text) && isIdentByte(text[end]) {
+		end++
+	}
+	name = text[begin:end]
+	if begin > 0 && text[begin-1] == '.' {
+		tend := begin - 1
+		tbegin := tend
+		for tbegin > 0 && isIdentByte(text[tbegin-1]) {
+			tbegin--
+		}
+		target = text[tbegin:tend]
+	}
+	return target, name
+}
+
+// stringAt returns the contents of the string literal containing off, and the offset where the literal begins.
+// Only single line strings are considered.
+func stringAt(text string, off int) (value string, begin int, ok bool) {
+	lineBegin := strings.LastIndexByte(text[:off], '\n') + 1
+	lineEnd := len(text)
+	if i := strings.IndexByte(text[off:], '\n'); i >= 0 {
+		lineEnd = off + i
+	}
+	var quote byte
+	var start int
+	for i := lineBegin; i < lineEnd; i++ {
+		c := text[i]
+		switch {
+		case quote == 0 && (c == '"' || c == '\''):
+			quote, start = c, i
+		case quote == 0 && (strings.HasPrefix(text[i:], "//") || c == '#'):
+			return "", 0, false
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			if start < off && off <= i {
+				return unescape(text[start+1 : i]), start, true
+			}
+			quote = 0
+		}
+	}
+	return "", 0, false
+}
+
+func unescape(x string) string {
+	var sb strings.Builder
+	for i := 0; i < len(x); i++ {
+		if x[i] == '\\' && i+1 < len(x) {
+			i++
+		}
+		sb.WriteByte(x[i])
+	}
+	return sb.String()
+}
+
+// isImportString returns true if the string literal beginning at off is the path of an import.
+func isImportString(text string, off int) bool {
+	before := strings.TrimRightFunc(text[:off], func(r rune) bool {
+		return r == ' ' || r == '\t'
+	})
+	for _, kw := range []string{"import", "importstr", "importbin"} {
+		if rest, ok := strings.CutSuffix(before, kw); ok {
+			if rest == "" || !isIdentByte(rest[len(rest)-1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var importBindingRe = regexp.MustCompile(`\blocal\s+([A-Za-z_][A-Za-z0-9_]*)\s*=\s*import\s+(?:"((?:[^"\\]|\\.)*)"|'((?:[^'\\]|\\.)*)')`)
+
+// importBindings returns the locals in text which are bound directly to an import, and the path they import.
+func importBindings(text string) map[string]string {
+	ret := map[string]string{}
+	for _, m := range importBindingRe.FindAllStringSubmatch(text, -1) {
+		ret[m[1]] = unescape(m[2] + m[3])
+	}
+	return ret
+}
+
+// localBinding returns the offset of the closest local binding of name before off.
+func localBinding(text string, off int, name string) (int, bool) {
+	re, err := regexp.Compile(`\blocal\s+` + regexp.QuoteMeta(name) + `\b`)
+	if err != nil {
+		return 0, false
+	}
+	ms := re.FindAllStringIndex(text[:off], -1)
+	if len(ms) == 0 {
+		return 0, false
+	}
+	m := ms[len(ms)-1]
+	return m[1] - len(name), true
+}
+
+// memberRefRe matches a field access which is being typed at the end of a line.
+var memberRefRe = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\.([A-Za-z0-9_]*)$`)
+
+// lineCol returns the 1-based line and byte column of off.
+func lineCol(text string, off int) (line, col int) {
+	line = strings.Count(text[:off], "\n") + 1
+	col = off - (strings.LastIndexByte(text[:off], '\n') + 1) + 1
+	return line, col
+}
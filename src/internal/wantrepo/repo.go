@@ -2,9 +2,14 @@ package wantrepo
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"blobcache.io/glfs"
 	"go.brendoncarroll.net/state/cadata"
@@ -62,6 +67,7 @@ type Repo struct {
 	rawConfig string
 	config    wantcfg.ModuleConfig
 	ignoreSet stringsets.Set
+	params    map[string]any
 }
 
 func (r *Repo) RawConfig() string {
@@ -72,6 +78,40 @@ func (r *Repo) Config() wantcfg.ModuleConfig {
 	return r.config
 }
 
+// Profile returns the profile called name from the module config.
+func (r *Repo) Profile(name string) (*wantcfg.Profile, error) {
+	prof, exists := r.config.Profiles[name]
+	if !exists {
+		names := slices.Sorted(maps.Keys(r.config.Profiles))
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no profile %q: the module does not declare any profiles", name)
+		}
+		return nil, fmt.Errorf("no profile %q: the module declares %s", name, strings.Join(names, ", "))
+	}
+	return &prof, nil
+}
+
+// ProfileExport returns the path in the build output which the profile called name exports,
+// and the path in the repo which it is exported to.
+// The destination must be ignored by the module, so that exporting cannot change the module's source.
+func (r *Repo) ProfileExport(name string) (from, dst string, _ error) {
+	prof, err := r.Profile(name)
+	if err != nil {
+		return "", "", err
+	}
+	from = wantc.BoundingPrefix(prof.Query)
+	if prof.Export == "" {
+		if !stringsets.Superset(r.ignoreSet, wantc.SetFromQuery("", prof.Query)) {
+			return "", "", fmt.Errorf("can only export profile %q to its own paths if they are ignored in the module config", name)
+		}
+		return from, from, nil
+	}
+	if !stringsets.Superset(r.ignoreSet, wantc.SetFromQuery("", wantcfg.DirPath(prof.Export))) {
+		return "", "", fmt.Errorf("can only export profile %q to %s if it is ignored in the module config", name, prof.Export)
+	}
+	return from, prof.Export, nil
+}
+
 func (r *Repo) RootPath() string {
 	return r.dir
 }
@@ -80,10 +120,46 @@ func (r *Repo) PathFilter(x string) bool {
 	return !r.ignoreSet.Contains(x)
 }
 
+// WalkFiles calls fn with the path of each regular file in the repo under the prefix p.
+// Ignored paths and the .git directory are skipped.
+func (r *Repo) WalkFiles(p string, fn func(p string) error) error {
+	return filepath.WalkDir(filepath.Join(r.dir, filepath.FromSlash(p)), func(fp string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(r.dir, fp)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && (d.Name() == ".git" || !r.PathFilter(rel)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return fn(rel)
+	})
+}
+
 func (r *Repo) Metadata() map[string]any {
 	return map[string]any{}
 }
 
+// Params returns the build parameters set for builds of the repo.
+func (r *Repo) Params() map[string]any {
+	return r.params
+}
+
+// SetParams sets the build parameters for builds of the repo.
+// They replace the defaults declared in the module config.
+func (r *Repo) SetParams(params map[string]any) {
+	r.params = params
+}
+
 // Import imports a filesystem from the Repo
 func (repo *Repo) Import(ctx context.Context, dst cadata.PostExister, p string) (*glfs.Ref, error) {
 	imp := glfsport.Importer{
@@ -1,9 +1,13 @@
 package wantrepo
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"wantbuild.io/want/src/wantcfg"
 )
 
 func TestInitOpen(t *testing.T) {
@@ -23,3 +27,55 @@ func TestInitOpen(t *testing.T) {
 	require.NoError(t, err)
 	t.Log(r.RawConfig())
 }
+
+func TestProfiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "WANT"), []byte(`local want = import "@want";
+	{
+		ignore: want.union([want.dirPath(".git"), want.dirPath("out")]),
+		profiles: {
+			site: {query: want.dirPath("site"), export: "out/site"},
+			gen: {query: want.dirPath("out/gen")},
+			src: {query: want.dirPath("src")},
+			bad: {query: want.dirPath("site"), export: "site2"},
+		},
+	}`), 0o644))
+	r, err := Open(dir)
+	require.NoError(t, err)
+
+	prof, err := r.Profile("site")
+	require.NoError(t, err)
+	require.Equal(t, wantcfg.DirPath("site"), prof.Query)
+	_, err = r.Profile("docs")
+	require.EqualError(t, err, `no profile "docs": the module declares bad, gen, site, src`)
+
+	tcs := []struct {
+		Name      string
+		From, Dst string
+		Err       string
+	}{
+		{Name: "site", From: "site", Dst: "out/site"},
+		{Name: "gen", From: "out/gen", Dst: "out/gen"},
+		{Name: "src", Err: `can only export profile "src" to its own paths if they are ignored in the module config`},
+		{Name: "bad", Err: `can only export profile "bad" to site2 if it is ignored in the module config`},
+		{Name: "docs", Err: `no profile "docs": the module declares bad, gen, site, src`},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			from, dst, err := r.ProfileExport(tc.Name)
+			if tc.Err != "" {
+				require.EqualError(t, err, tc.Err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.From, from)
+			require.Equal(t, tc.Dst, dst)
+		})
+	}
+
+	// profile queries are checked when the module is opened
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "WANT"), []byte(`local want = import "@want";
+	{profiles: {site: {query: want.glob("site/[]")}}}`), 0o644))
+	_, err = Open(dir)
+	require.ErrorContains(t, err, `profile "site"`)
+}
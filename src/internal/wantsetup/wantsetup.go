@@ -55,7 +55,7 @@ func Install(ctx context.Context, jsys wantjob.System, outDir string, snippet st
 func NewExecutor() wantjob.MultiExecutor {
 	return wantjob.MultiExecutor{
 		"dag":    dagops.Executor{},
-		"import": importops.NewExecutor(),
+		"import": importops.NewExecutor(importops.Config{}),
 		"glfs":   glfsops.Executor{},
 	}
 }
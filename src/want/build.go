@@ -28,6 +28,12 @@ type BuildResult struct {
 	Targets       []Target
 	TargetResults []wantjob.Result
 	OutputRoot    *glfs.Ref
+	// Query selects the paths which were built.
+	Query wantcfg.PathSet
+	// Job is the root job which performed the build, in the job system it was built with.
+	Job wantjob.Idx
+	// DAG is the plan's DAG, which contains the node for each target.
+	DAG glfs.Ref
 
 	// TODO: remove
 	Store cadata.Getter
@@ -39,7 +45,7 @@ func Build(ctx context.Context, jobs wantjob.System, src cadata.Getter, bt Build
 	if err != nil {
 		return nil, err
 	}
-	outRef, outStore, err := glfstasks.Do(ctx, jobs, stores.Union{src, scratch}, joinOpName("want", wantops.OpBuild), *btRef)
+	job, outRef, outStore, err := wantops.DoJob(ctx, jobs, stores.Union{src, scratch}, joinOpName("want", wantops.OpBuild), *btRef)
 	if err != nil {
 		return nil, err
 	}
@@ -49,47 +55,120 @@ func Build(ctx context.Context, jobs wantjob.System, src cadata.Getter, bt Build
 	}
 	return &BuildResult{
 		Source:        bt.Main,
+		Query:         bt.Query,
 		Targets:       br.Targets,
 		TargetResults: br.TargetResults,
 		OutputRoot:    br.Output,
+		Job:           job,
+		DAG:           br.Plan.DAG,
 
 		Store: outStore,
 	}, nil
 }
 
 func (sys *System) Build(ctx context.Context, repo *wantrepo.Repo, query wantcfg.PathSet) (*BuildResult, error) {
-	afid, err := sys.Import(ctx, repo)
+	return sys.build(ctx, sys.jobs, repo, query)
+}
+
+// BuildProfile builds the profile called name in the module config.
+// The output of the result contains exactly the paths in the profile's query.
+func (sys *System) BuildProfile(ctx context.Context, repo *wantrepo.Repo, name string) (*BuildResult, error) {
+	prof, err := repo.Profile(name)
 	if err != nil {
 		return nil, err
 	}
-	af, err := sys.ViewArtifact(ctx, *afid)
+	res, err := sys.Build(ctx, repo, prof.Query)
 	if err != nil {
 		return nil, err
 	}
-	root, err := af.GLFS()
+	if res.OutputRoot != nil {
+		scratch := stores.NewMem()
+		out, err := wantc.Select(ctx, scratch, res.Store, *res.OutputRoot, prof.Query)
+		if err != nil {
+			return nil, err
+		}
+		res.OutputRoot = out
+		res.Store = stores.Union{res.Store, scratch}
+	}
+	return res, nil
+}
+
+func (sys *System) build(ctx context.Context, jobs wantjob.System, repo *wantrepo.Repo, query wantcfg.PathSet) (*BuildResult, error) {
+	bt, src, err := sys.buildTask(ctx, repo, query)
 	if err != nil {
 		return nil, err
 	}
-	return Build(ctx, sys.jobs, af.Store, BuildTask{
+	return Build(ctx, jobs, src, *bt)
+}
+
+// buildTask imports the module in repo, and returns a BuildTask for the paths in query, along with a store containing the module.
+func (sys *System) buildTask(ctx context.Context, repo *wantrepo.Repo, query wantcfg.PathSet) (*BuildTask, cadata.Getter, error) {
+	afid, err := sys.Import(ctx, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	af, err := sys.ViewArtifact(ctx, *afid)
+	if err != nil {
+		return nil, nil, err
+	}
+	root, err := af.GLFS()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &BuildTask{
 		Main:     *root,
 		Metadata: repo.Metadata(),
+		Params:   repo.Params(),
 		Query:    query,
-	})
+	}, af.Store, nil
 }
 
 // Blame lists the build targets
 func (sys *System) Blame(ctx context.Context, repo *wantrepo.Repo) ([]Target, error) {
-	afid, err := sys.Import(ctx, repo)
+	plan, _, err := sys.plan(ctx, repo)
 	if err != nil {
 		return nil, err
 	}
-	af, err := sys.ViewArtifact(ctx, *afid)
+	return plan.Targets, nil
+}
+
+// plan compiles the module in repo, and returns the plan along with a store containing the target DAGs and the module.
+func (sys *System) plan(ctx context.Context, repo *wantrepo.Repo) (*wantc.Plan, cadata.Getter, error) {
+	ct, src, err := sys.compileTask(ctx, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	plan, planStore, err := wantops.DoCompile(ctx, sys.jobs, joinOpName("want", wantops.OpCompile), src, *ct)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plan, stores.Union{src, planStore}, nil
+}
+
+// Lint loads the module in repo, and reports likely mistakes.
+// If the module does not compile, the compiler's diagnostic is the only one returned.
+func (sys *System) Lint(ctx context.Context, repo *wantrepo.Repo) ([]*wantc.Diagnostic, error) {
+	ct, src, err := sys.compileTask(ctx, repo)
 	if err != nil {
 		return nil, err
 	}
+	return wantc.NewCompiler().Lint(ctx, stores.NewMem(), src, *ct)
+}
+
+// compileTask imports the module in repo, and evaluates its dependencies.
+// It returns a task to compile the module, and a store containing the module and its dependencies.
+func (sys *System) compileTask(ctx context.Context, repo *wantrepo.Repo) (*wantc.CompileTask, cadata.Getter, error) {
+	afid, err := sys.Import(ctx, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	af, err := sys.ViewArtifact(ctx, *afid)
+	if err != nil {
+		return nil, nil, err
+	}
 	root, err := af.GLFS()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	jctx := wantjob.Ctx{Context: ctx, Dst: stores.NewMem(), System: sys.jobs}
 	deps, err := wantops.MakeDeps(jctx, af.Store, *root, func(x wantcfg.Expr) (*glfs.Ref, error) {
@@ -103,17 +182,14 @@ func (sys *System) Blame(ctx context.Context, repo *wantrepo.Repo) ([]Target, er
 		return ref, nil
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	plan, _, err := wantops.DoCompile(ctx, sys.jobs, joinOpName("want", wantops.OpCompile), af.Store, wantc.CompileTask{
+	return &wantc.CompileTask{
 		Module:   *root,
 		Metadata: repo.Metadata(),
+		Params:   repo.Params(),
 		Deps:     deps,
-	})
-	if err != nil {
-		return nil, err
-	}
-	return plan.Targets, nil
+	}, stores.Union{af.Store, jctx.Dst}, nil
 }
 
 func (sys *System) evalExpr(ctx context.Context, x wantcfg.Expr) (*glfs.Ref, cadata.Getter, error) {
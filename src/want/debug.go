@@ -0,0 +1,71 @@
+package want
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"wantbuild.io/want/src/internal/glfstasks"
+	"wantbuild.io/want/src/internal/op/qemuops"
+	"wantbuild.io/want/src/internal/stores"
+	"wantbuild.io/want/src/wantjob"
+	"wantbuild.io/want/src/wantqemu"
+)
+
+type QEMUConsole = qemuops.Console
+
+// Debug reruns the task from a qemu.amd64_microvm job with its console serial port attached to con.
+// A console serial port is added if the task does not have one.
+// The VM is given the same kernel, input, and virtiofs mounts as the original job.
+// The rerun does not create a job, and its result is not cached.
+func (sys *System) Debug(ctx context.Context, jobid wantjob.JobID, con QEMUConsole) error {
+	j, src, err := sys.InspectJob(ctx, jobid)
+	if err != nil {
+		return err
+	}
+	if op := joinOpName("qemu", qemuops.OpAmd64MicroVM); j.Task.Op != op {
+		return fmt.Errorf("can only debug %v jobs, job %v has op %v", op, jobid, j.Task.Op)
+	}
+	inputRef, err := glfstasks.ParseGLFSRef(j.Task.Input)
+	if err != nil {
+		return err
+	}
+	t, err := wantqemu.GetMicroVMTask(ctx, src, *inputRef)
+	if err != nil {
+		return err
+	}
+	// the executor connects cfg.Console to the VM's console, so only add one if the task doesn't have it already.
+	if !slices.ContainsFunc(t.SerialPorts, func(sp wantqemu.SerialSpec) bool { return sp.Console != nil }) {
+		t.SerialPorts = append(t.SerialPorts, wantqemu.SerialSpec{Console: &struct{}{}})
+	}
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	scratch := stores.NewMem()
+	taskRef, err := wantqemu.PostMicroVMTask(ctx, stores.Fork{W: scratch, R: src}, *t)
+	if err != nil {
+		return err
+	}
+	jc := wantjob.Ctx{
+		Context: ctx,
+		Dst:     stores.NewMem(),
+		System:  sys.jobs,
+		Writer: func(string) io.Writer {
+			return os.Stderr
+		},
+	}
+	cfg := sys.execCfg.QEMU
+	cfg.Console = &con
+	if err := install(jc, qemuops.InstallSnippet(), cfg.InstallDir); err != nil {
+		return err
+	}
+	exec := qemuops.NewExecutor(cfg)
+	res := exec.Execute(jc, stores.Union{scratch, src}, wantjob.Task{
+		Op:    qemuops.OpAmd64MicroVM,
+		Input: glfstasks.MarshalGLFSRef(*taskRef),
+	})
+	return res.Err()
+}
@@ -46,6 +46,13 @@ type job struct {
 
 	createdAt, startAt, endAt tai64.TAI64N
 	stackTrace                []byte
+
+	// readCache is true if the job can be completed using a cached result
+	readCache bool
+	// cachePolicy decides readCache for the job's children.
+	cachePolicy cachePolicy
+	// cacheHit is true if the job was completed without executing its task.
+	cacheHit bool
 }
 
 func newJob(sys *jobSystem, parent *job, idx wantjob.Idx, dst cadata.Store, src cadata.Getter, task wantjob.Task) *job {
@@ -76,7 +83,7 @@ func newJob(sys *jobSystem, parent *job, idx wantjob.Idx, dst cadata.Store, src
 func (j *job) Spawn(ctx context.Context, src cadata.Getter, task wantjob.Task) (wantjob.Idx, error) {
 	j.childMu.Lock()
 	defer j.childMu.Unlock()
-	idx, child, err := j.sys.spawn(ctx, j, src, task)
+	idx, child, err := j.sys.spawn(ctx, j, src, task, j.cachePolicy(task.Op), j.cachePolicy)
 	if err != nil {
 		return 0, err
 	}
@@ -267,7 +274,21 @@ func newJobSystem(db *sqlx.DB, logDir string, exec wantjob.Executor, numWorkers
 }
 
 func (sys *jobSystem) Spawn(ctx context.Context, src cadata.Getter, task wantjob.Task) (wantjob.Idx, error) {
-	idx, j, err := sys.spawn(ctx, nil, src, task)
+	return sys.spawnRoot(ctx, src, task, true, cacheAll)
+}
+
+// SpawnNoCache spawns a root job which will execute the task, even if a cached result exists.
+// If noCacheChildren is true, then all of the job's descendants will also ignore the cache.
+func (sys *jobSystem) SpawnNoCache(ctx context.Context, src cadata.Getter, task wantjob.Task, noCacheChildren bool) (wantjob.Idx, error) {
+	policy := cacheAll
+	if noCacheChildren {
+		policy = cacheNone
+	}
+	return sys.spawnRoot(ctx, src, task, false, policy)
+}
+
+func (sys *jobSystem) spawnRoot(ctx context.Context, src cadata.Getter, task wantjob.Task, readCache bool, policy cachePolicy) (wantjob.Idx, error) {
+	idx, j, err := sys.spawn(ctx, nil, src, task, readCache, policy)
 	if err != nil {
 		return 0, err
 	}
@@ -338,13 +359,48 @@ func (sys *jobSystem) ViewResult(ctx context.Context, idx wantjob.Idx) (*wantjob
 	return j.viewResult()
 }
 
+// wasCached returns true if the finished root job was completed using a cached result.
+func (sys *jobSystem) wasCached(idx wantjob.Idx) (bool, error) {
+	sys.mu.RLock()
+	j, exists := sys.rootJobs[idx]
+	sys.mu.RUnlock()
+	if !exists {
+		return false, fmt.Errorf("job not found %v", idx)
+	}
+	if !j.isDone() {
+		return false, fmt.Errorf("job %v is not done", idx)
+	}
+	return j.cacheHit, nil
+}
+
+// forget removes a finished root job and its descendants from the database,
+// so that their results can no longer be used as a cache.
+func (sys *jobSystem) forget(ctx context.Context, idx wantjob.Idx) error {
+	sys.mu.Lock()
+	defer sys.mu.Unlock()
+	j, exists := sys.rootJobs[idx]
+	if !exists {
+		return fmt.Errorf("job not found %v", idx)
+	}
+	if !j.isDone() {
+		return fmt.Errorf("cannot forget job %v, it is still running", idx)
+	}
+	if err := dbutil.DoTx(ctx, sys.db, func(tx *sqlx.Tx) error {
+		return wantdb.DropJob(tx, wantjob.JobID{idx})
+	}); err != nil {
+		return err
+	}
+	delete(sys.rootJobs, idx)
+	return nil
+}
+
 func (sys *jobSystem) ListInfos(ctx context.Context) ([]*wantjob.JobInfo, error) {
 	return dbutil.ROTx1(ctx, sys.db, func(tx *sqlx.Tx) ([]*wantjob.JobInfo, error) {
 		return wantdb.ListJobInfos(tx, nil)
 	})
 }
 
-func (sys *jobSystem) spawn(ctx context.Context, parent *job, src cadata.Getter, task wantjob.Task) (wantjob.Idx, *job, error) {
+func (sys *jobSystem) spawn(ctx context.Context, parent *job, src cadata.Getter, task wantjob.Task, readCache bool, policy cachePolicy) (wantjob.Idx, *job, error) {
 	var (
 		idx   wantjob.Idx
 		dbJob *wantjob.Job
@@ -354,12 +410,12 @@ func (sys *jobSystem) spawn(ctx context.Context, parent *job, src cadata.Getter,
 		var err error
 		var jobid wantjob.JobID
 		if parent == nil {
-			if idx, err = wantdb.CreateRootJob(tx, task); err != nil {
+			if idx, err = wantdb.CreateRootJob(tx, task, readCache); err != nil {
 				return err
 			}
 			jobid = wantjob.JobID{idx}
 		} else {
-			if idx, err = wantdb.CreateChildJob(tx, parent.id, task); err != nil {
+			if idx, err = wantdb.CreateChildJob(tx, parent.id, task, readCache); err != nil {
 				return err
 			}
 			jobid = append(slices.Clone(parent.id), idx)
@@ -377,6 +433,8 @@ func (sys *jobSystem) spawn(ctx context.Context, parent *job, src cadata.Getter,
 
 	dst := wantdb.NewDBStore(sys.db, dstID)
 	j := newJob(sys, parent, idx, dst, src, task)
+	j.readCache = readCache
+	j.cachePolicy = policy
 	sys.maybeEnqueue(j, dbJob)
 	return idx, j, nil
 }
@@ -388,6 +446,7 @@ func (s *jobSystem) maybeEnqueue(jstate *job, dbJob *wantjob.Job) {
 	case wantjob.QUEUED:
 		s.queue <- jstate
 	case wantjob.DONE:
+		jstate.cacheHit = true
 		jstate.result = dbJob.Result
 		jstate.endAt = tai64.Now()
 		close(jstate.done)
@@ -400,9 +459,8 @@ func (s *jobSystem) process(x *job) (retErr error) {
 			x.finish(s.bgCtx, *wantjob.Result_ErrInternal(retErr))
 		}
 	}()
-	taskID := x.task.ID()
 	var original bool
-	res, err := s.og.Do(taskID, func() (wantjob.Result, error) {
+	execute := func() (wantjob.Result, error) {
 		original = true
 		jc := wantjob.Ctx{
 			Context: x.ctx,
@@ -419,10 +477,18 @@ func (s *jobSystem) process(x *job) (retErr error) {
 			return *wantjob.Result_ErrInternal(err), nil
 		}
 		return res, nil
-	})
+	}
+	var res wantjob.Result
+	var err error
+	if x.readCache {
+		res, err = s.og.Do(x.task.ID(), execute)
+	} else {
+		res, err = execute()
+	}
 	if err != nil {
 		return err
 	}
+	x.cacheHit = !original
 	// if it was not originally computed, and the output is successful GLFS, then
 	// we need to Pull into the job's store.
 	if !original && res.ErrCode == 0 {
@@ -468,6 +534,25 @@ func (s *jobSystem) Shutdown() {
 	s.wg.Wait()
 }
 
+// cachePolicy returns true if a job performing an op can be completed using a cached result.
+type cachePolicy = func(op wantjob.OpName) bool
+
+func cacheAll(wantjob.OpName) bool { return true }
+
+func cacheNone(wantjob.OpName) bool { return false }
+
+var _ wantjob.System = &policySystem{}
+
+// policySystem is a wantjob.System which applies a cachePolicy to the jobs it spawns and all their descendants.
+type policySystem struct {
+	*jobSystem
+	policy cachePolicy
+}
+
+func (ps *policySystem) Spawn(ctx context.Context, src cadata.Getter, task wantjob.Task) (wantjob.Idx, error) {
+	return ps.spawnRoot(ctx, src, task, ps.policy(task.Op), ps.policy)
+}
+
 type onceGroup[K comparable, V any] struct {
 	sf    singleflight.Group[K, V]
 	mu    sync.RWMutex
@@ -2,6 +2,7 @@ package want
 
 import (
 	"runtime"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -63,3 +64,116 @@ func TestJobStores(t *testing.T) {
 	require.NoError(t, glfs.WalkRefs(ctx, s2, *ref, func(ref glfs.Ref) error { count++; return nil }))
 	require.Equal(t, 4, count)
 }
+
+func TestSpawnNoCache(t *testing.T) {
+	ctx := testutil.Context(t)
+	db := wantdb.NewMemory()
+	require.NoError(t, wantdb.Setup(ctx, db))
+	var count int
+	exec := wantjob.BasicExecutor{
+		"op1": func(jc wantjob.Ctx, src cadata.Getter, x []byte) wantjob.Result {
+			count++
+			return *wantjob.Success(wantjob.Schema_NoRefs, x)
+		},
+	}
+	jsys := newJobSystem(db, t.TempDir(), exec, 1)
+	defer jsys.Shutdown()
+
+	s := stores.NewMem()
+	task := wantjob.Task{Op: "op1", Input: []byte("hello")}
+	for i := 0; i < 2; i++ {
+		_, _, err := wantjob.Do(ctx, jsys, s, task)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, count)
+
+	idx, err := jsys.SpawnNoCache(ctx, s, task, false)
+	require.NoError(t, err)
+	require.NoError(t, jsys.Await(ctx, idx))
+	res, _, err := jsys.ViewResult(ctx, idx)
+	require.NoError(t, err)
+	require.NoError(t, res.Err())
+	require.Equal(t, task.Input, res.Root)
+	require.Equal(t, 2, count)
+}
+
+func TestNoCacheResultNotCached(t *testing.T) {
+	ctx := testutil.Context(t)
+	db := wantdb.NewMemory()
+	require.NoError(t, wantdb.Setup(ctx, db))
+	var count int
+	exec := wantjob.BasicExecutor{
+		// op1 produces a different result every time it runs.
+		"op1": func(jc wantjob.Ctx, src cadata.Getter, x []byte) wantjob.Result {
+			count++
+			return *wantjob.Success(wantjob.Schema_NoRefs, []byte(strconv.Itoa(count)))
+		},
+	}
+	s := stores.NewMem()
+	task := wantjob.Task{Op: "op1", Input: []byte("hello")}
+	do := func(jsys *jobSystem, noCache bool) []byte {
+		var idx wantjob.Idx
+		var err error
+		if noCache {
+			idx, err = jsys.SpawnNoCache(ctx, s, task, false)
+		} else {
+			idx, err = jsys.Spawn(ctx, s, task)
+		}
+		require.NoError(t, err)
+		require.NoError(t, jsys.Await(ctx, idx))
+		res, _, err := jsys.ViewResult(ctx, idx)
+		require.NoError(t, err)
+		require.NoError(t, res.Err())
+		return res.Root
+	}
+
+	jsys := newJobSystem(db, t.TempDir(), exec, 1)
+	defer jsys.Shutdown()
+	require.Equal(t, []byte("1"), do(jsys, true))
+	// the result of the first job was not cached, so the task is executed again.
+	require.Equal(t, []byte("2"), do(jsys, false))
+	require.Equal(t, []byte("3"), do(jsys, true))
+	require.Equal(t, []byte("2"), do(jsys, false))
+
+	// a new job system only has the database's cache.
+	jsys2 := newJobSystem(db, t.TempDir(), exec, 1)
+	defer jsys2.Shutdown()
+	require.Equal(t, []byte("2"), do(jsys2, false))
+	require.Equal(t, 3, count)
+}
+
+func TestForget(t *testing.T) {
+	ctx := testutil.Context(t)
+	db := wantdb.NewMemory()
+	require.NoError(t, wantdb.Setup(ctx, db))
+	var count int
+	exec := wantjob.BasicExecutor{
+		"op1": func(jc wantjob.Ctx, src cadata.Getter, x []byte) wantjob.Result {
+			count++
+			return *wantjob.Success(wantjob.Schema_NoRefs, x)
+		},
+	}
+	jsys := newJobSystem(db, t.TempDir(), exec, 1)
+	defer jsys.Shutdown()
+
+	s := stores.NewMem()
+	task := wantjob.Task{Op: "op1", Input: []byte("hello")}
+	idx, err := jsys.SpawnNoCache(ctx, s, task, false)
+	require.NoError(t, err)
+	require.NoError(t, jsys.Await(ctx, idx))
+	cached, err := jsys.wasCached(idx)
+	require.NoError(t, err)
+	require.False(t, cached)
+	require.NoError(t, jsys.forget(ctx, idx))
+
+	// the result was forgotten, so the task is executed again, and then cached.
+	for i := 0; i < 2; i++ {
+		idx, err := jsys.Spawn(ctx, s, task)
+		require.NoError(t, err)
+		require.NoError(t, jsys.Await(ctx, idx))
+		cached, err := jsys.wasCached(idx)
+		require.NoError(t, err)
+		require.Equal(t, i > 0, cached)
+	}
+	require.Equal(t, 2, count)
+}
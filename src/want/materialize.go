@@ -0,0 +1,65 @@
+package want
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"blobcache.io/glfs"
+
+	"wantbuild.io/want/src/internal/glfsport"
+	"wantbuild.io/want/src/wantcfg"
+)
+
+func (s *System) materializeDir() string {
+	return filepath.Join(s.stateDir, "materialized")
+}
+
+// Materialize evaluates x, and exports the result to the state directory.
+// It returns the path of the exported file or directory.
+// x must not depend on a module, like the entries in a module's namespace.
+// Results are stored by content, so each one is only exported once.
+func (sys *System) Materialize(ctx context.Context, x wantcfg.Expr) (string, error) {
+	ref, src, err := sys.evalExpr(ctx, x)
+	if err != nil {
+		return "", err
+	}
+	name := ref.CID.String()
+	if ref.Type == glfs.TypeBlob {
+		// namespace blobs are usually Jsonnet libraries, the extension lets editors open them as such.
+		name += ".libsonnet"
+	}
+	dir := sys.materializeDir()
+	target := filepath.Join(dir, name)
+	if _, err := os.Stat(target); err == nil {
+		return target, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	// export to a temporary path first, so a partial export is never used.
+	tmp, err := os.MkdirTemp(dir, "tmp-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	exp := glfsport.Exporter{
+		Dir:   tmp,
+		Store: src,
+		Cache: glfsport.NullCache{},
+	}
+	if err := exp.Export(ctx, *ref, name); err != nil {
+		return "", err
+	}
+	if err := os.Rename(filepath.Join(tmp, name), target); err != nil {
+		// someone else may have exported it first
+		if _, err2 := os.Stat(target); err2 != nil {
+			return "", err
+		}
+	}
+	return target, nil
+}
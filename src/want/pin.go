@@ -0,0 +1,146 @@
+package want
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"wantbuild.io/want/src/internal/op/importops"
+	"wantbuild.io/want/src/internal/stores"
+	"wantbuild.io/want/src/internal/wantc"
+	"wantbuild.io/want/src/internal/wantfmt"
+	"wantbuild.io/want/src/internal/wantrepo"
+	"wantbuild.io/want/src/wantjob"
+)
+
+// pinFuncs are the library functions for the import operations which can be pinned,
+// with their parameters in order, and the defaults for optional string parameters.
+var pinFuncs = map[wantjob.OpName]struct {
+	name     string
+	params   []string
+	defaults map[string]string
+}{
+	importops.OpFromURL:   {"importURL", []string{"url", "algo", "hash", "transforms"}, nil},
+	importops.OpFromGit:   {"importGit", []string{"url", "commitHash", "branch", "submodules", "lfs"}, map[string]string{"branch": "master"}},
+	importops.OpFromGoZip: {"importGoZip", []string{"path", "version", "hash"}, nil},
+}
+
+// PinnedImport is an import and the value which pins it.
+type PinnedImport struct {
+	Import wantc.Import
+	Pin    importops.Pin
+}
+
+// PinResult is the output of Pin
+type PinResult struct {
+	// Pinned are the imports whose pins were written to the module's source files.
+	Pinned []PinnedImport
+	// Unresolved are the imports whose pins could not be written,
+	// because there was no call with literal arguments to change.
+	Unresolved []PinnedImport
+	// Skipped are the imports which cannot be pinned automatically.
+	Skipped []wantc.Import
+	// Files are the paths of the source files which were changed.
+	Files []string
+}
+
+// Pin runs each import in the module which is not pinned by a hash, and writes the hash to the call which created it.
+// Only calls in the module's own source files, with string literal arguments, are changed.
+func (sys *System) Pin(ctx context.Context, repo *wantrepo.Repo) (*PinResult, error) {
+	ct, src, err := sys.compileTask(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	imports, err := wantc.NewCompiler().Imports(ctx, stores.NewMem(), src, *ct)
+	if err != nil {
+		return nil, err
+	}
+	cfg := sys.execCfg.Import
+	cfg.Offline = false
+	e := importops.NewExecutor(cfg)
+	jc := wantjob.Ctx{Context: ctx, Dst: stores.NewMem(), System: sys.jobs}
+
+	ret := &PinResult{}
+	var pinned []PinnedImport
+	// editIdx is the index in edits of the edit for each pinned import.
+	var editIdx []int
+	var edits []wantc.ArgEdit
+	seen := map[string]struct{}{}
+	seenEdits := map[string]int{}
+	for _, imp := range imports {
+		if err := importops.CheckPinned(imp.Op, imp.Spec); err == nil {
+			continue
+		}
+		key := string(imp.Op) + " " + string(imp.Spec)
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		fn, exists := pinFuncs[imp.Op]
+		if !exists {
+			ret.Skipped = append(ret.Skipped, imp)
+			continue
+		}
+		pin, err := e.Pin(jc, imp.Op, imp.Spec)
+		if err != nil {
+			return nil, fmt.Errorf("pinning %v: %w", imp.Pos, err)
+		}
+		pinned = append(pinned, PinnedImport{Import: imp, Pin: *pin})
+		// imports which only differ in the arguments which are not matched, share an edit.
+		editKey := fmt.Sprint(fn.name, pin.Args, pin.Param, pin.Value)
+		if i, exists := seenEdits[editKey]; exists {
+			editIdx = append(editIdx, i)
+			continue
+		}
+		seenEdits[editKey] = len(edits)
+		editIdx = append(editIdx, len(edits))
+		edits = append(edits, wantc.ArgEdit{
+			Func:     fn.name,
+			Params:   fn.params,
+			Match:    pin.Args,
+			Defaults: fn.defaults,
+			Param:    pin.Param,
+			Value:    pin.Value,
+		})
+	}
+	if len(edits) == 0 {
+		return ret, nil
+	}
+
+	counts := make([]int, len(edits))
+	if err := repo.WalkFiles("", func(p string) error {
+		if !wantfmt.IsJsonnetPath(p) {
+			return nil
+		}
+		fp := filepath.Join(repo.RootPath(), filepath.FromSlash(p))
+		data, err := os.ReadFile(fp)
+		if err != nil {
+			return err
+		}
+		out, n, err := wantc.EditCalls(p, data, edits)
+		if err != nil {
+			return fmt.Errorf("editing %s: %w", p, err)
+		}
+		changed := false
+		for i := range n {
+			counts[i] += n[i]
+			changed = changed || n[i] > 0
+		}
+		if !changed {
+			return nil
+		}
+		ret.Files = append(ret.Files, p)
+		return os.WriteFile(fp, out, 0o644)
+	}); err != nil {
+		return nil, err
+	}
+	for i, pi := range pinned {
+		if counts[editIdx[i]] > 0 {
+			ret.Pinned = append(ret.Pinned, pi)
+		} else {
+			ret.Unresolved = append(ret.Unresolved, pi)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,330 @@
+package want
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"go.brendoncarroll.net/state/cadata"
+	"golang.org/x/sync/errgroup"
+
+	"wantbuild.io/want/src/internal/dbutil"
+	"wantbuild.io/want/src/internal/glfstasks"
+	"wantbuild.io/want/src/internal/op/dagops"
+	"wantbuild.io/want/src/internal/op/glfsops"
+	"wantbuild.io/want/src/internal/stores"
+	"wantbuild.io/want/src/internal/wantdag"
+	"wantbuild.io/want/src/internal/wantdb"
+	"wantbuild.io/want/src/internal/wantrepo"
+	"wantbuild.io/want/src/wantcfg"
+	"wantbuild.io/want/src/wantjob"
+)
+
+// ReproConfig configures a reproducibility check.
+type ReproConfig struct {
+	// Parallel causes both builds to run at the same time.
+	Parallel bool
+	// NumWorkers is the number of workers used for each build.
+	// Zero means use the System's job system.
+	NumWorkers [2]int
+}
+
+// ReproResult is the outcome of a reproducibility check.
+type ReproResult struct {
+	Targets []ReproTarget
+}
+
+// Divergent returns the targets which did not reproduce.
+func (rr ReproResult) Divergent() (ret []ReproTarget) {
+	for _, rt := range rr.Targets {
+		if !rt.Same() {
+			ret = append(ret, rt)
+		}
+	}
+	return ret
+}
+
+// ReproTarget is the outcome of building a single target twice.
+type ReproTarget struct {
+	Target  Target
+	Results [2]wantjob.Result
+
+	// Diff lists the paths which differ between the outputs of the two builds.
+	Diff []string
+	// FirstJobs are the first jobs in each build which performed the same task, but produced different results.
+	// FirstJobs is nil if no such jobs could be found.
+	FirstJobs *[2]DivergentJob
+}
+
+func (rt ReproTarget) Same() bool {
+	a, b := rt.Results[0], rt.Results[1]
+	return a.ErrCode == b.ErrCode && bytes.Equal(a.Root, b.Root)
+}
+
+// DivergentJob is a job which produced a different result than another job performing the same task.
+type DivergentJob struct {
+	ID     wantjob.JobID
+	Task   wantjob.Task
+	Result wantjob.Result
+}
+
+// VerifyRepro builds the targets matching query twice and compares the results.
+// Only imports are allowed to use the cache, all other tasks are executed again for each build.
+func (sys *System) VerifyRepro(ctx context.Context, repo *wantrepo.Repo, query wantcfg.PathSet, cfg ReproConfig) (*ReproResult, error) {
+	var (
+		systems [2]*policySystem
+		results [2]*BuildResult
+	)
+	for i, n := range cfg.NumWorkers {
+		js := sys.jobs
+		if n > 0 {
+			js = newJobSystem(sys.db, sys.logDir(), newExecutor(sys.execCfg), n)
+			defer js.Shutdown()
+		}
+		systems[i] = &policySystem{jobSystem: js, policy: reproCachePolicy}
+	}
+	doBuild := func(i int) error {
+		res, err := sys.build(ctx, systems[i], repo, query)
+		if err != nil {
+			return fmt.Errorf("build %d: %w", i, err)
+		}
+		results[i] = res
+		return nil
+	}
+	if cfg.Parallel {
+		eg := errgroup.Group{}
+		for i := range systems {
+			eg.Go(func() error { return doBuild(i) })
+		}
+		if err := eg.Wait(); err != nil {
+			return nil, err
+		}
+	} else {
+		for i := range systems {
+			if err := doBuild(i); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var trees [2]*jobNode
+	for i, res := range results {
+		var err error
+		if trees[i], err = dbutil.ROTx1(ctx, sys.db, func(tx *sqlx.Tx) (*jobNode, error) {
+			return loadJobTree(tx, wantjob.JobID{res.Job})
+		}); err != nil {
+			return nil, err
+		}
+	}
+	byTask := make(map[wantjob.TaskID]*jobNode)
+	trees[1].forEach(func(n *jobNode) {
+		if _, exists := byTask[n.Task.ID()]; !exists {
+			byTask[n.Task.ID()] = n
+		}
+	})
+
+	// find the job for each node in the first build, so that divergence can be traced back through the DAG.
+	planDAG, err := wantdag.GetDAG(ctx, results[0].Store, results[0].DAG)
+	if err != nil {
+		return nil, err
+	}
+	var roots []wantdag.NodeID
+	for _, target := range results[0].Targets {
+		roots = append(roots, target.Node)
+	}
+	dag, ids, jobs, err := dagJobs(ctx, results[0].Store, planDAG, roots, trees[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var ret ReproResult
+	src := stores.Union{results[0].Store, results[1].Store}
+	for i, target := range results[0].Targets {
+		rt := ReproTarget{
+			Target:  target,
+			Results: [2]wantjob.Result{results[0].TargetResults[i], results[1].TargetResults[i]},
+		}
+		if !rt.Same() {
+			left, err1 := glfstasks.ParseGLFSRef(rt.Results[0].Root)
+			right, err2 := glfstasks.ParseGLFSRef(rt.Results[1].Root)
+			if err1 == nil && err2 == nil {
+				var err error
+				if rt.Diff, err = diffPaths(ctx, src, *left, *right); err != nil {
+					return nil, err
+				}
+			}
+			if jobs != nil {
+				if a, b := firstDivergentNode(dag, ids[i], jobs, byTask); a != nil {
+					rt.FirstJobs = &[2]DivergentJob{a.divergentJob(), b.divergentJob()}
+				}
+			}
+		}
+		ret.Targets = append(ret.Targets, rt)
+	}
+	return &ret, nil
+}
+
+// reproCachePolicy only allows imports to use the cache.
+// Imports have their integrity checked by hash, so there is nothing to learn by fetching them again.
+func reproCachePolicy(op wantjob.OpName) bool {
+	return strings.HasPrefix(string(op), "import.")
+}
+
+// firstDivergent descends from n, returning the deepest job which produced a different result
+// from the job performing the same task in other.
+// If n itself does not diverge, then nil is returned.
+func firstDivergent(n *jobNode, other map[wantjob.TaskID]*jobNode) (*jobNode, *jobNode) {
+	n2, exists := other[n.Task.ID()]
+	if !exists || n.sameResult(n2) {
+		return nil, nil
+	}
+	for _, child := range n.Children {
+		if a, b := firstDivergent(child, other); a != nil {
+			return a, b
+		}
+	}
+	return n, n2
+}
+
+// dagJobs finds the job in tree which executed each node, when the roots of x were built.
+// Builds narrow x to their roots, and execute the result with a single dag.execAll job, which spawns a job for each derived node.
+// The job for a node is found by its task, which is formed from the results of its inputs, the same way as during the build.
+// It returns the narrowed DAG, the IDs of the roots in it, and the job for each of its nodes, which is nil for facts and nodes which did not run.
+// jobs is nil if the dag.execAll job is not in tree.
+func dagJobs(ctx context.Context, src cadata.Getter, x wantdag.DAG, roots []wantdag.NodeID, tree *jobNode) (_ wantdag.DAG, ids []wantdag.NodeID, jobs []*jobNode, _ error) {
+	x, ids, err := wantdag.Narrow(ctx, src, x, roots, glfsops.Narrowers("glfs."))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	scratch := stores.NewMem()
+	xRef, err := wantdag.PostDAG(ctx, scratch, x)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	execAll := tree.find(wantjob.Task{
+		Op:    joinOpName("dag", dagops.OpExecAll),
+		Input: glfstasks.MarshalGLFSRef(*xRef),
+	}.ID())
+	if execAll == nil {
+		return x, ids, nil, nil
+	}
+	byTask := make(map[wantjob.TaskID]*jobNode)
+	for _, child := range execAll.Children {
+		byTask[child.Task.ID()] = child
+	}
+	jobs = make([]*jobNode, len(x))
+	results := make([]wantjob.Result, len(x))
+	resolve := func(id wantdag.NodeID) wantjob.Result { return results[id] }
+	for i, node := range x {
+		if node.IsFact() {
+			results[i] = *glfstasks.Success(*node.Value)
+			continue
+		}
+		inputRef, err := wantdag.PrepareInput(ctx, scratch, stores.Union{src, scratch}, node.Inputs, resolve)
+		if err != nil {
+			// an input failed, so the node did not run.
+			results[i] = *wantjob.Result_ErrExec(err)
+			continue
+		}
+		task := wantjob.Task{Op: node.Op, Input: glfstasks.MarshalGLFSRef(*inputRef)}
+		if jobs[i] = byTask[task.ID()]; jobs[i] == nil || jobs[i].Result == nil {
+			results[i] = *wantjob.Result_ErrExec(fmt.Errorf("no job found for node %d", i))
+			continue
+		}
+		results[i] = *jobs[i].Result
+	}
+	return x, ids, jobs, nil
+}
+
+// firstDivergentNode returns the first job, among the jobs for id and the nodes it depends on,
+// which produced a different result from the job performing the same task in other.
+// Nodes come after their inputs, so the first such job is the earliest cause of the divergence.
+func firstDivergentNode(x wantdag.DAG, id wantdag.NodeID, jobs []*jobNode, other map[wantjob.TaskID]*jobNode) (*jobNode, *jobNode) {
+	needed := make([]bool, len(x))
+	needed[id] = true
+	for i := int(id); i >= 0; i-- {
+		if !needed[i] {
+			continue
+		}
+		for _, in := range x[i].Inputs {
+			needed[in.Node] = true
+		}
+	}
+	for i := range needed {
+		if !needed[i] || jobs[i] == nil {
+			continue
+		}
+		if a, b := firstDivergent(jobs[i], other); a != nil {
+			return a, b
+		}
+	}
+	return nil, nil
+}
+
+// jobNode is a job and all of its descendants.
+type jobNode struct {
+	ID wantjob.JobID
+	wantjob.Job
+	Children []*jobNode
+}
+
+func loadJobTree(tx *sqlx.Tx, jobid wantjob.JobID) (*jobNode, error) {
+	j, err := wantdb.InspectJob(tx, jobid)
+	if err != nil {
+		return nil, err
+	}
+	idxs, err := wantdb.ListChildren(tx, jobid)
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(idxs)
+	n := &jobNode{ID: jobid, Job: *j}
+	for _, idx := range idxs {
+		child, err := loadJobTree(tx, append(slices.Clone(jobid), idx))
+		if err != nil {
+			return nil, err
+		}
+		n.Children = append(n.Children, child)
+	}
+	return n, nil
+}
+
+// forEach calls fn on n and all its descendants in depth-first order.
+func (n *jobNode) forEach(fn func(*jobNode)) {
+	fn(n)
+	for _, child := range n.Children {
+		child.forEach(fn)
+	}
+}
+
+// find returns the first job in depth-first order performing the task with id.
+func (n *jobNode) find(id wantjob.TaskID) *jobNode {
+	if n.Task.ID() == id {
+		return n
+	}
+	for _, child := range n.Children {
+		if x := child.find(id); x != nil {
+			return x
+		}
+	}
+	return nil
+}
+
+func (n *jobNode) sameResult(other *jobNode) bool {
+	a, b := n.Result, other.Result
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.ErrCode == b.ErrCode && bytes.Equal(a.Root, b.Root)
+}
+
+func (n *jobNode) divergentJob() DivergentJob {
+	dj := DivergentJob{ID: n.ID, Task: n.Task}
+	if n.Result != nil {
+		dj.Result = *n.Result
+	}
+	return dj
+}
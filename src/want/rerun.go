@@ -0,0 +1,107 @@
+package want
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"slices"
+
+	"blobcache.io/glfs"
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/internal/glfstasks"
+	"wantbuild.io/want/src/internal/stores"
+	"wantbuild.io/want/src/wantjob"
+)
+
+// RerunResult is the outcome of running a job's task a second time.
+type RerunResult struct {
+	// ID is the id of the job which performed the rerun.
+	ID   wantjob.JobID
+	Task wantjob.Task
+
+	// Recorded is the result of the original job
+	Recorded wantjob.Result
+	// Result is the result of the rerun
+	Result wantjob.Result
+	// Diff lists the paths which differ between the recorded and new output trees.
+	// It is only populated if both results are successful and the roots are GLFS Refs.
+	Diff []string
+}
+
+// Same returns true if the rerun produced exactly the recorded result.
+func (rr RerunResult) Same() bool {
+	return rr.Recorded.ErrCode == rr.Result.ErrCode && bytes.Equal(rr.Recorded.Root, rr.Result.Root)
+}
+
+// Rerun executes the task from a finished job again, ignoring any cached result for it,
+// and compares the new result with the recorded one.
+// If noCache is true, then the jobs spawned while executing the task will also ignore the cache.
+func (sys *System) Rerun(ctx context.Context, jobid wantjob.JobID, noCache bool) (*RerunResult, error) {
+	j, src, err := sys.InspectJob(ctx, jobid)
+	if err != nil {
+		return nil, err
+	}
+	if j.State != wantjob.DONE || j.Result == nil {
+		return nil, fmt.Errorf("cannot rerun job %v in state %v", jobid, j.State)
+	}
+	idx, err := sys.jobs.SpawnNoCache(ctx, src, j.Task, noCache)
+	if err != nil {
+		return nil, err
+	}
+	if err := sys.jobs.Await(ctx, idx); err != nil {
+		return nil, err
+	}
+	res, resStore, err := sys.jobs.ViewResult(ctx, idx)
+	if err != nil {
+		return nil, err
+	}
+	rr := &RerunResult{
+		ID:       wantjob.JobID{idx},
+		Task:     j.Task,
+		Recorded: *j.Result,
+		Result:   *res,
+	}
+	if !rr.Same() && rr.Recorded.ErrCode == wantjob.OK && rr.Result.ErrCode == wantjob.OK {
+		left, err1 := glfstasks.ParseGLFSRef(rr.Recorded.Root)
+		right, err2 := glfstasks.ParseGLFSRef(rr.Result.Root)
+		if err1 == nil && err2 == nil {
+			if rr.Diff, err = diffPaths(ctx, stores.Union{src, resStore}, *left, *right); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return rr, nil
+}
+
+// diffPaths returns the sorted paths to all the non-tree entries which are not the same in left and right.
+func diffPaths(ctx context.Context, src cadata.Getter, left, right glfs.Ref) ([]string, error) {
+	ag := glfs.NewAgent()
+	scratch := stores.NewMem()
+	diff, err := ag.Compare(ctx, scratch, stores.Union{src, scratch}, left, right)
+	if err != nil {
+		return nil, err
+	}
+	s := stores.Union{src, scratch}
+	var ret []string
+	for _, ref := range []*glfs.Ref{diff.Left, diff.Right} {
+		if ref == nil {
+			continue
+		}
+		if ref.Type != glfs.TypeTree {
+			ret = append(ret, "")
+			continue
+		}
+		if err := ag.WalkTree(ctx, s, *ref, func(prefix string, ent glfs.TreeEntry) error {
+			if ent.Ref.Type != glfs.TypeTree {
+				ret = append(ret, path.Join(prefix, ent.Name))
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(ret)
+	return slices.Compact(ret), nil
+}
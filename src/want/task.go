@@ -12,9 +12,11 @@ import (
 	"wantbuild.io/want/src/internal/op/glfsops"
 	"wantbuild.io/want/src/internal/op/goops"
 	"wantbuild.io/want/src/internal/op/importops"
+	"wantbuild.io/want/src/internal/op/ociops"
 	"wantbuild.io/want/src/internal/op/qemuops"
 	"wantbuild.io/want/src/internal/op/wantops"
 	"wantbuild.io/want/src/internal/op/wasmops"
+	"wantbuild.io/want/src/internal/wantdag"
 	"wantbuild.io/want/src/internal/wantsetup"
 	"wantbuild.io/want/src/wantjob"
 )
@@ -28,10 +30,16 @@ type executor struct {
 	setupOg onceGroup[string, wantjob.Executor]
 }
 
-type QEMUConfig = qemuops.Config
+type (
+	QEMUConfig  = qemuops.Config
+	WASMConfig  = wasmops.Config
+	FetchConfig = importops.Config
+)
 
 type ExecutorConfig struct {
-	QEMU QEMUConfig
+	QEMU   QEMUConfig
+	WASM   WASMConfig
+	Import FetchConfig
 
 	GoRoot  string
 	GoState string
@@ -41,22 +49,31 @@ func NewExecutor(cfg ExecutorConfig) wantjob.Executor {
 	return newExecutor(cfg)
 }
 
+// newWantExecutor returns the executor for want operations, which uses the other operations in the executor from newExecutor.
+func newWantExecutor(narrowers map[wantjob.OpName]wantdag.Narrower) wantops.Executor {
+	return wantops.Executor{
+		CompileOp:    "want." + wantops.OpCompile,
+		DAGExecOp:    "dag." + dagops.OpExecLast,
+		DAGExecAllOp: "dag." + dagops.OpExecAll,
+		Narrowers:    narrowers,
+	}
+}
+
 // newExecutor
 // qemuDir is the qemu install dir
 func newExecutor(cfg ExecutorConfig) *executor {
+	importExec := importops.NewExecutor(cfg.Import)
+	importExec.FromURLOp = "import." + importops.OpFromURL
+	narrowers := glfsops.Narrowers("glfs.")
 	return &executor{
 		execs: map[wantjob.OpName]wantjob.Executor{
 			"glfs":   glfsops.Executor{},
-			"import": importops.NewExecutor(),
+			"import": importExec,
 
-			"dag":    dagops.Executor{},
+			"dag":    dagops.Executor{Narrowers: narrowers},
 			"assert": assertops.Executor{},
-			"want": wantops.Executor{
-				CompileOp: "want." + wantops.OpCompile,
-				DAGExecOp: "dag." + dagops.OpExecLast,
-			},
-
-			"wasm": wasmops.NewExecutor(),
+			"oci":    ociops.Executor{},
+			"want":   newWantExecutor(narrowers),
 		},
 		setup: map[wantjob.OpName]func(jc wantjob.Ctx) (wantjob.Executor, error){
 			"qemu": func(jc wantjob.Ctx) (wantjob.Executor, error) {
@@ -65,6 +82,9 @@ func newExecutor(cfg ExecutorConfig) *executor {
 				}
 				return qemuops.NewExecutor(cfg.QEMU), nil
 			},
+			"wasm": func(jc wantjob.Ctx) (wantjob.Executor, error) {
+				return wasmops.NewExecutor(cfg.WASM)
+			},
 			"golang": func(jc wantjob.Ctx) (wantjob.Executor, error) {
 				if err := install(jc, goops.InstallSnippet(), cfg.GoRoot); err != nil {
 					return nil, err
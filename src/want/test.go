@@ -0,0 +1,120 @@
+package want
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.brendoncarroll.net/state/cadata"
+	"golang.org/x/sync/errgroup"
+
+	"wantbuild.io/want/src/internal/glfstasks"
+	"wantbuild.io/want/src/internal/op/dagops"
+	"wantbuild.io/want/src/internal/wantc"
+	"wantbuild.io/want/src/internal/wantrepo"
+	"wantbuild.io/want/src/wantcfg"
+	"wantbuild.io/want/src/wantjob"
+)
+
+// TestConfig configures a test run.
+type TestConfig struct {
+	// Retries is the number of times a failing test is run again, without the cache.
+	// A test which fails and then passes is flaky.
+	Retries int
+}
+
+// TestResult is the outcome of running a single test target.
+type TestResult struct {
+	Target Target
+	// Result is the result of the last attempt.
+	Result wantjob.Result
+	// Elapsed is the total time spent on all the attempts.
+	Elapsed time.Duration
+	// Cached is true if the result was read from the cache, instead of running the test.
+	Cached bool
+	// Attempts is the number of times the test was run.
+	Attempts int
+}
+
+// Passed returns true if the test succeeded, possibly after retrying.
+func (tr TestResult) Passed() bool {
+	return tr.Result.ErrCode == wantjob.OK
+}
+
+// Flaky returns true if the test failed, and then passed when it was retried.
+func (tr TestResult) Flaky() bool {
+	return tr.Passed() && tr.Attempts > 1
+}
+
+// Test runs the test targets which intersect query.
+// All of the tests are run, even if some of them fail.
+// A test which only passes when retried is flaky, and its successful result is not cached.
+func (sys *System) Test(ctx context.Context, repo *wantrepo.Repo, query wantcfg.PathSet, cfg TestConfig) ([]TestResult, error) {
+	plan, src, err := sys.plan(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	var ret []TestResult
+	for _, target := range plan.Targets {
+		if target.IsTest && wantc.Intersects(target.To, query) {
+			ret = append(ret, TestResult{Target: target})
+		}
+	}
+	eg, ctx := errgroup.WithContext(ctx)
+	for i := range ret {
+		eg.Go(func() error {
+			return sys.runTest(ctx, src, &ret[i], cfg)
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (sys *System) runTest(ctx context.Context, src cadata.Getter, tr *TestResult, cfg TestConfig) error {
+	task := wantjob.Task{
+		Op:    joinOpName("dag", dagops.OpExecLast),
+		Input: glfstasks.MarshalGLFSRef(tr.Target.DAG),
+	}
+	startTime := time.Now()
+	defer func() { tr.Elapsed = time.Since(startTime) }()
+	for tr.Attempts = 1; ; tr.Attempts++ {
+		var idx wantjob.Idx
+		var err error
+		if tr.Attempts == 1 {
+			idx, err = sys.jobs.Spawn(ctx, src, task)
+		} else {
+			// the failure may be cached in memory, and the tasks which failed
+			// may not fail again, so the retry has to execute everything.
+			idx, err = sys.jobs.SpawnNoCache(ctx, src, task, true)
+		}
+		if err != nil {
+			return err
+		}
+		if err := sys.jobs.Await(ctx, idx); err != nil {
+			return err
+		}
+		res, _, err := sys.jobs.ViewResult(ctx, idx)
+		if err != nil {
+			return err
+		}
+		tr.Result = *res
+		if tr.Attempts == 1 {
+			if tr.Cached, err = sys.jobs.wasCached(idx); err != nil {
+				return err
+			}
+		}
+		if tr.Passed() {
+			if tr.Flaky() {
+				if err := sys.jobs.forget(ctx, idx); err != nil {
+					return fmt.Errorf("removing flaky result: %w", err)
+				}
+			}
+			return nil
+		}
+		if tr.Attempts > cfg.Retries {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package want
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/internal/op/glfsops"
+	"wantbuild.io/want/src/internal/op/importops"
+	"wantbuild.io/want/src/internal/stores"
+	"wantbuild.io/want/src/internal/wantrepo"
+	"wantbuild.io/want/src/wantcfg"
+	"wantbuild.io/want/src/wantjob"
+)
+
+// loadFetchConfig reads the fetch config from the state directory, if it exists.
+// The vendor and git cache directories default to ones in the state directory, and the Go proxy defaults to GOPROXY.
+func (s *System) loadFetchConfig() (*FetchConfig, error) {
+	var cfg FetchConfig
+	data, err := os.ReadFile(s.fetchConfigPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", s.fetchConfigPath(), err)
+		}
+	}
+	if cfg.VendorDir == "" {
+		cfg.VendorDir = s.vendorDir()
+	}
+	if cfg.GitCacheDir == "" {
+		cfg.GitCacheDir = s.gitCacheDir()
+	}
+	if cfg.GoProxy == "" {
+		cfg.GoProxy = os.Getenv("GOPROXY")
+	}
+	return &cfg, nil
+}
+
+// VendorResult is the output of Vendor
+type VendorResult struct {
+	// Dir is the vendor directory
+	Dir string
+	// Vendored is the number of import tasks whose content is in the vendor directory
+	Vendored int
+	// Skipped is the number of import tasks which cannot be vendored.
+	Skipped int
+}
+
+// Vendor downloads the content for every import task performed while building the module into the vendor directory.
+// The module is built again, executing imports even if they are cached, so that imports which are only known during the build,
+// like the packages in a lockfile, are performed and vendored as well.
+// Other tasks can use the cache, and targets which fail do not stop their imports from being vendored.
+// Once vendored, the module can be built with the fetch config set to offline.
+func (sys *System) Vendor(ctx context.Context, repo *wantrepo.Repo) (*VendorResult, error) {
+	cfg := sys.execCfg
+	cfg.Import.Offline = false
+	ve := &vendorExecutor{
+		Executor: newExecutor(cfg),
+		imports:  importops.NewExecutor(cfg.Import),
+		seen:     make(map[wantjob.TaskID]struct{}),
+	}
+	js := newJobSystem(sys.db, sys.logDir(), ve, sys.numWorkers)
+	defer js.Shutdown()
+	bt, src, err := sys.buildTask(ctx, repo, wantcfg.Prefix(""))
+	if err != nil {
+		return nil, err
+	}
+	// build directly, instead of with a want.build job, which fails if any of the targets fail.
+	jc := wantjob.Ctx{
+		Context: ctx,
+		System:  &policySystem{jobSystem: js, policy: vendorCachePolicy},
+		Dst:     stores.NewMem(),
+	}
+	we := newWantExecutor(glfsops.Narrowers("glfs."))
+	if _, err := we.Build(jc, src, *bt); err != nil {
+		return nil, err
+	}
+	ve.mu.Lock()
+	defer ve.mu.Unlock()
+	if err := errors.Join(ve.errs...); err != nil {
+		return nil, err
+	}
+	ret := ve.res
+	ret.Dir = cfg.Import.VendorDir
+	return &ret, nil
+}
+
+// vendorCachePolicy executes imports, and the compiles and DAGs which spawn them, so that every import is seen by the vendorExecutor.
+// A cached job does not spawn any children, so the imports beneath it would be missed.
+func vendorCachePolicy(op wantjob.OpName) bool {
+	for _, prefix := range []string{"import.", "want.", "dag."} {
+		if strings.HasPrefix(string(op), prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// vendorExecutor vendors the content for each import task before performing it.
+type vendorExecutor struct {
+	wantjob.Executor
+	imports *importops.Executor
+
+	mu   sync.Mutex
+	seen map[wantjob.TaskID]struct{}
+	res  VendorResult
+	errs []error
+}
+
+func (e *vendorExecutor) Execute(jc wantjob.Ctx, src cadata.Getter, task wantjob.Task) wantjob.Result {
+	if op, isImport := strings.CutPrefix(string(task.Op), "import."); isImport {
+		if err := e.vendorTask(jc, src, wantjob.Task{Op: wantjob.OpName(op), Input: task.Input}); err != nil {
+			err = fmt.Errorf("vendoring %s: %w", task.Op, err)
+			e.mu.Lock()
+			e.errs = append(e.errs, err)
+			e.mu.Unlock()
+			return *wantjob.Result_ErrExec(err)
+		}
+	}
+	return e.Executor.Execute(jc, src, task)
+}
+
+func (e *vendorExecutor) vendorTask(jc wantjob.Ctx, src cadata.Getter, task wantjob.Task) error {
+	e.mu.Lock()
+	_, exists := e.seen[task.ID()]
+	e.seen[task.ID()] = struct{}{}
+	e.mu.Unlock()
+	if exists {
+		return nil
+	}
+	ok, err := e.imports.VendorTask(jc, src, task)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if ok {
+		e.res.Vendored++
+	} else {
+		e.res.Skipped++
+	}
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/pbnjay/memory"
 	"go.brendoncarroll.net/state/cadata"
 
+	"wantbuild.io/want/src/internal/dbutil"
 	"wantbuild.io/want/src/internal/glfstasks"
 	"wantbuild.io/want/src/internal/op/dagops"
 	"wantbuild.io/want/src/internal/stores"
@@ -27,8 +28,9 @@ type System struct {
 	stateDir   string
 	numWorkers int
 
-	db   *sqlx.DB
-	jobs *jobSystem
+	db      *sqlx.DB
+	execCfg ExecutorConfig
+	jobs    *jobSystem
 }
 
 func New(stateDir string, numWorkers int) *System {
@@ -52,6 +54,22 @@ func (s *System) logDir() string {
 	return filepath.Join(s.stateDir, "log")
 }
 
+func (s *System) wasmCacheDir() string {
+	return filepath.Join(s.stateDir, "wasmcache")
+}
+
+func (s *System) vendorDir() string {
+	return filepath.Join(s.stateDir, "vendor")
+}
+
+func (s *System) gitCacheDir() string {
+	return filepath.Join(s.stateDir, "gitcache")
+}
+
+func (s *System) fetchConfigPath() string {
+	return filepath.Join(s.stateDir, "fetch.json")
+}
+
 func (s *System) goRoot() string {
 	return filepath.Join(s.stateDir, "goroot")
 }
@@ -70,14 +88,23 @@ func (s *System) Init(ctx context.Context) error {
 	}
 	numWorkers := runtime.GOMAXPROCS(0)
 
-	exec := newExecutor(ExecutorConfig{
+	fetchCfg, err := s.loadFetchConfig()
+	if err != nil {
+		return err
+	}
+	s.execCfg = ExecutorConfig{
+		Import: *fetchCfg,
 		QEMU: QEMUConfig{
 			InstallDir: s.qemuDir(),
 			MemLimit:   int64(memory.TotalMemory()) / 2 * 3,
 		},
+		WASM: WASMConfig{
+			CacheDir: s.wasmCacheDir(),
+		},
 		GoRoot:  s.goRoot(),
 		GoState: s.goState(),
-	})
+	}
+	exec := newExecutor(s.execCfg)
 	s.jobs = newJobSystem(s.db, s.logDir(), exec, numWorkers)
 	return nil
 }
@@ -106,6 +133,26 @@ func (sys *System) JobSystem() wantjob.System {
 	return sys.jobs
 }
 
+// InspectJob returns information about the job with the given id from the database.
+// The returned store contains the data the job's task refers to.
+func (sys *System) InspectJob(ctx context.Context, jobid wantjob.JobID) (*wantjob.Job, cadata.Getter, error) {
+	var (
+		j   *wantjob.Job
+		sid wantdb.StoreID
+	)
+	if err := dbutil.ROTx(ctx, sys.db, func(tx *sqlx.Tx) error {
+		var err error
+		if j, err = wantdb.InspectJob(tx, jobid); err != nil {
+			return err
+		}
+		sid, err = wantdb.GetJobStoreID(tx, jobid)
+		return err
+	}); err != nil {
+		return nil, nil, err
+	}
+	return j, wantdb.NewDBStore(sys.db, sid), nil
+}
+
 func (sys *System) EvalSnippet(ctx context.Context, repo *wantrepo.Repo, calledFrom string, expr []byte) (*glfs.Ref, cadata.Getter, error) {
 	s := stores.NewMem()
 	c := wantc.NewCompiler()
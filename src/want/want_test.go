@@ -3,15 +3,25 @@ package want
 import (
 	"fmt"
 	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"blobcache.io/glfs"
+	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.brendoncarroll.net/state/cadata"
 
+	"wantbuild.io/want/src/internal/dbutil"
+	"wantbuild.io/want/src/internal/glfstasks"
+	"wantbuild.io/want/src/internal/op/dagops"
+	"wantbuild.io/want/src/internal/op/glfsops"
 	"wantbuild.io/want/src/internal/stores"
 	"wantbuild.io/want/src/internal/testutil"
+	"wantbuild.io/want/src/internal/wantdag"
 	"wantbuild.io/want/src/internal/wantdb"
+	"wantbuild.io/want/src/wantjob"
 )
 
 func TestInit(t *testing.T) {
@@ -94,3 +104,108 @@ func TestEvalNoRepo(t *testing.T) {
 		})
 	}
 }
+
+func TestFirstDivergent(t *testing.T) {
+	mkNode := func(id wantjob.Idx, input, output string, children ...*jobNode) *jobNode {
+		return &jobNode{
+			ID: wantjob.JobID{id},
+			Job: wantjob.Job{
+				Task:   wantjob.Task{Op: "op1", Input: []byte(input)},
+				Result: &wantjob.Result{Root: []byte(output)},
+			},
+			Children: children,
+		}
+	}
+	a := mkNode(0, "root", "out1",
+		mkNode(1, "same", "x"),
+		mkNode(2, "leaf", "y1"),
+		mkNode(3, "downstream", "z1"),
+	)
+	b := mkNode(10, "root", "out2",
+		mkNode(11, "same", "x"),
+		mkNode(12, "leaf", "y2"),
+		mkNode(13, "downstream2", "z2"),
+	)
+	byTask := map[wantjob.TaskID]*jobNode{}
+	b.forEach(func(n *jobNode) { byTask[n.Task.ID()] = n })
+
+	x, y := firstDivergent(a, byTask)
+	require.NotNil(t, x)
+	require.Equal(t, wantjob.JobID{2}, x.ID)
+	require.Equal(t, wantjob.JobID{12}, y.ID)
+
+	x, _ = firstDivergent(a.Children[0], byTask)
+	require.Nil(t, x)
+}
+
+func TestDAGJobs(t *testing.T) {
+	ctx := testutil.Context(t)
+	db := wantdb.NewMemory()
+	require.NoError(t, wantdb.Setup(ctx, db))
+	var count atomic.Int32
+	exec := wantjob.MultiExecutor{
+		"dag": dagops.Executor{},
+		"test": wantjob.BasicExecutor{
+			// rand outputs something different every time it runs.
+			"rand": func(jc wantjob.Ctx, src cadata.Getter, x []byte) wantjob.Result {
+				return glfstasks.Exec(x, func(glfs.Ref) (*glfs.Ref, error) {
+					return glfs.PostBlob(jc.Context, jc.Dst, strings.NewReader(fmt.Sprint(count.Add(1))))
+				})
+			},
+			"copy": func(jc wantjob.Ctx, src cadata.Getter, x []byte) wantjob.Result {
+				return glfstasks.Exec(x, func(ref glfs.Ref) (*glfs.Ref, error) {
+					return &ref, glfstasks.FastSync(jc.Context, jc.Dst, src, ref)
+				})
+			},
+		},
+	}
+	js := newJobSystem(db, t.TempDir(), exec, 2)
+	defer js.Shutdown()
+
+	s := stores.NewMem()
+	b := wantdag.NewBuilder(s)
+	f, err := b.Fact(ctx, s, testutil.PostString(t, s, "seed"))
+	require.NoError(t, err)
+	mustDerived := func(op wantjob.OpName, in wantdag.NodeID) wantdag.NodeID {
+		nid, err := b.Derived(ctx, op, []wantdag.NodeInput{{Node: in}})
+		require.NoError(t, err)
+		return nid
+	}
+	diverges := mustDerived("test.copy", mustDerived("test.rand", f))
+	same := mustDerived("test.copy", f)
+	x := b.Finish()
+	roots := []wantdag.NodeID{diverges, same}
+
+	// build the roots twice, the way builds do, without caching the nodes.
+	var trees [2]*jobNode
+	for i := range trees {
+		sub, _, err := wantdag.Narrow(ctx, s, x, roots, glfsops.Narrowers("glfs."))
+		require.NoError(t, err)
+		subRef, err := wantdag.PostDAG(ctx, s, sub)
+		require.NoError(t, err)
+		ps := &policySystem{jobSystem: js, policy: reproCachePolicy}
+		idx, err := ps.Spawn(ctx, s, wantjob.Task{Op: "dag.execAll", Input: glfstasks.MarshalGLFSRef(*subRef)})
+		require.NoError(t, err)
+		require.NoError(t, ps.Await(ctx, idx))
+		trees[i], err = dbutil.ROTx1(ctx, db, func(tx *sqlx.Tx) (*jobNode, error) {
+			return loadJobTree(tx, wantjob.JobID{idx})
+		})
+		require.NoError(t, err)
+	}
+	byTask := map[wantjob.TaskID]*jobNode{}
+	trees[1].forEach(func(n *jobNode) { byTask[n.Task.ID()] = n })
+
+	dag, ids, jobs, err := dagJobs(ctx, s, x, roots, trees[0])
+	require.NoError(t, err)
+	require.NotNil(t, jobs)
+	require.Equal(t, wantjob.OpName("test.copy"), jobs[ids[0]].Task.Op)
+
+	a, b2 := firstDivergentNode(dag, ids[0], jobs, byTask)
+	require.NotNil(t, a)
+	require.Equal(t, wantjob.OpName("test.rand"), a.Task.Op)
+	require.Equal(t, a.Task, b2.Task)
+	require.False(t, a.sameResult(b2))
+
+	a, _ = firstDivergentNode(dag, ids[1], jobs, byTask)
+	require.Nil(t, a)
+}
@@ -6,4 +6,22 @@ package wantcfg
 type ModuleConfig struct {
 	Ignore    PathSet         `json:"ignore"`
 	Namespace map[string]Expr `json:"namespace"`
+	// Strict requires every import to identify its content by a hash.
+	// Git imports must name a full commit hash, not a branch or tag.
+	Strict bool `json:"strict,omitempty"`
+	// Params are the build parameters which can be set for a build, with their default values.
+	// Values set for a build must have the same JSON type as the default, unless the default is null.
+	Params map[string]any `json:"params,omitempty"`
+	// Profiles are named parts of the build output, which can be built and exported on their own.
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+}
+
+// Profile is a named part of a module's build output.
+type Profile struct {
+	// Query selects the paths in the build output which are in the profile.
+	Query PathSet `json:"query"`
+	// Export is the path in the module which the profile is exported to by want export-repo.
+	// It must be ignored by the module.
+	// If it is empty, the profile is exported to the same paths in the module.
+	Export string `json:"export,omitempty"`
 }
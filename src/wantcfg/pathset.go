@@ -12,6 +12,8 @@ type PathSet struct {
 	Unit      *string   `json:"unit,omitempty"`
 	Prefix    *string   `json:"prefix,omitempty"`
 	Suffix    *string   `json:"suffix,omitempty"`
+	Glob      *string   `json:"glob,omitempty"`
+	Regex     *string   `json:"regex,omitempty"`
 	Not       *PathSet  `json:"not,omitempty"`
 	Union     []PathSet `json:"union,omitempty"`
 	Intersect []PathSet `json:"intersect,omitempty"`
@@ -25,6 +27,10 @@ func (ps PathSet) String() string {
 		return fmt.Sprintf("(prefix %q)", *ps.Prefix)
 	case ps.Suffix != nil:
 		return fmt.Sprintf("(suffix %q)", *ps.Suffix)
+	case ps.Glob != nil:
+		return fmt.Sprintf("(glob %q)", *ps.Glob)
+	case ps.Regex != nil:
+		return fmt.Sprintf("(regex %q)", *ps.Regex)
 
 	case ps.Not != nil:
 		return fmt.Sprintf("(NOT %v)", *ps.Not)
@@ -56,6 +62,17 @@ func Suffix(x string) PathSet {
 	return PathSet{Suffix: &x}
 }
 
+// Glob returns a PathSet containing all paths which match the glob pattern x.
+// "*" and "?" do not match "/", and "**" as a whole path element matches any number of elements.
+func Glob(x string) PathSet {
+	return PathSet{Glob: &x}
+}
+
+// Regex returns a PathSet containing all paths which entirely match the regular expression x.
+func Regex(x string) PathSet {
+	return PathSet{Regex: &x}
+}
+
 // Union returns the union of n PathSets
 func Union(xs ...PathSet) PathSet {
 	return PathSet{Union: xs}
@@ -13,6 +13,11 @@ func (s Statement) Expr() Expr {
 	}
 }
 
+// IsTest returns true if the statement is marked as a test.
+func (s Statement) IsTest() bool {
+	return s.Put != nil && s.Put.Test
+}
+
 type Put struct {
 	// Dst is the PathSet this statement occupies within the module.
 	// Only these paths will be taken from Src.
@@ -21,4 +26,6 @@ type Put struct {
 	Src Expr `json:"src"`
 	// Place will wrap Src in place operation for the given path
 	Place string `json:"place,omitempty"`
+	// Test marks the statement as a test, which is run by want test.
+	Test bool `json:"test,omitempty"`
 }
@@ -12,7 +12,7 @@ var blameCmd = star.Command{
 	Metadata: star.Metadata{
 		Short: "check what produces what",
 	},
-	Flags: []star.IParam{},
+	Flags: []star.IParam{setParam},
 	F: func(c star.Context) error {
 		ctx := c.Context
 		wbs, err := newSys(&c)
@@ -20,7 +20,7 @@ var blameCmd = star.Command{
 			return err
 		}
 		defer wbs.Close()
-		repo, err := openRepo()
+		repo, err := openRepoWithParams(c)
 		if err != nil {
 			return err
 		}
@@ -7,29 +7,33 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"slices"
 	"time"
 
 	"blobcache.io/glfs"
 	"blobcache.io/glfs/glfsiofs"
 	"github.com/pkg/errors"
-	"go.brendoncarroll.net/exp/slices2"
 	"go.brendoncarroll.net/star"
 
 	"wantbuild.io/want/src/internal/glfstasks"
 	"wantbuild.io/want/src/internal/stringsets"
 	"wantbuild.io/want/src/internal/wantc"
+	"wantbuild.io/want/src/internal/wantrepo"
 	"wantbuild.io/want/src/want"
 	"wantbuild.io/want/src/wantcfg"
 )
 
 var buildCmd = star.Command{
 	Metadata: star.Metadata{Short: "run a build"},
-	Flags:    []star.IParam{},
+	Flags:    []star.IParam{profileParam, setParam},
 	Pos:      []star.IParam{pathsParam},
 	F: func(c star.Context) error {
 		startTime := time.Now()
 		// query
-		q := mkBuildQuery(pathsParam.LoadAll(c)...)
+		q, err := cmdQuery(c, pathsParam.LoadAll(c)...)
+		if err != nil {
+			return err
+		}
 		res, close, err := doBuild(c, q)
 		if err != nil {
 			return err
@@ -38,7 +42,7 @@ var buildCmd = star.Command{
 		dur := time.Since(startTime)
 		if res.OutputRoot != nil {
 			c.Printf("INPUT: %v\n", res.Source)
-			c.Printf("QUERY: %v\n", q)
+			c.Printf("QUERY: %v\n", res.Query)
 		}
 		for i, targ := range res.Targets {
 			tres := res.TargetResults[i]
@@ -60,17 +64,33 @@ var buildCmd = star.Command{
 
 var lsCmd = star.Command{
 	Metadata: star.Metadata{Short: "list tree entries in the build output"},
-	Flags:    []star.IParam{},
+	Flags:    []star.IParam{profileParam, setParam},
 	Pos:      []star.IParam{pathParam},
 	F: func(c star.Context) error {
 		ctx := c.Context
-		p := pathParam.Load(c)
-		q := mkBuildQuery(p)
+		arg := pathParam.Load(c)
+		q, err := cmdQuery(c, arg)
+		if err != nil {
+			return err
+		}
+		var p string
+		if profileParam.Load(c) == "" {
+			if isPattern(arg) {
+				return fmt.Errorf("cannot ls %q, it is a pattern", arg)
+			}
+			if p, err = modulePath(arg); err != nil {
+				return err
+			}
+			q = wantcfg.Prefix(p)
+		}
 		res, close, err := doBuild(c, q)
 		if err != nil {
 			return err
 		}
 		defer close()
+		if profileParam.Load(c) != "" {
+			p = wantc.BoundingPrefix(res.Query)
+		}
 		src := res.Store
 		ref := res.OutputRoot
 		if ref == nil {
@@ -97,23 +117,61 @@ var lsCmd = star.Command{
 
 var catCmd = star.Command{
 	Metadata: star.Metadata{Short: "concatenate files from the build output and write them to stdout"},
-	Flags:    []star.IParam{},
+	Flags:    []star.IParam{profileParam, setParam},
 	Pos:      []star.IParam{pathsParam},
 	F: func(c star.Context) error {
 		ctx := c.Context
-		ps := pathsParam.LoadAll(c)
-		q := mkBuildQuery(pathsParam.LoadAll(c)...)
+		args := pathsParam.LoadAll(c)
+		q, err := cmdQuery(c, args...)
+		if err != nil {
+			return err
+		}
 		res, close, err := doBuild(c, q)
 		if err != nil {
 			return err
 		}
 		defer close()
 		src := res.Store
-		ref := res.OutputRoot
+		if res.OutputRoot == nil {
+			return fmt.Errorf("cannot cat, errors occured in build. see want build")
+		}
+		// find the files
+		var ps []string
+		if name := profileParam.Load(c); name != "" {
+			if ps, err = matchPaths(ctx, src, *res.OutputRoot, res.Query); err != nil {
+				return err
+			}
+			if len(ps) == 0 {
+				return fmt.Errorf("profile %q does not contain any files", name)
+			}
+		} else {
+			for _, arg := range args {
+				if !isPattern(arg) {
+					p, err := modulePath(arg)
+					if err != nil {
+						return err
+					}
+					ps = append(ps, p)
+					continue
+				}
+				q, err := mkBuildQuery(arg)
+				if err != nil {
+					return err
+				}
+				matched, err := matchPaths(ctx, src, *res.OutputRoot, q)
+				if err != nil {
+					return err
+				}
+				if len(matched) == 0 {
+					return fmt.Errorf("no files match %q", arg)
+				}
+				ps = append(ps, matched...)
+			}
+		}
 		// process the output
 		w := c.StdOut
 		for _, p := range ps {
-			ref, err = glfs.GetAtPath(ctx, src, *ref, p)
+			ref, err := glfs.GetAtPath(ctx, src, *res.OutputRoot, p)
 			if err != nil {
 				return err
 			}
@@ -135,10 +193,13 @@ var catCmd = star.Command{
 var serveHttpCmd = star.Command{
 	Metadata: star.Metadata{Short: "serve the build output over http"},
 	Pos:      []star.IParam{pathParam},
-	Flags:    []star.IParam{},
+	Flags:    []star.IParam{profileParam, setParam},
 	F: func(c star.Context) error {
 		ctx := c.Context
-		q := mkBuildQuery(pathParam.Load(c))
+		q, err := cmdQuery(c, pathParam.Load(c))
+		if err != nil {
+			return err
+		}
 		res, close, err := doBuild(c, q)
 		if err != nil {
 			return err
@@ -152,7 +213,7 @@ var serveHttpCmd = star.Command{
 		if ref == nil {
 			return fmt.Errorf("error during build")
 		}
-		ref, err = glfs.GetAtPath(ctx, src, *ref, wantc.BoundingPrefix(q))
+		ref, err = glfs.GetAtPath(ctx, src, *ref, wantc.BoundingPrefix(res.Query))
 		if err != nil {
 			return err
 		}
@@ -177,9 +238,12 @@ var serveHttpCmd = star.Command{
 var exportZipCmd = star.Command{
 	Metadata: star.Metadata{Short: "export the build output to zip file"},
 	Pos:      []star.IParam{pathParam},
-	Flags:    []star.IParam{outParam},
+	Flags:    []star.IParam{outParam, profileParam, setParam},
 	F: func(c star.Context) error {
-		q := mkBuildQuery(pathParam.Load(c))
+		q, err := cmdQuery(c, pathParam.Load(c))
+		if err != nil {
+			return err
+		}
 		res, close, err := doBuild(c, q)
 		if err != nil {
 			return err
@@ -190,7 +254,7 @@ var exportZipCmd = star.Command{
 		if ref == nil {
 			return fmt.Errorf("error during build")
 		}
-		ref, err = glfs.GetAtPath(c.Context, src, *ref, wantc.BoundingPrefix(q))
+		ref, err = glfs.GetAtPath(c.Context, src, *ref, wantc.BoundingPrefix(res.Query))
 		if err != nil {
 			return err
 		}
@@ -208,27 +272,32 @@ var exportZipCmd = star.Command{
 
 var exportRepoCmd = star.Command{
 	Metadata: star.Metadata{Short: "export build targets to local repo"},
-	Flags:    []star.IParam{unitPathSetParam, prefixPathSetParam, suffixPathSetParam},
+	Flags:    []star.IParam{unitPathSetParam, prefixPathSetParam, suffixPathSetParam, profileParam, setParam},
 	F: func(c star.Context) error {
 		ctx := c.Context
-
 		var psets []wantcfg.PathSet
 		psets = append(psets, unitPathSetParam.LoadAll(c)...)
 		psets = append(psets, prefixPathSetParam.LoadAll(c)...)
 		psets = append(psets, suffixPathSetParam.LoadAll(c)...)
+		if name := profileParam.Load(c); name != "" {
+			if len(psets) > 0 {
+				return errProfilePaths
+			}
+			return exportProfile(c, name)
+		}
 		if len(psets) == 0 {
-			return fmt.Errorf("must provide a path set")
+			return fmt.Errorf("must provide a path set or a profile")
 		}
 		q := wantcfg.Union(psets...)
 
-		repo, err := openRepo()
+		repo, err := openRepoWithParams(c)
 		if err != nil {
 			return err
 		}
 		if !supersets(repo.Config().Ignore, q) {
 			return fmt.Errorf("can only export to paths which are ignored in the module config")
 		}
-		res, close, err := doBuild(c, q)
+		res, close, err := buildRepo(c, repo, q)
 		if err != nil {
 			return err
 		}
@@ -259,32 +328,77 @@ var exportRepoCmd = star.Command{
 	},
 }
 
-func doBuild(c star.Context, q wantcfg.PathSet) (*want.BuildResult, func(), error) {
+// exportProfile builds the profile called name, and exports it to the local repo.
+func exportProfile(c star.Context, name string) error {
 	ctx := c.Context
-	wbs, err := newSys(&c)
+	repo, err := openRepoWithParams(c)
 	if err != nil {
-		return nil, nil, err
+		return err
+	}
+	from, dst, err := repo.ProfileExport(name)
+	if err != nil {
+		return err
+	}
+	res, close, err := buildRepo(c, repo, wantcfg.PathSet{})
+	if err != nil {
+		return err
+	}
+	defer close()
+	if res.OutputRoot == nil {
+		return fmt.Errorf("error during build")
+	}
+	ref, err := glfs.GetAtPath(ctx, res.Store, *res.OutputRoot, from)
+	if err != nil {
+		return err
+	}
+	if err := repo.Export(ctx, res.Store, dst, *ref); err != nil {
+		return err
+	}
+	c.Printf("%s\n", dst)
+	return c.StdOut.Flush()
+}
+
+// cmdQuery returns the PathSet for the path arguments to a command which builds the profile set by --profile instead.
+// Paths cannot be given along with --profile.
+func cmdQuery(c star.Context, args ...string) (wantcfg.PathSet, error) {
+	args = slices.DeleteFunc(args, func(arg string) bool { return arg == "" })
+	if profileParam.Load(c) != "" {
+		if len(args) > 0 {
+			return wantcfg.PathSet{}, errProfilePaths
+		}
+		return wantcfg.PathSet{}, nil
 	}
-	repo, err := openRepo()
+	return mkBuildQuery(args...)
+}
+
+var errProfilePaths = errors.New("paths cannot be given with --profile, the profile selects the paths")
+
+// doBuild builds the paths in q, or the profile set by --profile.
+func doBuild(c star.Context, q wantcfg.PathSet) (*want.BuildResult, func(), error) {
+	repo, err := openRepoWithParams(c)
 	if err != nil {
 		return nil, nil, err
 	}
-	res, err := wbs.Build(ctx, repo, q)
-	return res, func() { wbs.Close() }, err
+	return buildRepo(c, repo, q)
 }
 
-// TODO: support paths relative to working directory within the module
-func mkBuildQuery(prefixes ...string) wantcfg.PathSet {
-	var q wantcfg.PathSet
-	switch len(prefixes) {
-	case 0:
-		q = wantcfg.Prefix("")
-	case 1:
-		q = wantcfg.Prefix(prefixes[0])
-	default:
-		q = wantcfg.Union(slices2.Map(prefixes, wantcfg.Prefix)...)
+// buildRepo builds the paths in q from repo, or the profile set by --profile, in which case q is ignored.
+func buildRepo(c star.Context, repo *wantrepo.Repo, q wantcfg.PathSet) (*want.BuildResult, func(), error) {
+	ctx := c.Context
+	wbs, err := newSys(&c)
+	if err != nil {
+		return nil, nil, err
+	}
+	var res *want.BuildResult
+	if name := profileParam.Load(c); name != "" {
+		res, err = wbs.BuildProfile(ctx, repo, name)
+	} else {
+		res, err = wbs.Build(ctx, repo, q)
+	}
+	if err != nil {
+		err = reportDiagnostic(&c, repo, err)
 	}
-	return q
+	return res, func() { wbs.Close() }, err
 }
 
 func supersets(a, b wantcfg.PathSet) bool {
@@ -322,6 +436,12 @@ var suffixPathSetParam = star.Param[wantcfg.PathSet]{
 	},
 }
 
+var profileParam = star.Param[string]{
+	Name:    "profile",
+	Default: star.Ptr(""),
+	Parse:   star.ParseString,
+}
+
 var pathParam = star.Param[string]{
 	Name:     "path",
 	Repeated: false,
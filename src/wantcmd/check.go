@@ -0,0 +1,131 @@
+package wantcmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.brendoncarroll.net/star"
+
+	"wantbuild.io/want/src/internal/wantc"
+	"wantbuild.io/want/src/internal/wantrepo"
+)
+
+var checkCmd = star.Command{
+	Metadata: star.Metadata{Short: "compile the module and report any errors"},
+	Flags:    []star.IParam{jsonParam, setParam},
+	F: func(c star.Context) error {
+		ctx := c.Context
+		wbs, err := newSys(&c)
+		if err != nil {
+			return err
+		}
+		defer wbs.Close()
+		repo, err := openRepoWithParams(c)
+		if err != nil {
+			return err
+		}
+		_, err = wbs.Blame(ctx, repo)
+		var diag *wantc.Diagnostic
+		if err != nil && !errors.As(err, &diag) {
+			return err
+		}
+		if asJSON, _ := jsonParam.LoadOpt(c); asJSON {
+			diags := []wantc.Diagnostic{}
+			if diag != nil {
+				diags = append(diags, *diag)
+			}
+			if err := json.NewEncoder(c.StdOut).Encode(diags); err != nil {
+				return err
+			}
+		} else if diag != nil {
+			renderDiagnostic(c.StdOut, repo.RootPath(), *diag)
+		}
+		if err := c.StdOut.Flush(); err != nil {
+			return err
+		}
+		if diag != nil {
+			return errors.New("compilation failed")
+		}
+		return nil
+	},
+}
+
+var jsonParam = star.Param[bool]{
+	Name:    "json",
+	Default: star.Ptr("false"),
+	Parse:   strconv.ParseBool,
+}
+
+// reportDiagnostic writes err to stderr with source snippets, if it is a compiler diagnostic.
+func reportDiagnostic(c *star.Context, repo *wantrepo.Repo, err error) error {
+	var diag *wantc.Diagnostic
+	if !errors.As(err, &diag) {
+		return err
+	}
+	renderDiagnostic(c.StdErr, repo.RootPath(), *diag)
+	if err := c.StdErr.Flush(); err != nil {
+		return err
+	}
+	return errors.New("compilation failed")
+}
+
+// renderDiagnostic writes d to w, with the source lines from the module at root.
+func renderDiagnostic(w io.Writer, root string, d wantc.Diagnostic) {
+	msg := d.Message
+	if d.StmtNum != nil {
+		msg = fmt.Sprintf("statement %d: %s", *d.StmtNum, msg)
+	}
+	fmt.Fprintf(w, "%v: %s\n", d.Pos, msg)
+	renderSnippet(w, root, d.Pos, "")
+	for _, r := range d.Related {
+		fmt.Fprintf(w, "  %v: %s\n", r.Pos, r.Message)
+		renderSnippet(w, root, r.Pos, "  ")
+	}
+}
+
+// renderSnippet writes the line at pos, and a marker under the column.
+func renderSnippet(w io.Writer, root string, pos wantc.Position, indent string) {
+	if pos.Module != "" || pos.Line < 1 {
+		return
+	}
+	line, ok := readLine(filepath.Join(root, filepath.FromSlash(pos.Path)), pos.Line)
+	if !ok {
+		return
+	}
+	num := fmt.Sprint(pos.Line)
+	gutter := strings.Repeat(" ", len(num))
+	fmt.Fprintf(w, "%s %s | %s\n", indent, num, line)
+	if pos.Column < 1 || pos.Column > len(line)+1 {
+		return
+	}
+	// keep tabs, so the marker lines up with the source
+	marker := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, line[:pos.Column-1])
+	fmt.Fprintf(w, "%s %s | %s^\n", indent, gutter, marker)
+}
+
+func readLine(p string, n int) (string, bool) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for i := 1; sc.Scan(); i++ {
+		if i == n {
+			return sc.Text(), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,62 @@
+package wantcmd
+
+import (
+	"bufio"
+	"fmt"
+
+	"blobcache.io/glfs"
+	"go.brendoncarroll.net/star"
+
+	"wantbuild.io/want/src/internal/glfsarchive"
+	"wantbuild.io/want/src/internal/wantc"
+)
+
+var exportCmd = star.Command{
+	Metadata: star.Metadata{Short: "export the build output to an archive file"},
+	Pos:      []star.IParam{pathParam},
+	Flags:    []star.IParam{outParam, formatParam, profileParam, setParam},
+	F: func(c star.Context) error {
+		format, ok := formatParam.LoadOpt(c)
+		if !ok {
+			format = glfsarchive.FormatTar
+		}
+		out := outParam.Load(c)
+		defer out.Close()
+		q, err := cmdQuery(c, pathParam.Load(c))
+		if err != nil {
+			return err
+		}
+		res, close, err := doBuild(c, q)
+		if err != nil {
+			return err
+		}
+		defer close()
+		src := res.Store
+		ref := res.OutputRoot
+		if ref == nil {
+			return fmt.Errorf("error during build")
+		}
+		ref, err = glfs.GetAtPath(c.Context, src, *ref, wantc.BoundingPrefix(res.Query))
+		if err != nil {
+			return err
+		}
+		bw := bufio.NewWriter(out)
+		if err := glfsarchive.Write(c.Context, src, *ref, format, bw); err != nil {
+			return err
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+		c.Printf("%v\n", out.Name())
+		return c.StdOut.Flush()
+	},
+}
+
+var formatParam = star.Param[glfsarchive.Format]{
+	Name:    "format",
+	Default: star.Ptr(string(glfsarchive.FormatTar)),
+	Parse:   glfsarchive.ParseFormat,
+}
@@ -0,0 +1,78 @@
+package wantcmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.brendoncarroll.net/star"
+
+	"wantbuild.io/want/src/internal/wantc"
+	"wantbuild.io/want/src/internal/wantfmt"
+)
+
+var fmtCmd = star.Command{
+	Metadata: star.Metadata{Short: "format the Jsonnet files in the module"},
+	Flags:    []star.IParam{checkParam},
+	Pos:      []star.IParam{pathsParam},
+	F: func(c star.Context) error {
+		repo, err := openRepo()
+		if err != nil {
+			return err
+		}
+		check, _ := checkParam.LoadOpt(c)
+		q, err := mkBuildQuery(pathsParam.LoadAll(c)...)
+		if err != nil {
+			return err
+		}
+		set := wantc.SetFromQuery("", q)
+		// walk the deepest directory which contains every path in q
+		dir := wantc.BoundingPrefix(q)
+		if finfo, err := os.Stat(filepath.Join(repo.RootPath(), filepath.FromSlash(dir))); err != nil || !finfo.IsDir() {
+			dir = strings.TrimSuffix(path.Dir(dir), ".")
+		}
+		var unformatted int
+		if err := repo.WalkFiles(dir, func(p string) error {
+			if !wantfmt.IsJsonnetPath(p) || !set.Contains(p) {
+				return nil
+			}
+			fp := filepath.Join(repo.RootPath(), filepath.FromSlash(p))
+			data, err := os.ReadFile(fp)
+			if err != nil {
+				return err
+			}
+			out, err := wantfmt.Jsonnet(p, data)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(data, out) {
+				return nil
+			}
+			unformatted++
+			c.Printf("%s\n", p)
+			if check {
+				return nil
+			}
+			return os.WriteFile(fp, out, 0o644)
+		}); err != nil {
+			return err
+		}
+		if err := c.StdOut.Flush(); err != nil {
+			return err
+		}
+		if check && unformatted > 0 {
+			return fmt.Errorf("%d files are not formatted", unformatted)
+		}
+		return nil
+	},
+}
+
+var checkParam = star.Param[bool]{
+	Name:    "check",
+	Default: star.Ptr("false"),
+	Parse:   strconv.ParseBool,
+}
@@ -4,19 +4,23 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"go.brendoncarroll.net/star"
+
+	"wantbuild.io/want/src/want"
 	"wantbuild.io/want/src/wantjob"
 )
 
 var jobCmd = star.NewDir(star.Metadata{
 	Short: "inspect and manage jobs",
 }, map[star.Symbol]star.Command{
-	"ls":   lsJobCmd,
-	"drop": dropJobCmd,
+	"ls":    lsJobCmd,
+	"drop":  dropJobCmd,
+	"rerun": rerunJobCmd,
 })
 
 var lsJobCmd = star.Command{
@@ -62,6 +66,39 @@ var dropJobCmd = star.Command{
 	},
 }
 
+var rerunJobCmd = star.Command{
+	Metadata: star.Metadata{Short: "execute a job's task again and compare the result to the recorded one"},
+	Pos:      []star.IParam{jobidParam},
+	Flags:    []star.IParam{noCacheParam},
+	F: func(c star.Context) error {
+		wbs, err := newSys(&c)
+		if err != nil {
+			return err
+		}
+		defer wbs.Close()
+		jobid := jobidParam.Load(c)
+		noCache, _ := noCacheParam.LoadOpt(c)
+		rr, err := wbs.Rerun(c.Context, jobid, noCache)
+		if err != nil {
+			return err
+		}
+		c.Printf("OP: %v\n", rr.Task.Op)
+		c.Printf("RECORDED: %v %s\n", rr.Recorded.ErrCode, rr.Recorded.Root)
+		c.Printf("RERUN(%v): %v %s\n", rr.ID, rr.Result.ErrCode, rr.Result.Root)
+		if rr.Same() {
+			c.Printf("SAME\n")
+			return c.StdOut.Flush()
+		}
+		for _, p := range rr.Diff {
+			c.Printf("  DIFF: %q\n", p)
+		}
+		if err := c.StdOut.Flush(); err != nil {
+			return err
+		}
+		return fmt.Errorf("rerun of job %v produced a different result", jobid)
+	},
+}
+
 var dashCmd = star.Command{
 	Metadata: star.Metadata{Short: "serve dashboard on localhost"},
 	F: func(c star.Context) error {
@@ -82,6 +119,12 @@ var dashCmd = star.Command{
 	},
 }
 
+var noCacheParam = star.Param[bool]{
+	Name:    "no-cache",
+	Default: star.Ptr("false"),
+	Parse:   strconv.ParseBool,
+}
+
 var jobidParam = star.Param[wantjob.JobID]{
 	Parse: func(x string) (wantjob.JobID, error) {
 		parts := strings.Split(strings.Trim(x, "/"), "/")
@@ -99,3 +142,20 @@ var jobidParam = star.Param[wantjob.JobID]{
 		return ret, nil
 	},
 }
+
+var debugCmd = star.Command{
+	Metadata: star.Metadata{Short: "rerun a failed microvm job with a console attached to the terminal"},
+	Pos:      []star.IParam{jobidParam},
+	F: func(c star.Context) error {
+		wbs, err := newSys(&c)
+		if err != nil {
+			return err
+		}
+		defer wbs.Close()
+		jobid := jobidParam.Load(c)
+		return wbs.Debug(c.Context, jobid, want.QEMUConsole{
+			In:  os.Stdin,
+			Out: os.Stdout,
+		})
+	},
+}
@@ -0,0 +1,51 @@
+package wantcmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.brendoncarroll.net/star"
+
+	"wantbuild.io/want/src/internal/wantc"
+)
+
+var lintCmd = star.Command{
+	Metadata: star.Metadata{Short: "report likely mistakes in the module"},
+	Flags:    []star.IParam{jsonParam, setParam},
+	F: func(c star.Context) error {
+		ctx := c.Context
+		wbs, err := newSys(&c)
+		if err != nil {
+			return err
+		}
+		defer wbs.Close()
+		repo, err := openRepoWithParams(c)
+		if err != nil {
+			return err
+		}
+		diags, err := wbs.Lint(ctx, repo)
+		if err != nil {
+			return err
+		}
+		if asJSON, _ := jsonParam.LoadOpt(c); asJSON {
+			out := []wantc.Diagnostic{}
+			for _, d := range diags {
+				out = append(out, *d)
+			}
+			if err := json.NewEncoder(c.StdOut).Encode(out); err != nil {
+				return err
+			}
+		} else {
+			for _, d := range diags {
+				renderDiagnostic(c.StdOut, repo.RootPath(), *d)
+			}
+		}
+		if err := c.StdOut.Flush(); err != nil {
+			return err
+		}
+		if len(diags) > 0 {
+			return fmt.Errorf("%d problems found", len(diags))
+		}
+		return nil
+	},
+}
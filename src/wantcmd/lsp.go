@@ -0,0 +1,32 @@
+package wantcmd
+
+import (
+	"context"
+
+	"go.brendoncarroll.net/star"
+
+	"wantbuild.io/want/src/internal/wantc"
+	"wantbuild.io/want/src/internal/wantlsp"
+)
+
+var lspCmd = star.Command{
+	Metadata: star.Metadata{Short: "run a language server for the module, over stdin and stdout"},
+	F: func(c star.Context) error {
+		wbs, err := newSys(&c)
+		if err != nil {
+			return err
+		}
+		defer wbs.Close()
+		repo, err := openRepo()
+		if err != nil {
+			return err
+		}
+		return wantlsp.Serve(c.Context, wantlsp.Config{
+			Root: repo.RootPath(),
+			Compile: func(ctx context.Context) ([]wantc.Target, error) {
+				return wbs.Blame(ctx, repo)
+			},
+			Materialize: wbs.Materialize,
+		}, c.StdIn, c.StdOut)
+	},
+}
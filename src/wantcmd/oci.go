@@ -0,0 +1,68 @@
+package wantcmd
+
+import (
+	"fmt"
+
+	"blobcache.io/glfs"
+	"go.brendoncarroll.net/star"
+
+	"wantbuild.io/want/src/internal/op/ociops"
+	"wantbuild.io/want/src/internal/wantc"
+)
+
+var exportOCICmd = star.Command{
+	Metadata: star.Metadata{Short: "export an OCI image layout from the build output to a directory or a registry"},
+	Pos:      []star.IParam{pathParam},
+	Flags:    []star.IParam{ociDirParam, ociPushParam, profileParam, setParam},
+	F: func(c star.Context) error {
+		ctx := c.Context
+		dir, _ := ociDirParam.LoadOpt(c)
+		dst, _ := ociPushParam.LoadOpt(c)
+		hasDir, hasPush := dir != "", dst != ""
+		if hasDir == hasPush {
+			return fmt.Errorf("exactly one of --dir or --push must be provided")
+		}
+		q, err := cmdQuery(c, pathParam.Load(c))
+		if err != nil {
+			return err
+		}
+		res, close, err := doBuild(c, q)
+		if err != nil {
+			return err
+		}
+		defer close()
+		src := res.Store
+		ref := res.OutputRoot
+		if ref == nil {
+			return fmt.Errorf("error during build")
+		}
+		ref, err = glfs.GetAtPath(ctx, src, *ref, wantc.BoundingPrefix(res.Query))
+		if err != nil {
+			return err
+		}
+		if hasDir {
+			if err := ociops.ExportLayout(ctx, src, *ref, dir); err != nil {
+				return err
+			}
+			c.Printf("%s\n", dir)
+		} else {
+			if err := ociops.PushLayout(ctx, src, *ref, dst); err != nil {
+				return err
+			}
+			c.Printf("%s\n", dst)
+		}
+		return c.StdOut.Flush()
+	},
+}
+
+var ociDirParam = star.Param[string]{
+	Name:    "dir",
+	Default: star.Ptr(""),
+	Parse:   star.ParseString,
+}
+
+var ociPushParam = star.Param[string]{
+	Name:    "push",
+	Default: star.Ptr(""),
+	Parse:   star.ParseString,
+}
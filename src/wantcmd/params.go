@@ -0,0 +1,51 @@
+package wantcmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.brendoncarroll.net/star"
+
+	"wantbuild.io/want/src/internal/wantrepo"
+)
+
+// buildParam is a build parameter set on the command line.
+type buildParam struct {
+	Key   string
+	Value any
+}
+
+// setParam sets a build parameter, as key=value.
+// The value is parsed as JSON, and used as a string if it is not valid JSON.
+var setParam = star.Param[buildParam]{
+	Name:     "set",
+	Repeated: true,
+	Parse: func(s string) (buildParam, error) {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok || k == "" {
+			return buildParam{}, fmt.Errorf("build parameters must be key=value, have %q", s)
+		}
+		var x any
+		if err := json.Unmarshal([]byte(v), &x); err != nil {
+			x = v
+		}
+		return buildParam{Key: k, Value: x}, nil
+	},
+}
+
+// openRepoWithParams opens the repo, with the build parameters set by the --set flag.
+func openRepoWithParams(c star.Context) (*wantrepo.Repo, error) {
+	repo, err := openRepo()
+	if err != nil {
+		return nil, err
+	}
+	if bps := setParam.LoadAll(c); len(bps) > 0 {
+		params := map[string]any{}
+		for _, bp := range bps {
+			params[bp.Key] = bp.Value
+		}
+		repo.SetParams(params)
+	}
+	return repo, nil
+}
@@ -0,0 +1,163 @@
+package wantcmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"blobcache.io/glfs"
+	"go.brendoncarroll.net/state/cadata"
+
+	"wantbuild.io/want/src/internal/wantc"
+	"wantbuild.io/want/src/internal/wantrepo"
+	"wantbuild.io/want/src/wantcfg"
+)
+
+// workingDir returns the root of the module containing the working directory, and the working directory.
+func workingDir() (root, wd string, _ error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", "", err
+	}
+	yes, root, err := wantrepo.FindRepo(wd)
+	if err != nil {
+		return "", "", err
+	}
+	if !yes {
+		return "", "", fmt.Errorf("%s is not in a want project", wd)
+	}
+	return root, wd, nil
+}
+
+// modulePath returns the path in the module for p, which is relative to the working directory.
+func modulePath(p string) (string, error) {
+	root, wd, err := workingDir()
+	if err != nil {
+		return "", err
+	}
+	return resolvePath(root, wd, p)
+}
+
+// resolvePath returns the path in the module at root for p, which is relative to wd.
+// The root of the module is the empty path.
+func resolvePath(root, wd, p string) (string, error) {
+	fp := filepath.FromSlash(p)
+	if !filepath.IsAbs(fp) {
+		fp = filepath.Join(wd, fp)
+	}
+	rel, err := filepath.Rel(root, fp)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("path %q is outside of the module at %s", p, root)
+	}
+	if rel == "." {
+		rel = ""
+	}
+	return rel, nil
+}
+
+// mkBuildQuery returns the PathSet for the path arguments to a command.
+// With no arguments, it is the working directory.
+func mkBuildQuery(args ...string) (wantcfg.PathSet, error) {
+	root, wd, err := workingDir()
+	if err != nil {
+		return wantcfg.PathSet{}, err
+	}
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	var qs []wantcfg.PathSet
+	for _, arg := range args {
+		q, err := pathQuery(root, wd, arg)
+		if err != nil {
+			return wantcfg.PathSet{}, err
+		}
+		qs = append(qs, q)
+	}
+	if len(qs) == 1 {
+		return qs[0], nil
+	}
+	return wantcfg.Union(qs...), nil
+}
+
+// pathQuery returns the PathSet for a single path argument, which is relative to wd.
+//   - A path ending in "/...", or "..." alone, is everything in that directory.
+//   - A path containing a wildcard is a glob, so "*.json" is every path ending in ".json" in the directory.
+//   - A path ending in "/", or naming "." or "..", is that directory.
+//   - Any other path is a prefix, so "foo" includes "foo.want" and "foo/bar".
+func pathQuery(root, wd, arg string) (wantcfg.PathSet, error) {
+	switch {
+	case arg == "..." || strings.HasSuffix(arg, "/..."):
+		p, err := resolvePath(root, wd, strings.TrimSuffix(arg, "..."))
+		if err != nil {
+			return wantcfg.PathSet{}, err
+		}
+		return dirQuery(p), nil
+	case isGlob(arg):
+		// resolve the directories before the first wildcard
+		i := strings.IndexAny(arg, globMeta)
+		dir, rest := path.Split(arg[:i])
+		p, err := resolvePath(root, wd, dir)
+		if err != nil {
+			return wantcfg.PathSet{}, err
+		}
+		if p != "" {
+			p += "/"
+		}
+		q := wantcfg.Glob(p + rest + arg[i:])
+		return q, wantc.CheckPathSet(q)
+	default:
+		p, err := resolvePath(root, wd, arg)
+		if err != nil {
+			return wantcfg.PathSet{}, err
+		}
+		switch base := path.Base(arg); {
+		case strings.HasSuffix(arg, "/") || base == "." || base == "..":
+			return dirQuery(p), nil
+		default:
+			return wantcfg.Prefix(p), nil
+		}
+	}
+}
+
+// globMeta are the characters which make a path argument a glob.
+const globMeta = "*?["
+
+func isGlob(arg string) bool {
+	return strings.ContainsAny(arg, globMeta)
+}
+
+// isPattern returns true if arg selects paths with a pattern, rather than naming a single path.
+func isPattern(arg string) bool {
+	return arg == "..." || strings.HasSuffix(arg, "/...") || isGlob(arg)
+}
+
+// matchPaths returns the paths of the blobs in root which are in q.
+func matchPaths(ctx context.Context, src cadata.Getter, root glfs.Ref, q wantcfg.PathSet) ([]string, error) {
+	set := wantc.SetFromQuery("", q)
+	var ret []string
+	if err := glfs.WalkTree(ctx, src, root, func(prefix string, ent glfs.TreeEntry) error {
+		p := path.Join(prefix, ent.Name)
+		if ent.Ref.Type == glfs.TypeBlob && set.Contains(p) {
+			ret = append(ret, p)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// dirQuery returns the PathSet for the directory at p.
+func dirQuery(p string) wantcfg.PathSet {
+	if p == "" {
+		return wantcfg.Prefix("")
+	}
+	return wantcfg.DirPath(p)
+}
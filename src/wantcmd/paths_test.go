@@ -0,0 +1,65 @@
+package wantcmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"wantbuild.io/want/src/wantcfg"
+)
+
+func TestPathQuery(t *testing.T) {
+	root := filepath.FromSlash("/home/user/mod")
+	sub := filepath.Join(root, "site")
+	tcs := []struct {
+		WD  string
+		Arg string
+
+		Path  string
+		Query wantcfg.PathSet
+		Err   bool
+	}{
+		{WD: root, Arg: ".", Path: "", Query: wantcfg.Prefix("")},
+		{WD: root, Arg: "", Path: "", Query: wantcfg.Prefix("")},
+		{WD: root, Arg: "foo", Path: "foo", Query: wantcfg.Prefix("foo")},
+		{WD: root, Arg: "foo/", Path: "foo", Query: wantcfg.DirPath("foo")},
+		{WD: sub, Arg: ".", Path: "site", Query: wantcfg.DirPath("site")},
+		{WD: sub, Arg: "a.json", Path: "site/a.json", Query: wantcfg.Prefix("site/a.json")},
+		{WD: sub, Arg: "..", Path: "", Query: wantcfg.Prefix("")},
+		{WD: sub, Arg: "../docs", Path: "docs", Query: wantcfg.Prefix("docs")},
+		{WD: sub, Arg: filepath.Join(root, "docs"), Path: "docs", Query: wantcfg.Prefix("docs")},
+		{WD: sub, Arg: "../..", Err: true},
+		{WD: root, Arg: "../other", Err: true},
+		{WD: root, Arg: "./...", Query: wantcfg.Prefix("")},
+		{WD: root, Arg: "...", Query: wantcfg.Prefix("")},
+		{WD: sub, Arg: "./...", Query: wantcfg.DirPath("site")},
+		{WD: sub, Arg: "css/...", Query: wantcfg.DirPath("site/css")},
+		{WD: sub, Arg: "../...", Query: wantcfg.Prefix("")},
+		{WD: sub, Arg: "../../...", Err: true},
+		{WD: root, Arg: "*.json", Query: wantcfg.Glob("*.json")},
+		{WD: sub, Arg: "*.json", Query: wantcfg.Glob("site/*.json")},
+		{WD: sub, Arg: "**/*.json", Query: wantcfg.Glob("site/**/*.json")},
+		{WD: sub, Arg: "css/*.css", Query: wantcfg.Glob("site/css/*.css")},
+		{WD: sub, Arg: "../docs/*.md", Query: wantcfg.Glob("docs/*.md")},
+		{WD: sub, Arg: "../../*.md", Err: true},
+		{WD: root, Arg: "[a", Err: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Arg, func(t *testing.T) {
+			if tc.Err {
+				_, err := pathQuery(root, tc.WD, tc.Arg)
+				require.Error(t, err)
+				return
+			}
+			if !isPattern(tc.Arg) {
+				p, err := resolvePath(root, tc.WD, tc.Arg)
+				require.NoError(t, err)
+				require.Equal(t, tc.Path, p)
+			}
+			q, err := pathQuery(root, tc.WD, tc.Arg)
+			require.NoError(t, err)
+			require.Equal(t, tc.Query, q)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package wantcmd
+
+import (
+	"fmt"
+
+	"go.brendoncarroll.net/star"
+)
+
+var pinCmd = star.Command{
+	Metadata: star.Metadata{
+		Short: "run the imports which are not pinned by a hash, and write their hashes into the module",
+	},
+	F: func(c star.Context) error {
+		ctx := c.Context
+		wbs, err := newSys(&c)
+		if err != nil {
+			return err
+		}
+		defer wbs.Close()
+		repo, err := openRepo()
+		if err != nil {
+			return err
+		}
+		res, err := wbs.Pin(ctx, repo)
+		if err != nil {
+			return err
+		}
+		for _, pi := range res.Pinned {
+			c.Printf("%v: pinned %s %q\n", pi.Import.Pos, pi.Pin.Param, pi.Pin.Value)
+		}
+		for _, p := range res.Files {
+			c.Printf("changed %s\n", p)
+		}
+		for _, pi := range res.Unresolved {
+			c.Printf("%v: could not find the call to change, set %s to %q\n", pi.Import.Pos, pi.Pin.Param, pi.Pin.Value)
+		}
+		for _, imp := range res.Skipped {
+			c.Printf("%v: %s imports cannot be pinned automatically\n", imp.Pos, imp.Op)
+		}
+		if err := c.StdOut.Flush(); err != nil {
+			return err
+		}
+		if n := len(res.Unresolved) + len(res.Skipped); n > 0 {
+			return fmt.Errorf("%d imports could not be pinned", n)
+		}
+		return nil
+	},
+}
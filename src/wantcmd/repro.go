@@ -0,0 +1,84 @@
+package wantcmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.brendoncarroll.net/star"
+
+	"wantbuild.io/want/src/want"
+)
+
+var verifyReproCmd = star.Command{
+	Metadata: star.Metadata{Short: "build twice without the cache and check that the outputs are the same"},
+	Pos:      []star.IParam{pathsParam},
+	Flags:    []star.IParam{parallelParam, workersParam, setParam},
+	F: func(c star.Context) error {
+		ctx := c.Context
+		q, err := mkBuildQuery(pathsParam.LoadAll(c)...)
+		if err != nil {
+			return err
+		}
+		cfg := want.ReproConfig{}
+		cfg.Parallel, _ = parallelParam.LoadOpt(c)
+		workers := workersParam.LoadAll(c)
+		if len(workers) > len(cfg.NumWorkers) {
+			return fmt.Errorf("--workers can be specified at most %d times", len(cfg.NumWorkers))
+		}
+		copy(cfg.NumWorkers[:], workers)
+
+		wbs, err := newSys(&c)
+		if err != nil {
+			return err
+		}
+		defer wbs.Close()
+		repo, err := openRepoWithParams(c)
+		if err != nil {
+			return err
+		}
+		res, err := wbs.VerifyRepro(ctx, repo, q, cfg)
+		if err != nil {
+			return err
+		}
+		divergent := res.Divergent()
+		for _, rt := range divergent {
+			targ := rt.Target
+			if targ.IsStatement {
+				c.Printf("%s[%v]:\n", targ.DefinedIn, targ.DefinedNum)
+			} else {
+				c.Printf("%s:\n", targ.DefinedIn)
+			}
+			for i, tres := range rt.Results {
+				c.Printf("  BUILD %d: %v %s\n", i, tres.ErrCode, tres.Root)
+			}
+			for _, p := range rt.Diff {
+				c.Printf("  DIFF: %q\n", p)
+			}
+			if rt.FirstJobs != nil {
+				for i, dj := range rt.FirstJobs {
+					c.Printf("  FIRST JOB %d: %v %v %v %s\n", i, dj.ID, dj.Task.Op, dj.Result.ErrCode, dj.Result.Root)
+				}
+			}
+		}
+		c.Printf("%d/%d targets reproduced\n", len(res.Targets)-len(divergent), len(res.Targets))
+		if err := c.StdOut.Flush(); err != nil {
+			return err
+		}
+		if len(divergent) > 0 {
+			return fmt.Errorf("%d targets did not reproduce", len(divergent))
+		}
+		return nil
+	},
+}
+
+var parallelParam = star.Param[bool]{
+	Name:    "parallel",
+	Default: star.Ptr("false"),
+	Parse:   strconv.ParseBool,
+}
+
+var workersParam = star.Param[int]{
+	Name:     "workers",
+	Repeated: true,
+	Parse:    strconv.Atoi,
+}
@@ -1,7 +1,7 @@
 package wantcmd
 
 import (
-	"fmt"
+	"encoding/json"
 	"maps"
 	"os"
 	"path/filepath"
@@ -10,6 +10,7 @@ import (
 
 	"go.brendoncarroll.net/star"
 
+	"wantbuild.io/want/src/internal/wantc"
 	"wantbuild.io/want/src/internal/wantrepo"
 	"wantbuild.io/want/src/want"
 )
@@ -25,16 +26,28 @@ var rootCmd = star.NewDir(star.Metadata{Short: "want build system"},
 
 		"import-repo": importRepoCmd,
 		"build":       buildCmd,
+		"test":        testCmd,
+		"check":       checkCmd,
+		"lsp":         lspCmd,
+		"lint":        lintCmd,
+		"fmt":         fmtCmd,
 		"ls":          lsCmd,
 		"cat":         catCmd,
 
+		"verify-repro": verifyReproCmd,
+		"vendor":       vendorCmd,
+		"pin":          pinCmd,
+
 		"blame": blameCmd,
 		"job":   jobCmd,
+		"debug": debugCmd,
 		"dash":  dashCmd,
 
 		"serve-http":  serveHttpCmd,
+		"export":      exportCmd,
 		"export-zip":  exportZipCmd,
 		"export-repo": exportRepoCmd,
+		"export-oci":  exportOCICmd,
 
 		"status": statusCmd,
 		"scrub":  *scrubCmd,
@@ -56,7 +69,7 @@ var initCmd = star.Command{
 
 var statusCmd = star.Command{
 	Metadata: star.Metadata{Short: "print status information"},
-	Flags:    []star.IParam{},
+	Flags:    []star.IParam{setParam},
 	F: func(c star.Context) error {
 		wd, err := os.Getwd()
 		if err != nil {
@@ -67,12 +80,32 @@ var statusCmd = star.Command{
 			return err
 		}
 		if yes {
-			repo, err := wantrepo.Open(repoRoot)
+			repo, err := openRepoWithParams(c)
 			if err != nil {
 				return err
 			}
 			c.Printf("ROOT: %s\n", repoRoot)
 			c.Printf("CONFIG: %v\n", repo.RawConfig())
+			params, err := wantc.ResolveParams(repo.Config(), repo.Params())
+			if err != nil {
+				return err
+			}
+			if len(params) > 0 {
+				c.Printf("PARAMS:\n")
+				for _, k := range slices.Sorted(maps.Keys(params)) {
+					data, err := json.Marshal(params[k])
+					if err != nil {
+						return err
+					}
+					c.Printf("  %s = %s\n", k, data)
+				}
+			}
+			if profiles := repo.Config().Profiles; len(profiles) > 0 {
+				c.Printf("PROFILES:\n")
+				for _, k := range slices.Sorted(maps.Keys(profiles)) {
+					c.Printf("  %s: %v\n", k, profiles[k].Query)
+				}
+			}
 		} else {
 			c.Printf("%s is not in a want project\n", wd)
 		}
@@ -118,18 +151,11 @@ func newSys(c *star.Context) (*want.System, error) {
 }
 
 func openRepo() (*wantrepo.Repo, error) {
-	wd, err := os.Getwd()
+	root, _, err := workingDir()
 	if err != nil {
 		return nil, err
 	}
-	yes, repoPath, err := wantrepo.FindRepo(wd)
-	if err != nil {
-		return nil, err
-	}
-	if !yes {
-		return nil, fmt.Errorf("%s is not in a want project", wd)
-	}
-	return wantrepo.Open(repoPath)
+	return wantrepo.Open(root)
 }
 
 func getStateDir() string {
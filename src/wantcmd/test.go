@@ -0,0 +1,85 @@
+package wantcmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.brendoncarroll.net/star"
+
+	"wantbuild.io/want/src/want"
+)
+
+var testCmd = star.Command{
+	Metadata: star.Metadata{Short: "run the test targets"},
+	Pos:      []star.IParam{pathsParam},
+	Flags:    []star.IParam{retriesParam, setParam},
+	F: func(c star.Context) error {
+		ctx := c.Context
+		q, err := mkBuildQuery(pathsParam.LoadAll(c)...)
+		if err != nil {
+			return err
+		}
+		cfg := want.TestConfig{}
+		cfg.Retries, _ = retriesParam.LoadOpt(c)
+
+		wbs, err := newSys(&c)
+		if err != nil {
+			return err
+		}
+		defer wbs.Close()
+		repo, err := openRepoWithParams(c)
+		if err != nil {
+			return err
+		}
+		results, err := wbs.Test(ctx, repo, q, cfg)
+		if err != nil {
+			return reportDiagnostic(&c, repo, err)
+		}
+		var passed, failed, flaky int
+		for _, tr := range results {
+			status := "PASS"
+			switch {
+			case tr.Flaky():
+				status = "FLAKY"
+				flaky++
+			case tr.Passed():
+				passed++
+			default:
+				status = "FAIL"
+				failed++
+			}
+			name := tr.Target.DefinedIn
+			if tr.Target.IsStatement {
+				name = fmt.Sprintf("%s[%d]", name, tr.Target.DefinedNum)
+			}
+			timing := tr.Elapsed.Round(time.Millisecond).String()
+			if tr.Cached {
+				timing = "(cached)"
+			}
+			if tr.Attempts > 1 {
+				timing += fmt.Sprintf(" attempts=%d", tr.Attempts)
+			}
+			c.Printf("%-6s %s %s\n", status, name, timing)
+			if !tr.Passed() {
+				msg := string(tr.Result.Root)
+				c.Printf("    %v %s\n", tr.Result.ErrCode, strings.ReplaceAll(strings.TrimSpace(msg), "\n", "\n    "))
+			}
+		}
+		c.Printf("%d passed, %d failed, %d flaky\n", passed, failed, flaky)
+		if err := c.StdOut.Flush(); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d tests failed", failed, len(results))
+		}
+		return nil
+	},
+}
+
+var retriesParam = star.Param[int]{
+	Name:    "retries",
+	Default: star.Ptr("0"),
+	Parse:   strconv.Atoi,
+}
@@ -0,0 +1,31 @@
+package wantcmd
+
+import (
+	"go.brendoncarroll.net/star"
+)
+
+var vendorCmd = star.Command{
+	Metadata: star.Metadata{
+		Short: "download the content imported by the module into the vendor directory",
+	},
+	Flags: []star.IParam{},
+	F: func(c star.Context) error {
+		ctx := c.Context
+		wbs, err := newSys(&c)
+		if err != nil {
+			return err
+		}
+		defer wbs.Close()
+		repo, err := openRepo()
+		if err != nil {
+			return err
+		}
+		res, err := wbs.Vendor(ctx, repo)
+		if err != nil {
+			return err
+		}
+		c.Printf("VENDOR: %s\n", res.Dir)
+		c.Printf("vendored %d imports, skipped %d\n", res.Vendored, res.Skipped)
+		return c.StdOut.Flush()
+	},
+}
@@ -53,7 +53,7 @@ func (t MicroVMTask) Validate() error {
 
 type SerialSpec struct {
 	WantHTTP *struct{} `json:"wanthttp,omitempty"`
-	Console  *struct{} `jaon:"console,omitempty"`
+	Console  *struct{} `json:"console,omitempty"`
 }
 
 type VirtioFSSpec struct {
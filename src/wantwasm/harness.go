@@ -11,6 +11,7 @@ import (
 	"os"
 	"path"
 	"runtime"
+	"strings"
 	"unsafe"
 
 	"blobcache.io/glfs"
@@ -124,7 +125,7 @@ func Main(fn func(jc wantjob.Ctx, src cadata.Getter, x []byte) ([]byte, error))
 func importPath(ctx context.Context, s cadata.Store, p string, finfo os.FileInfo) (*glfs.Ref, error) {
 	if finfo == nil {
 		var err error
-		finfo, err = os.Stat(p)
+		finfo, err = os.Lstat(p)
 		if err != nil {
 			return nil, err
 		}
@@ -146,7 +147,7 @@ func importTree(ctx context.Context, s cadata.Store, p string, finfo os.FileInfo
 	ys := make([]glfs.TreeEntry, len(xs))
 	for i := range xs {
 		p2 := path.Join(p, xs[i].Name())
-		finfo2, err := os.Stat(p2)
+		finfo2, err := os.Lstat(p2)
 		if err != nil {
 			return nil, err
 		}
@@ -154,7 +155,7 @@ func importTree(ctx context.Context, s cadata.Store, p string, finfo os.FileInfo
 		if err != nil {
 			return nil, err
 		}
-		ys[i] = glfs.TreeEntry{Name: xs[i].Name(), FileMode: finfo.Mode(), Ref: *ref}
+		ys[i] = glfs.TreeEntry{Name: xs[i].Name(), FileMode: finfo2.Mode(), Ref: *ref}
 	}
 	runtime.GC()
 	return glfs.PostTreeSlice(ctx, s, ys)
@@ -170,5 +171,9 @@ func importFile(ctx context.Context, s cadata.Store, p string) (*glfs.Ref, error
 }
 
 func importSymlink(ctx context.Context, s cadata.Store, p string) (*glfs.Ref, error) {
-	return nil, errors.New("symlinks unsupported")
+	target, err := os.Readlink(p)
+	if err != nil {
+		return nil, err
+	}
+	return glfs.PostBlob(ctx, s, strings.NewReader(target))
 }
@@ -90,6 +90,31 @@ func GetWASIp1Task(ctx context.Context, ag *glfs.Agent, s cadata.Getter, ref glf
 	}, nil
 }
 
+// WASIp1ComponentTask runs a component built from a wasip1 core module with the wasi_snapshot_preview1 adapter.
+// It is configured the same way as a WASIp1Task, and has the same filesystem contract.
+type WASIp1ComponentTask struct {
+	Memory  uint64
+	Program []byte
+	Input   glfs.Ref
+	Args    []string
+	Env     map[string]string
+}
+
+// PostWASIp1ComponentTask converts a Task to a glfs Tree stored in s.
+// The tree has the same layout as a WASIp1Task.
+func PostWASIp1ComponentTask(ctx context.Context, ag *glfs.Agent, s cadata.PostExister, task WASIp1ComponentTask) (*glfs.Ref, error) {
+	return PostWASIp1Task(ctx, ag, s, WASIp1Task(task))
+}
+
+// GetWASIp1ComponentTask parses a task from a glfs Tree.
+func GetWASIp1ComponentTask(ctx context.Context, ag *glfs.Agent, s cadata.Getter, ref glfs.Ref) (*WASIp1ComponentTask, error) {
+	task, err := GetWASIp1Task(ctx, ag, s, ref)
+	if err != nil {
+		return nil, err
+	}
+	return (*WASIp1ComponentTask)(task), nil
+}
+
 // NativeGLFSTask is a Git-Like Filesystem Task
 type NativeGLFSTask struct {
 	Program []byte